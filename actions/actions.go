@@ -0,0 +1,78 @@
+// Package actions is the catalog of typed AMI actions. Every action is a
+// struct named after the AMI action with fields tagged as headers, required
+// headers are validated by ToMessage:
+//
+//	resp, err := actions.Send(ctx, cl, actions.QueueAdd{Queue: "sales", Interface: "PJSIP/100"})
+//
+// Responses and list events decode into the response types of the package:
+//
+//	members, err := actions.List[actions.QueueMember](ctx, cl, actions.QueueStatus{Queue: "sales"}, "QueueMember")
+package actions
+
+//go:generate go run gen.go
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/staskobzar/goami2"
+)
+
+// Action is AMI action converted to message
+type Action interface {
+	ToMessage() (*goami2.Message, error)
+}
+
+// Send sends action and returns the response. Returns error wrapping
+// goami2.ErrAMI when action is rejected.
+func Send(ctx context.Context, cl *goami2.Client, a Action) (*goami2.Message, error) {
+	action, err := a.ToMessage()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cl.SendAction(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return resp, fmt.Errorf("%w: %s failed: %s", goami2.ErrAMI, action.Field("Action"), resp.Field("Message"))
+	}
+	return resp, nil
+}
+
+// Query sends action and decodes the response into value of type T
+func Query[T any](ctx context.Context, cl *goami2.Client, a Action) (T, error) {
+	var v T
+	resp, err := Send(ctx, cl, a)
+	if err != nil {
+		return v, err
+	}
+	err = resp.Decode(&v)
+	return v, err
+}
+
+// List sends list action and decodes the list events with the event name
+// into values of type T. Other events of the list are skipped.
+func List[T any](ctx context.Context, cl *goami2.Client, a Action, event string) ([]T, error) {
+	action, err := a.ToMessage()
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := cl.SendActionList(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	var list []T
+	for _, msg := range msgs {
+		if !strings.EqualFold(msg.Field("Event"), event) {
+			continue
+		}
+		v, err := goami2.Decode[T](msg)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
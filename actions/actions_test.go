@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T) (*goami2test.Server, *goami2.Client) {
+	ami := goami2test.NewServer()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		cl.Close()
+		_ = ami.Close()
+	})
+	return ami, cl
+}
+
+func TestSend(t *testing.T) {
+	ami, cl := newClient(t)
+	ami.Handle("QueueAdd", func(action *goami2.Message) []*goami2.Message {
+		if action.Field("Queue") != "sales" {
+			return []*goami2.Message{goami2test.Error("Unable to add interface: No such queue")}
+		}
+		return []*goami2.Message{goami2test.Success("Message", "Added interface to queue")}
+	})
+	ctx := context.Background()
+
+	resp, err := Send(ctx, cl, QueueAdd{Queue: "sales", Interface: "PJSIP/100"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Added interface to queue", resp.Field("Message"))
+
+	resp, err = Send(ctx, cl, QueueAdd{Queue: "support", Interface: "PJSIP/100"})
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+	assert.EqualError(t, err, "goami2: AMI proto: QueueAdd failed: Unable to add interface: No such queue")
+	assert.NotNil(t, resp)
+
+	_, err = Send(ctx, cl, QueueAdd{Queue: "sales"})
+	assert.ErrorContains(t, err, "missing required field")
+}
+
+func TestQuery(t *testing.T) {
+	ami, cl := newClient(t)
+	ami.Handle("CoreStatus", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{goami2test.Success("CoreStartupDate", "2024-01-15",
+			"CoreStartupTime", "10:00:00", "CoreCurrentCalls", "3")}
+	})
+
+	status, err := Query[CoreStatusResponse](context.Background(), cl, CoreStatus{})
+	assert.Nil(t, err)
+	assert.Equal(t, CoreStatusResponse{CoreStartupDate: "2024-01-15", CoreStartupTime: "10:00:00",
+		CoreCurrentCalls: 3}, status)
+}
+
+func TestList(t *testing.T) {
+	ami, cl := newClient(t)
+	ami.Handle("QueueStatus", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{
+			goami2test.Success("EventList", "start", "Message", "Queue status will follow"),
+			goami2test.Event("QueueParams", "Queue", "sales", "Calls", "1"),
+			goami2test.Event("QueueMember", "Queue", "sales", "Name", "Alice", "Paused", "1", "Penalty", "2"),
+			goami2test.Event("QueueMember", "Queue", "sales", "Name", "Bob", "Paused", "0"),
+			goami2test.Event("QueueStatusComplete", "EventList", "Complete", "ListItems", "3"),
+		}
+	})
+
+	members, err := List[QueueMember](context.Background(), cl, QueueStatus{Queue: "sales"}, "QueueMember")
+	assert.Nil(t, err)
+	assert.Equal(t, []QueueMember{
+		{Queue: "sales", Name: "Alice", Paused: true, Penalty: 2},
+		{Queue: "sales", Name: "Bob"},
+	}, members)
+}
@@ -0,0 +1,316 @@
+package actions
+
+import "time"
+
+// System and core actions
+
+// Ping action
+type Ping struct{}
+
+// CoreStatus action, response decodes into CoreStatusResponse
+type CoreStatus struct{}
+
+// CoreSettings action, response decodes into CoreSettingsResponse
+type CoreSettings struct{}
+
+// CoreShowChannels action lists CoreShowChannel events
+type CoreShowChannels struct{}
+
+// ListCommands action lists actions permitted for the manager user
+type ListCommands struct{}
+
+// Command action runs CLI command
+type Command struct {
+	Command string `ami:"Command,required"`
+}
+
+// Reload action reloads the module or all modules when empty
+type Reload struct {
+	Module string `ami:"Module,omitempty"`
+}
+
+// ModuleLoad action, LoadType is "load", "unload" or "reload"
+type ModuleLoad struct {
+	Module   string `ami:"Module,omitempty"`
+	LoadType string `ami:"LoadType,required"`
+}
+
+// ModuleCheck action
+type ModuleCheck struct {
+	Module string `ami:"Module,required"`
+}
+
+// Events action sets the session event mask, like "on", "off" or "call,agent"
+type Events struct {
+	EventMask string `ami:"EventMask,required"`
+}
+
+// Logoff action
+type Logoff struct{}
+
+// Database actions
+
+// DBGet action responds with DBGetResponse event
+type DBGet struct {
+	Family string `ami:"Family,required"`
+	Key    string `ami:"Key,required"`
+}
+
+// DBPut action
+type DBPut struct {
+	Family string `ami:"Family,required"`
+	Key    string `ami:"Key,required"`
+	Val    string `ami:"Val"`
+}
+
+// DBDel action
+type DBDel struct {
+	Family string `ami:"Family,required"`
+	Key    string `ami:"Key,required"`
+}
+
+// DBDelTree action deletes the family or the key tree of the family
+type DBDelTree struct {
+	Family string `ami:"Family,required"`
+	Key    string `ami:"Key,omitempty"`
+}
+
+// Channel actions
+
+// Status action lists Status events of the channel or all channels
+type Status struct {
+	Channel   string `ami:"Channel,omitempty"`
+	Variables string `ami:"Variables,omitempty"`
+}
+
+// Getvar action of the channel or global variable when channel is empty,
+// response decodes into GetvarResponse
+type Getvar struct {
+	Channel  string `ami:"Channel,omitempty"`
+	Variable string `ami:"Variable,required"`
+}
+
+// Setvar action of the channel or global variable when channel is empty
+type Setvar struct {
+	Channel  string `ami:"Channel,omitempty"`
+	Variable string `ami:"Variable,required"`
+	Value    string `ami:"Value"`
+}
+
+// Hangup action
+type Hangup struct {
+	Channel string `ami:"Channel,required"`
+	Cause   int    `ami:"Cause,omitempty"`
+}
+
+// Redirect action
+type Redirect struct {
+	Channel      string `ami:"Channel,required"`
+	ExtraChannel string `ami:"ExtraChannel,omitempty"`
+	Exten        string `ami:"Exten,required"`
+	Context      string `ami:"Context,required"`
+	Priority     int    `ami:"Priority,required"`
+}
+
+// BlindTransfer action
+type BlindTransfer struct {
+	Channel string `ami:"Channel,required"`
+	Exten   string `ami:"Exten,required"`
+	Context string `ami:"Context,omitempty"`
+}
+
+// Atxfer action
+type Atxfer struct {
+	Channel string `ami:"Channel,required"`
+	Exten   string `ami:"Exten,required"`
+	Context string `ami:"Context,omitempty"`
+}
+
+// AbsoluteTimeout action hangs up the channel after timeout
+type AbsoluteTimeout struct {
+	Channel string        `ami:"Channel,required"`
+	Timeout time.Duration `ami:"Timeout,required"`
+}
+
+// Park action
+type Park struct {
+	Channel        string        `ami:"Channel,required"`
+	TimeoutChannel string        `ami:"TimeoutChannel,omitempty"`
+	Timeout        time.Duration `ami:"Timeout,omitempty,ms"`
+	Parkinglot     string        `ami:"Parkinglot,omitempty"`
+}
+
+// PlayDTMF action
+type PlayDTMF struct {
+	Channel  string        `ami:"Channel,required"`
+	Digit    string        `ami:"Digit,required"`
+	Duration time.Duration `ami:"Duration,omitempty,ms"`
+}
+
+// MuteAudio action, Direction is "in", "out" or "all" and State is "on" or "off"
+type MuteAudio struct {
+	Channel   string `ami:"Channel,required"`
+	Direction string `ami:"Direction,required"`
+	State     string `ami:"State,required"`
+}
+
+// MixMonitor action
+type MixMonitor struct {
+	Channel string `ami:"Channel,required"`
+	File    string `ami:"File,omitempty"`
+	Options string `ami:"Options,omitempty"`
+	Command string `ami:"Command,omitempty"`
+}
+
+// StopMixMonitor action
+type StopMixMonitor struct {
+	Channel      string `ami:"Channel,required"`
+	MixMonitorID string `ami:"MixMonitorID,omitempty"`
+}
+
+// BridgeList action lists BridgeListItem events
+type BridgeList struct {
+	BridgeType string `ami:"BridgeType,omitempty"`
+}
+
+// Queue actions
+
+// QueueAdd action adds member interface to the queue
+type QueueAdd struct {
+	Queue          string `ami:"Queue,required"`
+	Interface      string `ami:"Interface,required"`
+	Penalty        int    `ami:"Penalty,omitempty"`
+	Paused         bool   `ami:"Paused,omitempty"`
+	MemberName     string `ami:"MemberName,omitempty"`
+	StateInterface string `ami:"StateInterface,omitempty"`
+}
+
+// QueueRemove action
+type QueueRemove struct {
+	Queue     string `ami:"Queue,required"`
+	Interface string `ami:"Interface,required"`
+}
+
+// QueuePause action pauses member in the queue or all queues when empty
+type QueuePause struct {
+	Interface string `ami:"Interface,required"`
+	Paused    bool   `ami:"Paused"`
+	Queue     string `ami:"Queue,omitempty"`
+	Reason    string `ami:"Reason,omitempty"`
+}
+
+// QueuePenalty action
+type QueuePenalty struct {
+	Interface string `ami:"Interface,required"`
+	Penalty   int    `ami:"Penalty"`
+	Queue     string `ami:"Queue,omitempty"`
+}
+
+// QueueStatus action lists QueueParams, QueueMember and QueueEntry events
+type QueueStatus struct {
+	Queue  string `ami:"Queue,omitempty"`
+	Member string `ami:"Member,omitempty"`
+}
+
+// QueueSummary action lists QueueSummary events
+type QueueSummary struct {
+	Queue string `ami:"Queue,omitempty"`
+}
+
+// PJSIP actions
+
+// PJSIPShowEndpoints action lists EndpointList events
+type PJSIPShowEndpoints struct{}
+
+// PJSIPShowEndpoint action lists endpoint details events
+type PJSIPShowEndpoint struct {
+	Endpoint string `ami:"Endpoint,required"`
+}
+
+// PJSIPShowContacts action lists ContactList events
+type PJSIPShowContacts struct{}
+
+// PJSIPShowRegistrationsOutbound action lists OutboundRegistrationDetail events
+type PJSIPShowRegistrationsOutbound struct{}
+
+// PJSIPQualify action
+type PJSIPQualify struct {
+	Endpoint string `ami:"Endpoint,required"`
+}
+
+// PJSIPRegister action
+type PJSIPRegister struct {
+	Registration string `ami:"Registration,required"`
+}
+
+// PJSIPUnregister action
+type PJSIPUnregister struct {
+	Registration string `ami:"Registration,required"`
+}
+
+// PJSIPNotify action sends NOTIFY to the endpoint or URI
+type PJSIPNotify struct {
+	Endpoint string            `ami:"Endpoint,omitempty"`
+	URI      string            `ami:"URI,omitempty"`
+	Variable map[string]string `ami:"Variable,omitempty"`
+}
+
+// Dialplan, state and conference actions
+
+// ExtensionState action, response decodes into ExtensionStateResponse
+type ExtensionState struct {
+	Exten   string `ami:"Exten,required"`
+	Context string `ami:"Context,required"`
+}
+
+// ExtensionStateList action lists ExtensionStatus events
+type ExtensionStateList struct{}
+
+// DeviceStateList action lists DeviceStateChange events
+type DeviceStateList struct{}
+
+// ShowDialPlan action
+type ShowDialPlan struct {
+	Extension string `ami:"Extension,omitempty"`
+	Context   string `ami:"Context,omitempty"`
+}
+
+// MailboxCount action, response decodes into MailboxCountResponse
+type MailboxCount struct {
+	Mailbox string `ami:"Mailbox,required"`
+}
+
+// MailboxStatus action
+type MailboxStatus struct {
+	Mailbox string `ami:"Mailbox,required"`
+}
+
+// GetConfig action
+type GetConfig struct {
+	Filename string `ami:"Filename,required"`
+	Category string `ami:"Category,omitempty"`
+	Filter   string `ami:"Filter,omitempty"`
+}
+
+// ConfbridgeList action lists ConfbridgeList events
+type ConfbridgeList struct {
+	Conference string `ami:"Conference,required"`
+}
+
+// ConfbridgeKick action
+type ConfbridgeKick struct {
+	Conference string `ami:"Conference,required"`
+	Channel    string `ami:"Channel,required"`
+}
+
+// ConfbridgeMute action
+type ConfbridgeMute struct {
+	Conference string `ami:"Conference,required"`
+	Channel    string `ami:"Channel,required"`
+}
+
+// ConfbridgeUnmute action
+type ConfbridgeUnmute struct {
+	Conference string `ami:"Conference,required"`
+	Channel    string `ami:"Channel,required"`
+}
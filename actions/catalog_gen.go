@@ -0,0 +1,275 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package actions
+
+import "github.com/staskobzar/goami2"
+
+// ToMessage validates required headers and creates "Ping" action
+func (a Ping) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "CoreStatus" action
+func (a CoreStatus) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "CoreSettings" action
+func (a CoreSettings) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "CoreShowChannels" action
+func (a CoreShowChannels) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ListCommands" action
+func (a ListCommands) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Command" action
+func (a Command) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Reload" action
+func (a Reload) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ModuleLoad" action
+func (a ModuleLoad) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ModuleCheck" action
+func (a ModuleCheck) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Events" action
+func (a Events) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Logoff" action
+func (a Logoff) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "DBGet" action
+func (a DBGet) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "DBPut" action
+func (a DBPut) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "DBDel" action
+func (a DBDel) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "DBDelTree" action
+func (a DBDelTree) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Status" action
+func (a Status) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Getvar" action
+func (a Getvar) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Setvar" action
+func (a Setvar) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Hangup" action
+func (a Hangup) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Redirect" action
+func (a Redirect) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "BlindTransfer" action
+func (a BlindTransfer) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Atxfer" action
+func (a Atxfer) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "AbsoluteTimeout" action
+func (a AbsoluteTimeout) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "Park" action
+func (a Park) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PlayDTMF" action
+func (a PlayDTMF) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "MuteAudio" action
+func (a MuteAudio) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "MixMonitor" action
+func (a MixMonitor) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "StopMixMonitor" action
+func (a StopMixMonitor) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "BridgeList" action
+func (a BridgeList) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueueAdd" action
+func (a QueueAdd) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueueRemove" action
+func (a QueueRemove) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueuePause" action
+func (a QueuePause) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueuePenalty" action
+func (a QueuePenalty) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueueStatus" action
+func (a QueueStatus) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "QueueSummary" action
+func (a QueueSummary) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPShowEndpoints" action
+func (a PJSIPShowEndpoints) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPShowEndpoint" action
+func (a PJSIPShowEndpoint) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPShowContacts" action
+func (a PJSIPShowContacts) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPShowRegistrationsOutbound" action
+func (a PJSIPShowRegistrationsOutbound) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPQualify" action
+func (a PJSIPQualify) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPRegister" action
+func (a PJSIPRegister) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPUnregister" action
+func (a PJSIPUnregister) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "PJSIPNotify" action
+func (a PJSIPNotify) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ExtensionState" action
+func (a ExtensionState) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ExtensionStateList" action
+func (a ExtensionStateList) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "DeviceStateList" action
+func (a DeviceStateList) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ShowDialPlan" action
+func (a ShowDialPlan) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "MailboxCount" action
+func (a MailboxCount) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "MailboxStatus" action
+func (a MailboxStatus) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "GetConfig" action
+func (a GetConfig) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ConfbridgeList" action
+func (a ConfbridgeList) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ConfbridgeKick" action
+func (a ConfbridgeKick) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ConfbridgeMute" action
+func (a ConfbridgeMute) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
+
+// ToMessage validates required headers and creates "ConfbridgeUnmute" action
+func (a ConfbridgeUnmute) ToMessage() (*goami2.Message, error) {
+	return goami2.NewActionFromStruct(a)
+}
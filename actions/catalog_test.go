@@ -0,0 +1,82 @@
+package actions
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogToMessage(t *testing.T) {
+	tests := map[string]struct {
+		action Action
+		want   string
+	}{
+		"Ping": {Ping{}, "Action: Ping\r\n\r\n"},
+		"QueueAdd": {QueueAdd{Queue: "sales", Interface: "PJSIP/100", Penalty: 2},
+			"Action: QueueAdd\r\nQueue: sales\r\nInterface: PJSIP/100\r\nPenalty: 2\r\n\r\n"},
+		"QueuePause": {QueuePause{Interface: "PJSIP/100"},
+			"Action: QueuePause\r\nInterface: PJSIP/100\r\nPaused: false\r\n\r\n"},
+		"PJSIPShowEndpoints": {PJSIPShowEndpoints{}, "Action: PJSIPShowEndpoints\r\n\r\n"},
+		"DBGet": {DBGet{Family: "cidname", Key: "100"},
+			"Action: DBGet\r\nFamily: cidname\r\nKey: 100\r\n\r\n"},
+		"ModuleLoad": {ModuleLoad{Module: "res_pjsip.so", LoadType: "reload"},
+			"Action: ModuleLoad\r\nModule: res_pjsip.so\r\nLoadType: reload\r\n\r\n"},
+		"Park": {Park{Channel: "PJSIP/100-01", Timeout: 45 * time.Second},
+			"Action: Park\r\nChannel: PJSIP/100-01\r\nTimeout: 45000\r\n\r\n"},
+		"PJSIPNotify": {PJSIPNotify{Endpoint: "100", Variable: map[string]string{"Event": "check-sync"}},
+			"Action: PJSIPNotify\r\nEndpoint: 100\r\nVariable: Event=check-sync\r\n\r\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := tc.action.ToMessage()
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, msg.String())
+		})
+	}
+}
+
+func TestCatalogRequiredFields(t *testing.T) {
+	tests := map[string]Action{
+		`QueueAdd: missing required field "Interface"`:  QueueAdd{Queue: "sales"},
+		`DBGet: missing required field "Family"`:        DBGet{Key: "100"},
+		`Redirect: missing required field "Priority"`:   Redirect{Channel: "PJSIP/100-01", Exten: "200", Context: "default"},
+		`ModuleLoad: missing required field "LoadType"`: ModuleLoad{Module: "res_pjsip.so"},
+	}
+	for want, action := range tests {
+		t.Run(want, func(t *testing.T) {
+			_, err := action.ToMessage()
+			assert.ErrorIs(t, err, goami2.ErrAMI)
+			assert.ErrorContains(t, err, want)
+		})
+	}
+
+	_, err := Command{Command: "core show version\r\nAction: Logoff"}.ToMessage()
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+}
+
+// catalog_gen.go must be regenerated with "go generate" when actions are added
+func TestCatalogGenerated(t *testing.T) {
+	fset := token.NewFileSet()
+	catalog, err := parser.ParseFile(fset, "catalog.go", nil, 0)
+	assert.Nil(t, err)
+	generated, err := parser.ParseFile(fset, "catalog_gen.go", nil, 0)
+	assert.Nil(t, err)
+
+	methods := make(map[string]bool)
+	for _, decl := range generated.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv != nil {
+			methods[fd.Recv.List[0].Type.(*ast.Ident).Name] = true
+		}
+	}
+	ast.Inspect(catalog, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok {
+			assert.True(t, methods[ts.Name.Name], "missing %s.ToMessage", ts.Name.Name)
+		}
+		return true
+	})
+}
@@ -0,0 +1,49 @@
+//go:build ignore
+
+// gen creates ToMessage methods for the action structs of catalog.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+)
+
+func main() {
+	file, err := parser.ParseFile(token.NewFileSet(), "catalog.go", nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\npackage actions\n\n")
+	buf.WriteString("import \"github.com/staskobzar/goami2\"\n")
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); !ok {
+				continue
+			}
+			fmt.Fprintf(&buf, "\n// ToMessage validates required headers and creates %q action\n", ts.Name.Name)
+			fmt.Fprintf(&buf, "func (a %s) ToMessage() (*goami2.Message, error) {\n", ts.Name.Name)
+			buf.WriteString("\treturn goami2.NewActionFromStruct(a)\n}\n")
+		}
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("catalog_gen.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// CoreStatusResponse is the response of CoreStatus
+type CoreStatusResponse struct {
+	CoreStartupDate  string
+	CoreStartupTime  string
+	CoreReloadDate   string
+	CoreReloadTime   string
+	CoreCurrentCalls int
+}
+
+// CoreSettingsResponse is the response of CoreSettings
+type CoreSettingsResponse struct {
+	AMIversion         string
+	AsteriskVersion    string
+	SystemName         string
+	CoreMaxCalls       int
+	CoreMaxLoadAvg     float64
+	CoreRunUser        string
+	CoreRunGroup       string
+	CoreMaxFilehandles int
+}
+
+// GetvarResponse is the response of Getvar
+type GetvarResponse struct {
+	Variable string
+	Value    string
+}
+
+// ExtensionStateResponse is the response of ExtensionState
+type ExtensionStateResponse struct {
+	Exten      string
+	Context    string
+	Hint       string
+	Status     int
+	StatusText string
+}
+
+// MailboxCountResponse is the response of MailboxCount
+type MailboxCountResponse struct {
+	Mailbox     string
+	UrgMessages int
+	NewMessages int
+	OldMessages int
+}
+
+// DBGetResponse is the event listed by DBGet
+type DBGetResponse struct {
+	Family string
+	Key    string
+	Val    string
+}
+
+// CoreShowChannel is the event listed by CoreShowChannels
+type CoreShowChannel struct {
+	goami2.ChannelSnapshot
+	Application     string
+	ApplicationData string
+	Duration        time.Duration
+	BridgeId        string
+}
+
+// QueueParams is the queue event listed by QueueStatus
+type QueueParams struct {
+	Queue            string
+	Max              int
+	Strategy         string
+	Calls            int
+	Holdtime         int
+	TalkTime         int
+	Completed        int
+	Abandoned        int
+	ServiceLevel     int
+	ServicelevelPerf float64
+	Weight           int
+}
+
+// QueueMember is the member event listed by QueueStatus
+type QueueMember struct {
+	Queue          string
+	Name           string
+	Location       string
+	StateInterface string
+	Membership     string
+	Penalty        int
+	CallsTaken     int
+	LastCall       int64
+	LastPause      int64
+	InCall         bool
+	Status         int
+	Paused         bool
+	PausedReason   string
+}
+
+// QueueEntry is the caller event listed by QueueStatus
+type QueueEntry struct {
+	goami2.ChannelSnapshot
+	Queue    string
+	Position int
+	Wait     int
+}
+
+// EndpointList is the event listed by PJSIPShowEndpoints
+type EndpointList struct {
+	ObjectType     string
+	ObjectName     string
+	Transport      string
+	Aor            string
+	Auths          string
+	OutboundAuths  string
+	Contacts       string
+	DeviceState    string
+	ActiveChannels string
+}
+
+// ContactList is the event listed by PJSIPShowContacts
+type ContactList struct {
+	ObjectType       string
+	ObjectName       string
+	Endpoint         string
+	Aor              string
+	URI              string
+	UserAgent        string
+	Status           string
+	RoundtripUsec    int
+	QualifyFrequency int
+}
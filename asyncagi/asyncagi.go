@@ -0,0 +1,255 @@
+// Package asyncagi controls dialplan execution of the channels running
+// AsyncAGI application, "AGI(agi:async)", with AGI commands sent over AMI.
+// Sessions are created from AsyncAGIStart events, commands are sent with AGI
+// action and their results are read from AsyncAGIExec events.
+package asyncagi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/staskobzar/goami2"
+)
+
+// events consumed by the server. Asterisk 11 and older send "AsyncAGI"
+// event with "SubEvent" header.
+var agiEvents = []string{"AsyncAGIStart", "AsyncAGIExec", "AsyncAGIEnd", "AsyncAGI"}
+
+// ErrEnded is returned by Exec when channel leaves AsyncAGI application
+var ErrEnded = fmt.Errorf("%w: asyncagi session ended", goami2.ErrEOF)
+
+// ErrNotAttached is returned by Exec when server is not attached to client
+var ErrNotAttached = errors.New("asyncagi: server is not attached to client")
+
+// Result of AGI command, like "200 result=1 (timeout) endpos=1234"
+type Result struct {
+	Code   int               // response code, 200 on success
+	Result int               // value of "result="
+	Data   string            // text in parenthesis
+	Extra  map[string]string // other key=value pairs, like "endpos"
+	Raw    string            // result line as received
+}
+
+// Server keeps AsyncAGI sessions of the channels. Server is safe for
+// concurrent use.
+type Server struct {
+	mu        sync.Mutex
+	client    *goami2.Client
+	sessions  map[string]*Session // by channel name
+	onSession []func(*Session)
+	lastID    atomic.Uint64
+}
+
+// Session is the AsyncAGI session of the channel
+type Session struct {
+	Channel string
+	Env     map[string]string // AGI environment, like "agi_callerid" or "agi_extension"
+
+	srv     *Server
+	mu      sync.Mutex
+	pending map[string]chan Result // by CommandID
+	done    chan struct{}
+}
+
+// New creates server without sessions
+func New() *Server {
+	return &Server{sessions: make(map[string]*Session)}
+}
+
+// Attach registers server as AMI client event handler. Client is used to
+// send AGI commands. Handler can be removed with Client.RemoveHandler.
+func (s *Server) Attach(cl *goami2.Client) goami2.HandlerID {
+	s.mu.Lock()
+	s.client = cl
+	s.mu.Unlock()
+	return cl.OnEvents(agiEvents, s.Handle)
+}
+
+// OnSession registers handler called in new goroutine for every session
+// started by AsyncAGIStart event. Handler can run the session with Exec
+// until it ends.
+func (s *Server) OnSession(fn func(*Session)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSession = append(s.onSession, fn)
+}
+
+// Session returns session of the channel
+func (s *Server) Session(channel string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[channel]
+	return sess, ok
+}
+
+// Handle updates sessions with AsyncAGIStart, AsyncAGIExec and AsyncAGIEnd
+// events. Other events are ignored.
+func (s *Server) Handle(msg *goami2.Message) {
+	name := msg.Field("Event")
+	if strings.EqualFold(name, "AsyncAGI") {
+		name = "AsyncAGI" + msg.Field("SubEvent")
+	}
+	channel := msg.Field("Channel")
+	if channel == "" {
+		return
+	}
+	switch strings.ToLower(name) {
+	case "asyncagistart":
+		s.start(channel, msg)
+	case "asyncagiexec":
+		if sess, ok := s.Session(channel); ok {
+			sess.result(msg.Field("CommandID"), ParseResult(unescape(msg.Field("Result"))))
+		}
+	case "asyncagiend":
+		s.end(channel)
+	}
+}
+
+func (s *Server) start(channel string, msg *goami2.Message) {
+	sess := &Session{
+		Channel: channel,
+		Env:     parseEnv(unescape(msg.Field("Env"))),
+		srv:     s,
+		pending: make(map[string]chan Result),
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	if prev, ok := s.sessions[channel]; ok {
+		close(prev.done)
+	}
+	s.sessions[channel] = sess
+	handlers := s.onSession
+	s.mu.Unlock()
+
+	for _, fn := range handlers {
+		go fn(sess)
+	}
+}
+
+func (s *Server) end(channel string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[channel]; ok {
+		close(sess.done)
+		delete(s.sessions, channel)
+	}
+}
+
+// Done returns a channel that is closed when session ends
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Exec sends AGI command, like "STREAM FILE hello-world #", and waits for its
+// result. Returns result with error wrapping goami2.ErrAMI when command
+// fails with not 200 code, ErrEnded when session ends before the result
+// and context error when context is done.
+func (s *Session) Exec(ctx context.Context, command string) (Result, error) {
+	s.srv.mu.Lock()
+	cl := s.srv.client
+	s.srv.mu.Unlock()
+	if cl == nil {
+		return Result{}, ErrNotAttached
+	}
+
+	id := "agi-" + strconv.FormatUint(s.srv.lastID.Add(1), 10)
+	ch := make(chan Result, 1)
+	s.mu.Lock()
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	action := goami2.NewAction("AGI").
+		Set("Channel", s.Channel).
+		Set("Command", command).
+		Set("CommandID", id)
+	resp, err := cl.SendAction(ctx, action)
+	if err != nil {
+		return Result{}, err
+	}
+	if !resp.IsSuccess() {
+		return Result{}, fmt.Errorf("%w: agi command %q rejected: %s", goami2.ErrAMI, command,
+			resp.Field("Message"))
+	}
+
+	select {
+	case res := <-ch:
+		if res.Code != 200 {
+			return res, fmt.Errorf("%w: agi command %q failed: %s", goami2.ErrAMI, command, res.Raw)
+		}
+		return res, nil
+	case <-s.done:
+		return Result{}, ErrEnded
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+func (s *Session) result(id string, res Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch, ok := s.pending[id]; ok {
+		ch <- res
+		delete(s.pending, id)
+	}
+}
+
+// ParseResult parses AGI result line like "200 result=1 (timeout) endpos=1234"
+func ParseResult(line string) Result {
+	line = strings.TrimSpace(line)
+	res := Result{Raw: line}
+	code, rest, _ := strings.Cut(line, " ")
+	res.Code, _ = strconv.Atoi(code)
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '(' {
+			data, tail, _ := strings.Cut(rest[1:], ")")
+			res.Data, rest = data, tail
+			continue
+		}
+		var field string
+		field, rest, _ = strings.Cut(rest, " ")
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		if key == "result" {
+			res.Result, _ = strconv.Atoi(value)
+			continue
+		}
+		if res.Extra == nil {
+			res.Extra = make(map[string]string)
+		}
+		res.Extra[key] = value
+	}
+	return res
+}
+
+// parseEnv parses AGI environment lines like "agi_channel: PJSIP/100-01"
+func parseEnv(env string) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(env, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok {
+			vars[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return vars
+}
+
+// unescape decodes URL encoded header value of AsyncAGI events
+func unescape(value string) string {
+	if s, err := url.QueryUnescape(value); err == nil {
+		return s
+	}
+	return value
+}
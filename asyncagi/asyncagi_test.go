@@ -0,0 +1,147 @@
+package asyncagi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+func TestParseResult(t *testing.T) {
+	tests := map[string]struct {
+		line string
+		want Result
+	}{
+		"result": {"200 result=1\n", Result{Code: 200, Result: 1, Raw: "200 result=1"}},
+		"data": {"200 result=0 (timeout) endpos=12000", Result{Code: 200, Data: "timeout",
+			Extra: map[string]string{"endpos": "12000"}, Raw: "200 result=0 (timeout) endpos=12000"}},
+		"negative": {"200 result=-1", Result{Code: 200, Result: -1, Raw: "200 result=-1"}},
+		"invalid": {"510 Invalid or unknown command", Result{Code: 510,
+			Raw: "510 Invalid or unknown command"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseResult(tc.line))
+		})
+	}
+}
+
+func TestServerSessions(t *testing.T) {
+	srv := New()
+	var started []*Session
+	srv.OnSession(func(s *Session) { started = append(started, s) })
+
+	srv.Handle(event(t, "Event: AsyncAGIStart\r\nChannel: PJSIP/100-01\r\n"+
+		"Env: agi_request%3A%20async%0Aagi_channel%3A%20PJSIP%2F100-01%0Aagi_extension%3A%20600%0A%0A\r\n"))
+	sess, ok := srv.Session("PJSIP/100-01")
+	assert.True(t, ok)
+	assert.Equal(t, "async", sess.Env["agi_request"])
+	assert.Equal(t, "600", sess.Env["agi_extension"])
+
+	_, err := sess.Exec(context.Background(), "ANSWER")
+	assert.ErrorIs(t, err, ErrNotAttached)
+
+	// Asterisk 11 event
+	srv.Handle(event(t, "Event: AsyncAGI\r\nSubEvent: End\r\nChannel: PJSIP/100-01\r\n"))
+	_, ok = srv.Session("PJSIP/100-01")
+	assert.False(t, ok)
+	select {
+	case <-sess.Done():
+	default:
+		t.Fatal("session is not done")
+	}
+	srv.Handle(event(t, "Event: AsyncAGIEnd\r\nChannel: PJSIP/100-01\r\n"))
+	srv.Handle(event(t, "Event: AsyncAGIStart\r\n"))
+}
+
+func TestSessionExec(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("AGI", func(action *goami2.Message) []*goami2.Message {
+		if action.Field("Channel") != "PJSIP/100-01" {
+			return []*goami2.Message{goami2test.Error("No such channel")}
+		}
+		result := "200%20result%3D0%20(timeout)%0A"
+		switch action.Field("Command") {
+		case "FOO":
+			result = "510%20Invalid%20or%20unknown%20command%0A"
+		case "WAIT":
+			return []*goami2.Message{goami2test.Success()}
+		}
+		go ami.Emit(goami2test.Event("AsyncAGIExec", "Channel", action.Field("Channel"),
+			"CommandID", action.Field("CommandID"), "Result", result))
+		return []*goami2.Message{goami2test.Success("Message", "Added AGI command to queue")}
+	})
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	srv := New()
+	srv.Attach(cl)
+	sessions := make(chan *Session, 1)
+	srv.OnSession(func(s *Session) { sessions <- s })
+	ami.Emit(goami2test.Event("AsyncAGIStart", "Channel", "PJSIP/100-01", "Env", "agi_request%3A%20async%0A"))
+	sess := <-sessions
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res, err := sess.Exec(ctx, "STREAM FILE hello-world #")
+	assert.Nil(t, err)
+	assert.Equal(t, "timeout", res.Data)
+
+	res, err = sess.Exec(ctx, "FOO")
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+	assert.Equal(t, 510, res.Code)
+
+	lost := &Session{Channel: "PJSIP/200-01", srv: srv, pending: map[string]chan Result{},
+		done: make(chan struct{})}
+	_, err = lost.Exec(ctx, "ANSWER")
+	assert.ErrorContains(t, err, "No such channel")
+
+	done := make(chan error)
+	go func() {
+		_, err := sess.Exec(ctx, "WAIT")
+		done <- err
+	}()
+	_, _ = ami.WaitAction(ctx, "AGI")
+	_, _ = ami.WaitAction(ctx, "AGI")
+	_, _ = ami.WaitAction(ctx, "AGI")
+	_, _ = ami.WaitAction(ctx, "AGI")
+	ami.Emit(goami2test.Event("AsyncAGIEnd", "Channel", "PJSIP/100-01"))
+	assert.ErrorIs(t, <-done, ErrEnded)
+}
+
+func TestServerAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	srv := New()
+	var started atomic.Int32
+	srv.OnSession(func(*Session) { started.Add(1) })
+	srv.Attach(cl)
+	const sessions = 3000
+	var burst strings.Builder
+	for i := 0; i < sessions; i++ {
+		fmt.Fprintf(&burst, "Event: AsyncAGIStart\r\nChannel: PJSIP/100-%08x\r\n\r\n", i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return started.Load() == sessions }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
@@ -0,0 +1,111 @@
+package goami2
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+)
+
+// SendBatch sends actions back to back with one write to the connection and
+// waits for their responses until context is done, so batch takes about one
+// round trip instead of one per action. Responses are returned in order of
+// the actions. ActionID is added to the actions that do not have one and
+// must be unique within the batch. Responses are not sent to the AllMessages
+// channel. Failed response is not an error, check it with IsSuccess. When
+// context is done, responses received so far are returned with context
+// error and missing responses are nil.
+func (c *Client) SendBatch(ctx context.Context, actions []*Message) ([]*Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, nil
+	}
+	release, err := c.accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ids := make([]string, 0, len(actions))
+	waiters := make([]chan *Message, 0, len(actions))
+	finish := make([]func(*Message, error), 0, len(actions))
+	resps := make([]*Message, len(actions))
+	defer func() {
+		c.mu.Lock()
+		for _, id := range ids {
+			if _, ok := c.pending[id]; ok && ctx.Err() != nil {
+				c.expire(id)
+			}
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+		for i, fn := range finish {
+			fn(resps[i], err)
+		}
+	}()
+
+	var buf bytes.Buffer
+	for _, action := range actions {
+		c.stampActionID(action)
+		if c.tracer != nil {
+			finish = append(finish, c.tracer.StartAction(ctx, action))
+		}
+		err = c.chain(func(action *Message) error {
+			c.stampActionID(action)
+			id := action.ActionID()
+			ch := make(chan *Message, 1)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if _, ok := c.pending[id]; ok {
+				return fmt.Errorf("%w: batch: duplicate ActionID %q", ErrAMI, id)
+			}
+			if c.pending == nil {
+				c.pending = make(map[string]chan *Message)
+			}
+			c.pending[id] = ch
+			ids, waiters = append(ids, id), append(waiters, ch)
+			buf.Write(action.Byte())
+			return nil
+		})(action)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(waiters) != len(actions) {
+		err = fmt.Errorf("%w: batch: action is not sent by middleware", ErrAMI)
+		return nil, err
+	}
+
+	if c.limiter != nil && !rateExempt(ctx) {
+		for range actions {
+			if err = c.limiter.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sent := time.Now()
+	if err = c.writeContext(ctx, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	for range actions[1:] {
+		c.metrics.IncActionsSent() // one write is counted by writeContext
+	}
+
+	for i, ch := range waiters {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				err = c.lostErr()
+				return resps, err
+			}
+			c.metrics.ObserveActionLatency(time.Since(sent))
+			resps[i] = msg
+		case <-ctx.Done():
+			err = ctx.Err()
+			return resps, err
+		}
+	}
+	return resps, nil
+}
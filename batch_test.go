@@ -0,0 +1,84 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSendBatch(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	// server replies after reading the whole batch, in reverse order
+	batch := make(chan int, 1)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for n := range batch {
+			var ids []string
+			for i := 0; i < n; i++ {
+				msg, err := srvReadAction(r)
+				if err != nil {
+					return
+				}
+				ids = append(ids, msg.ActionID()+"\r\nMessage: "+msg.Field("Action"))
+			}
+			for i := len(ids) - 1; i >= 0; i-- {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + ids[i] + "\r\n\r\n"))
+			}
+		}
+	}()
+
+	batch <- 3
+	actions := []*Message{NewAction("Ping"), NewAction("CoreStatus").Set("ActionID", "status"), NewAction("Uptime")}
+	resps, err := cl.SendBatch(context.Background(), actions)
+	assert.Nil(t, err)
+	assert.Len(t, resps, 3)
+	for i, resp := range resps {
+		assert.Equal(t, actions[i].Field("Action"), resp.Field("Message"))
+		assert.Equal(t, actions[i].ActionID(), resp.ActionID())
+	}
+	assert.Equal(t, "status", resps[1].ActionID())
+
+	resps, err = cl.SendBatch(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, resps)
+
+	_, err = cl.SendBatch(context.Background(), []*Message{
+		NewAction("Ping").Set("ActionID", "1"), NewAction("Ping").Set("ActionID", "1"),
+	})
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorContains(t, err, `duplicate ActionID "1"`)
+	assert.Empty(t, cl.pending)
+
+	t.Run("context done", func(t *testing.T) {
+		batch <- 1
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		resps, err := cl.SendBatch(ctx, []*Message{NewAction("Ping"), NewAction("Ping")})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NotNil(t, resps[0])
+		assert.Nil(t, resps[1])
+	})
+
+	t.Run("middleware error", func(t *testing.T) {
+		cl.Use(func(next SendFunc) SendFunc {
+			return func(action *Message) error {
+				if action.Field("Action") == "Reload" {
+					return errors.New("not allowed")
+				}
+				return next(action)
+			}
+		})
+		_, err := cl.SendBatch(context.Background(), []*Message{NewAction("Ping"), NewAction("Reload")})
+		assert.EqualError(t, err, "not allowed")
+		assert.Empty(t, cl.pending)
+	})
+}
@@ -0,0 +1,152 @@
+// Package bridge publishes AMI events as JSON to event buses, like NATS or
+// Kafka, or to HTTP webhooks through a pluggable sink. Subject, or topic, and key of every
+// record are rendered from event headers with templates like
+// "ami.{Event}.{Channel}".
+package bridge
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// delivery retry defaults
+const (
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// Bridge publishes events to the sink. Fields must be set before Attach.
+// Bridge is safe for concurrent use.
+type Bridge struct {
+	Events     []string                       // published events, all events when empty
+	Filter     func(msg *goami2.Message) bool // publish only events accepted by filter, when set
+	Key        string                         // record key template, no key when empty
+	Backoff    time.Duration                  // delay after the first delivery failure, DefaultBackoff when zero
+	MaxBackoff time.Duration                  // delay doubles up to MaxBackoff, DefaultMaxBackoff when zero
+	MaxRetries int                            // retries of failed delivery, retries until Close when zero
+
+	sink    Sink
+	subject string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	onError []func(error)
+}
+
+// New creates bridge publishing events to the sink with subject template.
+// Template placeholders "{Header}" are replaced with the header values of
+// the event, for example "ami.{Event}" gives "ami.Hangup".
+func New(sink Sink, subject string) *Bridge {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bridge{sink: sink, subject: subject, ctx: ctx, cancel: cancel}
+}
+
+// Attach registers bridge as AMI client event handler. Handler can be
+// removed with Client.RemoveHandler.
+func (b *Bridge) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.OnEvents(b.Events, b.Handle)
+}
+
+// OnError registers handler called with delivery errors. Event is dropped
+// after error when retries are exhausted.
+func (b *Bridge) OnError(fn func(error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onError = append(b.onError, fn)
+}
+
+// Handle publishes event to the sink and retries with backoff when delivery
+// fails. Handle blocks until event is delivered, dropped or bridge is
+// closed, so events are published in order.
+func (b *Bridge) Handle(msg *goami2.Message) {
+	if !msg.IsEvent() || (b.Filter != nil && !b.Filter(msg)) {
+		return
+	}
+	data, err := msg.MarshalJSON()
+	if err != nil {
+		b.fail(err)
+		return
+	}
+	rec := Record{Subject: Render(b.subject, msg), Data: data}
+	if b.Key != "" {
+		rec.Key = Render(b.Key, msg)
+	}
+
+	_ = retry(b.ctx, b.Backoff, b.MaxBackoff, b.MaxRetries, func(ctx context.Context) error {
+		return b.sink.Publish(ctx, rec)
+	}, b.fail)
+}
+
+// Close stops delivery retries. Events handled after Close are published
+// with canceled context.
+func (b *Bridge) Close() {
+	b.cancel()
+}
+
+// retry calls fn until it succeeds, retries are exhausted or ctx is done.
+// Every failure is reported to fail and the delay between attempts doubles
+// from backoff up to maxBackoff. Zero maxRetries retries until ctx is done.
+func retry(ctx context.Context, backoff, maxBackoff time.Duration, maxRetries int,
+	fn func(ctx context.Context) error, fail func(error),
+) error {
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		fail(err)
+		if !temporary(err) || (maxRetries > 0 && attempt >= maxRetries) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (b *Bridge) fail(err error) {
+	b.mu.Lock()
+	handlers := b.onError
+	b.mu.Unlock()
+	for _, fn := range handlers {
+		fn(err)
+	}
+}
+
+// Render replaces "{Header}" placeholders of the template with the message
+// header values. Whitespace in values is replaced with "_" and missing
+// headers are rendered empty.
+func Render(template string, msg *goami2.Message) string {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			break
+		}
+		sb.WriteString(template[:start])
+		value := msg.Field(template[start+1 : start+end])
+		sb.WriteString(strings.Join(strings.Fields(value), "_"))
+		template = template[start+end+1:]
+	}
+	sb.WriteString(template)
+	return sb.String()
+}
@@ -0,0 +1,145 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+func TestRender(t *testing.T) {
+	msg := event(t, "Event: Newchannel\r\nChannel: PJSIP/100-01\r\nCallerIDName: John Doe\r\n")
+	tests := map[string]string{
+		"ami.{Event}.{Channel}": "ami.Newchannel.PJSIP/100-01",
+		"{callerIDname}":        "John_Doe",
+		"ami.{Missing}":         "ami.",
+		"ami.events":            "ami.events",
+		"ami.{Event":            "ami.{Event",
+	}
+	for template, want := range tests {
+		t.Run(template, func(t *testing.T) {
+			assert.Equal(t, want, Render(template, msg))
+		})
+	}
+}
+
+func TestBridgeHandle(t *testing.T) {
+	var recs []Record
+	b := New(SinkFunc(func(_ context.Context, rec Record) error {
+		recs = append(recs, rec)
+		return nil
+	}), "ami.{Event}")
+	b.Key = "{Linkedid}"
+	b.Filter = func(msg *goami2.Message) bool { return msg.Field("Channel") != "Local/100" }
+
+	b.Handle(event(t, "Event: Hangup\r\nChannel: PJSIP/100-01\r\nLinkedid: 1598887690.70\r\n"))
+	b.Handle(event(t, "Event: Hangup\r\nChannel: Local/100\r\n"))
+	b.Handle(event(t, "Response: Success\r\n"))
+	assert.Len(t, recs, 1)
+	assert.Equal(t, "ami.Hangup", recs[0].Subject)
+	assert.Equal(t, "1598887690.70", recs[0].Key)
+	assert.JSONEq(t, `{"Event":"Hangup","Channel":"PJSIP/100-01","Linkedid":"1598887690.70"}`, string(recs[0].Data))
+}
+
+func TestBridgeRetry(t *testing.T) {
+	fails := 2
+	var published int
+	b := New(SinkFunc(func(context.Context, Record) error {
+		if fails > 0 {
+			fails--
+			return errors.New("bus is down")
+		}
+		published++
+		return nil
+	}), "ami")
+	b.Backoff = time.Millisecond
+	var errs []error
+	b.OnError(func(err error) { errs = append(errs, err) })
+
+	b.Handle(event(t, "Event: Hangup\r\n"))
+	assert.Equal(t, 1, published)
+	assert.Len(t, errs, 2)
+
+	// event is dropped when retries are exhausted
+	fails, errs = 5, nil
+	b.MaxRetries = 1
+	b.Handle(event(t, "Event: Hangup\r\n"))
+	assert.Equal(t, 1, published)
+	assert.Len(t, errs, 2)
+
+	// close stops retries
+	b.MaxRetries = 0
+	b.Backoff = time.Hour
+	done := make(chan struct{})
+	go func() {
+		b.Handle(event(t, "Event: Hangup\r\n"))
+		close(done)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	b.Close()
+	<-done
+}
+
+func TestBridgeAttach(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	recs := make(chan Record, 2)
+	b := New(SinkFunc(func(_ context.Context, rec Record) error {
+		recs <- rec
+		return nil
+	}), "ami.{Event}")
+	b.Events = []string{"Hangup"}
+	b.Attach(cl)
+	defer b.Close()
+
+	ami.Emit(goami2test.Event("Newchannel"), goami2test.Event("Hangup", "Channel", "PJSIP/100-01"))
+	select {
+	case rec := <-recs:
+		assert.Equal(t, "ami.Hangup", rec.Subject)
+	case <-time.After(time.Second):
+		t.Fatal("event is not published")
+	}
+}
+
+func TestBridgeAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	var published atomic.Int32
+	b := New(SinkFunc(func(_ context.Context, rec Record) error {
+		published.Add(1)
+		return nil
+	}), "ami.{Event}")
+	b.Events = []string{"Hangup"}
+	b.Attach(cl)
+	defer b.Close()
+	const total = 500
+	var burst strings.Builder
+	for i := 0; i < total; i++ {
+		fmt.Fprintf(&burst, "Event: Hangup\r\nChannel: PJSIP/100-%08x\r\nCause: 16\r\n\r\n", i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return published.Load() == total }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
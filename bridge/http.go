@@ -0,0 +1,190 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is the delay before partial batch is posted
+const DefaultFlushInterval = time.Second
+
+// StatusError is returned when the webhook replies with non 2xx status
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bridge: webhook replied %s", e.Status)
+}
+
+// Temporary reports if the request can be retried. Server errors and
+// "429 Too Many Requests" are temporary.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// HTTPSink posts records as JSON to the webhook URL. Record data is posted
+// as a JSON object, or as a JSON array of objects when batching is enabled.
+// Record subject and key are not sent. Failed requests are retried by the
+// sink, so Bridge retries can be limited with Bridge.MaxRetries. Fields must
+// be set before the first Publish.
+type HTTPSink struct {
+	URL           string
+	Header        http.Header   // request headers, like Authorization
+	Client        *http.Client  // http.DefaultClient when nil
+	BatchSize     int           // records posted in one request, every record is posted when zero
+	FlushInterval time.Duration // partial batch delay, DefaultFlushInterval when zero
+	Backoff       time.Duration // delay after the first failed request, DefaultBackoff when zero
+	MaxBackoff    time.Duration // delay doubles up to MaxBackoff, DefaultMaxBackoff when zero
+	MaxRetries    int           // retries of failed request, retries until Close when zero
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	send    sync.Mutex // posts batches in order
+	mu      sync.Mutex
+	batch   [][]byte
+	timer   *time.Timer
+	onError []func(error)
+}
+
+// NewHTTPSink creates sink posting records to the url
+func NewHTTPSink(url string) *HTTPSink {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HTTPSink{URL: url, Header: make(http.Header), ctx: ctx, cancel: cancel}
+}
+
+// OnError registers handler called with request errors. Handler is called
+// for every failed attempt, batch is dropped when retries are exhausted.
+func (s *HTTPSink) OnError(fn func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = append(s.onError, fn)
+}
+
+// Publish posts the record, or adds it to the batch. Full batch is posted
+// by Publish and partial batch is posted after FlushInterval.
+func (s *HTTPSink) Publish(ctx context.Context, rec Record) error {
+	if s.BatchSize <= 1 {
+		return s.post(ctx, [][]byte{rec.Data}, false)
+	}
+
+	s.mu.Lock()
+	s.batch = append(s.batch, rec.Data)
+	if len(s.batch) < s.BatchSize {
+		if s.timer == nil {
+			interval := s.FlushInterval
+			if interval <= 0 {
+				interval = DefaultFlushInterval
+			}
+			s.timer = time.AfterFunc(interval, func() { _ = s.Flush(s.ctx) })
+		}
+		s.mu.Unlock()
+		return nil
+	}
+	batch := s.take()
+	s.mu.Unlock()
+	return s.post(ctx, batch, true)
+}
+
+// Flush posts the partial batch
+func (s *HTTPSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	batch := s.take()
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.post(ctx, batch, true)
+}
+
+// Close posts the partial batch and stops retries of requests in progress
+func (s *HTTPSink) Close(ctx context.Context) error {
+	err := s.Flush(ctx)
+	s.cancel()
+	return err
+}
+
+// take returns batch and stops flush timer. Must be called with mu locked.
+func (s *HTTPSink) take() [][]byte {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	batch := s.batch
+	s.batch = nil
+	return batch
+}
+
+func (s *HTTPSink) post(ctx context.Context, batch [][]byte, array bool) error {
+	var body []byte
+	if array {
+		body = append(body, '[')
+		body = append(body, bytes.Join(batch, []byte(","))...)
+		body = append(body, ']')
+	} else {
+		body = batch[0]
+	}
+
+	s.send.Lock()
+	defer s.send.Unlock()
+	// requests stop when either the caller or the sink context is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	return retry(ctx, s.Backoff, s.MaxBackoff, s.MaxRetries, func(ctx context.Context) error {
+		return s.do(ctx, body)
+	}, s.fail)
+}
+
+func (s *HTTPSink) do(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+func (s *HTTPSink) fail(err error) {
+	s.mu.Lock()
+	handlers := s.onError
+	s.mu.Unlock()
+	for _, fn := range handlers {
+		fn(err)
+	}
+}
+
+// temporary reports if the failed delivery can be retried
+func temporary(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type webhook struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+	status []int // reply statuses, 200 when empty
+	auth   string
+	posted chan struct{}
+}
+
+func newWebhook() *webhook {
+	wh := &webhook{posted: make(chan struct{}, 10)}
+	wh.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		wh.mu.Lock()
+		defer wh.mu.Unlock()
+		wh.auth = r.Header.Get("Authorization")
+		status := http.StatusOK
+		if len(wh.status) > 0 {
+			status, wh.status = wh.status[0], wh.status[1:]
+		}
+		if status == http.StatusOK {
+			wh.bodies = append(wh.bodies, r.Header.Get("Content-Type")+" "+string(body))
+		}
+		w.WriteHeader(status)
+		wh.posted <- struct{}{}
+	}))
+	return wh
+}
+
+func (wh *webhook) posts() []string {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return wh.bodies
+}
+
+func TestHTTPSink(t *testing.T) {
+	wh := newWebhook()
+	defer wh.Close()
+
+	sink := NewHTTPSink(wh.URL)
+	sink.Header.Set("Authorization", "Bearer s3cret")
+	assert.Nil(t, sink.Publish(context.Background(), Record{Data: []byte(`{"Event":"Hangup"}`)}))
+	assert.Equal(t, []string{`application/json {"Event":"Hangup"}`}, wh.posts())
+	assert.Equal(t, "Bearer s3cret", wh.auth)
+}
+
+func TestHTTPSinkRetry(t *testing.T) {
+	wh := newWebhook()
+	defer wh.Close()
+
+	sink := NewHTTPSink(wh.URL)
+	sink.Backoff = time.Millisecond
+	var errs []error
+	sink.OnError(func(err error) { errs = append(errs, err) })
+
+	wh.status = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	assert.Nil(t, sink.Publish(context.Background(), Record{Data: []byte(`{}`)}))
+	assert.Len(t, wh.posts(), 1)
+	assert.Len(t, errs, 2)
+
+	// client errors are not retried
+	wh.status, errs = []int{http.StatusBadRequest}, nil
+	err := sink.Publish(context.Background(), Record{Data: []byte(`{}`)})
+	var se *StatusError
+	assert.ErrorAs(t, err, &se)
+	assert.Equal(t, http.StatusBadRequest, se.StatusCode)
+	assert.Len(t, errs, 1)
+
+	wh.status, errs = []int{500, 500, 500}, nil
+	sink.MaxRetries = 1
+	assert.NotNil(t, sink.Publish(context.Background(), Record{Data: []byte(`{}`)}))
+	assert.Len(t, errs, 2)
+	assert.Len(t, wh.posts(), 1)
+}
+
+func TestHTTPSinkBatch(t *testing.T) {
+	wh := newWebhook()
+	defer wh.Close()
+
+	sink := NewHTTPSink(wh.URL)
+	sink.BatchSize = 2
+	sink.FlushInterval = 10 * time.Millisecond
+	ctx := context.Background()
+
+	assert.Nil(t, sink.Publish(ctx, Record{Data: []byte(`{"n":1}`)}))
+	assert.Empty(t, wh.posts())
+	assert.Nil(t, sink.Publish(ctx, Record{Data: []byte(`{"n":2}`)}))
+	assert.Equal(t, []string{`application/json [{"n":1},{"n":2}]`}, wh.posts())
+
+	// partial batch is posted after flush interval
+	<-wh.posted
+	assert.Nil(t, sink.Publish(ctx, Record{Data: []byte(`{"n":3}`)}))
+	select {
+	case <-wh.posted:
+	case <-time.After(time.Second):
+		t.Fatal("partial batch is not posted")
+	}
+	assert.Equal(t, `application/json [{"n":3}]`, wh.posts()[1])
+
+	// close posts partial batch
+	sink.FlushInterval = time.Hour
+	assert.Nil(t, sink.Publish(ctx, Record{Data: []byte(`{"n":4}`)}))
+	assert.Nil(t, sink.Close(ctx))
+	assert.Len(t, wh.posts(), 3)
+	assert.Nil(t, sink.Flush(ctx))
+}
@@ -0,0 +1,71 @@
+package bridge
+
+import "context"
+
+// Record is the event published to the sink
+type Record struct {
+	Subject string // NATS subject or Kafka topic
+	Key     string // Kafka message key, empty when not set
+	Data    []byte // event as JSON object
+}
+
+// Sink publishes records to the event bus
+type Sink interface {
+	Publish(ctx context.Context, rec Record) error
+}
+
+// SinkFunc is the function used as a sink
+type SinkFunc func(ctx context.Context, rec Record) error
+
+// Publish calls f(ctx, rec)
+func (f SinkFunc) Publish(ctx context.Context, rec Record) error {
+	return f(ctx, rec)
+}
+
+// NATSPublisher publishes data to the subject. It is implemented by
+// *nats.Conn of github.com/nats-io/nats.go.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATS creates sink publishing records to the NATS connection. Record key
+// is not used.
+func NATS(conn NATSPublisher) Sink {
+	return SinkFunc(func(ctx context.Context, rec Record) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return conn.Publish(rec.Subject, rec.Data)
+	})
+}
+
+// KafkaProducer produces message with key and value to the topic. Kafka
+// clients differ in message types so the producer is usually a small
+// adapter, for example for github.com/segmentio/kafka-go writer:
+//
+//	bridge.KafkaProducerFunc(func(ctx context.Context, topic string, key, value []byte) error {
+//		return w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+//	})
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaProducerFunc is the function used as KafkaProducer
+type KafkaProducerFunc func(ctx context.Context, topic string, key, value []byte) error
+
+// Produce calls f(ctx, topic, key, value)
+func (f KafkaProducerFunc) Produce(ctx context.Context, topic string, key, value []byte) error {
+	return f(ctx, topic, key, value)
+}
+
+// Kafka creates sink producing records to the record subject as topic.
+// Empty record key is produced as nil key.
+func Kafka(producer KafkaProducer) Sink {
+	return SinkFunc(func(ctx context.Context, rec Record) error {
+		var key []byte
+		if rec.Key != "" {
+			key = []byte(rec.Key)
+		}
+		return producer.Produce(ctx, rec.Subject, key, rec.Data)
+	})
+}
@@ -0,0 +1,49 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type natsConn struct {
+	subject string
+	data    []byte
+}
+
+func (c *natsConn) Publish(subject string, data []byte) error {
+	c.subject, c.data = subject, data
+	return nil
+}
+
+func TestNATSSink(t *testing.T) {
+	conn := &natsConn{}
+	sink := NATS(conn)
+	assert.Nil(t, sink.Publish(context.Background(), Record{Subject: "ami.Hangup", Key: "1", Data: []byte("{}")}))
+	assert.Equal(t, "ami.Hangup", conn.subject)
+	assert.Equal(t, "{}", string(conn.data))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sink.Publish(ctx, Record{Subject: "ami.Newchannel"}), context.Canceled)
+	assert.Equal(t, "ami.Hangup", conn.subject)
+}
+
+func TestKafkaSink(t *testing.T) {
+	var topic string
+	var key, value []byte
+	sink := Kafka(KafkaProducerFunc(func(_ context.Context, tp string, k, v []byte) error {
+		topic, key, value = tp, k, v
+		return nil
+	}))
+
+	assert.Nil(t, sink.Publish(context.Background(), Record{Subject: "ami-events", Key: "1598887690.70",
+		Data: []byte("{}")}))
+	assert.Equal(t, "ami-events", topic)
+	assert.Equal(t, "1598887690.70", string(key))
+	assert.Equal(t, "{}", string(value))
+
+	assert.Nil(t, sink.Publish(context.Background(), Record{Subject: "ami-events"}))
+	assert.Nil(t, key)
+}
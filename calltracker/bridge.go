@@ -0,0 +1,191 @@
+package calltracker
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// bridge events consumed by the tracker
+var bridgeEvents = []string{"BridgeCreate", "BridgeEnter", "BridgeLeave", "BridgeDestroy"}
+
+// transfer events reported by the tracker
+var transferEvents = []string{"AttendedTransfer", "BlindTransfer"}
+
+// Bridge is the state of live bridge
+type Bridge struct {
+	goami2.BridgeSnapshot
+	Members []string // Uniqueid of the bridged channels in order they entered
+	Created time.Time
+}
+
+type bridgeEntry struct {
+	br  Bridge
+	seq uint64 // creation order
+}
+
+// Transfer is the call transfer reported by AttendedTransfer or
+// BlindTransfer event
+type Transfer struct {
+	Attended   bool
+	Result     string // "Success" or failure reason
+	Transferer string // Uniqueid of the channel that performed the transfer
+	Transferee string // Uniqueid of the transferred channel
+	Target     string // Uniqueid of the attended transfer target channel
+	Context    string // blind transfer destination context
+	Extension  string // blind transfer destination extension
+	Event      *goami2.Message
+}
+
+// Bridges returns live bridges ordered by creation
+func (t *Tracker) Bridges() []Bridge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entries := make([]*bridgeEntry, 0, len(t.bridges))
+	for _, e := range t.bridges {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	bridges := make([]Bridge, len(entries))
+	for i, e := range entries {
+		bridges[i] = e.copy()
+	}
+	return bridges
+}
+
+// Bridge returns live bridge by BridgeUniqueid
+func (t *Tracker) Bridge(id string) (Bridge, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.bridges[id]
+	if !ok {
+		return Bridge{}, false
+	}
+	return e.copy(), true
+}
+
+// ChannelBridge returns bridge the channel with given Uniqueid is in
+func (t *Tracker) ChannelBridge(uniqueid string) (Bridge, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.memberOf(uniqueid); e != nil {
+		return e.copy(), true
+	}
+	return Bridge{}, false
+}
+
+// Peers returns live channels bridged with the channel with given Uniqueid,
+// that is who the channel is talking to
+func (t *Tracker) Peers(uniqueid string) []Channel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.memberOf(uniqueid)
+	if e == nil {
+		return nil
+	}
+	var peers []Channel
+	for _, id := range e.br.Members {
+		if ch, ok := t.channels[id]; ok && id != uniqueid {
+			peers = append(peers, ch.ch)
+		}
+	}
+	return peers
+}
+
+// memberOf returns bridge with the channel. Must be called with locked mutex
+func (t *Tracker) memberOf(uniqueid string) *bridgeEntry {
+	for _, e := range t.bridges {
+		for _, id := range e.br.Members {
+			if id == uniqueid {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// handleBridge updates bridge state with bridge event
+func (t *Tracker) handleBridge(msg *goami2.Message, name string) (Change, bool) {
+	var snap goami2.BridgeSnapshot
+	if msg.Decode(&snap) != nil || snap.BridgeUniqueid == "" {
+		return Change{}, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.bridges[snap.BridgeUniqueid]
+	if !ok {
+		t.seq++
+		e = &bridgeEntry{br: Bridge{Created: t.now()}, seq: t.seq}
+	}
+	e.br.BridgeSnapshot = snap
+	change := Change{Kind: BridgeUpdated}
+	uniqueid := msg.Field("Uniqueid")
+	if ch, ok := t.channels[uniqueid]; ok {
+		change.Channel = ch.ch
+	}
+
+	switch {
+	case strings.EqualFold(name, "BridgeDestroy"):
+		delete(t.bridges, snap.BridgeUniqueid)
+		change.Kind = BridgeDestroyed
+	case strings.EqualFold(name, "BridgeEnter"):
+		if uniqueid != "" {
+			e.br.Members = append(remove(e.br.Members, uniqueid), uniqueid)
+		}
+	case strings.EqualFold(name, "BridgeLeave"):
+		e.br.Members = remove(e.br.Members, uniqueid)
+	}
+	if change.Kind != BridgeDestroyed {
+		t.bridges[snap.BridgeUniqueid] = e
+		if !ok {
+			change.Kind = BridgeCreated
+		}
+	}
+	change.Bridge = e.copy()
+	return change, true
+}
+
+// handleTransfer reports transfer event as the change of transferer channel
+func (t *Tracker) handleTransfer(msg *goami2.Message, name string) (Change, bool) {
+	tr := &Transfer{
+		Attended:   strings.EqualFold(name, "AttendedTransfer"),
+		Result:     msg.Field("Result"),
+		Transferee: msg.Field("TransfereeUniqueid"),
+		Event:      msg,
+	}
+	if tr.Attended {
+		tr.Transferer = msg.Field("OrigTransfererUniqueid")
+		tr.Target = msg.Field("TransferTargetUniqueid")
+	} else {
+		tr.Transferer = msg.Field("TransfererUniqueid")
+		tr.Context = msg.Field("Context")
+		tr.Extension = msg.Field("Extension")
+	}
+	change := Change{Kind: ChannelTransferred, Transfer: tr}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.channels[tr.Transferer]; ok {
+		change.Channel = e.ch
+	}
+	return change, true
+}
+
+// copy returns bridge with own members slice
+func (e *bridgeEntry) copy() Bridge {
+	br := e.br
+	br.Members = append([]string(nil), e.br.Members...)
+	return br
+}
+
+func remove(ids []string, id string) []string {
+	out := ids[:0]
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package calltracker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerBridges(t *testing.T) {
+	tr := New()
+	var changes []Change
+	tr.OnChange(func(c Change) { changes = append(changes, c) })
+
+	tr.Handle(event(t, newchannel100))
+	tr.Handle(event(t, newchannel200))
+	tr.Handle(event(t, "Event: BridgeCreate\r\nBridgeUniqueid: b1\r\nBridgeType: basic\r\n"+
+		"BridgeNumChannels: 0\r\n"))
+	tr.Handle(event(t, "Event: BridgeEnter\r\nBridgeUniqueid: b1\r\nBridgeNumChannels: 1\r\n"+
+		"Channel: PJSIP/100-01\r\nUniqueid: 1598887681.60\r\n"))
+	tr.Handle(event(t, "Event: BridgeEnter\r\nBridgeUniqueid: b1\r\nBridgeNumChannels: 2\r\n"+
+		"Channel: PJSIP/200-02\r\nUniqueid: 1598887681.61\r\n"))
+	tr.Handle(event(t, "Event: BridgeEnter\r\nChannel: PJSIP/300-03\r\n"))
+
+	bridges := tr.Bridges()
+	assert.Len(t, bridges, 1)
+	assert.Equal(t, "b1", bridges[0].BridgeUniqueid)
+	assert.Equal(t, 2, bridges[0].BridgeNumChannels)
+	assert.Equal(t, []string{"1598887681.60", "1598887681.61"}, bridges[0].Members)
+
+	br, ok := tr.ChannelBridge("1598887681.61")
+	assert.True(t, ok)
+	assert.Equal(t, "b1", br.BridgeUniqueid)
+	peers := tr.Peers("1598887681.60")
+	assert.Len(t, peers, 1)
+	assert.Equal(t, "PJSIP/200-02", peers[0].Channel)
+	assert.Nil(t, tr.Peers("unknown"))
+
+	tr.Handle(event(t, "Event: BridgeLeave\r\nBridgeUniqueid: b1\r\nBridgeNumChannels: 1\r\n"+
+		"Channel: PJSIP/200-02\r\nUniqueid: 1598887681.61\r\n"))
+	br, ok = tr.Bridge("b1")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"1598887681.60"}, br.Members)
+	_, ok = tr.ChannelBridge("1598887681.61")
+	assert.False(t, ok)
+
+	tr.Handle(event(t, "Event: BridgeDestroy\r\nBridgeUniqueid: b1\r\nBridgeNumChannels: 0\r\n"))
+	assert.Empty(t, tr.Bridges())
+	_, ok = tr.Bridge("b1")
+	assert.False(t, ok)
+
+	kinds := make([]ChangeKind, 0, len(changes))
+	for _, c := range changes[2:] {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ChangeKind{BridgeCreated, BridgeUpdated, BridgeUpdated,
+		BridgeUpdated, BridgeDestroyed}, kinds)
+	assert.Equal(t, "PJSIP/100-01", changes[3].Channel.Channel)
+	assert.Equal(t, []string{"1598887681.60"}, changes[3].Bridge.Members)
+}
+
+func TestTrackerTransfers(t *testing.T) {
+	tr := New()
+	var changes []Change
+	tr.OnChange(func(c Change) { changes = append(changes, c) })
+	tr.Handle(event(t, newchannel100))
+
+	tr.Handle(event(t, "Event: BlindTransfer\r\nResult: Success\r\n"+
+		"TransfererChannel: PJSIP/100-01\r\nTransfererUniqueid: 1598887681.60\r\n"+
+		"TransfereeUniqueid: 1598887681.61\r\nContext: default\r\nExtension: 300\r\n"))
+	tr.Handle(event(t, "Event: AttendedTransfer\r\nResult: Success\r\n"+
+		"OrigTransfererUniqueid: 1598887681.60\r\nTransfereeUniqueid: 1598887681.61\r\n"+
+		"TransferTargetUniqueid: 1598887690.70\r\n"))
+
+	assert.Len(t, changes, 3)
+	blind := changes[1]
+	assert.Equal(t, ChannelTransferred, blind.Kind)
+	assert.Equal(t, "PJSIP/100-01", blind.Channel.Channel)
+	assert.False(t, blind.Transfer.Attended)
+	assert.Equal(t, "Success", blind.Transfer.Result)
+	assert.Equal(t, "1598887681.61", blind.Transfer.Transferee)
+	assert.Equal(t, "300", blind.Transfer.Extension)
+
+	attended := changes[2].Transfer
+	assert.True(t, attended.Attended)
+	assert.Equal(t, "1598887681.60", attended.Transferer)
+	assert.Equal(t, "1598887690.70", attended.Target)
+	assert.Equal(t, "AttendedTransfer", attended.Event.Field("Event"))
+}
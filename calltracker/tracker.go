@@ -0,0 +1,293 @@
+// Package calltracker keeps in-memory state of live Asterisk channels, calls
+// and bridges built from AMI channel and bridge events.
+package calltracker
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// channel events consumed by the tracker
+var channelEvents = []string{"Newchannel", "Newstate", "NewCallerid", "NewConnectedLine", "Hangup"}
+
+// Channel is the state of live channel
+type Channel struct {
+	goami2.ChannelSnapshot
+	Created time.Time // time the channel was seen first
+	Updated time.Time // time of the last channel event
+}
+
+// Call is a group of channels with the same Linkedid
+type Call struct {
+	Linkedid string
+	Channels []Channel // ordered by creation
+}
+
+// ChangeKind is the kind of channel or bridge state change
+type ChangeKind int
+
+// State changes
+const (
+	ChannelCreated ChangeKind = iota
+	ChannelUpdated
+	ChannelHungup
+	BridgeCreated
+	BridgeUpdated
+	BridgeDestroyed
+	ChannelTransferred
+)
+
+// String returns change kind name
+func (k ChangeKind) String() string {
+	switch k {
+	case ChannelCreated:
+		return "created"
+	case ChannelUpdated:
+		return "updated"
+	case ChannelHungup:
+		return "hungup"
+	case BridgeCreated:
+		return "bridge created"
+	case BridgeUpdated:
+		return "bridge updated"
+	case BridgeDestroyed:
+		return "bridge destroyed"
+	case ChannelTransferred:
+		return "transferred"
+	}
+	return "unknown"
+}
+
+// Change is the notification of channel or bridge state change
+type Change struct {
+	Kind     ChangeKind
+	Channel  Channel   // channel of channel changes and bridge enter or leave
+	Cause    int       // hangup cause code
+	CauseTxt string    // hangup cause description
+	Bridge   Bridge    // bridge of bridge changes
+	Transfer *Transfer // transfer of ChannelTransferred change
+}
+
+// Tracker tracks live channels from Newchannel, Newstate, NewCallerid,
+// NewConnectedLine and Hangup events and bridges from BridgeCreate,
+// BridgeEnter, BridgeLeave and BridgeDestroy events. AttendedTransfer and
+// BlindTransfer events are reported as changes. Tracker is safe for
+// concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	channels map[string]*entry       // by Uniqueid
+	bridges  map[string]*bridgeEntry // by BridgeUniqueid
+	seq      uint64
+	onChange []func(Change)
+	now      func() time.Time
+}
+
+type entry struct {
+	ch  Channel
+	seq uint64 // creation order
+}
+
+// New creates empty tracker
+func New() *Tracker {
+	return &Tracker{
+		channels: make(map[string]*entry),
+		bridges:  make(map[string]*bridgeEntry),
+		now:      time.Now,
+	}
+}
+
+// Attach registers tracker as AMI client event handler. Handler can be
+// removed with Client.RemoveHandler.
+func (t *Tracker) Attach(c *goami2.Client) goami2.HandlerID {
+	events := append(append(append([]string(nil), channelEvents...), bridgeEvents...), transferEvents...)
+	return c.OnEvents(events, t.Handle)
+}
+
+// OnChange registers function called on every channel or bridge state change.
+// Function is called from Handle and must not call OnChange.
+func (t *Tracker) OnChange(fn func(Change)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = append(t.onChange, fn)
+}
+
+// Handle updates tracker state with AMI event. Events that are not tracked
+// or without Uniqueid or BridgeUniqueid are ignored.
+func (t *Tracker) Handle(msg *goami2.Message) {
+	name := msg.Field("Event")
+	var change Change
+	var ok bool
+	switch {
+	case isOneOf(name, channelEvents):
+		change, ok = t.handleChannel(msg, name)
+	case isOneOf(name, bridgeEvents):
+		change, ok = t.handleBridge(msg, name)
+	case isOneOf(name, transferEvents):
+		change, ok = t.handleTransfer(msg, name)
+	}
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	handlers := t.onChange
+	t.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(change)
+	}
+}
+
+// handleChannel updates channel state with channel event
+func (t *Tracker) handleChannel(msg *goami2.Message, name string) (Change, bool) {
+	var snap goami2.ChannelSnapshot
+	if msg.Decode(&snap) != nil || snap.Uniqueid == "" {
+		return Change{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.apply(msg, name, snap), true
+}
+
+// apply event to the channel state. Must be called with locked mutex
+func (t *Tracker) apply(msg *goami2.Message, name string, snap goami2.ChannelSnapshot) Change {
+	now := t.now()
+	e, ok := t.channels[snap.Uniqueid]
+	if !ok {
+		t.seq++
+		e = &entry{ch: Channel{Created: now}, seq: t.seq}
+	}
+	e.ch.ChannelSnapshot = merge(e.ch.ChannelSnapshot, snap)
+	e.ch.Updated = now
+
+	if strings.EqualFold(name, "Hangup") {
+		delete(t.channels, snap.Uniqueid)
+		ev, _ := goami2.Decode[goami2.HangupEvent](msg)
+		return Change{Kind: ChannelHungup, Channel: e.ch, Cause: ev.Cause, CauseTxt: ev.CauseTxt}
+	}
+
+	t.channels[snap.Uniqueid] = e
+	if !ok {
+		return Change{Kind: ChannelCreated, Channel: e.ch}
+	}
+	return Change{Kind: ChannelUpdated, Channel: e.ch}
+}
+
+// Channels returns live channels ordered by creation
+func (t *Tracker) Channels() []Channel {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sorted(func(*entry) bool { return true })
+}
+
+// Channel returns live channel by Uniqueid
+func (t *Tracker) Channel(uniqueid string) (Channel, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.channels[uniqueid]
+	if !ok {
+		return Channel{}, false
+	}
+	return e.ch, true
+}
+
+// Call returns call of the live channel with given Uniqueid
+func (t *Tracker) Call(uniqueid string) (Call, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e, ok := t.channels[uniqueid]
+	if !ok {
+		return Call{}, false
+	}
+	linkedid := linkedID(e.ch)
+	return Call{
+		Linkedid: linkedid,
+		Channels: t.sorted(func(e *entry) bool { return linkedID(e.ch) == linkedid }),
+	}, true
+}
+
+// Calls returns live calls ordered by creation of the first channel
+func (t *Tracker) Calls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var calls []Call
+	index := make(map[string]int)
+	for _, ch := range t.sorted(func(*entry) bool { return true }) {
+		id := linkedID(ch)
+		i, ok := index[id]
+		if !ok {
+			i = len(calls)
+			index[id] = i
+			calls = append(calls, Call{Linkedid: id})
+		}
+		calls[i].Channels = append(calls[i].Channels, ch)
+	}
+	return calls
+}
+
+// sorted returns channels matching filter ordered by creation.
+// Must be called with locked mutex
+func (t *Tracker) sorted(filter func(*entry) bool) []Channel {
+	entries := make([]*entry, 0, len(t.channels))
+	for _, e := range t.channels {
+		if filter(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+	channels := make([]Channel, len(entries))
+	for i, e := range entries {
+		channels[i] = e.ch
+	}
+	return channels
+}
+
+// linkedID returns Linkedid of the channel or Uniqueid when
+// channel has no Linkedid
+func linkedID(ch Channel) string {
+	if ch.Linkedid != "" {
+		return ch.Linkedid
+	}
+	return ch.Uniqueid
+}
+
+// merge updates channel snapshot with non empty fields of the event snapshot
+func merge(cur, ev goami2.ChannelSnapshot) goami2.ChannelSnapshot {
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&cur.Channel, ev.Channel)
+	set(&cur.ChannelStateDesc, ev.ChannelStateDesc)
+	set(&cur.CallerIDNum, ev.CallerIDNum)
+	set(&cur.CallerIDName, ev.CallerIDName)
+	set(&cur.ConnectedLineNum, ev.ConnectedLineNum)
+	set(&cur.ConnectedLineName, ev.ConnectedLineName)
+	set(&cur.Language, ev.Language)
+	set(&cur.AccountCode, ev.AccountCode)
+	set(&cur.Context, ev.Context)
+	set(&cur.Exten, ev.Exten)
+	set(&cur.Uniqueid, ev.Uniqueid)
+	set(&cur.Linkedid, ev.Linkedid)
+	if ev.ChannelStateDesc != "" {
+		cur.ChannelState = ev.ChannelState
+	}
+	if ev.Priority != 0 {
+		cur.Priority = ev.Priority
+	}
+	return cur
+}
+
+func isOneOf(name string, events []string) bool {
+	for _, ev := range events {
+		if strings.EqualFold(ev, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package calltracker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+const (
+	newchannel100 = "Event: Newchannel\r\nChannel: PJSIP/100-01\r\nChannelState: 0\r\n" +
+		"ChannelStateDesc: Down\r\nCallerIDNum: 100\r\nExten: 200\r\n" +
+		"Uniqueid: 1598887681.60\r\nLinkedid: 1598887681.60\r\n"
+	newchannel200 = "Event: Newchannel\r\nChannel: PJSIP/200-02\r\nChannelState: 0\r\n" +
+		"ChannelStateDesc: Down\r\nUniqueid: 1598887681.61\r\nLinkedid: 1598887681.60\r\n"
+	newchannel300 = "Event: Newchannel\r\nChannel: PJSIP/300-03\r\nChannelState: 0\r\n" +
+		"ChannelStateDesc: Down\r\nUniqueid: 1598887690.70\r\nLinkedid: 1598887690.70\r\n"
+)
+
+func TestTracker(t *testing.T) {
+	tr := New()
+	var changes []Change
+	tr.OnChange(func(c Change) { changes = append(changes, c) })
+
+	tr.Handle(event(t, newchannel100))
+	tr.Handle(event(t, newchannel200))
+	tr.Handle(event(t, newchannel300))
+	tr.Handle(event(t, "Event: Newstate\r\nChannel: PJSIP/100-01\r\nChannelState: 6\r\n"+
+		"ChannelStateDesc: Up\r\nUniqueid: 1598887681.60\r\n"))
+	tr.Handle(event(t, "Event: NewCallerid\r\nCallerIDName: Alice\r\nUniqueid: 1598887681.60\r\n"))
+	tr.Handle(event(t, "Event: FullyBooted\r\nUniqueid: 1598887681.60\r\n"))
+	tr.Handle(event(t, "Event: Newstate\r\nChannel: PJSIP/400-04\r\n"))
+
+	channels := tr.Channels()
+	assert.Len(t, channels, 3)
+	assert.Equal(t, "PJSIP/100-01", channels[0].Channel)
+	assert.Equal(t, "PJSIP/200-02", channels[1].Channel)
+	assert.Equal(t, "PJSIP/300-03", channels[2].Channel)
+
+	ch, ok := tr.Channel("1598887681.60")
+	assert.True(t, ok)
+	assert.Equal(t, 6, ch.ChannelState)
+	assert.Equal(t, "Up", ch.ChannelStateDesc)
+	assert.Equal(t, "Alice", ch.CallerIDName)
+	assert.Equal(t, "100", ch.CallerIDNum)
+	assert.Equal(t, "200", ch.Exten)
+	assert.False(t, ch.Created.IsZero())
+
+	call, ok := tr.Call("1598887681.61")
+	assert.True(t, ok)
+	assert.Equal(t, "1598887681.60", call.Linkedid)
+	assert.Len(t, call.Channels, 2)
+	assert.Len(t, tr.Calls(), 2)
+
+	tr.Handle(event(t, "Event: Hangup\r\nChannel: PJSIP/200-02\r\nUniqueid: 1598887681.61\r\n"+
+		"Cause: 16\r\nCause-txt: Normal Clearing\r\n"))
+	_, ok = tr.Channel("1598887681.61")
+	assert.False(t, ok)
+	call, _ = tr.Call("1598887681.60")
+	assert.Len(t, call.Channels, 1)
+	_, ok = tr.Call("unknown")
+	assert.False(t, ok)
+
+	kinds := make([]ChangeKind, 0, len(changes))
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ChangeKind{ChannelCreated, ChannelCreated, ChannelCreated,
+		ChannelUpdated, ChannelUpdated, ChannelHungup}, kinds)
+	hangup := changes[len(changes)-1]
+	assert.Equal(t, "PJSIP/200-02", hangup.Channel.Channel)
+	assert.Equal(t, 16, hangup.Cause)
+	assert.Equal(t, "Normal Clearing", hangup.CauseTxt)
+}
+
+func TestChangeKindString(t *testing.T) {
+	assert.Equal(t, "created", ChannelCreated.String())
+	assert.Equal(t, "updated", ChannelUpdated.String())
+	assert.Equal(t, "hungup", ChannelHungup.String())
+	assert.Equal(t, "bridge destroyed", BridgeDestroyed.String())
+	assert.Equal(t, "transferred", ChannelTransferred.String())
+	assert.Equal(t, "unknown", ChangeKind(42).String())
+}
+
+func TestTrackerAttach(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	attached := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1024)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+		_, _ = connSrv.Read(buf)
+		_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		<-attached
+		_, _ = connSrv.Write([]byte(newchannel100 + "\r\n"))
+		_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+	}()
+
+	cl, err := goami2.NewClientWithContext(context.Background(), connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	tr := New()
+	created := make(chan Change, 1)
+	tr.OnChange(func(c Change) { created <- c })
+	tr.Attach(cl)
+	close(attached)
+
+	select {
+	case c := <-created:
+		assert.Equal(t, "PJSIP/100-01", c.Channel.Channel)
+	case <-time.After(time.Second):
+		t.Fatal("channel is not tracked")
+	}
+	assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+}
+
+func TestTrackerAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	tr := New()
+	tr.Attach(cl)
+	const calls = 3000
+	var burst strings.Builder
+	for i := 0; i < calls; i++ {
+		burst.WriteString(fmt.Sprintf("Event: Newchannel\r\nChannel: PJSIP/100-%08x\r\n"+
+			"Uniqueid: 1598887681.%d\r\nLinkedid: 1598887681.%d\r\n\r\n", i, i, i))
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return len(tr.Calls()) == calls }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
@@ -0,0 +1,135 @@
+package goami2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// capture directions
+const (
+	CaptureIn  = "in"  // data read from the connection
+	CaptureOut = "out" // data written to the connection
+)
+
+// CaptureRecord is the raw data read from or written to the AMI connection.
+// Capture is written as JSON record per line.
+type CaptureRecord struct {
+	Time time.Time `json:"time"`
+	Dir  string    `json:"dir"`
+	Data string    `json:"data"`
+}
+
+// capture writes records of the client connections
+type capture struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+func newCapture(w io.Writer) *capture {
+	return &capture{enc: json.NewEncoder(w), now: time.Now}
+}
+
+func (c *capture) record(dir string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.enc.Encode(CaptureRecord{Time: c.now(), Dir: dir, Data: string(data)})
+}
+
+// captureConn records data of the wrapped connection
+type captureConn struct {
+	net.Conn
+	capture *capture
+}
+
+func (c *captureConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.capture.record(CaptureIn, b[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.capture.record(CaptureOut, redactPacket(b[:n]))
+	}
+	return n, err
+}
+
+// wrapConn wraps connection to record its data when capture is enabled
+func (c *Client) wrapConn(conn net.Conn) net.Conn {
+	if c.capture == nil || conn == nil {
+		return conn
+	}
+	if _, ok := conn.(*captureConn); ok {
+		return conn
+	}
+	return &captureConn{Conn: conn, capture: c.capture}
+}
+
+// Replay feeds inbound data of the capture written with WithCapture through
+// the parser and calls handle with every parsed message. When realTime is
+// true, Replay waits between records as long as in the original session.
+// Replay stops when context is done and returns context error, or returns
+// first parse error.
+func Replay(ctx context.Context, r io.Reader, realTime bool, handle func(msg *Message)) error {
+	dec := json.NewDecoder(r)
+	scanner := &packetScanner{}
+	var line strings.Builder
+	var last time.Time
+	for {
+		var rec CaptureRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("%w: failed to read capture: %w", ErrAMI, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if rec.Dir != CaptureIn {
+			continue
+		}
+		if realTime && !last.IsZero() && rec.Time.After(last) {
+			select {
+			case <-time.After(rec.Time.Sub(last)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		last = rec.Time
+
+		data := rec.Data
+		for data != "" {
+			chunk, rest, found := strings.Cut(data, "\n")
+			line.WriteString(chunk)
+			if !found {
+				break
+			}
+			data = rest
+			text := line.String() + "\n"
+			line.Reset()
+			if strings.HasPrefix(text, promptPrefix) {
+				continue
+			}
+			for _, packet := range scanner.push(normalizeLine(text)) {
+				msg, err := Parse(packet)
+				if err != nil {
+					return err
+				}
+				handle(msg)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package goami2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCapture(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	go func() {
+		r := bufio.NewReader(connSrv)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+		if _, err := srvReadAction(r); err != nil {
+			return
+		}
+		_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		if _, err := srvReadAction(r); err != nil {
+			return
+		}
+		_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n"))
+		_, _ = connSrv.Write([]byte("Uniqueid: 1598887690.70\r\n\r\nEvent: FullyBooted\r\n\r\n"))
+	}()
+
+	buf := &bytes.Buffer{}
+	cl, err := NewClient(connClient, "admin", "pa55w0rd", WithCapture(buf), WithCapture(nil))
+	assert.Nil(t, err)
+	assert.True(t, cl.Action(NewAction("Ping")))
+	<-cl.AllMessages()
+	<-cl.AllMessages()
+	cl.Close()
+
+	var records []CaptureRecord
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for dec.More() {
+		var rec CaptureRecord
+		assert.Nil(t, dec.Decode(&rec))
+		records = append(records, rec)
+	}
+	assert.Equal(t, CaptureIn, records[0].Dir)
+	assert.Equal(t, "Asterisk Call Manager/2.10.4\r\n", records[0].Data)
+	assert.Equal(t, CaptureOut, records[1].Dir)
+	assert.Contains(t, records[1].Data, "Secret: "+redactedValue)
+	assert.NotContains(t, records[1].Data, "pa55w0rd")
+	assert.False(t, records[0].Time.IsZero())
+
+	var msgs []*Message
+	err = Replay(context.Background(), bytes.NewReader(buf.Bytes()), false, func(msg *Message) {
+		msgs = append(msgs, msg)
+	})
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 3)
+	assert.Equal(t, "Authentication accepted", msgs[0].Field("Message"))
+	assert.Equal(t, "1598887690.70", msgs[1].Field("Uniqueid"))
+	assert.Equal(t, "FullyBooted", msgs[2].Field("Event"))
+}
+
+func TestReplay(t *testing.T) {
+	capture := func(records ...CaptureRecord) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		for _, rec := range records {
+			assert.Nil(t, enc.Encode(rec))
+		}
+		return buf
+	}
+	start := time.Date(2020, 8, 31, 3, 0, 0, 0, time.UTC)
+
+	t.Run("real time", func(t *testing.T) {
+		buf := capture(
+			CaptureRecord{Time: start, Dir: CaptureIn, Data: "Event: Hangup\r\n\r\n"},
+			CaptureRecord{Time: start.Add(time.Millisecond), Dir: CaptureOut, Data: "Action: Ping\r\n\r\n"},
+			CaptureRecord{Time: start.Add(30 * time.Millisecond), Dir: CaptureIn, Data: "Event: Hangup\r\n\r\n"},
+		)
+		n := 0
+		begin := time.Now()
+		assert.Nil(t, Replay(context.Background(), buf, true, func(*Message) { n++ }))
+		assert.Equal(t, 2, n)
+		assert.GreaterOrEqual(t, time.Since(begin), 30*time.Millisecond)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		buf := capture(
+			CaptureRecord{Time: start, Dir: CaptureIn, Data: "Event: Hangup\r\n\r\n"},
+			CaptureRecord{Time: start.Add(time.Hour), Dir: CaptureIn, Data: "Event: Hangup\r\n\r\n"},
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := Replay(ctx, buf, true, func(*Message) {})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		err = Replay(ctx, capture(CaptureRecord{Dir: CaptureIn}), false, func(*Message) {})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("invalid capture", func(t *testing.T) {
+		err := Replay(context.Background(), strings.NewReader("{foo"), false, func(*Message) {})
+		assert.ErrorIs(t, err, ErrAMI)
+	})
+
+	t.Run("invalid packet", func(t *testing.T) {
+		buf := capture(CaptureRecord{Time: start, Dir: CaptureIn, Data: "Event: MoH\r\nChan\r\n\r\n"})
+		err := Replay(context.Background(), buf, false, func(*Message) {})
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr)
+		assert.Equal(t, "Event: MoH\r\nChan\r\n\r\n", string(perr.Raw))
+	})
+}
@@ -0,0 +1,246 @@
+// Package cdr collects Cdr and CEL events of the calls and emits completed
+// call records when the calls end. Events are grouped by linkedid, the call
+// ends with CEL event LINKEDID_END. Asterisk must have cdr_manager and
+// cel_manager enabled and LINKEDID_END included in cel.conf events.
+package cdr
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// DefaultGrace is the default time to wait for Cdr events after the call end
+const DefaultGrace = 2 * time.Second
+
+// events consumed by the collector
+var recordEvents = []string{"Cdr", "CEL"}
+
+// CEL is the channel event logging record of "Event: CEL"
+type CEL struct {
+	EventName    string `json:"event_name"` // like "CHAN_START", "ANSWER" or "HANGUP"
+	EventTime    string `json:"event_time"`
+	AccountCode  string `json:"account_code,omitempty"`
+	CallerIDNum  string `json:"caller_id_num,omitempty"`
+	CallerIDName string `json:"caller_id_name,omitempty"`
+	Exten        string `json:"exten,omitempty"`
+	Context      string `json:"context,omitempty"`
+	Channel      string `json:"channel"`
+	Application  string `json:"application,omitempty"`
+	AppData      string `json:"app_data,omitempty"`
+	UniqueID     string `json:"uniqueid"`
+	LinkedID     string `json:"linkedid"`
+	Peer         string `json:"peer,omitempty"`
+	UserField    string `json:"user_field,omitempty"`
+	Extra        string `json:"extra,omitempty"`
+}
+
+// Call is the completed record of the call
+type Call struct {
+	Linkedid string            `json:"linkedid"`
+	Start    time.Time         `json:"start"` // time of the first CEL event, zero when unknown
+	End      time.Time         `json:"end"`   // time of LINKEDID_END event, zero when unknown
+	CDRs     []goami2.CdrEvent `json:"cdrs"`
+	Events   []CEL             `json:"events"` // in received order
+}
+
+// Collector groups Cdr and CEL events of the calls and sends completed
+// calls to the sink. Collector is safe for concurrent use.
+type Collector struct {
+	// Grace is the time to wait for Cdr events after LINKEDID_END event,
+	// and after the last Cdr event of the call without CEL events. Zero
+	// value sends the call to the sink immediately. Must be set before
+	// the first event.
+	Grace time.Duration
+
+	mu      sync.Mutex
+	sink    Sink
+	calls   map[string]*pending // by linkedid
+	linked  map[string]string   // linkedid by uniqueid
+	onError []func(error)
+}
+
+type pending struct {
+	call  Call
+	ended bool
+	timer *time.Timer
+}
+
+// New creates collector that sends completed calls to the sink
+func New(sink Sink) *Collector {
+	return &Collector{
+		Grace:  DefaultGrace,
+		sink:   sink,
+		calls:  make(map[string]*pending),
+		linked: make(map[string]string),
+	}
+}
+
+// Attach registers collector as AMI client event handler. Handler can be
+// removed with Client.RemoveHandler.
+func (c *Collector) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.OnEvents(recordEvents, c.Handle)
+}
+
+// OnError registers handler called with errors returned by the sink
+func (c *Collector) OnError(fn func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onError = append(c.onError, fn)
+}
+
+// Handle adds Cdr or CEL event to the call record. Other events are ignored.
+// Cdr event is matched to the call by "LinkedID" header when cdr_manager
+// custom fields include it, or by "UniqueID" of the channel seen in CEL
+// events, otherwise "UniqueID" is used as linkedid.
+func (c *Collector) Handle(msg *goami2.Message) {
+	switch strings.ToLower(msg.Field("Event")) {
+	case "cel":
+		ev, err := goami2.Decode[CEL](msg)
+		if err != nil || ev.LinkedID == "" {
+			return
+		}
+		c.addCEL(ev)
+	case "cdr":
+		ev, err := goami2.Decode[goami2.CdrEvent](msg)
+		if err != nil {
+			return
+		}
+		c.addCDR(msg.Field("LinkedID"), ev)
+	}
+}
+
+// Pending returns number of the calls that are not sent to the sink
+func (c *Collector) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.calls)
+}
+
+// Flush sends all collected calls to the sink, ended or not. Used before
+// shutdown, to not lose the calls in progress.
+func (c *Collector) Flush() {
+	c.mu.Lock()
+	calls := make([]*pending, 0, len(c.calls))
+	for _, p := range c.calls {
+		calls = append(calls, p)
+	}
+	c.mu.Unlock()
+	for _, p := range calls {
+		c.complete(p.call.Linkedid)
+	}
+}
+
+func (c *Collector) addCEL(ev CEL) {
+	c.mu.Lock()
+	p := c.call(ev.LinkedID)
+	if ev.UniqueID != "" {
+		c.linked[ev.UniqueID] = ev.LinkedID
+	}
+	p.call.Events = append(p.call.Events, ev)
+	if !p.ended && p.timer != nil {
+		// Cdr event came before CEL events, wait for the call end
+		p.timer.Stop()
+		p.timer = nil
+	}
+	t, ok := parseTime(ev.EventTime)
+	if ok && p.call.Start.IsZero() {
+		p.call.Start = t
+	}
+	now := false
+	if ev.EventName == "LINKEDID_END" {
+		p.call.End = t
+		p.ended = true
+		now = c.schedule(p)
+	}
+	c.mu.Unlock()
+	if now {
+		c.complete(ev.LinkedID)
+	}
+}
+
+func (c *Collector) addCDR(linkedid string, ev goami2.CdrEvent) {
+	c.mu.Lock()
+	if linkedid == "" {
+		linkedid = c.linked[ev.UniqueID]
+	}
+	if linkedid == "" {
+		linkedid = ev.UniqueID
+	}
+	p := c.call(linkedid)
+	p.call.CDRs = append(p.call.CDRs, ev)
+	now := false
+	if p.ended || len(p.call.Events) == 0 {
+		now = c.schedule(p)
+	}
+	c.mu.Unlock()
+	if now {
+		c.complete(linkedid)
+	}
+}
+
+// call returns pending call of the linkedid
+func (c *Collector) call(linkedid string) *pending {
+	p, ok := c.calls[linkedid]
+	if !ok {
+		p = &pending{call: Call{Linkedid: linkedid}}
+		c.calls[linkedid] = p
+	}
+	return p
+}
+
+// schedule sends the call to the sink after grace time. Returns true when
+// grace time is not set and the call must be sent immediately.
+func (c *Collector) schedule(p *pending) bool {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if c.Grace <= 0 {
+		return true
+	}
+	id := p.call.Linkedid
+	p.timer = time.AfterFunc(c.Grace, func() { c.complete(id) })
+	return false
+}
+
+// complete removes the call and sends it to the sink
+func (c *Collector) complete(linkedid string) {
+	c.mu.Lock()
+	p, ok := c.calls[linkedid]
+	if ok {
+		delete(c.calls, linkedid)
+		for _, ev := range p.call.Events {
+			delete(c.linked, ev.UniqueID)
+		}
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+	handlers := c.onError
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := c.sink.Record(p.call); err != nil {
+		for _, fn := range handlers {
+			fn(err)
+		}
+	}
+}
+
+// parseTime parses CEL event time as unix time with microseconds, the
+// default format, or as "2006-01-02 15:04:05" when cel.conf dateformat is set
+func parseTime(s string) (time.Time, bool) {
+	sec, frac, _ := strings.Cut(s, ".")
+	if n, err := strconv.ParseInt(sec, 10, 64); err == nil {
+		nsec, _ := strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+		return time.Unix(n, nsec), true
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,153 @@
+package cdr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+func cel(t *testing.T, name, uniqueid, eventTime string) *goami2.Message {
+	return event(t, "Event: CEL\r\nPrivilege: call,all\r\nEventName: "+name+"\r\n"+
+		"EventTime: "+eventTime+"\r\nCallerIDnum: 100\r\nChannel: PJSIP/"+uniqueid+"\r\n"+
+		"UniqueID: "+uniqueid+"\r\nLinkedID: 1598887690.70\r\n")
+}
+
+func cdrEvent(t *testing.T, uniqueid string) *goami2.Message {
+	return event(t, "Event: Cdr\r\nPrivilege: cdr,all\r\nSource: 100\r\nDestination: 200\r\n"+
+		"Channel: PJSIP/"+uniqueid+"\r\nDuration: 30\r\nBillableSeconds: 25\r\n"+
+		"Disposition: ANSWERED\r\nUniqueID: "+uniqueid+"\r\n")
+}
+
+func TestCollector(t *testing.T) {
+	var calls []Call
+	col := New(SinkFunc(func(call Call) error {
+		calls = append(calls, call)
+		return nil
+	}))
+	col.Grace = 0
+
+	col.Handle(cel(t, "CHAN_START", "1598887690.70", "1598887690.100000"))
+	col.Handle(cel(t, "CHAN_START", "1598887690.80", "1598887691.200000"))
+	col.Handle(cel(t, "HANGUP", "1598887690.80", "1598887720.000000"))
+	col.Handle(cdrEvent(t, "1598887690.80"))
+	col.Handle(event(t, "Event: Newchannel\r\nChannel: PJSIP/100-01\r\n"))
+	assert.Empty(t, calls)
+	assert.Equal(t, 1, col.Pending())
+
+	col.Handle(cel(t, "LINKEDID_END", "1598887690.70", "1598887721.500000"))
+	assert.Len(t, calls, 1)
+	assert.Equal(t, 0, col.Pending())
+	call := calls[0]
+	assert.Equal(t, "1598887690.70", call.Linkedid)
+	assert.Equal(t, time.Unix(1598887690, 100000000), call.Start)
+	assert.Equal(t, time.Unix(1598887721, 500000000), call.End)
+	assert.Len(t, call.Events, 4)
+	assert.Equal(t, "100", call.Events[0].CallerIDNum)
+	assert.Len(t, call.CDRs, 1)
+	assert.Equal(t, 30*time.Second, call.CDRs[0].Duration)
+	assert.Equal(t, "ANSWERED", call.CDRs[0].Disposition)
+}
+
+func TestCollectorGrace(t *testing.T) {
+	calls := make(chan Call, 2)
+	col := New(ChanSink(calls))
+	col.Grace = 20 * time.Millisecond
+
+	col.Handle(cel(t, "CHAN_START", "1598887690.70", "2020-08-31 12:00:00"))
+	col.Handle(cel(t, "LINKEDID_END", "1598887690.70", "2020-08-31 12:01:00"))
+	// Cdr event after the call end
+	col.Handle(cdrEvent(t, "1598887690.70"))
+	// call with Cdr event only
+	col.Handle(event(t, "Event: Cdr\r\nUniqueID: 1598887700.10\r\nLinkedID: 1598887700.05\r\n"))
+
+	got := map[string]Call{}
+	for i := 0; i < 2; i++ {
+		select {
+		case call := <-calls:
+			got[call.Linkedid] = call
+		case <-time.After(time.Second):
+			t.Fatal("call is not completed")
+		}
+	}
+	assert.Len(t, got["1598887690.70"].CDRs, 1)
+	assert.Equal(t, time.Minute, got["1598887690.70"].End.Sub(got["1598887690.70"].Start))
+	assert.Len(t, got["1598887700.05"].CDRs, 1)
+	assert.Empty(t, got["1598887700.05"].Events)
+}
+
+func TestCollectorFlush(t *testing.T) {
+	var errs []error
+	var calls []Call
+	col := New(SinkFunc(func(call Call) error {
+		calls = append(calls, call)
+		return errors.New("sink failed")
+	}))
+	col.OnError(func(err error) { errs = append(errs, err) })
+
+	col.Handle(cel(t, "CHAN_START", "1598887690.70", "1598887690.100000"))
+	// Cdr event of the call in progress does not complete it
+	col.Handle(cdrEvent(t, "1598887690.70"))
+	col.Handle(event(t, "Event: CEL\r\nEventName: CHAN_START\r\n"))
+	assert.Equal(t, 1, col.Pending())
+
+	col.Flush()
+	assert.Equal(t, 0, col.Pending())
+	assert.Len(t, calls, 1)
+	assert.Zero(t, calls[0].End)
+	assert.EqualError(t, errs[0], "sink failed")
+}
+
+func TestCollectorAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	var mu sync.Mutex
+	var calls []Call
+	col := New(SinkFunc(func(call Call) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+		return nil
+	}))
+	col.Grace = 0
+	col.Attach(cl)
+	const total = 150
+	var burst strings.Builder
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("1598887690.%d", i)
+		fmt.Fprintf(&burst, "Event: CEL\r\nEventName: CHAN_START\r\nChannel: PJSIP/%s\r\nUniqueID: %s\r\nLinkedID: %s\r\n\r\n", id, id, id)
+		fmt.Fprintf(&burst, "Event: Cdr\r\nChannel: PJSIP/%s\r\nDisposition: ANSWERED\r\nUniqueID: %s\r\n\r\n", id, id)
+		fmt.Fprintf(&burst, "Event: CEL\r\nEventName: LINKEDID_END\r\nChannel: PJSIP/%s\r\nUniqueID: %s\r\nLinkedID: %s\r\n\r\n", id, id, id)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls) == total
+	}, 5*time.Second, time.Millisecond)
+	mu.Lock()
+	for _, call := range calls {
+		assert.Len(t, call.CDRs, 1)
+		assert.Len(t, call.Events, 2)
+	}
+	mu.Unlock()
+	assert.Zero(t, col.Pending())
+	assert.Zero(t, cl.DroppedMessages())
+}
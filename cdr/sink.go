@@ -0,0 +1,48 @@
+package cdr
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Sink receives completed calls of the collector
+type Sink interface {
+	Record(call Call) error
+}
+
+// SinkFunc is the function used as a sink
+type SinkFunc func(call Call) error
+
+// Record calls f(call)
+func (f SinkFunc) Record(call Call) error {
+	return f(call)
+}
+
+// ChanSink sends calls to the channel. Record blocks until the call is
+// received.
+type ChanSink chan<- Call
+
+// Record sends call to the channel
+func (ch ChanSink) Record(call Call) error {
+	ch <- call
+	return nil
+}
+
+// JSONSink writes calls to the writer as JSON record per line
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONSink creates sink writing to w
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+// Record writes call as a JSON line
+func (s *JSONSink) Record(call Call) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(call)
+}
@@ -0,0 +1,36 @@
+package cdr
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/staskobzar/goami2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewJSONSink(buf)
+	call := Call{
+		Linkedid: "1598887690.70",
+		CDRs:     []goami2.CdrEvent{{Source: "100", Disposition: "ANSWERED"}},
+		Events:   []CEL{{EventName: "LINKEDID_END", UniqueID: "1598887690.70"}},
+	}
+	assert.Nil(t, sink.Record(call))
+	assert.Nil(t, sink.Record(Call{Linkedid: "1598887700.05"}))
+
+	dec := json.NewDecoder(buf)
+	var got Call
+	assert.Nil(t, dec.Decode(&got))
+	assert.Equal(t, call, got)
+	assert.Nil(t, dec.Decode(&got))
+	assert.Equal(t, "1598887700.05", got.Linkedid)
+	assert.False(t, dec.More())
+}
+
+func TestChanSink(t *testing.T) {
+	ch := make(chan Call, 1)
+	assert.Nil(t, ChanSink(ch).Record(Call{Linkedid: "1598887690.70"}))
+	assert.Equal(t, "1598887690.70", (<-ch).Linkedid)
+}
@@ -2,76 +2,495 @@ package goami2
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Client is a AMI connection management object
+// Client is a AMI connection management object.
+// Client is safe for concurrent use by multiple goroutines. Every message
+// is written to the connection atomically.
 type Client struct {
-	mu      sync.Mutex
-	conn    net.Conn
-	recv    chan *Message
-	err     chan error
-	timeout time.Duration // connection read/write timeout
+	mu       sync.Mutex
+	wmu      sync.Mutex // serializes writes to the connection
+	conn     net.Conn
+	buffered []byte // read from conn on login and not consumed yet
+	recv     chan *Message
+	err      chan error
+	timeout  time.Duration // connection read/write timeout
+	closed   bool
+	draining bool           // new actions are rejected, see Drain
+	inflight sync.WaitGroup // actions waiting for the response or list events
+
+	username string
+	password string
+
+	dial       func(ctx context.Context) (net.Conn, error)
+	dialer     Dialer
+	tlsConfig  *tls.Config
+	reconnect  bool
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	resync     []*Message     // actions sent after reconnect
+	outq       *outboundQueue // actions sent while reconnecting, nil when disabled
+
+	subs        map[<-chan *Message]*subscription
+	handlers    map[HandlerID]*eventHandler
+	handlerBuf  int // event handlers buffer size
+	lastHandler HandlerID
+	pending     map[string]chan *Message // actions waiting for response by ActionID
+	expired     []string                 // abandoned ActionIDs which late responses are dropped
+	lists       map[string]*eventList    // actions collecting list events by ActionID
+	filters     []string                 // event filters of the session
+	replay      *replayBuffer            // recent events for late subscribers, nil when disabled
+	commands    map[string]string        // permitted actions by lower case name with privileges, nil when not loaded
+
+	diag          chan Diagnostic // events not matching the schema, nil when validation is disabled
+	schema        Schema          // schema with lower case event names, bundled schema when nil
+	schemaUnknown bool            // report events missing in the schema
+	versionSchema Schema          // bundled schema of the connection AMI version
+	schemaVersion AMIVersion
+
+	actionTimeout time.Duration
+	idPrefix      string        // prefix of generated ActionIDs
+	idFunc        func() string // ActionID generator, session counter when nil
+	idSession     string        // random part of counter ActionIDs
+	idOnce        sync.Once
+	idSeq         atomic.Uint64
+
+	loginAttempts int
+	loginInterval time.Duration
+	authMD5       bool
+	eventsMask    string // "Events" header of login action
+	fallbackCreds []Credentials
+	credProvider  CredentialsProvider
+	discover      bool // load permitted actions after login
+
+	keepAlive        time.Duration
+	keepAliveTimeout time.Duration // Ping response deadline, network timeout when zero
+	staleTimeout     time.Duration // inbound silence before connection is considered dead
+	logger           *slog.Logger
+	metrics          Metrics
+	tracer           Tracer
+	capture          *capture // records connection data when not nil
+	limits           Limits   // inbound packets limits, defaults when zero
+	splitGlued       bool     // split packets glued without empty line
+
+	banner  string     // AMI prompt received on connect
+	version AMIVersion // AMI version of the banner
+
+	limiter    *limiter
+	middleware []func(next SendFunc) SendFunc
+	inbound    []func(next ReceiveFunc) ReceiveFunc
+
+	privileges []string // allowed events privileges
+
+	pmu         sync.Mutex // guards pause state and keeps messages order on resume
+	paused      bool
+	held        []*Message // messages received while paused
+	pauseBuffer int
+	overflow    OverflowPolicy
+	dropped     atomic.Uint64
+	droppedErrs atomic.Uint64
+
+	stop     chan struct{} // closed by Close to stop reading loop
+	done     chan struct{} // closed when reading loop stops
+	doneOnce sync.Once
+	running  atomic.Bool // reading loop is started
+
+	state        atomic.Int32 // ConnState
+	smu          sync.Mutex   // guards state changes
+	stateSubs    []chan StateChange
+	lastActivity atomic.Int64 // unix nano time of the last read
 }
 
+// OverflowPolicy defines what to do with incoming messages when
+// messages channel buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for the consumer to read the messages channel
+	// and drops the message when it takes too long
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the channel buffer
+	OverflowDropOldest
+	// OverflowDropNewest drops the incoming message
+	OverflowDropNewest
+)
+
 // Action sends AMI action to an Asterisk server
 // Returns true on success and false if fails
 // This function is deprecated and will be removed
 // Use Send or MustSend instead
 func (c *Client) Action(action *Message) bool {
-	if err := c.MustSend(action.Byte()); err != nil {
+	c.stampActionID(action)
+	if err := c.send(action); err != nil {
 		return false
 	}
 	return true
 }
 
 // AllMessages returns a channel that receives any AMI messages
-// from the client connection
+// from the client connection. All callers share the same channel, so each
+// message is received by one of them. Use SubscribeAll for independent
+// consumers.
 func (c *Client) AllMessages() <-chan *Message {
 	return c.recv
 }
 
-// Close client
+// Close client. Close stops reading loop and waits for it to exit before closing
+// the messages and errors channels, so no more messages are sent to the channels
+// after Close returns. Messages still buffered in the channels are discarded.
+// Actions waiting for the response fail with ErrClosed.
 func (c *Client) Close() {
+	c.setState(StateClosed, nil)
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.closed = true
 	if c.conn != nil {
 		_ = c.conn.Close()
 		c.conn = nil
 	}
-	if !isClosedChan(c.recv) {
-		close(c.recv)
-		c.recv = nil
+	if !isClosedChan(c.stop) {
+		close(c.stop)
+	}
+	c.mu.Unlock()
+
+	if c.running.Load() {
+		<-c.done
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drainClose(c.recv)
+	c.recv = nil
+	drainClose(c.err)
+	c.err = nil
+	drainClose(c.diag)
+	c.diag = nil
+	for ch, sub := range c.subs {
+		close(sub.ch)
+		delete(c.subs, ch)
+	}
+	c.stopHandlers()
+}
+
+// Shutdown gracefully closes client. It sends "Action: Logoff" and waits for
+// the "Goodbye" response bounded by the context before closing the client.
+// Returns context error if AMI server never acknowledges the logoff.
+// If connection is already dead or server closes it before responding then
+// client is closed right away. Actions still waiting for the response fail
+// with ErrClosed.
+func (c *Client) Shutdown(ctx context.Context) error {
+	defer c.Close()
+	c.mu.Lock()
+	c.closed = true // stop reconnecting when server closes connection
+	c.mu.Unlock()
+
+	resp, err := c.request(withoutRateLimit(ctx), NewAction("Logoff"))
+	if err != nil {
+		if errors.Is(err, ErrConn) || errors.Is(err, ErrClosed) {
+			return nil
+		}
+		return err
 	}
-	if !isClosedChan(c.err) {
-		close(c.err)
-		c.err = nil
+	switch strings.ToLower(resp.Field("Response")) {
+	case "goodbye", "success":
+		return nil
+	default:
+		return rejected(resp, "failed logoff")
+	}
+}
+
+// drop counts and logs dropped message
+func (c *Client) drop(msg *Message, reason string) {
+	total := c.dropped.Add(1)
+	c.extendedMetrics().IncMessagesDropped()
+	if c.logDebug() {
+		c.logger.Debug("dropped message", "reason", reason, "event", msg.Field("Event"),
+			"actionid", msg.ActionID(), "dropped", total)
 	}
 }
 
-// Err returns channel of errors of the client
+// DroppedMessages returns number of messages dropped because
+// messages channel, subscription or event handler buffer was full or
+// client was paused
+func (c *Client) DroppedMessages() uint64 {
+	return c.dropped.Load()
+}
+
+// DroppedErrors returns number of errors dropped because errors channel
+// buffer was full
+func (c *Client) DroppedErrors() uint64 {
+	return c.droppedErrs.Load()
+}
+
+// LoginContext sends login action with new credentials over running client
+// session and waits for the response until context is done. Returns ctx.Err()
+// when context is done. New credentials are used for the reconnect login.
+func (c *Client) LoginContext(ctx context.Context, username, password string) error {
+	login := NewAction("Login")
+	login.AddField("Username", username)
+	login.AddField("Secret", password)
+	resp, err := c.request(ctx, login)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "failed login")
+	}
+
+	c.mu.Lock()
+	c.username, c.password = username, password
+	c.mu.Unlock()
+	return nil
+}
+
+// LoginWithRetry runs AMI handshake, reads prompt and sends login, on the client
+// connection and retries up to attempts times with interval delay when login
+// is rejected or connection fails, for example when Asterisk has not loaded
+// manager configuration yet. Connection is redialed before each retry.
+// It does not retry on unexpected prompt or malformed response which means
+// the server is not AMI. Returns context error when context is done.
+// Constructors use it with WithLoginRetry option. Returns error if
+// client is already running.
+func (c *Client) LoginWithRetry(ctx context.Context, username, password string,
+	attempts int, interval time.Duration) error {
+	if c.running.Load() {
+		return fmt.Errorf("%w: client is running", ErrConn)
+	}
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+			c.closeConn()
+			conn, derr := c.dial(ctx)
+			if derr != nil {
+				err = derr
+				c.logger.Warn("failed to dial login", "attempt", i+1, "error", err)
+				continue
+			}
+			if !c.setConn(conn) {
+				return fmt.Errorf("%w: client closed", ErrConn)
+			}
+		}
+		if err = c.loginAny(ctx, username, password); err == nil || !retryLogin(ctx, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryLogin returns true if login can be retried after error
+func retryLogin(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var perr *ProtocolError
+	if errors.As(err, &perr) {
+		return perr.Response != nil // rejected login, not wrong prompt
+	}
+	return errors.Is(err, ErrConn)
+}
+
+// SendAction sends action and waits for the response until context is done.
+// Unique ActionID is added to the action if it does not have one and the
+// response with the same ActionID is returned. The response is not sent
+// to the AllMessages channel.
+// When context has no deadline the action timeout set by WithActionTimeout
+// is applied and ErrActionTimeout is returned if there is no response in time.
+func (c *Client) SendAction(ctx context.Context, action *Message) (*Message, error) {
+	if _, ok := ctx.Deadline(); ok || c.actionTimeout <= 0 {
+		return c.request(ctx, action)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.actionTimeout)
+	defer cancel()
+	resp, err := c.request(ctx, action)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: ActionID %q: %w", ErrActionTimeout, action.ActionID(), err)
+	}
+	return resp, err
+}
+
+// Err returns channel of errors of the client. Errors are delivered without
+// blocking the reading loop and the oldest errors are dropped when channel
+// buffer is full, see WithErrorBuffer.
 func (c *Client) Err() <-chan error {
 	return c.err
 }
 
 // MustSend sends a message to nework and returns
 // network errors if any write away. May block
-// until network timeout
+// until network timeout. Safe to call from multiple goroutines,
+// messages are written one by one. Use SendContext to bound
+// the write by context.
 func (c *Client) MustSend(msg []byte) error {
-	if c.conn == nil {
+	if err := c.acceptSend(); err != nil {
+		return err
+	}
+	if !c.hasMiddleware() && c.outq == nil {
+		return c.limitWrite(msg)
+	}
+	action, err := Parse(string(msg))
+	if err != nil {
+		return err
+	}
+	return c.send(action)
+}
+
+// SendContext sends action without waiting for the response. Writing is
+// bound by the context deadline instead of the network timeout and is aborted
+// when context is done. Same as other send methods, it is safe to call from
+// multiple goroutines: actions are written to the connection one by one
+// and never interleave. Connection is closed when action is written
+// partially, since AMI framing can not be recovered.
+func (c *Client) SendContext(ctx context.Context, action *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.acceptSend(); err != nil {
+		return err
+	}
+	return c.chain(func(action *Message) error {
+		c.stampActionID(action)
+		return c.queueOrWrite(action, func(action *Message) error {
+			return c.limitWriteContext(ctx, action.Byte())
+		})
+	})(action)
+}
+
+// send action through middleware chain
+func (c *Client) send(action *Message) error {
+	if err := c.acceptSend(); err != nil {
+		return err
+	}
+	return c.chain(func(action *Message) error {
+		return c.queueOrWrite(action, func(action *Message) error {
+			return c.limitWrite(action.Byte())
+		})
+	})(action)
+}
+
+// stampActionID adds generated ActionID to the action that does not have one
+func (c *Client) stampActionID(action *Message) {
+	if action.ActionID() == "" {
+		_ = action.SetField("ActionID", c.nextActionID())
+	}
+}
+
+// nextActionID returns ActionID with the client prefix. Unless WithActionIDFunc
+// is used, ActionID is a random session part with the counter of the client
+// actions, which keeps growing over reconnects so ids are never reused.
+func (c *Client) nextActionID() string {
+	if c.idFunc != nil {
+		return c.idPrefix + stripLineBreaks(c.idFunc())
+	}
+	c.idOnce.Do(func() {
+		buf := make([]byte, 4)
+		_, _ = rand.Read(buf)
+		c.idSession = hex.EncodeToString(buf)
+	})
+	return fmt.Sprintf("%s%s-%d", c.idPrefix, c.idSession, c.idSeq.Add(1))
+}
+
+// limitWrite waits for the rate limit and writes message to the connection
+func (c *Client) limitWrite(msg []byte) error {
+	if c.limiter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+		if err := c.limiter.wait(ctx); err != nil {
+			return fmt.Errorf("%w: rate limit: %w", ErrConn, err)
+		}
+	}
+	return c.write(msg)
+}
+
+// limitWriteContext waits for the rate limit and writes message to the
+// connection until context is done
+func (c *Client) limitWriteContext(ctx context.Context, msg []byte) error {
+	if c.limiter != nil {
+		if err := c.limiter.wait(ctx); err != nil {
+			return fmt.Errorf("%w: rate limit: %w", ErrConn, err)
+		}
+	}
+	return c.writeContext(ctx, msg)
+}
+
+// write message to the connection
+func (c *Client) write(msg []byte) error {
+	return c.writeContext(context.Background(), msg)
+}
+
+// writeContext writes message to the connection. Write deadline is the
+// context deadline or network timeout when context has no deadline.
+func (c *Client) writeContext(ctx context.Context, msg []byte) error {
+	conn := c.getConn()
+	if conn == nil {
 		return fmt.Errorf("%w: closed connection: failed to send message", ErrConn)
 	}
-	if err := c.setWTimeout(); err != nil {
-		return fmt.Errorf("%w: failed to set net timeout: %q", ErrConn, err)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.timeout)
 	}
-	if _, err := c.conn.Write(msg); err != nil {
-		return fmt.Errorf("%w: failed send message: %q", ErrConn, err)
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("%w: failed to set net timeout: %w", ErrConn, err)
 	}
+
+	// abort blocked write when context is done
+	var mu sync.Mutex
+	written := false
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !written {
+			_ = conn.SetWriteDeadline(time.Unix(1, 0))
+		}
+	})
+	defer stop()
+
+	c.logAction(msg)
+	n, err := conn.Write(msg)
+	mu.Lock()
+	written = true
+	mu.Unlock()
+	if err != nil {
+		if n > 0 {
+			c.closeConn()
+		}
+		ctxErr := ctx.Err()
+		if ctxErr == nil && ok && errors.Is(err, os.ErrDeadlineExceeded) {
+			// write deadline may expire just before the context timer
+			ctxErr = context.DeadlineExceeded
+		}
+		if ctxErr != nil {
+			return fmt.Errorf("%w: failed send message: %w: %w", ErrConn, ctxErr, err)
+		}
+		return fmt.Errorf("%w: failed send message: %w", ErrConn, err)
+	}
+	c.metrics.IncActionsSent()
 	return nil
 }
 
@@ -86,134 +505,770 @@ func (c *Client) Send(msg []byte) {
 
 // creates Client with default values
 func makeClient(conn net.Conn) *Client {
-	return &Client{
+	c := &Client{
 		conn:    conn,
-		recv:    make(chan *Message, 12),
-		err:     make(chan error, 1),
+		recv:    make(chan *Message, chanBuffer),
+		err:     make(chan error, chanBuffer),
 		timeout: netTimeout,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		dialer:  &net.Dialer{},
+		logger:  slog.New(nopHandler{}),
+		metrics: nopMetrics{},
+
+		handlerBuf: handlerBuffer,
+	}
+	if conn != nil {
+		addr := conn.RemoteAddr()
+		c.dial = c.dialAddr(addr.Network(), addr.String())
 	}
+	return c
+}
+
+// start reading loop
+func (c *Client) start(ctx context.Context) {
+	c.running.Store(true)
+	go c.loop(ctx)
 }
 
 // main consumer loop that reads from connection
 func (c *Client) loop(ctx context.Context) {
-	chPack, errConn := consume(c.conn)
+	c.running.Store(true)
+	defer c.doneOnce.Do(func() { close(c.done) })
+	defer c.setState(StateClosed, nil)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// abort reconnect when client is closed
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		err := c.read(ctx)
+		c.failPending()
+		if err == nil || c.isClosed() {
+			return
+		}
+		if !c.reconnect {
+			c.setState(StateClosed, err)
+			c.emitErr(err)
+			return
+		}
+		c.logger.Warn("connection lost", "error", err)
+		c.setState(StateReconnecting, err)
+		c.emitMsg(eventDisconnected(err))
+		if err := c.redial(ctx); err != nil {
+			c.setState(StateClosed, err)
+			c.emitErr(err)
+			return
+		}
+		c.metrics.IncReconnects()
+		c.emitMsg(eventReconnected())
+		c.refilter()
+		if c.discover {
+			go c.reloadPermissions(ctx)
+		}
+		for _, action := range c.resync {
+			if err := c.send(action.Clone()); err != nil {
+				c.logger.Warn("failed to resync", "action", action.Field("Action"), "error", err)
+			}
+		}
+		c.flushQueue()
+	}
+}
+
+// allowed returns false for events which privileges do not match
+// privilege filter
+func (c *Client) allowed(msg *Message) bool {
+	if len(c.privileges) == 0 || !msg.IsEvent() {
+		return true
+	}
+	for _, p := range msg.Privileges() {
+		for _, allowed := range c.privileges {
+			if strings.EqualFold(p, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lostErr returns error of the action which response was not received because
+// connection was lost or client was closed
+func (c *Client) lostErr() error {
+	switch {
+	case c.isClosed():
+		return ErrClosed
+	case c.reconnect:
+		return ErrReconnecting
+	}
+	return fmt.Errorf("%w: connection lost", ErrEOF)
+}
+
+// failPending releases all actions waiting for response when connection is lost
+func (c *Client) failPending() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	for id, list := range c.lists {
+		close(list.done)
+		delete(c.lists, id)
+	}
+}
+
+// read messages from current connection until it fails.
+// Returns nil when context is done
+func (c *Client) read(ctx context.Context) error {
+	conn := c.getConn()
+	if conn == nil {
+		return fmt.Errorf("%w: closed connection", ErrEOF)
+	}
+	chPack, errConn := consume(conn, c.takeBuffered(), c.limits.withDefaults(), c.splitGlued)
+
+	connCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	errKeepAlive := make(chan error, 2)
+	if c.keepAlive > 0 {
+		go c.keepalive(connCtx, errKeepAlive)
+	}
+	if c.staleTimeout > 0 {
+		go c.watchdog(connCtx, errKeepAlive)
+	}
+
 	for {
 		select {
-		case pack := <-chPack:
-			msg, err := Parse(pack)
+		case in := <-chPack:
+			c.touch()
+			if in.err != nil {
+				c.logger.Warn("discarded message", "error", in.err)
+				c.extendedMetrics().IncParseErrors()
+				c.emitErr(in.err)
+				continue
+			}
+			pack := in.data
+			msg, err := parsePacket(pack)
 			if err != nil {
+				c.logger.Warn("failed to parse message", "error", err, "packet", pack)
+				c.extendedMetrics().IncParseErrors()
 				c.emitErr(err)
 				continue
 			}
-			c.emitMsg(msg)
+			c.chainInbound(c.dispatch)(msg)
 		case <-ctx.Done():
-			c.emitErr(ErrEOF)
-			return
+			if !c.isClosed() {
+				c.emitErr(ErrEOF)
+			}
+			return nil
 		case err := <-errConn:
-			c.emitErr(err)
+			return err
+		case err := <-errKeepAlive:
+			c.closeConn()
+			drain(chPack, errConn)
+			return err
+		}
+	}
+}
+
+// dispatch received message to the waiting action, subscribers or
+// the messages channel
+func (c *Client) dispatch(msg *Message) {
+	c.logMessage(msg)
+	if msg.IsEvent() {
+		c.metrics.IncEventsReceived(eventName(msg))
+		c.checkSchema(msg)
+	}
+	if c.deliver(msg) || !c.allowed(msg) {
+		return
+	}
+	c.forward(msg)
+}
+
+// request sends action and waits for the response with the same ActionID.
+// ActionID is added to the action if it does not have one.
+// Response is not sent to the AllMessages channel.
+func (c *Client) request(ctx context.Context, action *Message) (*Message, error) {
+	return c.roundTrip(ctx, action, nil)
+}
+
+// roundTrip sends action and waits for the response. When list is given it
+// is registered with the response waiter to collect events of the action
+func (c *Client) roundTrip(ctx context.Context, action *Message, list *eventList) (resp *Message, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	release, err := c.accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if list != nil {
+		list.release = release // list is in flight until dropped
+	} else {
+		defer release()
+	}
+	c.stampActionID(action)
+	if c.tracer != nil {
+		finish := c.tracer.StartAction(ctx, action)
+		defer func() { finish(resp, err) }()
+	}
+	var id string
+	var sent time.Time
+	ch := make(chan *Message, 1)
+
+	// waiter is registered after middleware so it can change ActionID
+	err = c.chain(func(action *Message) error {
+		c.stampActionID(action)
+		id = action.ActionID()
+		c.mu.Lock()
+		if c.pending == nil {
+			c.pending = make(map[string]chan *Message)
+		}
+		c.pending[id] = ch
+		if list != nil {
+			if c.lists == nil {
+				c.lists = make(map[string]*eventList)
+			}
+			c.lists[id] = list
+		}
+		c.mu.Unlock()
+
+		if c.limiter != nil && !rateExempt(ctx) {
+			if err := c.limiter.wait(ctx); err != nil {
+				return err
+			}
+		}
+		sent = time.Now()
+		return c.writeContext(ctx, action.Byte())
+	})(action)
+
+	defer func() {
+		if id == "" {
 			return
 		}
+		c.mu.Lock()
+		if _, ok := c.pending[id]; ok && ctx.Err() != nil {
+			c.expire(id)
+		}
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return nil, c.lostErr()
+		}
+		c.metrics.ObserveActionLatency(time.Since(sent))
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deliver response to the action waiting for it. Returns false if
+// there is no action waiting for the message
+func (c *Client) deliver(msg *Message) bool {
+	if msg.IsEvent() {
+		return c.collect(msg)
+	}
+	if !msg.IsResponse() {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[msg.ActionID()]
+	if !ok {
+		return c.dropExpired(msg.ActionID())
+	}
+	delete(c.pending, msg.ActionID())
+	ch <- msg
+	return true
+}
+
+// expire remembers ActionID of the abandoned action, so the late response
+// is dropped. Keeps last expiredMax ids. Must be called with mu locked.
+func (c *Client) expire(id string) {
+	if len(c.expired) == expiredMax {
+		c.expired = c.expired[1:]
+	}
+	c.expired = append(c.expired, id)
+}
+
+// dropExpired returns true and forgets ActionID if it belongs to
+// abandoned action. Must be called with mu locked.
+func (c *Client) dropExpired(id string) bool {
+	for i, expired := range c.expired {
+		if expired == id {
+			c.expired = append(c.expired[:i], c.expired[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// redial connection and login with stored credentials. Makes up to
+// maxRetries attempts with backoff delay before each one
+func (c *Client) redial(ctx context.Context) error {
+	c.closeConn()
+	var err error
+	delay := c.backoff
+	for i := 0; c.maxRetries <= 0 || i < c.maxRetries; i++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: reconnect canceled: %w", ErrEOF, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = c.nextBackoff(delay)
+		if c.isClosed() {
+			return fmt.Errorf("%w: reconnect canceled: client closed", ErrEOF)
+		}
+
+		c.logger.Info("reconnecting", "attempt", i+1)
+		var conn net.Conn
+		if conn, err = c.dial(ctx); err != nil {
+			c.logger.Warn("failed to reconnect", "attempt", i+1, "error", err)
+			continue
+		}
+		if !c.setConn(conn) {
+			return fmt.Errorf("%w: reconnect canceled: client closed", ErrEOF)
+		}
+		c.mu.Lock()
+		username, password := c.username, c.password
+		c.mu.Unlock()
+		if err = c.loginAny(ctx, username, password); err != nil {
+			c.closeConn()
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: failed to reconnect after %d attempts: %w", ErrEOF, c.maxRetries, err)
+}
+
+// nextBackoff returns delay before the next reconnect attempt. Delay doubles
+// up to maxBackoff or stays the same when maxBackoff is not set
+func (c *Client) nextBackoff(delay time.Duration) time.Duration {
+	if c.maxBackoff <= c.backoff {
+		return c.backoff
 	}
+	if delay *= 2; delay > c.maxBackoff {
+		return c.maxBackoff
+	}
+	return delay
+}
+
+// synthetic event sent to the messages channel when connection is lost
+// and client starts reconnecting
+func eventDisconnected(err error) *Message {
+	msg := NewMessage()
+	msg.AddField("Event", "Disconnected")
+	msg.AddField("Reason", err.Error())
+	return msg
+}
+
+// synthetic event sent to the messages channel after successful reconnect
+func eventReconnected() *Message {
+	msg := NewMessage()
+	msg.AddField("Event", "Reconnected")
+	return msg
+}
+
+// packet received by consume or LimitError of the discarded packet
+type packet struct {
+	data string
+	err  error
 }
 
 // comsume all AMI data from network and split by AMI terminating \r\n\r\n.
-// When found send to main loop to parse or send error and stop on network close
-func consume(conn net.Conn) (chan string, chan error) {
+// Data buffered while login is read first. When found send to main loop to parse
+// or send error and stop on network close. Packets exceeding limits are
+// discarded and sent as LimitError. Glued packets are split when split is set.
+func consume(conn net.Conn, buffered []byte, limits Limits, split bool) (chan packet, chan error) {
 	_ = conn.SetReadDeadline(time.Time{}) // assure no dealine for reading
-	pack, chErr := make(chan string), make(chan error)
-	go func(chPack chan string, chErr chan error, conn net.Conn) {
+	pack, chErr := make(chan packet), make(chan error)
+	go func(chPack chan packet, chErr chan error, conn net.Conn) {
 		defer close(chPack)
 		defer close(chErr)
-		reader := bufio.NewReader(conn)
-		buf := &strings.Builder{}
+		reader := &lineReader{
+			r:   bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), conn)),
+			max: limits.LineLength,
+		}
+		scanner := &packetScanner{maxSize: limits.MessageSize, maxHeaders: limits.Headers, split: split}
 		for {
-			line, err := reader.ReadString('\n')
+			line, err := reader.readLine()
+			var limit *LimitError
+			if errors.As(err, &limit) {
+				scanner.discard()
+				chPack <- packet{err: err}
+				continue
+			}
 			if err != nil {
-				chErr <- fmt.Errorf("%w: failed read: %s", ErrEOF, err)
+				chErr <- fmt.Errorf("%w: failed read: %w", ErrEOF, err)
 				return
 			}
-			_, _ = buf.WriteString(line)
-			if line == "\r\n" { // end of packet
-				chPack <- buf.String()
-				buf.Reset()
+			packets, err := scanner.pushLine(line)
+			for _, p := range packets {
+				chPack <- packet{data: p}
+			}
+			if err != nil {
+				chPack <- packet{err: err}
 			}
 		}
 	}(pack, chErr, conn)
 	return pack, chErr
 }
 
+// drain consumer channels until it stops on closed connection
+func drain(chPack chan packet, chErr chan error) {
+	for {
+		select {
+		case <-chPack:
+		case <-chErr:
+			return
+		}
+	}
+}
+
+// emitErr sends error to the errors channel without blocking. When channel
+// is full the oldest error is dropped, so the channel keeps the most recent
+// errors, like the final ErrEOF, even if application never reads it.
 func (c *Client) emitErr(err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	select {
-	case c.err <- err:
-	case <-time.After(chanGiveup):
-		// failed to send and exit here to avoid blocking
+	if c.err == nil {
+		return
+	}
+	for {
+		select {
+		case c.err <- err:
+			return
+		default:
+		}
+		if cap(c.err) == 0 {
+			c.droppedErrs.Add(1)
+			return
+		}
+		select {
+		case <-c.err:
+			c.droppedErrs.Add(1)
+		default:
+		}
 	}
 }
 
 func (c *Client) emitMsg(msg *Message) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	select {
-	case c.recv <- msg:
-	case <-time.After(chanGiveup):
-		// failed to send and exit here to avoid blocking
+	if c.replay != nil && msg.IsEvent() {
+		c.replay.add(msg.Clone(), time.Now())
+	}
+	if c.publish(msg) || c.recv == nil {
+		return
+	}
+	defer func() { c.extendedMetrics().SetMessagesQueued(len(c.recv)) }()
+
+	for _, dropped := range offer(c.recv, msg, c.overflow) {
+		c.drop(dropped, "messages channel is full")
+	}
+}
+
+// offer sends message to the channel with overflow policy and returns
+// dropped messages
+func offer(ch chan *Message, msg *Message, policy OverflowPolicy) []*Message {
+	if policy == OverflowDropOldest && cap(ch) == 0 {
+		// nothing to drop in unbuffered channel
+		policy = OverflowDropNewest
+	}
+
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case ch <- msg:
+			return nil
+		default:
+			return []*Message{msg}
+		}
+	case OverflowDropOldest:
+		var dropped []*Message
+		for {
+			select {
+			case ch <- msg:
+				return dropped
+			default:
+			}
+			select {
+			case old := <-ch:
+				dropped = append(dropped, old)
+			default:
+			}
+		}
+	default:
+		// timer is started only when channel is full
+		select {
+		case ch <- msg:
+			return nil
+		default:
+		}
+		select {
+		case ch <- msg:
+			return nil
+		case <-time.After(chanGiveup):
+			// failed to send and exit here to avoid blocking
+			return []*Message{msg}
+		}
 	}
 }
 
-func (c *Client) login(username, password string) error {
+func (c *Client) login(ctx context.Context, username, password string) error {
+	if err := c.authenticate(ctx, username, password); err != nil {
+		c.logger.Warn("login failed", "username", username, "error", err)
+		return err
+	}
+	c.logger.Info("logged in", "username", username)
+	c.touch()
+	c.setState(StateConnected, nil)
+	return nil
+}
+
+// authenticate reads AMI prompt from the connection and sends login action.
+// Network operations are bound by timeout and are aborted when context is done.
+func (c *Client) authenticate(ctx context.Context, username, password string) (err error) {
+	conn := c.getConn()
+	if conn == nil {
+		return fmt.Errorf("%w: closed connection: failed to login", ErrConn)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// abort blocking reads and writes when context is done
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer func() {
+		stop()
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+			err = context.DeadlineExceeded
+		}
+	}()
+
 	// make sure connection is not blocking
-	if err := c.setRTimeout(); err != nil {
-		return fmt.Errorf("%w: failed setup read timeout for login: %s", ErrConn, err)
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("%w: failed setup read timeout for login: %w", ErrConn, err)
 	}
 
-	if err := c.setWTimeout(); err != nil {
-		return fmt.Errorf("%w: failed setup read timeout for login: %s", ErrConn, err)
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("%w: failed setup read timeout for login: %w", ErrConn, err)
 	}
 
 	// read prompt
-	buf := make([]byte, 1024) // long enough for prompt
-	n, err := c.conn.Read(buf)
+	limits := c.limits.withDefaults()
+	reader := &lineReader{r: bufio.NewReader(conn), max: limits.LineLength}
+	prompt, err := readPrompt(reader.r)
 	if err != nil {
-		return fmt.Errorf("%w: failed read prompt: %s", ErrConn, err)
-	}
-	if promptPrefix != string(buf[:len(promptPrefix)]) {
-		return fmt.Errorf("%w: unexpected prompt: %q", ErrAMI, buf[:n])
+		return err
 	}
+	c.setBanner(prompt)
 
-	// send login
+	// send login, messages received after the response are kept for reading loop
+	scanner := &packetScanner{maxSize: limits.MessageSize, maxHeaders: limits.Headers, split: c.splitGlued}
 	login := NewAction("Login")
 	login.AddField("Username", username)
-	login.AddField("Secret", password)
-	if _, err := c.conn.Write(login.Byte()); err != nil {
-		return fmt.Errorf("%w: failed write login: %q", ErrConn, err)
+	c.mu.Lock()
+	mask := c.eventsMask
+	c.mu.Unlock()
+	if mask != "" {
+		login.AddField("Events", mask)
+	}
+	if c.authMD5 {
+		key, err := c.challenge(conn, reader, scanner, password)
+		if err != nil {
+			return err
+		}
+		login.AddField("AuthType", "MD5")
+		login.AddField("Key", key)
+	} else {
+		login.AddField("Secret", password)
+	}
+	if err := c.writeLogin(conn, login); err != nil {
+		return fmt.Errorf("%w: failed write login: %w", ErrConn, err)
 	}
 
-	// read login response
-	n, err = c.conn.Read(buf)
+	packets, err := readPackets(reader, scanner)
 	if err != nil {
-		return fmt.Errorf("%w: failed to read login response: %s", ErrAMI, err)
+		return fmt.Errorf("%w: failed to read login response: %w", ErrConn, err)
 	}
+	buffered, _ := reader.r.Peek(reader.r.Buffered())
+	c.setBuffered(append([]byte(strings.Join(packets[1:], "")), buffered...))
 
-	msg, err := Parse(string(buf[:n]))
+	msg, err := Parse(packets[0])
 	if err != nil {
-		return fmt.Errorf("%w: failed to read login response: %s", ErrAMI, err)
+		return fmt.Errorf("failed to read login response: %w", err)
 	}
 
 	if !msg.IsSuccess() {
-		return fmt.Errorf("%w: failed login: %q", ErrAMI, msg.Field("Message"))
+		return rejected(msg, "failed login")
 	}
 
 	return nil
 }
 
-func (c *Client) setRTimeout() error {
-	return c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+// readPrompt reads AMI prompt line without line terminator. Prompt can be
+// split into several reads by stream transports, like SSH tunnels or AMI
+// proxies, and data following the prompt stays in the reader. Fails as soon
+// as received data does not match the prompt prefix.
+func readPrompt(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("%w: failed read prompt: %w", ErrConn, err)
+		}
+		if b == '\n' {
+			return strings.TrimRight(string(line), "\r"), nil
+		}
+		line = append(line, b)
+		n := min(len(line), len(promptPrefix))
+		if string(line[:n]) != promptPrefix[:n] || len(line) > maxPromptLength {
+			rest, _ := r.Peek(r.Buffered())
+			return "", &ProtocolError{Err: fmt.Errorf("%w: unexpected prompt: %q", ErrAMI,
+				append(line, rest...))}
+		}
+	}
+}
+
+// challenge sends "Action: Challenge" and returns MD5 key of the challenge
+// and the password for login with MD5 authentication
+func (c *Client) challenge(conn net.Conn, reader *lineReader, scanner *packetScanner,
+	password string) (string, error) {
+	action := NewAction("Challenge")
+	action.AddField("AuthType", "MD5")
+	if err := c.writeLogin(conn, action); err != nil {
+		return "", fmt.Errorf("%w: failed write challenge: %w", ErrConn, err)
+	}
+
+	packets, err := readPackets(reader, scanner)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read challenge response: %w", ErrConn, err)
+	}
+	msg, err := Parse(packets[0])
+	if err != nil {
+		return "", fmt.Errorf("failed to read challenge response: %w", err)
+	}
+	if !msg.IsSuccess() || msg.Field("Challenge") == "" {
+		return "", rejected(msg, "failed challenge")
+	}
+
+	sum := md5.Sum([]byte(msg.Field("Challenge") + password))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// writeLogin writes login or challenge action to the connection
+func (c *Client) writeLogin(conn net.Conn, action *Message) error {
+	c.logAction(action.Byte())
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := conn.Write(action.Byte())
+	return err
+}
+
+// readPackets reads lines until at least one complete packet is received
+func readPackets(reader *lineReader, scanner *packetScanner) ([]string, error) {
+	var packets []string
+	for len(packets) == 0 {
+		line, err := reader.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if packets, err = scanner.pushLine(line); err != nil {
+			return nil, err
+		}
+	}
+	return packets, nil
+}
+
+// setBuffered keeps data read from connection before reading loop starts
+func (c *Client) setBuffered(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffered = bytes.Clone(data)
+}
+
+// takeBuffered returns and resets data read before reading loop starts
+func (c *Client) takeBuffered() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.buffered
+	c.buffered = nil
+	return data
+}
+
+func (c *Client) getConn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// setConn installs new connection. Returns false and closes the connection
+// if the client is already closed
+func (c *Client) setConn(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		_ = conn.Close()
+		return false
+	}
+	c.conn = c.wrapConn(conn)
+	return true
 }
 
-func (c *Client) setWTimeout() error {
-	return c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+func (c *Client) closeConn() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// drainClose discards buffered values and closes the channel if it is not closed
+func drainClose[T any](c chan T) {
+	if c == nil {
+		return
+	}
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return
+			}
+		default:
+			close(c)
+			return
+		}
+	}
 }
 
 func isClosedChan[T any](c <-chan T) bool {
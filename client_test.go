@@ -3,8 +3,13 @@ package goami2
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,6 +28,21 @@ func connSrvSess(conn net.Conn, repl []string) {
 	}()
 }
 
+// read AMI action sent by client on the server side of the connection
+func srvReadAction(r *bufio.Reader) (*Message, error) {
+	buf := &strings.Builder{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(line)
+		if line == "\r\n" {
+			return Parse(buf.String())
+		}
+	}
+}
+
 func TestClientLogin(t *testing.T) {
 	connClint, connSrv := net.Pipe()
 	cl := makeClient(connClint)
@@ -31,7 +51,7 @@ func TestClientLogin(t *testing.T) {
 		defer func() { cl.timeout = netTimeout }()
 		cl.timeout = time.Nanosecond
 
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.ErrorContains(t, err, "i/o timeout")
 	})
 
@@ -39,33 +59,67 @@ func TestClientLogin(t *testing.T) {
 		go func() {
 			_, _ = connSrv.Write([]byte("foo bar"))
 		}()
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.ErrorContains(t, err, "unexpected prompt")
 	})
 
 	t.Run("fail on invalid AMI message", func(t *testing.T) {
 		connSrvSess(connSrv, []string{"invalid message\r\n\r\n"})
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.ErrorContains(t, err, "failed to read login response")
 	})
 
 	t.Run("fail on response status fail", func(t *testing.T) {
 		connSrvSess(connSrv,
 			[]string{"Response: Error\r\nMessage: Authentication failed\r\n\r\n"})
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.ErrorContains(t, err, "Authentication failed")
 	})
 
 	t.Run("login successfully", func(t *testing.T) {
 		connSrvSess(connSrv,
 			[]string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
+		assert.Nil(t, err)
+	})
+
+	t.Run("prompt split into several reads", func(t *testing.T) {
+		go func() {
+			for _, data := range []string{"Aster", "isk Call Manager/", "5.0.1\r"} {
+				_, _ = connSrv.Write([]byte(data))
+			}
+			// end of prompt comes with the login response
+			_, _ = connSrv.Write([]byte("\nResponse: Success\r\n"))
+			_, _ = srvReadAction(bufio.NewReader(connSrv))
+			_, _ = connSrv.Write([]byte("Message: Authentication accepted\r\n\r\n"))
+		}()
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.Nil(t, err)
+		assert.Equal(t, "Asterisk Call Manager/5.0.1", cl.Banner())
+	})
+
+	t.Run("abort on context cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		err := cl.login(ctx, "admin", "pwd")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("abort on context deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := cl.login(ctx, "admin", "pwd")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), netTimeout)
 	})
 
 	t.Run("write to closed connection", func(t *testing.T) {
 		_ = connSrv.Close()
-		err := cl.login("admin", "pwd")
+		err := cl.login(context.Background(), "admin", "pwd")
 		assert.ErrorContains(t, err, "failed setup read timeout")
 	})
 }
@@ -121,6 +175,38 @@ func TestClientClose(t *testing.T) {
 	})
 }
 
+func TestClientCloseWhileReading(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithBufferSize(1)(cl)
+		WithReconnect(0, time.Millisecond)(cl) // must not redial after Close
+		go cl.loop(context.Background())
+
+		go func() {
+			for {
+				if _, err := connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100\r\n\r\n")); err != nil {
+					return
+				}
+			}
+		}()
+
+		recv := cl.AllMessages()
+		consumed := make(chan struct{})
+		go func() {
+			for range recv {
+			}
+			close(consumed)
+		}()
+
+		time.Sleep(time.Millisecond)
+		assert.NotPanics(t, cl.Close)
+		assert.True(t, isClosedChan(cl.done))
+		<-consumed
+		_ = connSrv.Close()
+	}
+}
+
 func TestClientLoopRead(t *testing.T) {
 	setup := func() (net.Conn, net.Conn, *Client) {
 		connClint, connSrv := net.Pipe()
@@ -247,6 +333,73 @@ func TestClientLoopStreamRead(t *testing.T) {
 	}
 }
 
+func TestClientLoopPacketFraming(t *testing.T) {
+	t.Run("packets in single write", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100\r\n\r\n" +
+				"Event: Hangup\r\nChannel: PJSIP/100\r\n\r\nEvent: Partial\r\nChan"))
+			_, _ = connSrv.Write([]byte("nel: PJSIP/200\r\n\r\n"))
+		}()
+		assert.Equal(t, "Newchannel", (<-cl.AllMessages()).Field("Event"))
+		assert.Equal(t, "Hangup", (<-cl.AllMessages()).Field("Event"))
+		assert.Equal(t, "PJSIP/200", (<-cl.AllMessages()).Field("Channel"))
+	})
+
+	t.Run("glued packets without empty line", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithGluedPackets()(cl)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100\r\n" +
+				"Response: Success\r\nPing: Pong\r\n" +
+				"Event: Hangup\r\nCause: 16\r\n\r\n\r\n"))
+		}()
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "Event: Newchannel\r\nChannel: PJSIP/100\r\n\r\n", msg.String())
+		assert.Equal(t, "Pong", (<-cl.AllMessages()).Field("Ping"))
+		assert.Equal(t, "16", (<-cl.AllMessages()).Field("Cause"))
+		assert.Empty(t, cl.Err())
+	})
+
+	t.Run("repeated packet headers are not split by default", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			_, _ = connSrv.Write([]byte("Event: ChallengeResponseFailed\r\nChallenge: 123456\r\n" +
+				"Response: 8e8c2f7f\r\nExpectedResponse: 0c2d9a2b\r\n\r\n" +
+				"Event: UserEvent\r\nUserEvent: Custom\r\nEvent: Custom\r\nResponse: Ok\r\n\r\n"))
+		}()
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "ChallengeResponseFailed", msg.Field("Event"))
+		assert.Equal(t, "8e8c2f7f", msg.Field("Response"))
+		msg = <-cl.AllMessages()
+		assert.Equal(t, "Custom", msg.Field("UserEvent"))
+		assert.Equal(t, "Ok", msg.Field("Response"))
+	})
+
+	t.Run("packets read with login response", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n" +
+			"Event: FullyBooted\r\n\r\nEvent: SuccessfulAuth\r\n\r\n"})
+		cl, err := NewClient(connClient, "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+		assert.Equal(t, "SuccessfulAuth", (<-cl.AllMessages()).Field("Event"))
+	})
+}
+
 func TestIsClosedChan(t *testing.T) {
 	foo := make(chan struct{})
 
@@ -258,3 +411,954 @@ func TestIsClosedChan(t *testing.T) {
 	foo = nil
 	assert.True(t, isClosedChan(foo))
 }
+
+func TestClientReconnect(t *testing.T) {
+	t.Run("redial and login after connection lost", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv,
+			[]string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) {
+				conn, srv := net.Pipe()
+				connSrvSess(srv, []string{
+					"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+					"Event: FullyBooted\r\nStatus: Fully Booted\r\n\r\n",
+				})
+				return conn, nil
+			}
+		}
+
+		cl, err := NewClient(connClient, "admin", "pa55w0rd",
+			WithReconnect(3, time.Millisecond), withDial)
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		_ = connSrv.Close()
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "Disconnected", msg.Field("Event"))
+		assert.Contains(t, msg.Field("Reason"), "failed read")
+		msg = <-cl.AllMessages()
+		assert.Equal(t, "Reconnected", msg.Field("Event"))
+		msg = <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+	})
+
+	t.Run("exponential backoff", func(t *testing.T) {
+		cl := makeClient(nil)
+		WithReconnect(0, 10*time.Millisecond)(cl)
+		assert.Equal(t, 10*time.Millisecond, cl.nextBackoff(10*time.Millisecond))
+
+		WithMaxBackoff(50 * time.Millisecond)(cl)
+		delay := cl.backoff
+		var delays []time.Duration
+		for i := 0; i < 4; i++ {
+			delay = cl.nextBackoff(delay)
+			delays = append(delays, delay)
+		}
+		assert.Equal(t, []time.Duration{20 * time.Millisecond, 40 * time.Millisecond,
+			50 * time.Millisecond, 50 * time.Millisecond}, delays)
+	})
+
+	t.Run("give up after max retries", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv,
+			[]string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+
+		attempts := 0
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) {
+				attempts++
+				return nil, ErrConn
+			}
+		}
+
+		cl, err := NewClient(connClient, "admin", "pa55w0rd",
+			WithReconnect(2, time.Millisecond), withDial)
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		_ = connSrv.Close()
+		err = <-cl.Err()
+		assert.ErrorIs(t, err, ErrEOF)
+		assert.ErrorContains(t, err, "failed to reconnect after 2 attempts")
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("stop reconnect when client is closed", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv,
+			[]string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+
+		var attempts atomic.Int32
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) {
+				attempts.Add(1)
+				return nil, ErrConn
+			}
+		}
+
+		cl, err := NewClient(connClient, "admin", "pa55w0rd",
+			WithReconnect(0, 20*time.Millisecond), withDial)
+		assert.Nil(t, err)
+		_ = connSrv.Close()
+		cl.Close()
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int32(0), attempts.Load())
+	})
+
+	t.Run("fail pending actions and resync after reconnect", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		srvActions := make(chan *Message, 1)
+		go func() {
+			buf := make([]byte, 1024)
+			_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\n"))
+			_, _ = connSrv.Read(buf)
+			_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err == nil {
+				srvActions <- msg
+			}
+			_ = connSrv.Close() // never respond
+		}()
+
+		resynced := make(chan *Message, 2)
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) {
+				conn, srv := net.Pipe()
+				go func() {
+					buf := make([]byte, 1024)
+					_, _ = srv.Write([]byte("Asterisk Call Manager/2.10.4\n"))
+					_, _ = srv.Read(buf)
+					_, _ = srv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+					r := bufio.NewReader(srv)
+					for i := 0; i < 2; i++ {
+						msg, err := srvReadAction(r)
+						if err != nil {
+							return
+						}
+						resynced <- msg
+					}
+				}()
+				return conn, nil
+			}
+		}
+
+		filter := NewAction("Filter")
+		filter.AddField("Operation", "Add")
+		cl, err := NewClient(connClient, "admin", "pa55w0rd", withDial,
+			WithReconnect(3, time.Millisecond), WithResyncActions(filter, NewAction("Status")))
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		_, err = cl.SendAction(context.Background(), NewAction("Ping"))
+		assert.ErrorIs(t, err, ErrReconnecting)
+		assert.Equal(t, "Ping", (<-srvActions).Field("Action"))
+
+		assert.Equal(t, "Disconnected", (<-cl.AllMessages()).Field("Event"))
+		assert.Equal(t, "Reconnected", (<-cl.AllMessages()).Field("Event"))
+		msg := <-resynced
+		assert.Equal(t, "Filter", msg.Field("Action"))
+		assert.Equal(t, "Add", msg.Field("Operation"))
+		assert.Equal(t, "Status", (<-resynced).Field("Action"))
+	})
+
+	t.Run("new connection is not installed on closed client", func(t *testing.T) {
+		connClient, _ := net.Pipe()
+		cl := makeClient(connClient)
+		cl.Close()
+
+		conn, srv := net.Pipe()
+		assert.False(t, cl.setConn(conn))
+		assert.Nil(t, cl.getConn())
+		_, err := srv.Write([]byte("foo"))
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+}
+
+func TestClientLoopDuplicateHeaders(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	client := makeClient(connClient)
+	go client.loop(context.Background())
+	defer client.Close()
+
+	go func() {
+		// Newstate event with three ChanVariable headers
+		_, _ = connSrv.Write([]byte(getAmiFixtureCall()[2]))
+	}()
+
+	msg := <-client.AllMessages()
+	assert.Equal(t, "realm=", msg.Field("ChanVariable"))
+	assert.Equal(t, []string{
+		"realm=",
+		"SIPDOMAIN=okon.ferry.sip.com",
+		"SIPCALLID=b5ser03agv7huo7faai5",
+	}, msg.FieldValues("chanvariable"))
+}
+
+func TestClientShutdown(t *testing.T) {
+	setup := func() (net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithReconnect(0, time.Millisecond)(cl)
+		go cl.loop(context.Background())
+		return connSrv, cl
+	}
+
+	t.Run("logoff and close", func(t *testing.T) {
+		srv, cl := setup()
+		chErr := cl.Err()
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(srv))
+			if err != nil {
+				return
+			}
+			_, _ = srv.Write([]byte("Response: Goodbye\r\nActionID: " + msg.ActionID() +
+				"\r\nMessage: Thanks for all the fish.\r\n\r\n"))
+			_ = srv.Close()
+		}()
+
+		err := cl.Shutdown(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, cl.getConn())
+		_, ok := <-chErr
+		assert.False(t, ok)
+	})
+
+	t.Run("context error when logoff not acknowledged", func(t *testing.T) {
+		srv, cl := setup()
+		go func() {
+			_, _ = srvReadAction(bufio.NewReader(srv))
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := cl.Shutdown(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, cl.getConn())
+	})
+
+	t.Run("logoff rejected", func(t *testing.T) {
+		srv, cl := setup()
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(srv))
+			if err != nil {
+				return
+			}
+			_, _ = srv.Write([]byte("Response: Error\r\nActionID: " + msg.ActionID() +
+				"\r\nMessage: Permission denied\r\n\r\n"))
+		}()
+
+		err := cl.Shutdown(context.Background())
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Permission denied")
+	})
+
+	t.Run("in-flight actions fail with ErrClosed", func(t *testing.T) {
+		srv, cl := setup()
+		go func() {
+			r := bufio.NewReader(srv)
+			if _, err := srvReadAction(r); err != nil {
+				return
+			}
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = srv.Write([]byte("Response: Goodbye\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+			_ = srv.Close()
+		}()
+
+		chErr := make(chan error, 1)
+		go func() {
+			_, err := cl.SendAction(context.Background(), NewAction("Ping"))
+			chErr <- err
+		}()
+		assert.Eventually(t, func() bool {
+			cl.mu.Lock()
+			defer cl.mu.Unlock()
+			return len(cl.pending) == 1
+		}, time.Second, time.Millisecond)
+
+		assert.Nil(t, cl.Shutdown(context.Background()))
+		err := <-chErr
+		assert.ErrorIs(t, err, ErrClosed)
+		assert.NotErrorIs(t, err, ErrReconnecting)
+	})
+
+	t.Run("connection closed before goodbye", func(t *testing.T) {
+		srv, cl := setup()
+		go func() {
+			_, _ = srvReadAction(bufio.NewReader(srv))
+			_ = srv.Close()
+		}()
+		assert.Nil(t, cl.Shutdown(context.Background()))
+	})
+
+	t.Run("dead connection", func(t *testing.T) {
+		srv, cl := setup()
+		_ = srv.Close()
+		assert.NotPanics(t, func() {
+			err := cl.Shutdown(context.Background())
+			assert.Nil(t, err)
+		})
+		assert.NotPanics(t, func() {
+			err := cl.Shutdown(context.Background())
+			assert.Nil(t, err)
+		})
+	})
+}
+
+func TestClientOverflowPolicy(t *testing.T) {
+	setup := func(opts ...Option) (net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		for _, opt := range opts {
+			opt(cl)
+		}
+		return connSrv, cl
+	}
+	event := func(n int) *Message {
+		msg := NewMessage()
+		msg.AddField("Event", "PeerStatus")
+		msg.AddField("Seq", strconv.Itoa(n))
+		return msg
+	}
+
+	tests := map[string]struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		`block`:       {OverflowBlock, []string{"0", "1"}},
+		`drop newest`: {OverflowDropNewest, []string{"0", "1"}},
+		`drop oldest`: {OverflowDropOldest, []string{"3", "4"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, cl := setup(WithBufferSize(2), WithOverflowPolicy(tc.policy))
+			defer cl.Close()
+			for i := 0; i < 5; i++ {
+				cl.emitMsg(event(i))
+			}
+			assert.Equal(t, uint64(3), cl.DroppedMessages())
+			for _, want := range tc.want {
+				msg := <-cl.AllMessages()
+				assert.Equal(t, want, msg.Field("Seq"))
+			}
+		})
+	}
+
+	t.Run("drop oldest with unbuffered channel", func(t *testing.T) {
+		_, cl := setup(WithBufferSize(0), WithOverflowPolicy(OverflowDropOldest))
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cl.emitMsg(event(0))
+			cl.Close()
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("emit message blocked")
+		}
+		assert.Equal(t, uint64(1), cl.DroppedMessages())
+	})
+
+	t.Run("loop never blocks on drop policy", func(t *testing.T) {
+		srv, cl := setup(WithBufferSize(0), WithOverflowPolicy(OverflowDropNewest))
+		defer cl.Close()
+		go cl.loop(context.Background())
+		for i := 0; i < 100; i++ {
+			_, err := srv.Write([]byte("Event: PeerStatus\r\n\r\n"))
+			assert.Nil(t, err)
+		}
+		assert.Eventually(t, func() bool { return cl.DroppedMessages() == 100 },
+			time.Second, time.Millisecond)
+	})
+}
+
+func TestClientConcurrentSend(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	defer cl.Close()
+
+	count := 300
+	done := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for i := 0; i < count; i++ {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				done <- err
+				return
+			}
+			if msg.Len() != 4 || msg.Field("Channel") != "PJSIP/"+msg.ActionID() ||
+				msg.Field("Variable") != "ID="+msg.ActionID() {
+				done <- assert.AnError
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for i := 0; i < count; i++ {
+		go func(n int) {
+			id := strconv.Itoa(n)
+			msg := NewAction("Originate")
+			msg.AddField("ActionID", id)
+			msg.AddField("Channel", "PJSIP/"+id)
+			msg.AddField("Variable", "ID="+id)
+			assert.True(t, cl.Action(msg))
+		}(i)
+	}
+	assert.Nil(t, <-done)
+}
+
+func TestClientSendContext(t *testing.T) {
+	t.Run("deadline instead of network timeout", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		cl.timeout = 5 * time.Millisecond
+		defer cl.Close()
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = srvReadAction(bufio.NewReader(connSrv))
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.Nil(t, cl.SendContext(ctx, NewAction("Ping")))
+	})
+
+	t.Run("abort on context cancel", func(t *testing.T) {
+		connClient, _ := net.Pipe()
+		cl := makeClient(connClient)
+		defer cl.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		start := time.Now()
+		err := cl.SendContext(ctx, NewAction("Ping"))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, ErrConn)
+		assert.Less(t, time.Since(start), cl.timeout)
+		assert.NotNil(t, cl.getConn())
+
+		assert.ErrorIs(t, cl.SendContext(ctx, NewAction("Ping")), context.Canceled)
+	})
+
+	t.Run("close connection on partial write", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		defer cl.Close()
+
+		go func() {
+			buf := make([]byte, 5)
+			_, _ = connSrv.Read(buf)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := cl.SendContext(ctx, NewAction("Ping"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, cl.getConn())
+	})
+
+	t.Run("through middleware", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		defer cl.Close()
+		cl.Use(func(next SendFunc) SendFunc {
+			return func(action *Message) error {
+				action.AddField("Tenant", "tenant1")
+				return next(action)
+			}
+		})
+
+		got := make(chan *Message, 1)
+		go func() {
+			msg, _ := srvReadAction(bufio.NewReader(connSrv))
+			got <- msg
+		}()
+		assert.Nil(t, cl.SendContext(context.Background(), NewAction("Ping")))
+		assert.Equal(t, "tenant1", (<-got).Field("Tenant"))
+	})
+}
+
+func TestClientLoginContext(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for _, resp := range []string{"Error\r\nMessage: Authentication failed", "Success"} {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: " + resp + "\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	err := cl.LoginContext(context.Background(), "admin", "wrong")
+	assert.ErrorContains(t, err, "Authentication failed")
+	err = cl.LoginContext(context.Background(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	assert.Equal(t, "pa55w0rd", cl.password)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cl.LoginContext(ctx, "admin", "pa55w0rd")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClientSendAction(t *testing.T) {
+	t.Run("responses out of order", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		count := 10
+		go func() {
+			r := bufio.NewReader(connSrv)
+			ids := make([]string, 0, count)
+			for i := 0; i < count; i++ {
+				msg, err := srvReadAction(r)
+				if err != nil {
+					return
+				}
+				ids = append(ids, msg.ActionID())
+			}
+			_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+			for i := len(ids) - 1; i >= 0; i-- {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + ids[i] +
+					"\r\nMessage: " + ids[i] + "\r\n\r\n"))
+			}
+		}()
+
+		errs := make(chan error, count)
+		for i := 0; i < count; i++ {
+			go func() {
+				action := NewAction("Ping")
+				resp, err := cl.SendAction(context.Background(), action)
+				if err == nil && (action.ActionID() == "" || resp.Field("Message") != action.ActionID()) {
+					err = assert.AnError
+				}
+				errs <- err
+			}()
+		}
+		for i := 0; i < count; i++ {
+			assert.Nil(t, <-errs)
+		}
+
+		// only unsolicited messages are sent to messages channel
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+		cl.mu.Lock()
+		assert.Empty(t, cl.pending)
+		cl.mu.Unlock()
+	})
+
+	t.Run("keep given ActionID", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}()
+
+		action := NewAction("Ping")
+		action.AddField("ActionID", "my-id-1")
+		resp, err := cl.SendAction(context.Background(), action)
+		assert.Nil(t, err)
+		assert.Equal(t, "my-id-1", resp.ActionID())
+	})
+
+	t.Run("ActionID header name case", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			r := bufio.NewReader(connSrv)
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionid: " + msg.ActionID() + "\r\n\r\n"))
+			if msg, err = srvReadAction(r); err != nil {
+				return
+			}
+			id := msg.ActionID()
+			_, _ = connSrv.Write([]byte("Response: Success\r\nACTIONID: " + id + "\r\n" +
+				"EventList: start\r\n\r\n" +
+				"Event: Status\r\nactionid: " + id + "\r\nUniqueID: 1598887681.60\r\n\r\n" +
+				"Event: StatusComplete\r\nActionId: " + id + "\r\n\r\n"))
+		}()
+
+		resp, err := cl.SendAction(context.Background(), NewAction("Ping"))
+		assert.Nil(t, err)
+		assert.True(t, resp.IsSuccess())
+
+		list, err := cl.SendActionList(context.Background(), NewAction("Status"))
+		assert.Nil(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, "1598887681.60", list[0].Field("Uniqueid"))
+		assert.Equal(t, "Event: Status\r\nactionid: "+list[0].ActionID()+
+			"\r\nUniqueID: 1598887681.60\r\n\r\n", string(list[0].Bytes()))
+	})
+
+	t.Run("abort on context cancel", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_, _ = srvReadAction(bufio.NewReader(connSrv))
+			cancel()
+		}()
+
+		_, err := cl.SendAction(ctx, NewAction("Ping"))
+		assert.ErrorIs(t, err, context.Canceled)
+		cl.mu.Lock()
+		assert.Empty(t, cl.pending)
+		cl.mu.Unlock()
+	})
+}
+
+func TestClientSendActionTimeout(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithActionTimeout(20 * time.Millisecond)(cl)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	late := make(chan string, 1)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		msg, err := srvReadAction(r)
+		if err != nil {
+			return
+		}
+		late <- msg.ActionID()
+		time.Sleep(50 * time.Millisecond) // respond after action timeout
+		_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+	}()
+
+	_, err := cl.SendAction(context.Background(), NewAction("Ping"))
+	assert.ErrorIs(t, err, ErrActionTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// late response is dropped and not sent to messages channel
+	msg := <-cl.AllMessages()
+	assert.Equal(t, "FullyBooted", msg.Field("Event"))
+	cl.mu.Lock()
+	assert.Empty(t, cl.pending)
+	assert.NotContains(t, cl.expired, <-late)
+	cl.mu.Unlock()
+}
+
+func TestClientExpireActionIDs(t *testing.T) {
+	cl := makeClient(nil)
+	for i := 0; i < expiredMax+10; i++ {
+		cl.expire(strconv.Itoa(i))
+	}
+	assert.Len(t, cl.expired, expiredMax)
+	assert.False(t, cl.dropExpired("0"))
+	assert.True(t, cl.dropExpired("10"))
+	assert.False(t, cl.dropExpired("10"))
+	assert.Len(t, cl.expired, expiredMax-1)
+}
+
+func TestClientPrivilegeFilter(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithPrivilegeFilter("Call", "agent")(cl)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		_, _ = connSrv.Write([]byte("Event: Reload\r\nPrivilege: system,all\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: Newchannel\r\nPrivilege: call,all\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: NoPrivilege\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Response: Success\r\nPing: Pong\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: AgentLogin\r\nPrivilege: agent,all\r\n\r\n"))
+	}()
+
+	assert.Equal(t, "Newchannel", (<-cl.AllMessages()).Field("Event"))
+	assert.Equal(t, "Pong", (<-cl.AllMessages()).Field("Ping"))
+	assert.Equal(t, "AgentLogin", (<-cl.AllMessages()).Field("Event"))
+}
+
+func TestClientLoginWithRetry(t *testing.T) {
+	authFailed := "Response: Error\r\nMessage: Authentication failed\r\n\r\n"
+	authOK := "Response: Success\r\nMessage: Authentication accepted\r\n\r\n"
+	setup := func(first string, replies ...string) (*Client, *atomic.Int32) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{first})
+		cl := makeClient(connClient)
+		var dials atomic.Int32
+		cl.dial = func(context.Context) (net.Conn, error) {
+			n := int(dials.Add(1))
+			if n > len(replies) {
+				return nil, ErrConn
+			}
+			conn, srv := net.Pipe()
+			connSrvSess(srv, []string{replies[n-1]})
+			return conn, nil
+		}
+		return cl, &dials
+	}
+
+	t.Run("retry rejected login", func(t *testing.T) {
+		cl, dials := setup(authFailed, authFailed, authOK)
+		defer cl.Close()
+		err := cl.LoginWithRetry(context.Background(), "admin", "pa55w0rd", 3, time.Millisecond)
+		assert.Nil(t, err)
+		assert.Equal(t, int32(2), dials.Load())
+	})
+
+	t.Run("give up after attempts", func(t *testing.T) {
+		cl, dials := setup(authFailed)
+		defer cl.Close()
+		err := cl.LoginWithRetry(context.Background(), "admin", "pa55w0rd", 3, time.Millisecond)
+		assert.ErrorIs(t, err, ErrConn)
+		assert.Equal(t, int32(2), dials.Load())
+	})
+
+	t.Run("no retry on unexpected prompt", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		go func() { _, _ = connSrv.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n")) }()
+		cl := makeClient(connClient)
+		defer cl.Close()
+		var dials atomic.Int32
+		cl.dial = func(context.Context) (net.Conn, error) {
+			dials.Add(1)
+			return nil, ErrConn
+		}
+		err := cl.LoginWithRetry(context.Background(), "admin", "pa55w0rd", 3, time.Millisecond)
+		assert.ErrorContains(t, err, "unexpected prompt")
+		assert.Equal(t, int32(0), dials.Load())
+	})
+
+	t.Run("abort on context cancel", func(t *testing.T) {
+		cl, _ := setup(authFailed)
+		defer cl.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := cl.LoginWithRetry(ctx, "admin", "pa55w0rd", 3, time.Second)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("constructor option", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{authFailed})
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) {
+				conn, srv := net.Pipe()
+				connSrvSess(srv, []string{authOK})
+				return conn, nil
+			}
+		}
+		cl, err := NewClient(connClient, "admin", "pa55w0rd", withDial, WithLoginRetry(2, time.Millisecond))
+		assert.Nil(t, err)
+		defer cl.Close()
+		err = cl.LoginWithRetry(context.Background(), "admin", "pa55w0rd", 2, time.Millisecond)
+		assert.ErrorContains(t, err, "client is running")
+	})
+}
+
+func TestClientLoginMD5(t *testing.T) {
+	setup := func(challenge string) (*Client, chan *Message) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithAuthMD5()(cl)
+		actions := make(chan *Message, 2)
+		go func() {
+			_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+			r := bufio.NewReader(connSrv)
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte(challenge))
+			if msg, err = srvReadAction(r); err != nil {
+				return
+			}
+			actions <- msg
+			// md5("123456789" + "pa55w0rd")
+			if msg.Field("Key") == "892e6cb560ae00907247b3765f5555df" {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+			} else {
+				_, _ = connSrv.Write([]byte("Response: Error\r\nMessage: Authentication failed\r\n\r\n"))
+			}
+		}()
+		return cl, actions
+	}
+
+	t.Run("login with challenge", func(t *testing.T) {
+		cl, actions := setup("Response: Success\r\nChallenge: 123456789\r\n\r\n")
+		defer cl.Close()
+		err := cl.login(context.Background(), "admin", "pa55w0rd")
+		assert.Nil(t, err)
+
+		msg := <-actions
+		assert.Equal(t, "Challenge", msg.Field("Action"))
+		assert.Equal(t, "MD5", msg.Field("AuthType"))
+		msg = <-actions
+		assert.Equal(t, "Login", msg.Field("Action"))
+		assert.Equal(t, "MD5", msg.Field("AuthType"))
+		assert.Equal(t, "admin", msg.Field("Username"))
+		assert.Equal(t, "", msg.Field("Secret"))
+	})
+
+	t.Run("challenge rejected", func(t *testing.T) {
+		cl, _ := setup("Response: Error\r\nMessage: Must specify AuthType\r\n\r\n")
+		defer cl.Close()
+		err := cl.login(context.Background(), "admin", "pa55w0rd")
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "failed challenge")
+	})
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Run("network timeout", func(t *testing.T) {
+		cl := makeClient(nil)
+		WithTimeout(5 * time.Second)(cl)
+		assert.Equal(t, 5*time.Second, cl.timeout)
+		WithTimeout(0)(cl)
+		assert.Equal(t, 5*time.Second, cl.timeout)
+	})
+
+	t.Run("errors buffer keeps the most recent errors", func(t *testing.T) {
+		cl := makeClient(nil)
+		WithErrorBuffer(2)(cl)
+		for i := 1; i <= 3; i++ {
+			cl.emitErr(fmt.Errorf("error %d", i))
+		}
+		assert.Equal(t, uint64(1), cl.DroppedErrors())
+		assert.EqualError(t, <-cl.Err(), "error 2")
+		assert.EqualError(t, <-cl.Err(), "error 3")
+
+		WithErrorBuffer(-1)(cl)
+		cl.emitErr(errors.New("nobody reads"))
+		assert.Equal(t, uint64(2), cl.DroppedErrors())
+	})
+
+	t.Run("events mask and ActionID prefix", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		actions := make(chan *Message, 2)
+		go func() {
+			_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+			r := bufio.NewReader(connSrv)
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+			if msg, err = srvReadAction(r); err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}()
+
+		cl, err := NewClient(connClient, "admin", "pa55w0rd",
+			WithEventsMask("system", "call"), WithActionIDPrefix("app-\r\n"))
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "system,call", (<-actions).Field("Events"))
+
+		resp, err := cl.SendAction(context.Background(), NewAction("Ping"))
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(resp.ActionID(), "app-"))
+		assert.Equal(t, resp.ActionID(), (<-actions).ActionID())
+	})
+
+	t.Run("ActionID generator", func(t *testing.T) {
+		cl := makeClient(nil)
+		first := cl.nextActionID()
+		second := cl.nextActionID()
+		assert.Regexp(t, `^[0-9a-f]{8}-1$`, first)
+		assert.Equal(t, strings.TrimSuffix(first, "1")+"2", second)
+
+		WithActionIDFunc(nil)(cl)
+		WithActionIDPrefix("app-")(cl)
+		assert.True(t, strings.HasSuffix(cl.nextActionID(), "-3"))
+
+		n := 0
+		WithActionIDFunc(func() string { n++; return "id\r\n" + strconv.Itoa(n) })(cl)
+		assert.Equal(t, "app-id1", cl.nextActionID())
+	})
+
+	t.Run("stamp actions without ActionID", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		defer cl.Close()
+		WithActionIDFunc(func() string { return "gen" })(cl)
+
+		ids := make(chan string, 4)
+		go func() {
+			r := bufio.NewReader(connSrv)
+			for {
+				msg, err := srvReadAction(r)
+				if err != nil {
+					return
+				}
+				ids <- msg.ActionID()
+			}
+		}()
+
+		assert.True(t, cl.Action(NewAction("Ping")))
+		assert.Nil(t, cl.MustSend([]byte("Action: Ping\r\n\r\n")))
+		assert.Nil(t, cl.SendContext(context.Background(), NewAction("Ping")))
+		action := NewAction("Ping")
+		action.AddField("ActionID", "own")
+		assert.Nil(t, cl.SendContext(context.Background(), action))
+		assert.Equal(t, "gen", <-ids)
+		assert.Equal(t, "", <-ids, "raw bytes are sent as is")
+		assert.Equal(t, "gen", <-ids)
+		assert.Equal(t, "own", <-ids)
+	})
+}
+
+func BenchmarkClientDispatch(b *testing.B) {
+	bench := func(b *testing.B, subscribers int) {
+		cl := makeClient(nil)
+		drain := func(ch <-chan *Message) {
+			for msg := range ch {
+				msg.Release()
+			}
+		}
+		go drain(cl.AllMessages())
+		for i := 0; i < subscribers; i++ {
+			go drain(cl.Subscribe("Newstate"))
+		}
+		defer cl.Close()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			msg, _ := Parse(rawPack)
+			cl.dispatch(msg)
+		}
+	}
+	b.Run("messages channel", func(b *testing.B) { bench(b, 0) })
+	b.Run("subscribers", func(b *testing.B) { bench(b, 4) })
+}
@@ -0,0 +1,217 @@
+// Command goami2 connects to Asterisk Manager Interface, tails events and
+// sends actions.
+//
+// Usage:
+//
+//	goami2 [flags] tail
+//	goami2 [flags] action Name [Header=Value...]
+//	goami2 [flags] action -
+//
+// Command tail prints events until interrupted. Events are selected with
+// -events names or -filter expression, like
+//
+//	goami2 -filter 'Event == "Hangup" && Channel =~ "^PJSIP/"' tail
+//
+// Command action sends the action and prints the response and events of
+// the list actions. With "-" actions are read from stdin as AMI packets
+// separated with empty line. Secret is read from AMI_SECRET environment
+// variable when -secret flag is not set.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// config of the command
+type config struct {
+	addr    string
+	user    string
+	secret  string
+	events  string
+	filter  string
+	json    bool
+	count   int
+	timeout time.Duration
+}
+
+// run command with arguments and returns exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var cfg config
+	flags := flag.NewFlagSet("goami2", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&cfg.addr, "addr", "localhost:5038", `AMI address, "tls://" or "unix://" scheme for TLS or unix socket`)
+	flags.StringVar(&cfg.user, "user", "admin", "manager username")
+	flags.StringVar(&cfg.secret, "secret", "", "manager secret, AMI_SECRET environment variable when empty")
+	flags.StringVar(&cfg.events, "events", "", "comma separated names of the events to tail")
+	flags.StringVar(&cfg.filter, "filter", "", "filter expression of the events to tail")
+	flags.BoolVar(&cfg.json, "json", false, "print messages as JSON")
+	flags.IntVar(&cfg.count, "n", 0, "exit after number of events, no limit when zero")
+	flags.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "action timeout")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goami2 [flags] tail | action Name [Header=Value...] | action -")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if cfg.secret == "" {
+		cfg.secret = os.Getenv("AMI_SECRET")
+	}
+
+	var err error
+	switch cmd, args := flags.Arg(0), flags.Args()[1:]; cmd {
+	case "tail":
+		err = tail(ctx, cfg, stdout)
+	case "action":
+		err = action(ctx, cfg, args, stdin, stdout)
+	default:
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "goami2:", strings.TrimPrefix(err.Error(), "goami2: "))
+		return 1
+	}
+	return 0
+}
+
+func connect(ctx context.Context, cfg config) (*goami2.Client, error) {
+	return goami2.Dial(ctx, cfg.addr, cfg.user, cfg.secret, goami2.WithActionTimeout(cfg.timeout))
+}
+
+// tail prints events matching the filter until context is done or
+// connection is lost
+func tail(ctx context.Context, cfg config, out io.Writer) error {
+	match := func(msg *goami2.Message) bool { return msg.IsEvent() }
+	if cfg.events != "" {
+		match = goami2.MatchEvents(strings.Split(cfg.events, ",")...)
+	}
+	if cfg.filter != "" {
+		m, err := goami2.CompileMatcher(cfg.filter)
+		if err != nil {
+			return err
+		}
+		events := match
+		match = goami2.MatchAll(events, m)
+	}
+
+	cl, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	sub := cl.SubscribeMatch(match)
+	defer sub.Cancel()
+	for n := 0; cfg.count <= 0 || n < cfg.count; n++ {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return goami2.ErrClosed
+			}
+			if err := printMessage(out, msg, cfg.json); err != nil {
+				return err
+			}
+		case err := <-cl.Err():
+			if errors.Is(err, goami2.ErrEOF) {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// action sends action of the arguments or actions read from stdin and
+// prints responses
+func action(ctx context.Context, cfg config, args []string, stdin io.Reader, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing action name")
+	}
+	var actions []*goami2.Message
+	if args[0] == "-" {
+		r := bufio.NewReader(stdin)
+		for {
+			msg, err := goami2.ParseMessage(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			actions = append(actions, msg)
+		}
+	} else {
+		msg, err := actionOf(args)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, msg)
+	}
+
+	cl, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	for _, a := range actions {
+		msgs, err := cl.SendActionList(ctx, a)
+		var perr *goami2.ProtocolError
+		if errors.As(err, &perr) && perr.Response != nil {
+			_ = printMessage(out, perr.Response, cfg.json)
+		}
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := printMessage(out, msg, cfg.json); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// actionOf creates action from name and "Header=Value" arguments
+func actionOf(args []string) (*goami2.Message, error) {
+	msg := goami2.NewAction(args[0])
+	for _, arg := range args[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Header=Value", arg)
+		}
+		msg.AddField(name, value)
+	}
+	return msg, nil
+}
+
+func printMessage(out io.Writer, msg *goami2.Message, json bool) error {
+	var err error
+	if json {
+		_, err = fmt.Fprintln(out, msg.JSON())
+	} else {
+		_, err = io.WriteString(out, strings.ReplaceAll(msg.String(), "\r\n", "\n"))
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func server(t *testing.T) (*goami2test.Server, []string) {
+	ami := goami2test.NewServer()
+	t.Cleanup(func() { _ = ami.Close() })
+	addr, err := ami.Listen()
+	assert.Nil(t, err)
+	return ami, []string{"-addr", addr, "-user", ami.Username, "-secret", ami.Secret}
+}
+
+func TestTail(t *testing.T) {
+	ami, flags := server(t)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				ami.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/100-01"),
+					goami2test.Event("Hangup", "Channel", "PJSIP/100-01", "Cause", "16"))
+			}
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	args := append(flags, "-filter", `Event == "Hangup"`, "-json", "-n", "2", "tail")
+	code := run(context.Background(), args, nil, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+	msg, err := goami2.FromJSON([]byte(lines[0]))
+	assert.Nil(t, err)
+	assert.Equal(t, "Hangup", msg.Field("Event"))
+
+	code = run(context.Background(), append(flags, "-filter", `Event ==`, "tail"), nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "invalid filter expression")
+}
+
+func TestAction(t *testing.T) {
+	ami, flags := server(t)
+	ami.Handle("Getvar", func(action *goami2.Message) []*goami2.Message {
+		if action.Field("Variable") == "" {
+			return []*goami2.Message{goami2test.Error("No variable specified")}
+		}
+		return []*goami2.Message{goami2test.Success("Variable", action.Field("Variable"), "Value", "42")}
+	})
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append(flags, "action", "Getvar", "Variable=ANSWER"), nil, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Response: Success\nVariable: ANSWER\nValue: 42\n")
+
+	stdout.Reset()
+	stdin := strings.NewReader("Action: Getvar\nVariable: A\n\nAction: Getvar\n\n")
+	code = run(context.Background(), append(flags, "action", "-"), stdin, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout.String(), "Value: 42")
+	assert.Contains(t, stdout.String(), "Message: No variable specified")
+	assert.Contains(t, stderr.String(), "No variable specified")
+
+	assert.Equal(t, 1, run(context.Background(), append(flags, "action", "Getvar", "Variable"), nil, &stdout, &stderr))
+	assert.Equal(t, 2, run(context.Background(), append(flags, "unknown"), nil, &stdout, &stderr))
+}
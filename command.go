@@ -0,0 +1,89 @@
+package goami2
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	followsPrefix = "Response: Follows\r\n"
+	endCommand    = "--END COMMAND--"
+)
+
+// headers of the "Response: Follows" packet before command output
+var followsHeaders = []string{"Response", "Privilege", "ActionID", "Message"}
+
+// Command sends "Action: Command" with CLI command and returns command output lines.
+// Output is returned regardless of Asterisk version format: raw output lines
+// terminated with "--END COMMAND--" ("Response: Follows") or "Output" headers.
+// Returns error with AMI message if the command was rejected.
+func (c *Client) Command(ctx context.Context, cli string) ([]string, error) {
+	action := NewAction("Command")
+	action.AddField("Command", cli)
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(resp.Field("Response"), "error") {
+		return nil, rejected(resp, "command failed")
+	}
+	return resp.CommandOutput(), nil
+}
+
+// CommandOutput returns command output lines of "Action: Command" response.
+// Returns empty slice for messages without command output.
+func (m *Message) CommandOutput() []string {
+	return m.FieldValues("Output")
+}
+
+// parsePacket parses AMI packet including "Response: Follows" packets
+// and keeps the raw packet for Message.Bytes
+func parsePacket(pack string) (*Message, error) {
+	var msg *Message
+	var err error
+	if len(pack) < len(followsPrefix) || !strings.EqualFold(pack[:len(followsPrefix)], followsPrefix) {
+		msg, err = Parse(pack)
+	} else {
+		msg, err = parseFollows(pack)
+	}
+	if err != nil {
+		return nil, err
+	}
+	msg.raw = []byte(pack)
+	return msg, nil
+}
+
+// parseFollows parses "Response: Follows" packet. Known headers are parsed
+// and the rest lines until "--END COMMAND--" are added as "Output" headers
+func parseFollows(pack string) (*Message, error) {
+	body, _, found := strings.Cut(pack, endCommand)
+	if !found {
+		return nil, syntaxError(pack, len(pack), "invalid input: missing command end marker")
+	}
+
+	msg := NewMessage()
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	headers := true
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if headers {
+			name, value, ok := strings.Cut(line, ":")
+			if ok && isFollowsHeader(name) {
+				msg.AddField(name, strings.TrimLeft(value, " "))
+				continue
+			}
+			headers = false
+		}
+		msg.AddField("Output", line)
+	}
+	return msg, nil
+}
+
+func isFollowsHeader(name string) bool {
+	for _, h := range followsHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCommand(t *testing.T) {
+	setup := func(reply func(id string) string) *Client {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte(reply(msg.ActionID())))
+		}()
+		return cl
+	}
+
+	t.Run("response follows format", func(t *testing.T) {
+		cl := setup(func(id string) string {
+			return "Response: Follows\r\nPrivilege: Command\r\nActionID: " + id + "\r\n" +
+				"Channel              Location             State   Application(Data)\r\n" +
+				"\r\n" +
+				"0 active channels\r\n" +
+				"--END COMMAND--\r\n\r\n"
+		})
+		defer cl.Close()
+
+		out, err := cl.Command(context.Background(), "core show channels")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"Channel              Location             State   Application(Data)",
+			"",
+			"0 active channels",
+		}, out)
+	})
+
+	t.Run("output headers format", func(t *testing.T) {
+		cl := setup(func(id string) string {
+			return "Response: Success\r\nActionID: " + id + "\r\n" +
+				"Message: Command output follows\r\n" +
+				"Output: Name/username             Host\r\n" +
+				"Output: 0 sip peers\r\n\r\n"
+		})
+		defer cl.Close()
+
+		out, err := cl.Command(context.Background(), "sip show peers")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Name/username             Host", "0 sip peers"}, out)
+	})
+
+	t.Run("command rejected", func(t *testing.T) {
+		cl := setup(func(id string) string {
+			return "Response: Error\r\nActionID: " + id + "\r\nMessage: Permission denied\r\n\r\n"
+		})
+		defer cl.Close()
+
+		out, err := cl.Command(context.Background(), "core stop now")
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Permission denied")
+		assert.Nil(t, out)
+	})
+}
+
+func TestParseFollows(t *testing.T) {
+	t.Run("output glued to end marker", func(t *testing.T) {
+		msg, err := parsePacket("Response: Follows\r\nPrivilege: Command\r\n" +
+			"Uptime: 1 day\r\nSystem uptime--END COMMAND--\r\n\r\n")
+		assert.Nil(t, err)
+		assert.Equal(t, "Follows", msg.Field("Response"))
+		assert.Equal(t, "Command", msg.Field("Privilege"))
+		assert.Equal(t, []string{"Uptime: 1 day", "System uptime"}, msg.CommandOutput())
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		msg, err := parsePacket("Response: Follows\r\nActionID: 1\r\n--END COMMAND--\r\n\r\n")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", msg.ActionID())
+		assert.Empty(t, msg.CommandOutput())
+	})
+
+	t.Run("missing end marker", func(t *testing.T) {
+		_, err := parsePacket("Response: Follows\r\nActionID: 1\r\n\r\n")
+		assert.ErrorIs(t, err, ErrAMI)
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr)
+		assert.Equal(t, 34, perr.Offset)
+	})
+}
+
+func TestConsumeFollowsPacket(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	chPack, _ := consume(connClient, nil, DefaultLimits, false)
+	go func() {
+		_, _ = connSrv.Write([]byte("Response: Follows\r\nActionID: 1\r\nline 1\r\n\r\nline 3\r\n" +
+			"--END COMMAND--\r\n\r\nEvent: FullyBooted\r\n\r\n"))
+	}()
+	assert.Equal(t, "Response: Follows\r\nActionID: 1\r\nline 1\r\n\r\nline 3\r\n--END COMMAND--\r\n\r\n",
+		(<-chPack).data)
+	assert.Equal(t, "Event: FullyBooted\r\n\r\n", (<-chPack).data)
+	_ = connSrv.Close()
+}
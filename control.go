@@ -0,0 +1,162 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hangup hangs up the channel with "Action: Hangup". Channel can be a
+// regular expression in form "/regex/" to hang up all matching channels.
+// Cause is the hangup cause code, like 16 for normal clearing, and is not
+// sent when zero.
+func (c *Client) Hangup(ctx context.Context, channel string, cause int) (*Message, error) {
+	action := NewAction("Hangup")
+	action.AddField("Channel", channel)
+	if cause > 0 {
+		action.AddField("Cause", strconv.Itoa(cause))
+	}
+	return c.control(ctx, action, channel)
+}
+
+// Redirect transfers the channel to the dialplan extension with
+// "Action: Redirect". Priority defaults to 1 when zero.
+func (c *Client) Redirect(ctx context.Context, channel, dialContext, exten string, priority int) (*Message, error) {
+	if priority == 0 {
+		priority = 1
+	}
+	action := NewAction("Redirect")
+	action.AddField("Channel", channel)
+	action.AddField("Context", dialContext)
+	action.AddField("Exten", exten)
+	action.AddField("Priority", strconv.Itoa(priority))
+	return c.control(ctx, action, channel)
+}
+
+// Atxfer starts attended transfer of the channel to the extension with
+// "Action: Atxfer". Context of the channel is used when dialContext is empty.
+func (c *Client) Atxfer(ctx context.Context, channel, exten, dialContext string) (*Message, error) {
+	action := NewAction("Atxfer")
+	action.AddField("Channel", channel)
+	action.AddField("Exten", exten)
+	if dialContext != "" {
+		action.AddField("Context", dialContext)
+	}
+	return c.control(ctx, action, channel)
+}
+
+// Park parks the channel in the parking lot with "Action: Park". Default
+// parking lot is used when parkinglot is empty and parking lot timeout
+// when timeout is zero.
+func (c *Client) Park(ctx context.Context, channel, parkinglot string, timeout time.Duration) (*Message, error) {
+	action := NewAction("Park")
+	action.AddField("Channel", channel)
+	if parkinglot != "" {
+		action.AddField("Parkinglot", parkinglot)
+	}
+	if timeout > 0 {
+		action.AddField("Timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	}
+	return c.control(ctx, action, channel)
+}
+
+// Bridge bridges two channels with "Action: Bridge". When tone is true
+// courtesy tone is played to the second channel.
+func (c *Client) Bridge(ctx context.Context, channel1, channel2 string, tone bool) (*Message, error) {
+	if channel2 == "" {
+		return nil, fmt.Errorf("%w: bridge: missing channel", ErrAMI)
+	}
+	action := NewAction("Bridge")
+	action.AddField("Channel1", channel1)
+	action.AddField("Channel2", channel2)
+	action.AddField("Tone", yesNo(tone))
+	return c.control(ctx, action, channel1)
+}
+
+// PlayDTMF plays DTMF digit on the channel with "Action: PlayDTMF".
+// Default duration is used when duration is zero.
+func (c *Client) PlayDTMF(ctx context.Context, channel string, digit rune, duration time.Duration) (*Message, error) {
+	if !strings.ContainsRune("0123456789*#ABCDabcdwW", digit) {
+		return nil, fmt.Errorf("%w: playdtmf: invalid digit %q", ErrAMI, digit)
+	}
+	action := NewAction("PlayDTMF")
+	action.AddField("Channel", channel)
+	action.AddField("Digit", string(digit))
+	if duration > 0 {
+		action.AddField("Duration", strconv.FormatInt(duration.Milliseconds(), 10))
+	}
+	return c.control(ctx, action, channel)
+}
+
+// MuteAudio mutes or unmutes audio of the channel with "Action: MuteAudio".
+// Direction is "in", "out" or "all".
+func (c *Client) MuteAudio(ctx context.Context, channel, direction string, mute bool) (*Message, error) {
+	switch direction {
+	case "in", "out", "all":
+	default:
+		return nil, fmt.Errorf("%w: muteaudio: invalid direction %q", ErrAMI, direction)
+	}
+	state := "off"
+	if mute {
+		state = "on"
+	}
+	action := NewAction("MuteAudio")
+	action.AddField("Channel", channel)
+	action.AddField("Direction", direction)
+	action.AddField("State", state)
+	return c.control(ctx, action, channel)
+}
+
+// MixMonitor starts recording of the channel to the file with
+// "Action: MixMonitor". Options are MixMonitor application options, like
+// "b" to record only bridged audio. Response has "MixMonitorID" header used
+// to stop this recording with StopMixMonitor.
+func (c *Client) MixMonitor(ctx context.Context, channel, file, options string) (*Message, error) {
+	if file == "" {
+		return nil, fmt.Errorf("%w: mixmonitor: missing file", ErrAMI)
+	}
+	action := NewAction("MixMonitor")
+	action.AddField("Channel", channel)
+	action.AddField("File", file)
+	if options != "" {
+		action.AddField("Options", options)
+	}
+	return c.control(ctx, action, channel)
+}
+
+// StopMixMonitor stops recording of the channel with "Action: StopMixMonitor".
+// When id is empty the last started recording of the channel is stopped.
+func (c *Client) StopMixMonitor(ctx context.Context, channel, id string) (*Message, error) {
+	action := NewAction("StopMixMonitor")
+	action.AddField("Channel", channel)
+	if id != "" {
+		action.AddField("MixMonitorID", id)
+	}
+	return c.control(ctx, action, channel)
+}
+
+// control sends call control action and returns its successful response.
+// Returns error without sending the action when channel is missing.
+func (c *Client) control(ctx context.Context, action *Message, channel string) (*Message, error) {
+	name := strings.ToLower(action.Field("Action"))
+	if channel == "" {
+		return nil, fmt.Errorf("%w: %s: missing channel", ErrAMI, name)
+	}
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, name+" failed")
+	}
+	return resp, nil
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
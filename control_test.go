@@ -0,0 +1,106 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCallControl(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	actions := make(chan *Message, 1)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			resp := "Response: Success\r\n"
+			switch {
+			case msg.Field("Channel") == "PJSIP/999-01":
+				resp = "Response: Error\r\nMessage: No such channel\r\n"
+			case msg.Field("Action") == "MixMonitor":
+				resp += "MixMonitorID: 0x7f01\r\n"
+			}
+			_, _ = connSrv.Write([]byte(resp + "ActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	ctx := context.Background()
+	tests := map[string]struct {
+		send func() (*Message, error)
+		want string
+	}{
+		"hangup": {func() (*Message, error) { return cl.Hangup(ctx, "PJSIP/100-01", 16) },
+			"Action: Hangup\r\nChannel: PJSIP/100-01\r\nCause: 16\r\n"},
+		"hangup without cause": {func() (*Message, error) { return cl.Hangup(ctx, "/^PJSIP/", 0) },
+			"Action: Hangup\r\nChannel: /^PJSIP/\r\n"},
+		"redirect": {func() (*Message, error) { return cl.Redirect(ctx, "PJSIP/100-01", "default", "200", 0) },
+			"Action: Redirect\r\nChannel: PJSIP/100-01\r\nContext: default\r\nExten: 200\r\nPriority: 1\r\n"},
+		"atxfer": {func() (*Message, error) { return cl.Atxfer(ctx, "PJSIP/100-01", "300", "") },
+			"Action: Atxfer\r\nChannel: PJSIP/100-01\r\nExten: 300\r\n"},
+		"park": {func() (*Message, error) { return cl.Park(ctx, "PJSIP/100-01", "sales", 45*time.Second) },
+			"Action: Park\r\nChannel: PJSIP/100-01\r\nParkinglot: sales\r\nTimeout: 45000\r\n"},
+		"bridge": {func() (*Message, error) { return cl.Bridge(ctx, "PJSIP/100-01", "PJSIP/200-01", true) },
+			"Action: Bridge\r\nChannel1: PJSIP/100-01\r\nChannel2: PJSIP/200-01\r\nTone: yes\r\n"},
+		"playdtmf": {func() (*Message, error) { return cl.PlayDTMF(ctx, "PJSIP/100-01", '#', 250*time.Millisecond) },
+			"Action: PlayDTMF\r\nChannel: PJSIP/100-01\r\nDigit: #\r\nDuration: 250\r\n"},
+		"muteaudio": {func() (*Message, error) { return cl.MuteAudio(ctx, "PJSIP/100-01", "in", true) },
+			"Action: MuteAudio\r\nChannel: PJSIP/100-01\r\nDirection: in\r\nState: on\r\n"},
+		"mixmonitor": {func() (*Message, error) { return cl.MixMonitor(ctx, "PJSIP/100-01", "call.wav", "b") },
+			"Action: MixMonitor\r\nChannel: PJSIP/100-01\r\nFile: call.wav\r\nOptions: b\r\n"},
+		"stopmixmonitor": {func() (*Message, error) { return cl.StopMixMonitor(ctx, "PJSIP/100-01", "0x7f01") },
+			"Action: StopMixMonitor\r\nChannel: PJSIP/100-01\r\nMixMonitorID: 0x7f01\r\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := tc.send()
+			assert.Nil(t, err)
+			assert.True(t, resp.IsSuccess())
+			action := <-actions
+			assert.Equal(t, tc.want+"ActionID: "+action.ActionID()+"\r\n\r\n", action.String())
+		})
+	}
+
+	resp, err := cl.MixMonitor(ctx, "PJSIP/100-01", "call.wav", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "0x7f01", resp.Field("MixMonitorID"))
+	<-actions
+
+	_, err = cl.Hangup(ctx, "PJSIP/999-01", 0)
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorIs(t, err, ErrNoSuchChannel)
+	assert.ErrorContains(t, err, "hangup failed")
+	assert.ErrorContains(t, err, "No such channel")
+	<-actions
+}
+
+func TestClientCallControlInvalid(t *testing.T) {
+	cl := makeClient(nil)
+	ctx := context.Background()
+	tests := map[string]func() (*Message, error){
+		"hangup: missing channel":         func() (*Message, error) { return cl.Hangup(ctx, "", 0) },
+		"bridge: missing channel":         func() (*Message, error) { return cl.Bridge(ctx, "PJSIP/100-01", "", false) },
+		`playdtmf: invalid digit 'x'`:     func() (*Message, error) { return cl.PlayDTMF(ctx, "PJSIP/100-01", 'x', 0) },
+		`muteaudio: invalid direction ""`: func() (*Message, error) { return cl.MuteAudio(ctx, "PJSIP/100-01", "", true) },
+		"mixmonitor: missing file":        func() (*Message, error) { return cl.MixMonitor(ctx, "PJSIP/100-01", "", "") },
+	}
+	for want, send := range tests {
+		t.Run(want, func(t *testing.T) {
+			resp, err := send()
+			assert.ErrorIs(t, err, ErrAMI)
+			assert.ErrorContains(t, err, want)
+			assert.Nil(t, resp)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package goami2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Credentials of the manager user
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsProvider returns credentials to login with, for example loaded
+// from a secret store. It is called before every login, including reconnect.
+type CredentialsProvider func(ctx context.Context) ([]Credentials, error)
+
+// loginAny logs in with the first accepted credentials: credentials of the
+// provider, then given credentials and fallback credentials. Connection is
+// redialed before every next credentials, since Asterisk drops connection
+// after failed login. Accepted credentials are kept for reconnect.
+func (c *Client) loginAny(ctx context.Context, username, password string) error {
+	creds, err := c.credentials(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	for i, cred := range creds {
+		if i > 0 {
+			c.closeConn()
+			conn, derr := c.dial(ctx)
+			if derr != nil {
+				return derr
+			}
+			if !c.setConn(conn) {
+				return fmt.Errorf("%w: client closed", ErrConn)
+			}
+		}
+		if err = c.login(ctx, cred.Username, cred.Password); err == nil {
+			c.mu.Lock()
+			c.username, c.password = cred.Username, cred.Password
+			c.mu.Unlock()
+			return nil
+		}
+		var perr *ProtocolError
+		if !errors.As(err, &perr) || perr.Response == nil {
+			return err // not rejected login
+		}
+	}
+	return err
+}
+
+// credentials returns credentials to login with in order without duplicates
+func (c *Client) credentials(ctx context.Context, username, password string) ([]Credentials, error) {
+	var creds []Credentials
+	if c.credProvider != nil {
+		provided, err := c.credProvider(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to get credentials: %w", ErrConn, err)
+		}
+		creds = append(creds, provided...)
+	}
+	creds = append(creds, Credentials{Username: username, Password: password})
+	creds = append(creds, c.fallbackCreds...)
+
+	seen := make(map[Credentials]bool, len(creds))
+	unique := creds[:0]
+	for _, cred := range creds {
+		if !seen[cred] {
+			seen[cred] = true
+			unique = append(unique, cred)
+		}
+	}
+	return unique, nil
+}
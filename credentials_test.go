@@ -0,0 +1,66 @@
+package goami2_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCredentials(t *testing.T) {
+	ami := goami2test.NewServer()
+	ami.Username, ami.Secret = "admin", "n3w"
+	defer ami.Close()
+	addr, err := ami.Listen()
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	t.Run("fallback credentials", func(t *testing.T) {
+		cl, err := goami2.Dial(ctx, addr, "admin", "0ld", goami2.WithCredentials(
+			goami2.Credentials{Username: "guest", Password: "n3w"},
+			goami2.Credentials{Username: "admin", Password: "n3w"},
+		))
+		assert.Nil(t, err)
+		cl.Close()
+	})
+
+	t.Run("all credentials rejected", func(t *testing.T) {
+		_, err := goami2.Dial(ctx, addr, "admin", "0ld", goami2.WithCredentials(
+			goami2.Credentials{Username: "admin", Password: "0ld"},
+			goami2.Credentials{Username: "admin", Password: "wr0ng"},
+		))
+		var perr *goami2.ProtocolError
+		assert.True(t, errors.As(err, &perr))
+		assert.NotNil(t, perr.Response)
+	})
+
+	t.Run("provider refreshes credentials on reconnect", func(t *testing.T) {
+		var calls atomic.Int32
+		provider := func(context.Context) ([]goami2.Credentials, error) {
+			calls.Add(1)
+			return []goami2.Credentials{{Username: "admin", Password: "n3w"}}, nil
+		}
+		cl, err := goami2.Dial(ctx, addr, "admin", "0ld",
+			goami2.WithCredentialsProvider(provider), goami2.WithReconnect(0, time.Millisecond))
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, int32(1), calls.Load())
+
+		ami.Drop()
+		assert.Eventually(t, func() bool {
+			return calls.Load() == 2 && cl.State() == goami2.StateConnected
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("provider fails", func(t *testing.T) {
+		_, err := goami2.Dial(ctx, addr, "admin", "n3w", goami2.WithCredentialsProvider(
+			func(context.Context) ([]goami2.Credentials, error) { return nil, errors.New("vault is sealed") }))
+		assert.ErrorIs(t, err, goami2.ErrConn)
+		assert.ErrorContains(t, err, "failed to get credentials: vault is sealed")
+	})
+}
@@ -0,0 +1,112 @@
+package goami2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DB is the key-value API of the Asterisk database (AstDB), see Client.DB
+type DB struct {
+	client *Client
+}
+
+// DB returns API of the Asterisk database. Keys are grouped into families,
+// like "/family/key" with database CLI commands.
+func (c *Client) DB() *DB {
+	return &DB{client: c}
+}
+
+// Get returns value of the key with "Action: DBGet". Value is delivered with
+// "DBGetResponse" event following the response. Returns error wrapping
+// ErrNotFound when key does not exist.
+func (db *DB) Get(ctx context.Context, family, key string) (string, error) {
+	if family == "" || key == "" {
+		return "", fmt.Errorf("%w: dbget: missing family or key", ErrAMI)
+	}
+	action := NewAction("DBGet")
+	action.AddField("Family", family)
+	action.AddField("Key", key)
+	events, err := db.client.SendActionList(ctx, action)
+	if err != nil {
+		return "", dbError(err, "dbget", family, key)
+	}
+	for _, ev := range events {
+		if ev.IsEvent() && strings.EqualFold(ev.Field("Event"), "DBGetResponse") {
+			return ev.Field("Val"), nil
+		}
+	}
+	// old versions reply with the value in the response
+	if resp := events[0]; resp.IsResponse() && resp.Field("Val") != "" {
+		return resp.Field("Val"), nil
+	}
+	return "", fmt.Errorf("%w: dbget: no DBGetResponse event for %s/%s", ErrAMI, family, key)
+}
+
+// Put sets value of the key with "Action: DBPut"
+func (db *DB) Put(ctx context.Context, family, key, value string) error {
+	if family == "" || key == "" {
+		return fmt.Errorf("%w: dbput: missing family or key", ErrAMI)
+	}
+	action := NewAction("DBPut")
+	action.AddField("Family", family)
+	action.AddField("Key", key)
+	action.AddField("Val", value)
+	return db.send(ctx, action, "dbput", family, key)
+}
+
+// Del deletes the key with "Action: DBDel". Returns error wrapping
+// ErrNotFound when key does not exist.
+func (db *DB) Del(ctx context.Context, family, key string) error {
+	if family == "" || key == "" {
+		return fmt.Errorf("%w: dbdel: missing family or key", ErrAMI)
+	}
+	action := NewAction("DBDel")
+	action.AddField("Family", family)
+	action.AddField("Key", key)
+	return db.send(ctx, action, "dbdel", family, key)
+}
+
+// DelTree deletes keys of the family with "Action: DBDelTree". When key is
+// not empty only keys of the subtree family/key are deleted. Returns error
+// wrapping ErrNotFound when nothing is deleted.
+func (db *DB) DelTree(ctx context.Context, family, key string) error {
+	if family == "" {
+		return fmt.Errorf("%w: dbdeltree: missing family", ErrAMI)
+	}
+	action := NewAction("DBDelTree")
+	action.AddField("Family", family)
+	if key != "" {
+		action.AddField("Key", key)
+	}
+	return db.send(ctx, action, "dbdeltree", family, key)
+}
+
+func (db *DB) send(ctx context.Context, action *Message, op, family, key string) error {
+	resp, err := db.client.request(ctx, action)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return dbError(rejected(resp, op+" failed"), op, family, key)
+	}
+	return nil
+}
+
+// dbError adds database path to the rejected action with "Database entry
+// not found" message
+func dbError(err error, op, family, key string) error {
+	var perr *ProtocolError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &perr) || perr.Response == nil {
+		return err
+	}
+	path := "/" + family
+	if key != "" {
+		path += "/" + key
+	}
+	return &ProtocolError{
+		Response: perr.Response,
+		Err:      fmt.Errorf("%w: %s: %s", ErrNotFound, op, path),
+	}
+}
@@ -0,0 +1,70 @@
+package goami2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientDB(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	store := map[string]string{"/flags/night": "1"}
+	notFound := []*goami2.Message{goami2test.Error("Database entry not found")}
+	ami.Handle("DBGet", func(action *goami2.Message) []*goami2.Message {
+		val, ok := store["/"+action.Field("Family")+"/"+action.Field("Key")]
+		if !ok {
+			return notFound
+		}
+		return []*goami2.Message{
+			goami2test.Success("Message", "Result will follow"),
+			goami2test.Event("DBGetResponse", "Family", action.Field("Family"),
+				"Key", action.Field("Key"), "Val", val),
+			goami2test.Event("DBGetComplete", "EventList", "Complete", "ListItems", "1"),
+		}
+	})
+	ami.Handle("DBPut", func(action *goami2.Message) []*goami2.Message {
+		store["/"+action.Field("Family")+"/"+action.Field("Key")] = action.Field("Val")
+		return []*goami2.Message{goami2test.Success("Message", "Updated database successfully")}
+	})
+	ami.Handle("DBDel", func(action *goami2.Message) []*goami2.Message {
+		path := "/" + action.Field("Family") + "/" + action.Field("Key")
+		if _, ok := store[path]; !ok {
+			return notFound
+		}
+		delete(store, path)
+		return []*goami2.Message{goami2test.Success("Message", "Key deleted successfully")}
+	})
+	ami.Handle("DBDelTree", func(*goami2.Message) []*goami2.Message { return notFound })
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+	ctx := context.Background()
+	db := cl.DB()
+
+	val, err := db.Get(ctx, "flags", "night")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", val)
+
+	assert.Nil(t, db.Put(ctx, "route", "100", "PJSIP/100"))
+	val, err = db.Get(ctx, "route", "100")
+	assert.Nil(t, err)
+	assert.Equal(t, "PJSIP/100", val)
+
+	assert.Nil(t, db.Del(ctx, "route", "100"))
+	_, err = db.Get(ctx, "route", "100")
+	assert.ErrorIs(t, err, goami2.ErrNotFound)
+	assert.EqualError(t, err, "goami2: AMI proto: not found: dbget: /route/100")
+	var perr *goami2.ProtocolError
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, "Database entry not found", perr.Response.Field("Message"))
+
+	assert.ErrorIs(t, db.Del(ctx, "route", "100"), goami2.ErrNotFound)
+	assert.ErrorIs(t, db.DelTree(ctx, "route", ""), goami2.ErrNotFound)
+	assert.ErrorIs(t, db.Put(ctx, "", "100", "x"), goami2.ErrAMI)
+}
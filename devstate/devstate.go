@@ -0,0 +1,176 @@
+// Package devstate keeps in-memory cache of Asterisk device and presence
+// states loaded with DeviceStateList and PresenceStateList actions and kept
+// updated from DeviceStateChange and PresenceStateChange events.
+package devstate
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// watchBuffer is the buffer size of the watch channel
+const watchBuffer = 16
+
+// events consumed by the cache
+var stateEvents = []string{"DeviceStateChange", "PresenceStateChange", "Reconnected"}
+
+// State is the cached state of the device or presence provider
+type State struct {
+	Device   string    // device, like "PJSIP/100", or presence provider, like "CustomPresence:100"
+	State    string    // device state, like "NOT_INUSE", "INUSE" or "RINGING"
+	Presence string    // presence status, like "available", "away" or "dnd"
+	Subtype  string    // presence subtype
+	Message  string    // presence message
+	Updated  time.Time // time of the last change
+}
+
+// Cache is the device and presence state cache. Cache is safe for
+// concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	states   map[string]*State // by lower case device name
+	watchers map[<-chan State]*watcher
+	now      func() time.Time
+}
+
+type watcher struct {
+	device string // lower case device name
+	ch     chan State
+}
+
+// New creates empty cache
+func New() *Cache {
+	return &Cache{
+		states:   make(map[string]*State),
+		watchers: make(map[<-chan State]*watcher),
+		now:      time.Now,
+	}
+}
+
+// Attach registers cache as AMI client event handler. Cache is loaded
+// again when client reconnects. Handler can be removed with
+// Client.RemoveHandler. Attach before Load to not miss changes sent while
+// the cache is loading.
+func (c *Cache) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.OnEvents(stateEvents, func(msg *goami2.Message) {
+		if strings.EqualFold(msg.Field("Event"), "Reconnected") {
+			_ = c.Load(context.Background(), cl)
+			return
+		}
+		c.Handle(msg)
+	})
+}
+
+// Load updates cache with the result of DeviceStateList and
+// PresenceStateList actions
+func (c *Cache) Load(ctx context.Context, cl *goami2.Client) error {
+	for _, action := range []string{"DeviceStateList", "PresenceStateList"} {
+		list, err := cl.SendActionList(ctx, goami2.NewAction(action))
+		if err != nil {
+			return err
+		}
+		for _, msg := range list {
+			c.Handle(msg)
+		}
+	}
+	return nil
+}
+
+// Handle updates cache with DeviceStateChange or PresenceStateChange event.
+// Other events are ignored. Watchers of the device are notified when its
+// state changes.
+func (c *Cache) Handle(msg *goami2.Message) {
+	var device string
+	var update func(*State)
+	switch strings.ToLower(msg.Field("Event")) {
+	case "devicestatechange":
+		device = msg.Field("Device")
+		update = func(st *State) { st.State = msg.Field("State") }
+	case "presencestatechange":
+		device = msg.Field("Presentity")
+		update = func(st *State) {
+			st.Presence = msg.Field("Status")
+			st.Subtype = msg.Field("Subtype")
+			st.Message = msg.Field("Message")
+		}
+	}
+	if device == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(device)
+	st, ok := c.states[key]
+	if !ok {
+		st = &State{Device: device}
+		c.states[key] = st
+	}
+	prev := *st
+	update(st)
+	if ok && prev.State == st.State && prev.Presence == st.Presence &&
+		prev.Subtype == st.Subtype && prev.Message == st.Message {
+		return
+	}
+	st.Updated = c.now()
+	for _, w := range c.watchers {
+		if w.device != key {
+			continue
+		}
+		select {
+		case w.ch <- *st:
+		default:
+			// watcher is too slow, drop the update
+		}
+	}
+}
+
+// State returns cached state of the device. Device name is case insensitive.
+func (c *Cache) State(device string) (State, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.states[strings.ToLower(device)]
+	if !ok {
+		return State{}, false
+	}
+	return *st, true
+}
+
+// States returns cached states ordered by device name
+func (c *Cache) States() []State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	states := make([]State, 0, len(c.states))
+	for _, st := range c.states {
+		states = append(states, *st)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Device < states[j].Device })
+	return states
+}
+
+// Watch returns a channel that receives state of the device every time it
+// changes. Device name is case insensitive. Channel is buffered and updates
+// are dropped when watcher is too slow to read them. Channel is closed with
+// Unwatch.
+func (c *Cache) Watch(device string) <-chan State {
+	w := &watcher{device: strings.ToLower(device), ch: make(chan State, watchBuffer)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers[w.ch] = w
+	return w.ch
+}
+
+// Unwatch stops updates to the watch channel and closes it
+func (c *Cache) Unwatch(ch <-chan State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w, ok := c.watchers[ch]; ok {
+		close(w.ch)
+		delete(c.watchers, ch)
+	}
+}
@@ -0,0 +1,194 @@
+package devstate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+// serve accepts client login, writes packets after login and replies to
+// actions with packets returned by reply for the action name and ActionID
+func serve(t *testing.T, after string, reply func(action, id string) string) *goami2.Client {
+	connClient, connSrv := net.Pipe()
+	go func() {
+		r := bufio.NewReader(connSrv)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+		for {
+			var packet strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				packet.WriteString(line)
+				if line == "\r\n" {
+					break
+				}
+			}
+			msg, err := goami2.Parse(packet.String())
+			if err != nil {
+				return
+			}
+			if msg.Field("Action") == "Login" {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+				continue
+			}
+			if msg.Field("Action") == "Ping" {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n" + after))
+				continue
+			}
+			_, _ = connSrv.Write([]byte(reply(msg.Field("Action"), msg.ActionID())))
+		}
+	}()
+
+	cl, err := goami2.NewClientWithContext(context.Background(), connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	return cl
+}
+
+func listReply(action, id string) string {
+	start := "Response: Success\r\nActionID: " + id + "\r\nEventList: start\r\n" +
+		"Message: List will follow\r\n\r\n"
+	if action == "PresenceStateList" {
+		return start +
+			"Event: PresenceStateChange\r\nActionID: " + id + "\r\nPresentity: CustomPresence:100\r\n" +
+			"Status: away\r\nSubtype: lunch\r\nMessage: back at 2\r\n\r\n" +
+			"Event: PresenceStateListComplete\r\nActionID: " + id + "\r\nEventList: Complete\r\n\r\n"
+	}
+	return start +
+		"Event: DeviceStateChange\r\nActionID: " + id + "\r\nDevice: PJSIP/100\r\nState: INUSE\r\n\r\n" +
+		"Event: DeviceStateChange\r\nActionID: " + id + "\r\nDevice: Custom:dnd\r\nState: NOT_INUSE\r\n\r\n" +
+		"Event: DeviceStateListComplete\r\nActionID: " + id + "\r\nEventList: Complete\r\n\r\n"
+}
+
+func TestCacheLoad(t *testing.T) {
+	cl := serve(t, "", listReply)
+	defer cl.Close()
+
+	cache := New()
+	assert.Nil(t, cache.Load(context.Background(), cl))
+
+	states := cache.States()
+	assert.Len(t, states, 3)
+	assert.Equal(t, "Custom:dnd", states[0].Device)
+	assert.Equal(t, "CustomPresence:100", states[1].Device)
+	assert.Equal(t, "PJSIP/100", states[2].Device)
+
+	st, ok := cache.State("pjsip/100")
+	assert.True(t, ok)
+	assert.Equal(t, "INUSE", st.State)
+	st, _ = cache.State("CustomPresence:100")
+	assert.Equal(t, "away", st.Presence)
+	assert.Equal(t, "lunch", st.Subtype)
+	assert.Equal(t, "back at 2", st.Message)
+	_, ok = cache.State("PJSIP/200")
+	assert.False(t, ok)
+}
+
+func TestCacheLoadFails(t *testing.T) {
+	cl := serve(t, "", func(action, id string) string {
+		return "Response: Error\r\nActionID: " + id + "\r\nMessage: Invalid/unknown command\r\n\r\n"
+	})
+	defer cl.Close()
+
+	assert.ErrorIs(t, New().Load(context.Background(), cl), goami2.ErrAMI)
+}
+
+func TestCacheWatch(t *testing.T) {
+	cache := New()
+	now := time.Date(2020, 8, 31, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	ch := cache.Watch("pjsip/100")
+	other := cache.Watch("PJSIP/200")
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/100\r\nState: RINGING\r\n"))
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/100\r\nState: RINGING\r\n"))
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/100\r\nState: INUSE\r\n"))
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/300\r\nState: INUSE\r\n"))
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nState: INUSE\r\n"))
+	cache.Handle(event(t, "Event: Newstate\r\nDevice: PJSIP/100\r\n"))
+
+	st := <-ch
+	assert.Equal(t, "PJSIP/100", st.Device)
+	assert.Equal(t, "RINGING", st.State)
+	assert.Equal(t, now, st.Updated)
+	assert.Equal(t, "INUSE", (<-ch).State)
+	assert.Len(t, ch, 0)
+	assert.Len(t, other, 0)
+	assert.Len(t, cache.States(), 2)
+
+	cache.Unwatch(ch)
+	_, ok := <-ch
+	assert.False(t, ok)
+	cache.Unwatch(ch)
+	cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/100\r\nState: NOT_INUSE\r\n"))
+
+	// slow watcher drops updates
+	for i := 0; i < watchBuffer+2; i++ {
+		state := "INUSE"
+		if i%2 == 0 {
+			state = "NOT_INUSE"
+		}
+		cache.Handle(event(t, "Event: DeviceStateChange\r\nDevice: PJSIP/200\r\nState: "+state+"\r\n"))
+	}
+	assert.Len(t, other, watchBuffer)
+}
+
+func TestCacheAttach(t *testing.T) {
+	after := "Event: DeviceStateChange\r\nDevice: PJSIP/300\r\nState: RINGING\r\n\r\n" +
+		"Event: Reconnected\r\n\r\n"
+	cl := serve(t, after, listReply)
+	defer cl.Close()
+
+	cache := New()
+	loaded := cache.Watch("CustomPresence:100")
+	ringing := cache.Watch("PJSIP/300")
+	cache.Attach(cl)
+	_, err := cl.SendAction(context.Background(), goami2.NewAction("Ping"))
+	assert.Nil(t, err)
+
+	for _, ch := range []<-chan State{ringing, loaded} {
+		select {
+		case st := <-ch:
+			assert.NotEmpty(t, st.Device)
+		case <-time.After(time.Second):
+			t.Fatal("state is not updated")
+		}
+	}
+	st, _ := cache.State("PJSIP/300")
+	assert.Equal(t, "RINGING", st.State)
+}
+
+func TestCacheAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	cache := New()
+	cache.Attach(cl)
+	const devices = 3000
+	var burst strings.Builder
+	for i := 0; i < devices; i++ {
+		fmt.Fprintf(&burst, "Event: DeviceStateChange\r\nDevice: PJSIP/%d\r\nState: INUSE\r\n\r\n", i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return len(cache.States()) == devices }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
@@ -0,0 +1,63 @@
+package goami2
+
+import (
+	"context"
+	"sync"
+)
+
+// Drain gracefully closes client for maintenance. New actions fail with
+// ErrDraining right away, while actions waiting for the response and event
+// lists being collected are completed. Then client logs off and closes, see
+// Shutdown. When context is done first, client is closed, unfinished actions
+// fail with ErrClosed and context error is returned.
+func (c *Client) Drain(ctx context.Context) error {
+	c.mu.Lock()
+	c.draining = true
+	c.mu.Unlock()
+	c.logger.Info("draining client")
+
+	done := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.Close()
+		return ctx.Err()
+	}
+	return c.Shutdown(ctx)
+}
+
+// Draining returns true after Drain is called
+func (c *Client) Draining() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.draining
+}
+
+// accept registers action in flight, so Drain waits for it. Returned
+// function unregisters the action and is safe to call more than once.
+// Returns ErrDraining when client is draining, except for the internal
+// keepalive and logoff actions exempt from the rate limit.
+func (c *Client) accept(ctx context.Context) (func(), error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		if rateExempt(ctx) {
+			return func() {}, nil
+		}
+		return nil, ErrDraining
+	}
+	c.inflight.Add(1)
+	return sync.OnceFunc(c.inflight.Done), nil
+}
+
+// acceptSend returns ErrDraining when client is draining
+func (c *Client) acceptSend() error {
+	if c.Draining() {
+		return ErrDraining
+	}
+	return nil
+}
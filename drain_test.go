@@ -0,0 +1,84 @@
+package goami2_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientDrain(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("CoreShowChannels", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{
+			goami2test.Success("EventList", "start", "Message", "Channels will follow"),
+			goami2test.Event("CoreShowChannel", "Channel", "PJSIP/100-01"),
+		}
+	})
+	ctx := context.Background()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+
+	listed := make(chan []*goami2.Message)
+	go func() {
+		action := goami2.NewAction("CoreShowChannels")
+		action.AddField("ActionID", "list-1")
+		msgs, err := cl.SendActionList(ctx, action)
+		assert.Nil(t, err)
+		listed <- msgs
+	}()
+	_, err = ami.WaitAction(ctx, "CoreShowChannels")
+	assert.Nil(t, err)
+
+	drained := make(chan error)
+	go func() { drained <- cl.Drain(ctx) }()
+	assert.Eventually(t, cl.Draining, time.Second, time.Millisecond)
+
+	_, err = cl.SendAction(ctx, goami2.NewAction("Ping"))
+	assert.ErrorIs(t, err, goami2.ErrDraining)
+	assert.ErrorIs(t, err, goami2.ErrClosed)
+	assert.ErrorIs(t, cl.SendContext(ctx, goami2.NewAction("Ping")), goami2.ErrDraining)
+	assert.ErrorIs(t, cl.MustSend(goami2.NewAction("Ping").Byte()), goami2.ErrDraining)
+
+	select {
+	case <-drained:
+		t.Fatal("drained before list is complete")
+	case <-time.After(20 * time.Millisecond):
+	}
+	ami.Emit(goami2test.Event("CoreShowChannelsComplete", "ActionID", "list-1", "ListItems", "1"))
+	assert.Len(t, <-listed, 2)
+	assert.Nil(t, <-drained)
+	_, err = ami.WaitAction(ctx, "Logoff")
+	assert.Nil(t, err)
+	assert.Equal(t, goami2.StateClosed, cl.State())
+
+	t.Run("context done", func(t *testing.T) {
+		ami := goami2test.NewServer()
+		defer ami.Close()
+		block := make(chan struct{})
+		defer close(block)
+		ami.Handle("QueueStatus", func(*goami2.Message) []*goami2.Message {
+			<-block
+			return nil
+		})
+		cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+		assert.Nil(t, err)
+
+		failed := make(chan error)
+		go func() {
+			_, err := cl.SendAction(ctx, goami2.NewAction("QueueStatus"))
+			failed <- err
+		}()
+		_, err = ami.WaitAction(ctx, "QueueStatus")
+		assert.Nil(t, err)
+
+		dctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, cl.Drain(dctx), context.DeadlineExceeded)
+		assert.ErrorIs(t, <-failed, goami2.ErrClosed)
+	})
+}
@@ -0,0 +1,126 @@
+// Package dtmf pairs DTMFBegin and DTMFEnd events of the channels into
+// streams of the pressed digits.
+package dtmf
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// digitsBuffer is the buffer size of the digits channel
+const digitsBuffer = 32
+
+// repeatWindow is the time after the digit end when another end of the same
+// digit without begin is considered a duplicate
+const repeatWindow = 100 * time.Millisecond
+
+// events consumed by the monitor. Asterisk 11 and older send "DTMF" event
+// with "Begin" and "End" headers.
+var dtmfEvents = []string{"DTMFBegin", "DTMFEnd", "DTMF", "Hangup"}
+
+// Monitor keeps digit streams of the channels. Only digits received from
+// the channel are streamed, digits sent to the channel are ignored. Monitor
+// is safe for concurrent use.
+type Monitor struct {
+	mu    sync.Mutex
+	chans map[string]*stream // by channel name
+	now   func() time.Time
+}
+
+type stream struct {
+	ch      chan rune
+	begin   rune // digit of DTMFBegin without DTMFEnd, zero when none
+	last    rune // last streamed digit
+	lastEnd time.Time
+}
+
+// New creates monitor without streams
+func New() *Monitor {
+	return &Monitor{
+		chans: make(map[string]*stream),
+		now:   time.Now,
+	}
+}
+
+// Attach registers monitor as AMI client event handler. Handler can be
+// removed with Client.RemoveHandler.
+func (m *Monitor) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.OnEvents(dtmfEvents, m.Handle)
+}
+
+// Digits returns stream of the digits received from the channel. Digit is
+// sent when it ends, begin and end of the same key press give one digit.
+// Every call for the channel returns the same stream. Channel is buffered
+// and digits are dropped when reader is too slow. Channel is closed when
+// channel hangs up or with Close.
+func (m *Monitor) Digits(channel string) <-chan rune {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.chans[channel]
+	if !ok {
+		s = &stream{ch: make(chan rune, digitsBuffer)}
+		m.chans[channel] = s
+	}
+	return s.ch
+}
+
+// Close closes digits stream of the channel
+func (m *Monitor) Close(channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.chans[channel]; ok {
+		close(s.ch)
+		delete(m.chans, channel)
+	}
+}
+
+// Handle updates streams with DTMFBegin, DTMFEnd and Hangup events. Other
+// events and events of the channels without stream are ignored.
+func (m *Monitor) Handle(msg *goami2.Message) {
+	channel := msg.Field("Channel")
+	name := strings.ToLower(msg.Field("Event"))
+	if name == "hangup" {
+		m.Close(channel)
+		return
+	}
+	if !strings.EqualFold(msg.Field("Direction"), "Received") {
+		return
+	}
+	digit := []rune(msg.Field("Digit"))
+	if len(digit) != 1 {
+		return
+	}
+	if name == "dtmf" {
+		if yes, _ := msg.FieldBool("End"); yes {
+			name = "dtmfend"
+		} else if yes, _ := msg.FieldBool("Begin"); yes {
+			name = "dtmfbegin"
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.chans[channel]
+	if !ok {
+		return
+	}
+	switch name {
+	case "dtmfbegin":
+		s.begin = digit[0]
+	case "dtmfend":
+		now := m.now()
+		if s.begin == 0 && s.last == digit[0] && now.Sub(s.lastEnd) < repeatWindow {
+			// duplicate end of the same key press
+			return
+		}
+		s.begin, s.last, s.lastEnd = 0, digit[0], now
+		select {
+		case s.ch <- digit[0]:
+		default:
+			// reader is too slow, drop the digit
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dtmf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+// received reads digits available in the stream
+func received(ch <-chan rune) string {
+	var digits []rune
+	for {
+		select {
+		case d, ok := <-ch:
+			if !ok {
+				return string(digits)
+			}
+			digits = append(digits, d)
+		default:
+			return string(digits)
+		}
+	}
+}
+
+func TestMonitorDigits(t *testing.T) {
+	mon := New()
+	now := time.Date(2020, 8, 31, 12, 0, 0, 0, time.UTC)
+	mon.now = func() time.Time { return now }
+	digits := mon.Digits("PJSIP/100-01")
+	assert.Equal(t, digits, mon.Digits("PJSIP/100-01"))
+
+	press := func(name, digit, direction string) {
+		mon.Handle(event(t, "Event: "+name+"\r\nChannel: PJSIP/100-01\r\nDigit: "+digit+
+			"\r\nDirection: "+direction+"\r\n"))
+	}
+	press("DTMFBegin", "1", "Received")
+	press("DTMFEnd", "1", "Received")
+	// duplicate end
+	press("DTMFEnd", "1", "Received")
+	// digit sent to the channel
+	press("DTMFBegin", "2", "Sent")
+	press("DTMFEnd", "2", "Sent")
+	// end without begin
+	press("DTMFEnd", "#", "Received")
+	// same key pressed again
+	now = now.Add(time.Second)
+	press("DTMFEnd", "#", "Received")
+	press("DTMFBegin", "#", "Received")
+	press("DTMFEnd", "#", "Received")
+	press("DTMFEnd", "", "Received")
+	assert.Equal(t, "1###", received(digits))
+
+	// Asterisk 11 events
+	mon.Handle(event(t, "Event: DTMF\r\nChannel: PJSIP/100-01\r\nDigit: 5\r\n"+
+		"Direction: Received\r\nBegin: Yes\r\nEnd: No\r\n"))
+	mon.Handle(event(t, "Event: DTMF\r\nChannel: PJSIP/100-01\r\nDigit: 5\r\n"+
+		"Direction: Received\r\nBegin: No\r\nEnd: Yes\r\n"))
+	assert.Equal(t, "5", received(digits))
+
+	// channel without stream
+	mon.Handle(event(t, "Event: DTMFEnd\r\nChannel: PJSIP/200-01\r\nDigit: 1\r\nDirection: Received\r\n"))
+
+	mon.Handle(event(t, "Event: Hangup\r\nChannel: PJSIP/100-01\r\n"))
+	_, ok := <-digits
+	assert.False(t, ok)
+	mon.Close("PJSIP/100-01")
+}
+
+func TestMonitorAttach(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	mon := New()
+	mon.Attach(cl)
+	digits := mon.Digits("PJSIP/100-01")
+	ami.Emit(
+		goami2test.Event("DTMFBegin", "Channel", "PJSIP/100-01", "Digit", "7", "Direction", "Received"),
+		goami2test.Event("DTMFEnd", "Channel", "PJSIP/100-01", "Digit", "7", "Direction", "Received",
+			"DurationMs", "120"),
+	)
+	select {
+	case d := <-digits:
+		assert.Equal(t, '7', d)
+	case <-time.After(time.Second):
+		t.Fatal("no digit")
+	}
+}
+
+func TestMonitorAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	mon := New()
+	mon.Attach(cl)
+	const channels = 150
+	streams := make([]<-chan rune, channels)
+	var burst strings.Builder
+	for i := range streams {
+		channel := fmt.Sprintf("PJSIP/100-%08x", i)
+		streams[i] = mon.Digits(channel)
+		for _, d := range "0123456789" {
+			fmt.Fprintf(&burst, "Event: DTMFBegin\r\nChannel: %s\r\nDigit: %c\r\nDirection: Received\r\n\r\n", channel, d)
+			fmt.Fprintf(&burst, "Event: DTMFEnd\r\nChannel: %s\r\nDigit: %c\r\nDirection: Received\r\n\r\n", channel, d)
+		}
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return len(streams[channels-1]) == 10 }, 5*time.Second, time.Millisecond)
+	for _, digits := range streams {
+		assert.Equal(t, "0123456789", received(digits))
+	}
+	assert.Zero(t, cl.DroppedMessages())
+}
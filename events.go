@@ -0,0 +1,126 @@
+package goami2
+
+import "time"
+
+// Decode message headers into new value of struct type T, see Message.Decode.
+//
+//	ev, err := goami2.Decode[goami2.HangupEvent](msg)
+func Decode[T any](msg *Message) (T, error) {
+	var v T
+	err := msg.Decode(&v)
+	return v, err
+}
+
+// ChannelSnapshot is the channel state included into channel related events
+type ChannelSnapshot struct {
+	Channel           string
+	ChannelState      int
+	ChannelStateDesc  string
+	CallerIDNum       string
+	CallerIDName      string
+	ConnectedLineNum  string
+	ConnectedLineName string
+	Language          string
+	AccountCode       string
+	Context           string
+	Exten             string
+	Priority          int
+	Uniqueid          string
+	Linkedid          string
+}
+
+// DestChannelSnapshot is the state of the destination channel in dial events
+type DestChannelSnapshot struct {
+	DestChannel           string
+	DestChannelState      int
+	DestChannelStateDesc  string
+	DestCallerIDNum       string
+	DestCallerIDName      string
+	DestConnectedLineNum  string
+	DestConnectedLineName string
+	DestLanguage          string
+	DestAccountCode       string
+	DestContext           string
+	DestExten             string
+	DestPriority          int
+	DestUniqueid          string
+	DestLinkedid          string
+}
+
+// BridgeSnapshot is the bridge state included into bridge related events
+type BridgeSnapshot struct {
+	BridgeUniqueid        string
+	BridgeType            string
+	BridgeTechnology      string
+	BridgeCreator         string
+	BridgeName            string
+	BridgeNumChannels     int
+	BridgeVideoSourceMode string
+}
+
+// NewchannelEvent is raised when a new channel is created
+type NewchannelEvent struct {
+	ChannelSnapshot
+}
+
+// NewstateEvent is raised when a channel state changes
+type NewstateEvent struct {
+	ChannelSnapshot
+}
+
+// HangupEvent is raised when a channel is hung up
+type HangupEvent struct {
+	ChannelSnapshot
+	Cause    int
+	CauseTxt string `ami:"Cause-txt"`
+}
+
+// DialBeginEvent is raised when a dial action has started
+type DialBeginEvent struct {
+	ChannelSnapshot
+	DestChannelSnapshot
+	DialString string
+}
+
+// DialEndEvent is raised when a dial action has completed
+type DialEndEvent struct {
+	ChannelSnapshot
+	DestChannelSnapshot
+	DialStatus string
+	Forward    string
+}
+
+// BridgeEnterEvent is raised when a channel enters a bridge
+type BridgeEnterEvent struct {
+	BridgeSnapshot
+	ChannelSnapshot
+	SwapUniqueid string
+}
+
+// BridgeLeaveEvent is raised when a channel leaves a bridge
+type BridgeLeaveEvent struct {
+	BridgeSnapshot
+	ChannelSnapshot
+}
+
+// CdrEvent is raised by cdr_manager when a CDR is posted
+type CdrEvent struct {
+	AccountCode        string
+	Source             string
+	Destination        string
+	DestinationContext string
+	CallerID           string
+	Channel            string
+	DestinationChannel string
+	LastApplication    string
+	LastData           string
+	StartTime          string
+	AnswerTime         string
+	EndTime            string
+	Duration           time.Duration
+	BillableSeconds    time.Duration
+	Disposition        string
+	AMAFlags           string
+	UniqueID           string
+	UserField          string
+}
@@ -0,0 +1,106 @@
+package goami2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeHangupEvent(t *testing.T) {
+	msg, err := Parse("Event: Hangup\r\n" +
+		"Privilege: call,all\r\n" +
+		"Channel: PJSIP/100-00000001\r\n" +
+		"ChannelState: 6\r\n" +
+		"ChannelStateDesc: Up\r\n" +
+		"CallerIDNum: 100\r\n" +
+		"CallerIDName: Alice\r\n" +
+		"ConnectedLineNum: <unknown>\r\n" +
+		"Context: default\r\n" +
+		"Exten: 200\r\n" +
+		"Priority: 3\r\n" +
+		"Uniqueid: 1598887681.60\r\n" +
+		"Linkedid: 1598887681.60\r\n" +
+		"Cause: 16\r\n" +
+		"Cause-txt: Normal Clearing\r\n\r\n")
+	assert.Nil(t, err)
+
+	ev, err := Decode[HangupEvent](msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "PJSIP/100-00000001", ev.Channel)
+	assert.Equal(t, 6, ev.ChannelState)
+	assert.Equal(t, "Up", ev.ChannelStateDesc)
+	assert.Equal(t, "Alice", ev.CallerIDName)
+	assert.Equal(t, 3, ev.Priority)
+	assert.Equal(t, "1598887681.60", ev.Uniqueid)
+	assert.Equal(t, 16, ev.Cause)
+	assert.Equal(t, "Normal Clearing", ev.CauseTxt)
+}
+
+func TestDecodeDialBeginEvent(t *testing.T) {
+	msg, err := Parse("Event: DialBegin\r\n" +
+		"Channel: PJSIP/100-00000001\r\n" +
+		"Uniqueid: 1598887681.60\r\n" +
+		"DestChannel: PJSIP/200-00000002\r\n" +
+		"DestChannelState: 0\r\n" +
+		"DestUniqueid: 1598887681.61\r\n" +
+		"DialString: 200\r\n\r\n")
+	assert.Nil(t, err)
+
+	ev, err := Decode[DialBeginEvent](msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "PJSIP/100-00000001", ev.Channel)
+	assert.Equal(t, "1598887681.60", ev.Uniqueid)
+	assert.Equal(t, "PJSIP/200-00000002", ev.DestChannel)
+	assert.Equal(t, "1598887681.61", ev.DestUniqueid)
+	assert.Equal(t, "200", ev.DialString)
+}
+
+func TestDecodeBridgeEnterEvent(t *testing.T) {
+	msg, err := Parse("Event: BridgeEnter\r\n" +
+		"BridgeUniqueid: 6f1b-4c3a\r\n" +
+		"BridgeType: basic\r\n" +
+		"BridgeNumChannels: 2\r\n" +
+		"Channel: PJSIP/200-00000002\r\n" +
+		"Uniqueid: 1598887681.61\r\n\r\n")
+	assert.Nil(t, err)
+
+	ev, err := Decode[BridgeEnterEvent](msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "6f1b-4c3a", ev.BridgeUniqueid)
+	assert.Equal(t, "basic", ev.BridgeType)
+	assert.Equal(t, 2, ev.BridgeNumChannels)
+	assert.Equal(t, "PJSIP/200-00000002", ev.Channel)
+	assert.Equal(t, "", ev.SwapUniqueid)
+}
+
+func TestDecodeCdrEvent(t *testing.T) {
+	msg, err := Parse("Event: Cdr\r\n" +
+		"Source: 100\r\n" +
+		"Destination: 200\r\n" +
+		"StartTime: 2020-08-31 11:28:01\r\n" +
+		"Duration: 65\r\n" +
+		"BillableSeconds: 60\r\n" +
+		"Disposition: ANSWERED\r\n" +
+		"UniqueID: 1598887681.60\r\n\r\n")
+	assert.Nil(t, err)
+
+	ev, err := Decode[CdrEvent](msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "100", ev.Source)
+	assert.Equal(t, "2020-08-31 11:28:01", ev.StartTime)
+	assert.Equal(t, 65*time.Second, ev.Duration)
+	assert.Equal(t, time.Minute, ev.BillableSeconds)
+	assert.Equal(t, "ANSWERED", ev.Disposition)
+	assert.Equal(t, "1598887681.60", ev.UniqueID)
+}
+
+func TestDecodeFail(t *testing.T) {
+	msg := NewMessage()
+	msg.AddField("Cause", "normal")
+	_, err := Decode[HangupEvent](msg)
+	assert.ErrorIs(t, err, ErrAMI)
+
+	_, err = Decode[string](msg)
+	assert.ErrorIs(t, err, ErrAMI)
+}
@@ -0,0 +1,134 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExtensionStatus is the state of the extension hint. Status is the state
+// code: -2 removed, -1 deactivated, 0 idle, 1 in use, 2 busy,
+// 4 unavailable, 8 ringing, 16 on hold, or combination of them, like 9 for
+// in use and ringing.
+type ExtensionStatus struct {
+	Exten      string
+	Context    string
+	Hint       string // like "PJSIP/100"
+	Status     int
+	StatusText string // like "Idle", "InUse" or "Ringing"
+}
+
+// WatchExtensionState returns a channel that receives the state of the
+// extension hint, first the current state loaded with "ExtensionStateList"
+// action and then the state of every "ExtensionStatus" event. State is
+// loaded again after the client reconnects. Context "default" is used when
+// context is empty. Returns error wrapping ErrNotFound when there is no hint
+// for the extension. Channel is buffered and keeps the most recent states
+// when receiver is too slow. Channel is closed when ctx is done or client is
+// closed.
+func (c *Client) WatchExtensionState(ctx context.Context, exten, extContext string) (<-chan ExtensionStatus, error) {
+	if exten == "" {
+		return nil, fmt.Errorf("%w: watch extension state: missing extension", ErrAMI)
+	}
+	if extContext == "" {
+		extContext = "default"
+	}
+	sub := &subscription{
+		ch: make(chan *Message, chanBuffer),
+		filter: func(msg *Message) bool {
+			switch strings.ToLower(msg.Field("Event")) {
+			case "reconnected":
+				return true
+			case "extensionstatus":
+				return msg.Field("Exten") == exten && strings.EqualFold(msg.Field("Context"), extContext)
+			}
+			return false
+		},
+		passive:  true,
+		overflow: OverflowDropOldest,
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if c.subs == nil {
+		c.subs = make(map[<-chan *Message]*subscription)
+	}
+	c.subs[sub.ch] = sub
+	c.mu.Unlock()
+
+	// subscribed before loading the state to not miss changes
+	state, err := c.extensionState(ctx, exten, extContext)
+	if err != nil {
+		c.Unsubscribe(sub.ch)
+		return nil, err
+	}
+
+	out := make(chan ExtensionStatus, chanBuffer)
+	out <- state
+	go func() {
+		defer close(out)
+		defer c.Unsubscribe(sub.ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				if strings.EqualFold(msg.Field("Event"), "Reconnected") {
+					if state, err = c.extensionState(ctx, exten, extContext); err != nil {
+						continue // next event brings the state
+					}
+				} else {
+					state = decodeExtensionStatus(msg)
+				}
+				sendLatest(out, state)
+			}
+		}
+	}()
+	return out, nil
+}
+
+// extensionState finds state of the extension in the ExtensionStateList
+func (c *Client) extensionState(ctx context.Context, exten, extContext string) (ExtensionStatus, error) {
+	list, err := c.SendActionList(ctx, NewAction("ExtensionStateList"))
+	if err != nil {
+		return ExtensionStatus{}, err
+	}
+	for _, msg := range list {
+		if strings.EqualFold(msg.Field("Event"), "ExtensionStatus") &&
+			msg.Field("Exten") == exten && strings.EqualFold(msg.Field("Context"), extContext) {
+			return decodeExtensionStatus(msg), nil
+		}
+	}
+	return ExtensionStatus{}, fmt.Errorf("%w: no hint for extension %s@%s", ErrNotFound, exten, extContext)
+}
+
+func decodeExtensionStatus(msg *Message) ExtensionStatus {
+	st := ExtensionStatus{
+		Exten:      msg.Field("Exten"),
+		Context:    msg.Field("Context"),
+		Hint:       msg.Field("Hint"),
+		StatusText: msg.Field("StatusText"),
+	}
+	st.Status, _ = msg.FieldInt("Status")
+	return st
+}
+
+// sendLatest sends state dropping the oldest state when channel is full
+func sendLatest(ch chan ExtensionStatus, st ExtensionStatus) {
+	for {
+		select {
+		case ch <- st:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
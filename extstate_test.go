@@ -0,0 +1,70 @@
+package goami2_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientWatchExtensionState(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	var status atomic.Value
+	status.Store("0")
+	ami.Handle("ExtensionStateList", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{
+			goami2test.Success("EventList", "start", "Message", "Extension Statuses will follow"),
+			goami2test.Event("ExtensionStatus", "Exten", "200", "Context", "default", "Hint", "PJSIP/200",
+				"Status", "4", "StatusText", "Unavailable"),
+			goami2test.Event("ExtensionStatus", "Exten", "100", "Context", "default", "Hint", "PJSIP/100",
+				"Status", status.Load().(string), "StatusText", "Idle"),
+			goami2test.Event("ExtensionStateListComplete", "EventList", "Complete", "ListItems", "2"),
+		}
+	})
+	addr, err := ami.Listen()
+	assert.Nil(t, err)
+
+	cl, err := goami2.Dial(context.Background(), addr, "admin", "pa55w0rd", goami2.WithReconnect(0, time.Millisecond))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	states, err := cl.WatchExtensionState(ctx, "100", "")
+	assert.Nil(t, err)
+	st := <-states
+	assert.Equal(t, goami2.ExtensionStatus{Exten: "100", Context: "default", Hint: "PJSIP/100",
+		Status: 0, StatusText: "Idle"}, st)
+
+	ami.Emit(goami2test.Event("ExtensionStatus", "Exten", "200", "Context", "default", "Status", "1"))
+	ami.Emit(goami2test.Event("ExtensionStatus", "Exten", "100", "Context", "default",
+		"Hint", "PJSIP/100", "Status", "8", "StatusText", "Ringing"))
+	st = <-states
+	assert.Equal(t, 8, st.Status)
+	assert.Equal(t, "Ringing", st.StatusText)
+
+	// state is loaded again after reconnect
+	status.Store("1")
+	ami.Drop()
+	select {
+	case st = <-states:
+		assert.Equal(t, 1, st.Status)
+	case <-time.After(time.Second):
+		t.Fatal("no state after reconnect")
+	}
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-states
+		return !ok
+	}, time.Second, time.Millisecond)
+
+	_, err = cl.WatchExtensionState(context.Background(), "300", "default")
+	assert.ErrorIs(t, err, goami2.ErrNotFound)
+	assert.ErrorContains(t, err, "no hint for extension 300@default")
+}
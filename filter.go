@@ -0,0 +1,77 @@
+package goami2
+
+import (
+	"context"
+	"strings"
+)
+
+// AddEventFilter asks Asterisk to filter events sent to this manager session
+// with "Action: Filter". Pattern is a regular expression matched against event
+// lines, for example "Event: Newchannel". When include is false matching events
+// are excluded, Asterisk expects exclude filters prefixed with "!".
+// Manager user must have "system" write permissions and filters are applied
+// only to the current session. Added filters are applied again after reconnect.
+// Returns AMI error message if filter is rejected.
+func (c *Client) AddEventFilter(ctx context.Context, include bool, pattern string) error {
+	if !include {
+		pattern = "!" + pattern
+	}
+	resp, err := c.request(ctx, filterAction(pattern))
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "filter rejected")
+	}
+	c.mu.Lock()
+	c.filters = append(c.filters, pattern)
+	c.mu.Unlock()
+	return nil
+}
+
+// EventFilters returns filters added with AddEventFilter. Exclude filters
+// are prefixed with "!". AMI has no action to remove filters from the session,
+// they are removed when session is closed.
+func (c *Client) EventFilters() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.filters...)
+}
+
+// refilter sends filters added to the previous session after reconnect
+func (c *Client) refilter() {
+	for _, pattern := range c.EventFilters() {
+		if err := c.send(filterAction(pattern)); err != nil {
+			c.logger.Warn("failed to add filter", "filter", pattern, "error", err)
+		}
+	}
+}
+
+func filterAction(pattern string) *Message {
+	action := NewAction("Filter")
+	action.AddField("Operation", "Add")
+	action.AddField("Filter", pattern)
+	return action
+}
+
+// SetEventMask changes events sent to this manager session with "Action: Events".
+// Mask is a list of permission classes, for example "system", "call", or "on"
+// to receive all events and "off" to receive no events. The mask is also used
+// by login on reconnect, same as WithEventsMask.
+func (c *Client) SetEventMask(ctx context.Context, mask ...string) error {
+	value := stripLineBreaks(strings.Join(mask, ","))
+	action := NewAction("Events")
+	action.AddField("EventMask", value)
+
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "events mask rejected")
+	}
+	c.mu.Lock()
+	c.eventsMask = value
+	c.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,153 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientAddEventFilter(t *testing.T) {
+	setup := func(response string) (*Client, chan *Message) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		actions := make(chan *Message, 1)
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte("Response: " + response + "\r\nActionID: " + msg.ActionID() +
+				"\r\nMessage: Filter Rejected\r\n\r\n"))
+		}()
+		return cl, actions
+	}
+
+	t.Run("include filter", func(t *testing.T) {
+		cl, actions := setup("Success")
+		defer cl.Close()
+		err := cl.AddEventFilter(context.Background(), true, "Event: Newchannel")
+		assert.Nil(t, err)
+		msg := <-actions
+		assert.Equal(t, "Filter", msg.Field("Action"))
+		assert.Equal(t, "Add", msg.Field("Operation"))
+		assert.Equal(t, "Event: Newchannel", msg.Field("Filter"))
+	})
+
+	t.Run("exclude filter", func(t *testing.T) {
+		cl, actions := setup("Success")
+		defer cl.Close()
+		err := cl.AddEventFilter(context.Background(), false, "Event: RTCP")
+		assert.Nil(t, err)
+		assert.Equal(t, "!Event: RTCP", (<-actions).Field("Filter"))
+	})
+
+	t.Run("filter rejected", func(t *testing.T) {
+		cl, _ := setup("Error")
+		defer cl.Close()
+		err := cl.AddEventFilter(context.Background(), true, "[")
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Filter Rejected")
+	})
+}
+
+func TestClientSetEventMask(t *testing.T) {
+	setup := func(response string) (*Client, chan *Message) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		actions := make(chan *Message, 1)
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte("Response: " + response + "\r\nActionID: " + msg.ActionID() +
+				"\r\nMessage: Permission denied\r\n\r\n"))
+		}()
+		return cl, actions
+	}
+
+	t.Run("set mask", func(t *testing.T) {
+		cl, actions := setup("Success")
+		defer cl.Close()
+		err := cl.SetEventMask(context.Background(), "system", "call")
+		assert.Nil(t, err)
+		msg := <-actions
+		assert.Equal(t, "Events", msg.Field("Action"))
+		assert.Equal(t, "system,call", msg.Field("EventMask"))
+		cl.mu.Lock()
+		assert.Equal(t, "system,call", cl.eventsMask)
+		cl.mu.Unlock()
+	})
+
+	t.Run("mask rejected", func(t *testing.T) {
+		cl, _ := setup("Error")
+		defer cl.Close()
+		err := cl.SetEventMask(context.Background(), "off")
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Permission denied")
+		assert.Equal(t, "", cl.eventsMask)
+	})
+}
+
+func TestClientRefilterOnReconnect(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	go func() {
+		buf := make([]byte, 1024)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\n"))
+		_, _ = connSrv.Read(buf)
+		_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		r := bufio.NewReader(connSrv)
+		for i := 0; i < 2; i++ {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+		_ = connSrv.Close()
+	}()
+
+	actions := make(chan *Message, 2)
+	withDial := func(c *Client) {
+		c.dial = func(context.Context) (net.Conn, error) {
+			conn, srv := net.Pipe()
+			go func() {
+				buf := make([]byte, 1024)
+				_, _ = srv.Write([]byte("Asterisk Call Manager/2.10.4\n"))
+				_, _ = srv.Read(buf)
+				_, _ = srv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+				r := bufio.NewReader(srv)
+				for i := 0; i < 2; i++ {
+					msg, err := srvReadAction(r)
+					if err != nil {
+						return
+					}
+					actions <- msg
+				}
+			}()
+			return conn, nil
+		}
+	}
+
+	cl, err := NewClient(connClient, "admin", "pa55w0rd", withDial,
+		WithReconnect(3, time.Millisecond))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	assert.Nil(t, cl.AddEventFilter(context.Background(), true, "Event: Newchannel"))
+	assert.Nil(t, cl.AddEventFilter(context.Background(), false, "Event: RTCP"))
+	assert.Equal(t, []string{"Event: Newchannel", "!Event: RTCP"}, cl.EventFilters())
+
+	assert.Equal(t, "Disconnected", (<-cl.AllMessages()).Field("Event"))
+	assert.Equal(t, "Reconnected", (<-cl.AllMessages()).Field("Event"))
+	assert.Equal(t, "Event: Newchannel", (<-actions).Field("Filter"))
+	assert.Equal(t, "!Event: RTCP", (<-actions).Field("Filter"))
+}
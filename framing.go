@@ -0,0 +1,362 @@
+package goami2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// packetScanner splits stream of lines into AMI packets. Packet ends with
+// empty line, "Response: Follows" packet ends with command end marker and
+// empty line. When split is set, packets glued together without empty line
+// are split when "Event" or "Response" header starts new packet. First
+// "Response" header of the events with responseEvents names does not start
+// new packet.
+type packetScanner struct {
+	buf       []byte // packet lines, reused between packets
+	headers   int    // number of headers in the packet
+	follows   bool   // command output may have empty lines until end marker
+	split     bool   // split glued packets, see WithGluedPackets
+	respField bool   // "Response" header of current event is a field
+	skip      bool   // discarding lines of the packet that exceeds limits
+
+	maxSize    int // maximum packet size, unlimited when zero
+	maxHeaders int // maximum number of headers, unlimited when zero
+}
+
+// events that have "Response" header
+var responseEvents = []string{"OriginateResponse"}
+
+// push line terminated with "\r\n" and return completed packets
+func (s *packetScanner) push(line string) []string {
+	packets, _ := s.pushLine([]byte(trimLine(line)))
+	return packets
+}
+
+// pushLine pushes line without line terminator and returns completed packets.
+// Line is copied, so caller can reuse it. Packet that exceeds the limits is
+// discarded with LimitError.
+func (s *packetScanner) pushLine(line []byte) ([]string, error) {
+	if !s.follows {
+		line = bytes.TrimRight(line, " \t") // whitespace only line ends packet
+	}
+	if s.skip {
+		s.skipLine(line)
+		return nil, nil
+	}
+	if len(s.buf) == 0 {
+		if len(line) == 0 {
+			return nil, nil // extra empty line between packets
+		}
+		s.follows = equalFold(line, followsPrefix[:len(followsPrefix)-2])
+	}
+
+	var packets []string
+	if s.split && !s.follows && len(s.buf) > 0 && s.isPacketStart(line) {
+		// previous packet is missing empty line
+		packets = append(packets, s.take())
+	}
+	if len(s.buf) == 0 {
+		s.respField = isResponseEvent(line)
+	} else if isHeader(line, "Response") {
+		s.respField = false
+	}
+
+	if err := s.check(line); err != nil {
+		s.discard()
+		s.skipLine(line)
+		return packets, err
+	}
+	s.buf = append(append(s.buf, line...), '\r', '\n')
+	if len(line) > 0 && !s.follows {
+		s.headers++
+	}
+	if s.follows && bytes.HasSuffix(line, []byte(endCommand)) {
+		s.follows = false
+		return packets, nil
+	}
+	if len(line) == 0 && !s.follows { // end of packet
+		packets = append(packets, string(s.buf))
+		s.buf = s.buf[:0]
+		s.headers = 0
+	}
+	return packets, nil
+}
+
+// check returns LimitError when line does not fit into the packet limits
+func (s *packetScanner) check(line []byte) error {
+	if s.maxSize > 0 && len(s.buf)+len(line)+2 > s.maxSize {
+		return &LimitError{Limit: "message size", Max: s.maxSize}
+	}
+	if s.maxHeaders > 0 && len(line) > 0 && !s.follows && s.headers >= s.maxHeaders {
+		return &LimitError{Limit: "headers", Max: s.maxHeaders}
+	}
+	return nil
+}
+
+// discard drops the packet and skips its lines until the end of the packet
+func (s *packetScanner) discard() {
+	s.buf = s.buf[:0]
+	s.headers = 0
+	s.skip = true
+}
+
+// skipLine stops skipping lines of discarded packet at the end of the packet
+func (s *packetScanner) skipLine(line []byte) {
+	if s.follows {
+		if bytes.HasSuffix(line, []byte(endCommand)) {
+			s.skip, s.follows = false, false
+		}
+		return
+	}
+	if len(line) == 0 {
+		s.skip = false
+	}
+}
+
+// take returns buffered packet terminated with empty line and resets buffer
+func (s *packetScanner) take() string {
+	pack := string(append(s.buf, '\r', '\n'))
+	s.buf = s.buf[:0]
+	s.headers = 0
+	return pack
+}
+
+// flush returns incomplete packet terminated with empty line
+func (s *packetScanner) flush() (string, bool) {
+	if len(s.buf) == 0 {
+		return "", false
+	}
+	s.follows = false
+	return s.take(), true
+}
+
+// isPacketStart returns true if line is the first header of AMI packet
+func (s *packetScanner) isPacketStart(line []byte) bool {
+	return isHeader(line, "Event") || (isHeader(line, "Response") && !s.respField)
+}
+
+// isResponseEvent returns true if line is "Event" header of the event
+// that has "Response" header
+func isResponseEvent(line []byte) bool {
+	name, value, ok := bytes.Cut(line, []byte(":"))
+	if !ok || !equalFold(name, "Event") {
+		return false
+	}
+	value = bytes.TrimSpace(value)
+	for _, ev := range responseEvents {
+		if equalFold(value, ev) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHeader returns true if line is the header with the name
+func isHeader(line []byte, name string) bool {
+	h, _, ok := bytes.Cut(line, []byte(":"))
+	return ok && equalFold(h, name)
+}
+
+// equalFold reports whether b and ASCII string s are equal under case folding
+// without converting b to string
+func equalFold(b []byte, s string) bool {
+	if len(b) != len(s) {
+		return false
+	}
+	for i := 0; i < len(b); i++ {
+		if lower(b[i]) != lower(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// Parse AMI message as string into *Message structure. Parser is tolerant to
+// slightly nonconforming packets: lines may be terminated with "\n",
+// whitespace after header values is trimmed and lines starting with space or
+// tab continue the value of the previous header. Returns *ParseError with
+// the packet and the offset of the invalid line.
+func Parse(data string) (*Message, error) {
+	if i := strings.IndexByte(data, 0); i >= 0 {
+		return nil, syntaxError(data, i, "NUL byte")
+	}
+	msg, err := parseCanonical(canonical(data))
+	if err == nil {
+		return msg, nil
+	}
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.Raw = []byte(data) // offset is in the canonical form of the packet
+	}
+	return msg, err
+}
+
+// ParseBytes parses AMI packet, same as Parse. Packet larger than
+// DefaultLimits returns LimitError.
+func ParseBytes(data []byte) (*Message, error) {
+	if max := DefaultLimits.MessageSize; len(data) > max {
+		return nil, &LimitError{Limit: "message size", Max: max}
+	}
+	return Parse(string(data))
+}
+
+// ParseMessage reads AMI packet from r and parses it, for example packets
+// saved to a file or received from a proxy. Packets must be separated with
+// empty line and "Asterisk Call Manager" prompt before the packet is skipped.
+// To read several messages from the same stream pass *bufio.Reader, which is
+// used as is, otherwise data buffered after the packet is lost. Packet that
+// exceeds DefaultLimits is skipped and returns LimitError, so the next call
+// reads the next packet. Returns io.EOF when there are no more packets and
+// error wrapping io.ErrUnexpectedEOF when stream ends inside the packet.
+func ParseMessage(r io.Reader) (*Message, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	limits := DefaultLimits
+	reader := &lineReader{r: br, max: limits.LineLength}
+	scanner := &packetScanner{maxSize: limits.MessageSize, maxHeaders: limits.Headers}
+	var limitErr error
+	for {
+		line, err := reader.readLine()
+		var limit *LimitError
+		if errors.As(err, &limit) {
+			scanner.discard()
+			limitErr = err
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			if len(scanner.buf) > 0 || scanner.skip {
+				return nil, fmt.Errorf("%w: truncated packet: %w", ErrAMI, io.ErrUnexpectedEOF)
+			}
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(scanner.buf) == 0 && !scanner.skip && bytes.HasPrefix(line, []byte(promptPrefix)) {
+			continue
+		}
+		packets, err := scanner.pushLine(line)
+		if err != nil {
+			limitErr = err
+		}
+		if limitErr != nil && !scanner.skip {
+			return nil, limitErr // discarded packet is skipped
+		}
+		if len(packets) > 0 {
+			return Parse(packets[0])
+		}
+	}
+}
+
+// canonical returns packet with "\r\n" line terminators, without whitespace
+// at the end of lines and with folded lines joined. Packet already in
+// canonical form is returned as is.
+func canonical(data string) string {
+	if isCanonical(data) {
+		return data
+	}
+	buf := make([]byte, 0, len(data))
+	header := false // previous line is the header that can be continued
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		last := i == len(lines)-1
+		if !last {
+			line = strings.TrimRight(line, " \t\r")
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != line && header {
+			// continuation of the previous header value
+			buf = append(bytes.TrimSuffix(buf, []byte("\r\n")), ' ')
+		}
+		buf = append(buf, trimmed...)
+		if !last {
+			buf = append(buf, '\r', '\n')
+		}
+		header = trimmed != ""
+	}
+	return string(buf)
+}
+
+// isCanonical returns true when all lines are terminated with "\r\n", have
+// no trailing whitespace, except empty header value, and do not start with
+// whitespace
+func isCanonical(data string) bool {
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\n':
+			if i == 0 || data[i-1] != '\r' {
+				return false
+			}
+			if i > 1 && (data[i-2] == ' ' || data[i-2] == '\t') &&
+				(i < 3 || data[i-3] != ':') { // "Name: " empty value is canonical
+				return false
+			}
+			if i+1 < len(data) && (data[i+1] == ' ' || data[i+1] == '\t') {
+				return false
+			}
+		case ' ', '\t':
+			if i == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// trimLine removes "\n" or "\r\n" line terminator
+func trimLine(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// normalizeLine makes sure line is terminated with "\r\n"
+func normalizeLine(line string) string {
+	return trimLine(line) + "\r\n"
+}
+
+// lineReader reads lines reusing the buffer of bufio.Reader. Lines longer
+// than the buffer are collected in the scratch buffer, which is reused too.
+type lineReader struct {
+	r       *bufio.Reader
+	scratch []byte
+	max     int // maximum line length, unlimited when zero
+}
+
+// readLine returns next line without line terminator. Returned line is valid
+// until the next call. Line longer than the limit is skipped with LimitError.
+func (l *lineReader) readLine() ([]byte, error) {
+	line, err := l.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		l.scratch = append(l.scratch[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = l.r.ReadSlice('\n')
+			if l.max > 0 && len(l.scratch) > l.max+2 {
+				continue // keep reading until the end of too long line
+			}
+			l.scratch = append(l.scratch, line...)
+		}
+		line = l.scratch
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if l.max > 0 && len(line) > l.max {
+		return nil, &LimitError{Limit: "line length", Max: l.max}
+	}
+	return line, nil
+}
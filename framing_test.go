@@ -0,0 +1,214 @@
+package goami2
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketScanner(t *testing.T) {
+	s := &packetScanner{split: true}
+	var packets []string
+	for _, line := range []string{
+		"\r\n",
+		"Response: Follows\r\n",
+		"Event: inside command output\r\n",
+		"\r\n",
+		"--END COMMAND--\r\n",
+		"\r\n",
+		"Event: Hangup\r\n",
+		"Event: Newchannel\r\n",
+		"Channel: PJSIP/100\r\n",
+	} {
+		packets = append(packets, s.push(line)...)
+	}
+	assert.Equal(t, []string{
+		"Response: Follows\r\nEvent: inside command output\r\n\r\n--END COMMAND--\r\n\r\n",
+		"Event: Hangup\r\n\r\n",
+	}, packets)
+
+	pack, ok := s.flush()
+	assert.True(t, ok)
+	assert.Equal(t, "Event: Newchannel\r\nChannel: PJSIP/100\r\n\r\n", pack)
+	_, ok = s.flush()
+	assert.False(t, ok)
+}
+
+func TestPacketScannerResponseHeader(t *testing.T) {
+	s := &packetScanner{split: true}
+	var packets []string
+	for _, line := range []string{
+		"Event: OriginateResponse\r\n",
+		"Response: Success\r\n",
+		"Reason: 4\r\n",
+		"Response: Success\r\n",
+		"Ping: Pong\r\n",
+		"Response: Error\r\n",
+		"\r\n",
+	} {
+		packets = append(packets, s.push(line)...)
+	}
+	assert.Equal(t, []string{
+		"Event: OriginateResponse\r\nResponse: Success\r\nReason: 4\r\n\r\n",
+		"Response: Success\r\nPing: Pong\r\n\r\n",
+		"Response: Error\r\n\r\n",
+	}, packets)
+}
+
+func TestPacketScannerWhitespace(t *testing.T) {
+	s := &packetScanner{}
+	var packets []string
+	for _, line := range []string{
+		"Event: Newexten \r\n",
+		"AppData: Hello\r\n",
+		"  world\r\n",
+		" \t\r\n",
+		"Response: Follows\r\n",
+		"  indented output  \r\n",
+		"--END COMMAND--\r\n",
+		"\r\n",
+	} {
+		packets = append(packets, s.push(line)...)
+	}
+	assert.Equal(t, []string{
+		"Event: Newexten\r\nAppData: Hello\r\n  world\r\n\r\n",
+		"Response: Follows\r\n  indented output  \r\n--END COMMAND--\r\n\r\n",
+	}, packets)
+
+	msg, err := Parse(packets[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello world", msg.Field("AppData"))
+}
+
+func TestNormalizeLine(t *testing.T) {
+	assert.Equal(t, "Event: Hangup\r\n", normalizeLine("Event: Hangup\n"))
+	assert.Equal(t, "Event: Hangup\r\n", normalizeLine("Event: Hangup\r\n"))
+	assert.Equal(t, "\r\n", normalizeLine("\n"))
+}
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	r := &lineReader{r: bufio.NewReaderSize(strings.NewReader(
+		"Event: Hangup\r\nChannel: "+long+"\nCause: 16\r\n\ntail"), 16)}
+
+	var lines []string
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, []string{"Event: Hangup", "Channel: " + long, "Cause: 16", ""}, lines)
+}
+
+func BenchmarkReadPackets(b *testing.B) {
+	stream := strings.Repeat(rawPack, 1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(stream)))
+	for i := 0; i < b.N; i++ {
+		r := &lineReader{r: bufio.NewReader(strings.NewReader(stream))}
+		s := &packetScanner{}
+		for {
+			line, err := r.readLine()
+			if err != nil {
+				break
+			}
+			packets, _ := s.pushLine(line)
+			for _, pack := range packets {
+				msg, _ := Parse(pack)
+				msg.Release()
+			}
+		}
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	stream := "Asterisk Call Manager/5.0.1\r\n" +
+		"Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n" +
+		"Response: Success\nActionID: 1\n\n" +
+		"Event: Hangup\r\nCause: 16\r\n"
+	r := bufio.NewReader(iotest.OneByteReader(strings.NewReader(stream)))
+
+	msg, err := ParseMessage(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "PJSIP/100-01", msg.Field("Channel"))
+	msg, err = ParseMessage(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", msg.ActionID())
+	_, err = ParseMessage(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, err, ErrAMI)
+	_, err = ParseMessage(r)
+	assert.ErrorIs(t, err, io.EOF)
+
+	t.Run("skips packet exceeding limits", func(t *testing.T) {
+		long := strings.Repeat("x", DefaultLimits.LineLength+1)
+		r := bufio.NewReader(strings.NewReader("Event: Big\r\nValue: " + long + "\r\nMore: 1\r\n\r\n" +
+			"Event: Small\r\n\r\n"))
+		_, err := ParseMessage(r)
+		var limit *LimitError
+		assert.ErrorAs(t, err, &limit)
+		assert.Equal(t, "line length", limit.Limit)
+		msg, err := ParseMessage(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "Small", msg.Field("Event"))
+	})
+}
+
+func TestParseBytes(t *testing.T) {
+	msg, err := ParseBytes([]byte("Event: Hangup\r\nCause: 16\r\n\r\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "16", msg.Field("Cause"))
+
+	_, err = ParseBytes([]byte("Event: Hangup\r\nCause: 1\x006\r\n\r\n"))
+	var perr *ParseError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, 23, perr.Offset)
+	assert.ErrorContains(t, err, "NUL byte")
+
+	_, err = ParseBytes(make([]byte, DefaultLimits.MessageSize+1))
+	assert.ErrorIs(t, err, ErrLimit)
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"Event: Hangup\r\nChannel: PJSIP/100-01\r\nCause: 16\r\n\r\n",
+		"Response: Success\nActionID: 1\n  continued\n\n",
+		"Response: Follows\r\nPrivilege: Command\r\nline\r\n--END COMMAND--\r\n\r\n",
+		"Event: Foo\x00\r\n\r\n",
+		"Event:\r\n\r\n",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := ParseBytes(data)
+		if err != nil {
+			return
+		}
+		if strings.IndexByte(msg.String(), 0) >= 0 {
+			t.Fatalf("NUL byte in parsed message %q", msg.String())
+		}
+	})
+}
+
+func FuzzParseMessage(f *testing.F) {
+	f.Add([]byte("Asterisk Call Manager/5.0.1\r\nEvent: Hangup\r\n\r\nEvent: Newchannel\r\n\r\n"))
+	f.Add([]byte("Response: Follows\r\n\r\n--END COMMAND--\r\n\r\nEvent: Hangup\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bufio.NewReader(iotest.HalfReader(bytes.NewReader(data)))
+		for i := 0; i <= len(data); i++ {
+			if _, err := ParseMessage(r); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return
+			}
+		}
+		t.Fatal("ParseMessage does not stop at the end of data")
+	})
+}
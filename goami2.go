@@ -3,16 +3,22 @@ package goami2
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"time"
 )
 
 const (
-	promptPrefix = "Asterisk Call Manager/"
-	netTimeout   = 1 * time.Second       // default timeout for network read/write
-	chanGiveup   = 10 * time.Millisecond // timeout to giveup sending to a channel
+	promptPrefix    = "Asterisk Call Manager/"
+	maxPromptLength = 1024                  // long enough for prompt
+	netTimeout      = 1 * time.Second       // default timeout for network read/write
+	chanGiveup      = 10 * time.Millisecond // timeout to giveup sending to a channel
+	chanBuffer      = 12                    // default messages channel buffer size
+	handlerBuffer   = 1024                  // default event handlers buffer size
+	expiredMax      = 64                    // number of abandoned ActionIDs to remember
 )
 
 var (
@@ -20,24 +26,244 @@ var (
 	ErrConn = fmt.Errorf("%w: net conn", Error)
 	ErrAMI  = fmt.Errorf("%w: AMI proto", Error)
 	ErrEOF  = fmt.Errorf("%w: terminated", Error)
+
+	ErrKeepAliveTimeout = fmt.Errorf("%w: keepalive timeout", ErrEOF)
+	ErrStaleConnection  = fmt.Errorf("%w: stale connection", ErrEOF)
+	ErrUnknownVersion   = fmt.Errorf("%w: unknown manager version", Error)
+	ErrActionTimeout    = fmt.Errorf("%w: action timeout", Error)
+	ErrReconnecting     = fmt.Errorf("%w: connection lost, reconnecting", ErrConn)
+	ErrClosed           = fmt.Errorf("%w: client closed", ErrEOF)
+	ErrPermission       = fmt.Errorf("%w: permission denied", ErrAMI)
+	ErrNotFound         = fmt.Errorf("%w: not found", ErrAMI)
+	ErrNoSuchChannel    = fmt.Errorf("%w: no such channel", ErrNotFound)
+	ErrAuthFailed       = fmt.Errorf("%w: authentication failed", ErrAMI)
+	ErrDraining         = fmt.Errorf("%w: client is draining", ErrClosed)
+	ErrQueueFull        = fmt.Errorf("%w: outbound queue is full", ErrConn)
 )
 
+// ParseError is returned when AMI packet received from the server can not be
+// parsed. It carries the raw packet and wraps ErrAMI. Client keeps reading
+// messages after ParseError.
+type ParseError struct {
+	Raw    []byte // offending packet
+	Offset int    // byte offset of the invalid line, packet length for incomplete packet
+	Err    error
+}
+
+func (e *ParseError) Error() string { return e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// syntaxError creates ParseError of the packet data at the offset
+func syntaxError(data string, offset int, reason string) error {
+	err := fmt.Errorf("%w: %s", ErrAMI, reason)
+	if offset < len(data) {
+		err = fmt.Errorf("%w: %s at offset %d: %q", ErrAMI, reason, offset, data[offset:])
+	}
+	return &ParseError{Raw: []byte(data), Offset: offset, Err: err}
+}
+
+// ProtocolError is returned when AMI server rejects the action or replies
+// out of the protocol, for example with unexpected prompt. It wraps ErrAMI.
+type ProtocolError struct {
+	Response *Message // server response, nil when there is no valid response
+	Err      error
+}
+
+func (e *ProtocolError) Error() string { return e.Err.Error() }
+
+func (e *ProtocolError) Unwrap() error { return e.Err }
+
+// responseErrors are errors of the failed responses by lower case text of
+// the "Message" header, checked in order
+var responseErrors = []struct {
+	text string
+	err  error
+}{
+	{"authentication failed", ErrAuthFailed},
+	{"permission denied", ErrPermission},
+	{"no such channel", ErrNoSuchChannel},
+	{"channel does not exist", ErrNoSuchChannel},
+	{"not found", ErrNotFound},
+}
+
+// ResponseError returns nil for successful response and ProtocolError for
+// failed one. Error wraps ErrAuthFailed, ErrPermission, ErrNoSuchChannel or
+// ErrNotFound derived from the response "Message" header, otherwise ErrAMI.
+// Use it with responses of SendAction that are not checked by the client.
+func ResponseError(resp *Message) error {
+	if resp.IsSuccess() || strings.EqualFold(resp.Field("Response"), "Goodbye") {
+		return nil
+	}
+	return rejected(resp, "action failed")
+}
+
+// rejected creates ProtocolError for the failed response
+func rejected(resp *Message, reason string) error {
+	kind := ErrAMI
+	text := strings.ToLower(resp.Field("Message"))
+	for _, e := range responseErrors {
+		if strings.Contains(text, e.text) {
+			kind = e.err
+			break
+		}
+	}
+	return &ProtocolError{
+		Response: resp,
+		Err:      fmt.Errorf("%w: %s: %q", kind, reason, resp.Field("Message")),
+	}
+}
+
 // NewClient creates client. It is using NewClientWithContext in the background
 // with a bogus context. For better context control use NewClientWithContext function.
-func NewClient(conn net.Conn, username, password string) (*Client, error) {
-	return NewClientWithContext(context.Background(), conn, username, password)
+func NewClient(conn net.Conn, username, password string, opts ...Option) (*Client, error) {
+	return NewClientWithContext(context.Background(), conn, username, password, opts...)
 }
 
-// NewClientWithContext creates client with provided connection net.Conn and login into
-// AMI server. It returns error if fials to login. Runs internal connection loop and
-// provides AMI messages via AllMessages and error via Err methods.
-func NewClientWithContext(ctx context.Context, conn net.Conn, username, password string) (*Client, error) {
+// NewClientWithContext creates client with provided connection net.Conn and
+// login into AMI server. It returns error if fails to login. Login is aborted
+// when context is done. Runs internal connection loop and provides AMI
+// messages via AllMessages and error via Err methods.
+// Connection can be of any transport, like unix socket, SSH tunnel channel or
+// AMI proxy stream. To reconnect over custom transport use Dial with WithDialer.
+// Client behavior can be tuned with options.
+func NewClientWithContext(ctx context.Context, conn net.Conn, username, password string,
+	opts ...Option) (*Client, error) {
 	cl := makeClient(conn)
-	if err := cl.login(username, password); err != nil {
+	cl.username, cl.password = username, password
+	for _, opt := range opts {
+		opt(cl)
+	}
+	cl.conn = cl.wrapConn(conn)
+	first := cl.conn
+
+	if err := cl.LoginWithRetry(ctx, username, password, cl.loginAttempts, cl.loginInterval); err != nil {
+		if cl.getConn() != first {
+			cl.closeConn() // redialed on retry
+		}
+		return nil, err
+	}
+
+	cl.start(ctx)
+	if err := cl.discoverPermissions(ctx); err != nil {
+		cl.Close()
+		return nil, err
+	}
+	cl.flushQueue()
+
+	return cl, nil
+}
+
+// Dialer dials network connection. It is implemented by net.Dialer, tls.Dialer
+// and proxy dialers, for example golang.org/x/net/proxy.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialerFunc is an adapter to use ordinary function as Dialer
+type DialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// DialContext calls f(ctx, network, address)
+func (f DialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// Dial connects to AMI server TCP address, login and creates client. By
+// default it dials with net.Dialer, custom dialer can be set with WithDialer
+// option, for example SOCKS proxy dialer. Address with "tls://" scheme, for
+// example "tls://pbx.example.com:5039", or WithTLSConfig option enables TLS
+// session over the dialed connection. Address with "unix://" scheme connects
+// to unix socket. Dial and TLS handshake are bound with the network timeout,
+// see WithTimeout. Reconnect mode, when enabled, redials with the same dialer
+// and TLS configuration.
+func Dial(ctx context.Context, address, username, password string, opts ...Option) (*Client, error) {
+	cl := makeClient(nil)
+	cl.username, cl.password = username, password
+	network, address, useTLS := splitAddress(address)
+	cl.dial = cl.dialAddr(network, address)
+	for _, opt := range opts {
+		opt(cl)
+	}
+	if useTLS && cl.tlsConfig == nil {
+		cl.tlsConfig = &tls.Config{}
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, cl.timeout)
+	conn, err := cl.dial(dialCtx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	cl.conn = cl.wrapConn(conn)
+
+	if err := cl.LoginWithRetry(ctx, username, password, cl.loginAttempts, cl.loginInterval); err != nil {
+		cl.closeConn()
 		return nil, err
 	}
 
-	go cl.loop(ctx)
+	cl.start(ctx)
+	if err := cl.discoverPermissions(ctx); err != nil {
+		cl.Close()
+		return nil, err
+	}
+	cl.flushQueue()
 
 	return cl, nil
 }
+
+// DialTLS connects to AMI server address over TLS using provided tls.Config,
+// login and creates client. Same as Dial with "tls://" address and
+// WithTLSConfig, so dial and TLS handshake are bound with the timeout set by
+// WithTimeout and reconnect mode, when enabled, redials with the same TLS
+// configuration.
+func DialTLS(ctx context.Context, addr, username, password string, cfg *tls.Config,
+	opts ...Option) (*Client, error) {
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	return Dial(ctx, addr, username, password, append(opts, WithTLSConfig(cfg))...)
+}
+
+// splitAddress returns network and address of the address with optional
+// "tcp://", "tls://" or "unix://" scheme and true for TLS address
+func splitAddress(address string) (string, string, bool) {
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		return "tcp", address, false
+	}
+	switch strings.ToLower(scheme) {
+	case "tcp":
+		return "tcp", addr, false
+	case "tls":
+		return "tcp", addr, true
+	case "unix":
+		return "unix", addr, false
+	}
+	return "tcp", address, false
+}
+
+// dialAddr creates dial function that connects to the given address
+// with the client dialer and runs TLS handshake when TLS is enabled
+func (c *Client) dialAddr(network, address string) func(ctx context.Context) (net.Conn, error) {
+	return func(ctx context.Context) (net.Conn, error) {
+		conn, err := c.dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed dial: %w", ErrConn, err)
+		}
+		if c.tlsConfig == nil {
+			return conn, nil
+		}
+
+		cfg := c.tlsConfig
+		if cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+			cfg = cfg.Clone()
+			cfg.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("%w: failed tls handshake: %w", ErrConn, err)
+		}
+		return tlsConn, nil
+	}
+}
@@ -1,8 +1,17 @@
 package goami2
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
 	"net"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,3 +55,345 @@ func TestNewClientContext(t *testing.T) {
 		assert.ErrorIs(t, err, ErrEOF)
 	})
 }
+
+// self-signed certificate for TLS tests
+func testTLSConfig(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	srvCfg := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return srvCfg, &tls.Config{RootCAs: pool, ServerName: "localhost"}
+}
+
+func TestDialTLS(t *testing.T) {
+	srvCfg, cliCfg := testTLSConfig(t)
+
+	t.Run("login over tls", func(t *testing.T) {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", srvCfg)
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connSrvSess(conn, []string{
+				"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+				"Event: FullyBooted\r\nStatus: Fully Booted\r\n\r\n",
+			})
+		}()
+
+		cl, err := DialTLS(context.Background(), ln.Addr().String(), "admin", "pa55w0rd", cliCfg)
+		assert.Nil(t, err)
+		defer cl.Close()
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+	})
+
+	t.Run("fail on untrusted certificate", func(t *testing.T) {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", srvCfg)
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}
+		}()
+
+		_, err = DialTLS(context.Background(), ln.Addr().String(), "admin", "pa55w0rd",
+			&tls.Config{ServerName: "localhost"})
+		assert.ErrorIs(t, err, ErrConn)
+	})
+
+	t.Run("dial tls address scheme", func(t *testing.T) {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", srvCfg)
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connSrvSess(conn, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		}()
+
+		cl, err := Dial(context.Background(), "tls://"+ln.Addr().String(), "admin", "pa55w0rd",
+			WithTLSConfig(&tls.Config{RootCAs: cliCfg.RootCAs}))
+		assert.Nil(t, err)
+		defer cl.Close()
+		_, ok := cl.getConn().(*tls.Conn)
+		assert.True(t, ok)
+	})
+
+	t.Run("dial tls fails on plain tcp server", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				connSrvSess(conn, nil)
+			}
+		}()
+
+		_, err = Dial(context.Background(), "tls://"+ln.Addr().String(), "admin", "pa55w0rd")
+		assert.ErrorIs(t, err, ErrConn)
+		assert.ErrorContains(t, err, "failed tls handshake")
+	})
+
+	t.Run("handshake timeout", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			// accept and never run handshake
+			conn, err := ln.Accept()
+			if err == nil {
+				defer conn.Close()
+				time.Sleep(100 * time.Millisecond)
+			}
+		}()
+
+		_, err = DialTLS(context.Background(), ln.Addr().String(), "admin", "pa55w0rd", cliCfg,
+			WithTimeout(10*time.Millisecond))
+		assert.ErrorIs(t, err, ErrConn)
+		assert.ErrorContains(t, err, "failed tls handshake")
+	})
+}
+
+type pipeDialer struct {
+	addr  string
+	dials int
+	srv   func(conn net.Conn)
+}
+
+func (d *pipeDialer) DialContext(_ context.Context, _, address string) (net.Conn, error) {
+	d.addr = address
+	d.dials++
+	connClient, connSrv := net.Pipe()
+	d.srv(connSrv)
+	return connClient, nil
+}
+
+func TestDial(t *testing.T) {
+	t.Run("login over tcp", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connSrvSess(conn, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		}()
+
+		cl, err := Dial(context.Background(), ln.Addr().String(), "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "Asterisk Call Manager/2.10.4", cl.Banner())
+	})
+
+	t.Run("custom dialer", func(t *testing.T) {
+		d := &pipeDialer{srv: func(conn net.Conn) {
+			connSrvSess(conn, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		}}
+		cl, err := Dial(context.Background(), "pbx.example.com:5038", "admin", "pa55w0rd",
+			WithDialer(d), WithBufferSize(0))
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "pbx.example.com:5038", d.addr)
+		assert.Equal(t, 1, d.dials)
+		assert.Equal(t, 0, cap(cl.AllMessages()))
+	})
+
+	t.Run("unix socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ami.sock")
+		ln, err := net.Listen("unix", path)
+		assert.Nil(t, err)
+		defer ln.Close()
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			connSrvSess(conn, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		}()
+
+		cl, err := Dial(context.Background(), "unix://"+path, "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "unix", cl.getConn().RemoteAddr().Network())
+	})
+
+	t.Run("dial function", func(t *testing.T) {
+		var network, address string
+		dial := DialerFunc(func(_ context.Context, n, a string) (net.Conn, error) {
+			network, address = n, a
+			connClient, connSrv := net.Pipe()
+			connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+			return connClient, nil
+		})
+		cl, err := Dial(context.Background(), "tcp://pbx:5038", "admin", "pa55w0rd", WithDialer(dial))
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, "tcp", network)
+		assert.Equal(t, "pbx:5038", address)
+	})
+
+	t.Run("fail to dial", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		addr := ln.Addr().String()
+		_ = ln.Close()
+
+		_, err = Dial(context.Background(), addr, "admin", "pa55w0rd")
+		assert.ErrorIs(t, err, ErrConn)
+		assert.ErrorContains(t, err, "failed dial")
+	})
+
+	t.Run("fail on login", func(t *testing.T) {
+		d := &pipeDialer{srv: func(conn net.Conn) {
+			connSrvSess(conn, []string{"Response: Error\r\nMessage: Authentication failed\r\n\r\n"})
+		}}
+		_, err := Dial(context.Background(), "pbx:5038", "admin", "wrong", WithDialer(d))
+		assert.ErrorIs(t, err, ErrAMI)
+	})
+}
+
+func TestTypedErrors(t *testing.T) {
+	t.Run("parse error on login", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"invalid message\r\n\r\n"})
+
+		_, err := NewClient(connClient, "admin", "pa55w0rd")
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr)
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.Equal(t, "invalid message\r\n\r\n", string(perr.Raw))
+	})
+
+	t.Run("protocol error on rejected login", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"Response: Error\r\nMessage: Authentication failed\r\n\r\n"})
+
+		_, err := NewClient(connClient, "admin", "pa55w0rd")
+		var perr *ProtocolError
+		assert.ErrorAs(t, err, &perr)
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorIs(t, err, ErrAuthFailed)
+		assert.Equal(t, "Authentication failed", perr.Response.Field("Message"))
+	})
+
+	t.Run("loop keeps reading after parse error", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{
+			"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+			"Event: Hangup\r\ninvalid header\r\n\r\n",
+			"Event: FullyBooted\r\n\r\n",
+		})
+		cl, err := NewClient(connClient, "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		err = <-cl.Err()
+		var perr *ParseError
+		assert.ErrorAs(t, err, &perr)
+		assert.Equal(t, "Event: Hangup\r\ninvalid header\r\n\r\n", string(perr.Raw))
+		assert.Equal(t, 15, perr.Offset)
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+	})
+
+	t.Run("network error is wrapped", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		addr := ln.Addr().String()
+		_ = ln.Close()
+
+		_, err = Dial(context.Background(), addr, "admin", "pa55w0rd")
+		var nerr *net.OpError
+		assert.ErrorAs(t, err, &nerr)
+		assert.ErrorIs(t, err, ErrConn)
+	})
+}
+
+func TestResponseError(t *testing.T) {
+	tests := map[string]struct {
+		packet string
+		err    error
+		text   string
+	}{
+		`success`: {"Response: Success\r\nMessage: Pong\r\n\r\n", nil, ""},
+		`goodbye`: {"Response: Goodbye\r\nMessage: Thanks for all the fish.\r\n\r\n", nil, ""},
+		`auth failed`: {"Response: Error\r\nMessage: Authentication failed\r\n\r\n", ErrAuthFailed,
+			`goami2: AMI proto: authentication failed: action failed: "Authentication failed"`},
+		`permission denied`: {"Response: Error\r\nMessage: Permission denied\r\n\r\n", ErrPermission,
+			`goami2: AMI proto: permission denied: action failed: "Permission denied"`},
+		`no such channel`: {"Response: Error\r\nMessage: No such channel\r\n\r\n", ErrNoSuchChannel,
+			`goami2: AMI proto: not found: no such channel: action failed: "No such channel"`},
+		`channel does not exist`: {"Response: Error\r\nMessage: Channel does not exist: PJSIP/100-01\r\n\r\n",
+			ErrNoSuchChannel, ""},
+		`not found`: {"Response: Error\r\nMessage: Database entry not found\r\n\r\n", ErrNotFound, ""},
+		`other`: {"Response: Error\r\nMessage: Invalid/unknown command\r\n\r\n", ErrAMI,
+			`goami2: AMI proto: action failed: "Invalid/unknown command"`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := Parse(tc.packet)
+			assert.Nil(t, err)
+			err = ResponseError(resp)
+			if tc.err == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+			var perr *ProtocolError
+			assert.ErrorAs(t, err, &perr)
+			assert.Equal(t, resp, perr.Response)
+			if tc.text != "" {
+				assert.EqualError(t, err, tc.text)
+			}
+		})
+	}
+	assert.NotErrorIs(t, ErrAuthFailed, ErrPermission)
+	assert.ErrorIs(t, ErrNoSuchChannel, ErrNotFound)
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := map[string]struct {
+		network, address string
+		tls              bool
+	}{
+		"pbx:5038":               {"tcp", "pbx:5038", false},
+		"tcp://pbx:5038":         {"tcp", "pbx:5038", false},
+		"TLS://pbx:5039":         {"tcp", "pbx:5039", true},
+		"unix:///run/ami.sock":   {"unix", "/run/ami.sock", false},
+		"socks5://pbx:5038":      {"tcp", "socks5://pbx:5038", false},
+		"[2001:db8::1]:5038":     {"tcp", "[2001:db8::1]:5038", false},
+		"tls://[2001:db8::1]:39": {"tcp", "[2001:db8::1]:39", true},
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			network, address, tls := splitAddress(input)
+			assert.Equal(t, want.network, network)
+			assert.Equal(t, want.address, address)
+			assert.Equal(t, want.tls, tls)
+		})
+	}
+}
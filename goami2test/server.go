@@ -0,0 +1,416 @@
+// Package goami2test provides in-process AMI server for testing applications
+// that use goami2 client. Server accepts login, replies to actions with
+// scripted handlers, plays back events, records received actions and can
+// inject faults like slow writes and abrupt connection close.
+package goami2test
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// DefaultPrompt is the AMI prompt sent by the server on connect
+const DefaultPrompt = "Asterisk Call Manager/5.0.0"
+
+// ErrClosed is returned when server is closed
+var ErrClosed = errors.New("goami2test: server closed")
+
+// Handler returns messages sent in reply to the action. ActionID of the
+// action is added to the messages that do not have one. Nil result sends
+// nothing, for example to test action timeouts.
+type Handler func(action *goami2.Message) []*goami2.Message
+
+// Server is the mock AMI server. Fields must be set before the first
+// connection. Server is safe for concurrent use.
+type Server struct {
+	Prompt   string // AMI prompt, DefaultPrompt when empty
+	Username string // accepted username, any when empty
+	Secret   string // accepted secret, any when empty
+
+	mu       sync.Mutex
+	handlers map[string]Handler // by lower case action name
+	actions  []*goami2.Message
+	waited   int                   // number of actions returned by WaitAction
+	notify   chan struct{}         // closed and replaced when action is received
+	sessions map[*session]struct{} // connected sessions
+	delay    time.Duration
+	ln       net.Listener
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type session struct {
+	conn      net.Conn
+	wmu       sync.Mutex
+	loggedIn  bool
+	challenge string
+}
+
+// NewServer creates mock AMI server
+func NewServer() *Server {
+	return &Server{
+		handlers: make(map[string]Handler),
+		notify:   make(chan struct{}),
+		sessions: make(map[*session]struct{}),
+	}
+}
+
+// Handle sets handler of the action. Action name is case insensitive.
+// Actions without handler get "Response: Success".
+func (s *Server) Handle(action string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.ToLower(action)] = h
+}
+
+// Conn returns client side of the new in-memory connection to the server
+func (s *Server) Conn() net.Conn {
+	client, srv := net.Pipe()
+	if !s.serve(srv) {
+		_ = srv.Close()
+	}
+	return client
+}
+
+// Listen starts accepting TCP connections on the loopback interface and
+// returns the server address to use with goami2.Dial
+func (s *Server) Listen() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if s.closed || s.ln != nil {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return "", ErrClosed
+	}
+	s.ln = ln
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if !s.serve(conn) {
+				_ = conn.Close()
+			}
+		}
+	}()
+	return ln.Addr().String(), nil
+}
+
+// Emit sends messages, usually events, to all logged in sessions
+func (s *Server) Emit(msgs ...*goami2.Message) {
+	for _, sess := range s.active() {
+		for _, msg := range msgs {
+			_ = s.write(sess, msg)
+		}
+	}
+}
+
+// EmitRaw writes raw data to all logged in sessions as is, for example
+// to send broken packets
+func (s *Server) EmitRaw(data string) {
+	for _, sess := range s.active() {
+		s.sleep()
+		sess.wmu.Lock()
+		_, _ = sess.conn.Write([]byte(data))
+		sess.wmu.Unlock()
+	}
+}
+
+// Actions returns all actions received by the server, including login
+func (s *Server) Actions() []*goami2.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*goami2.Message(nil), s.actions...)
+}
+
+// WaitAction waits for the action with the name that is received after the
+// action returned by the previous WaitAction call. Action name is case
+// insensitive.
+func (s *Server) WaitAction(ctx context.Context, name string) (*goami2.Message, error) {
+	for {
+		s.mu.Lock()
+		for i := s.waited; i < len(s.actions); i++ {
+			if strings.EqualFold(s.actions[i].Field("Action"), name) {
+				s.waited = i + 1
+				s.mu.Unlock()
+				return s.actions[i], nil
+			}
+		}
+		notify := s.notify
+		s.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// SetWriteDelay delays every write of the server to simulate slow network
+func (s *Server) SetWriteDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = d
+}
+
+// Sessions returns number of connected sessions
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Drop abruptly closes all connected sessions. Server keeps accepting
+// new connections, so clients can reconnect.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sess := range s.sessions {
+		_ = sess.conn.Close()
+	}
+}
+
+// Close stops the server, closes all sessions and waits for them to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for sess := range s.sessions {
+		_ = sess.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// serve starts the session of the connection. Returns false when
+// server is closed.
+func (s *Server) serve(conn net.Conn) bool {
+	sess := &session{conn: conn}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	s.sessions[sess] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.sessions, sess)
+			s.mu.Unlock()
+			_ = conn.Close()
+		}()
+		s.run(sess)
+	}()
+	return true
+}
+
+// run writes prompt and replies to actions until connection is closed
+func (s *Server) run(sess *session) {
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = DefaultPrompt
+	}
+	s.sleep()
+	if _, err := sess.conn.Write([]byte(prompt + "\r\n")); err != nil {
+		return
+	}
+
+	r := bufio.NewReader(sess.conn)
+	for {
+		action, err := readAction(r)
+		if err != nil {
+			return
+		}
+		s.record(action)
+		replies, logoff := s.reply(sess, action)
+		for _, msg := range replies {
+			if msg.ActionID() == "" && action.ActionID() != "" {
+				msg.AddField("ActionID", action.ActionID())
+			}
+			if err := s.write(sess, msg); err != nil {
+				return
+			}
+		}
+		if logoff {
+			return
+		}
+	}
+}
+
+// reply returns messages sent in reply to the action and true when
+// session must be closed after them
+func (s *Server) reply(sess *session, action *goami2.Message) ([]*goami2.Message, bool) {
+	name := strings.ToLower(action.Field("Action"))
+	switch name {
+	case "challenge":
+		buf := make([]byte, 8)
+		_, _ = rand.Read(buf)
+		s.mu.Lock()
+		sess.challenge = hex.EncodeToString(buf)
+		s.mu.Unlock()
+		return []*goami2.Message{Success("Challenge", sess.challenge)}, false
+	case "login":
+		if !s.authenticate(sess, action) {
+			return []*goami2.Message{Error("Authentication failed")}, false
+		}
+		s.mu.Lock()
+		sess.loggedIn = true
+		s.mu.Unlock()
+		return []*goami2.Message{Success("Message", "Authentication accepted")}, false
+	case "logoff":
+		resp := goami2.NewMessage()
+		resp.AddField("Response", "Goodbye")
+		resp.AddField("Message", "Thanks for all the fish.")
+		return []*goami2.Message{resp}, true
+	}
+
+	s.mu.Lock()
+	loggedIn := sess.loggedIn
+	h, ok := s.handlers[name]
+	s.mu.Unlock()
+	if !loggedIn {
+		return []*goami2.Message{Error("Permission denied")}, false
+	}
+	if !ok {
+		return []*goami2.Message{Success()}, false
+	}
+	return clone(h(action)), false
+}
+
+// authenticate checks login action credentials
+func (s *Server) authenticate(sess *session, action *goami2.Message) bool {
+	if s.Username != "" && action.Field("Username") != s.Username {
+		return false
+	}
+	if s.Secret == "" {
+		return true
+	}
+	if strings.EqualFold(action.Field("AuthType"), "MD5") {
+		s.mu.Lock()
+		challenge := sess.challenge
+		s.mu.Unlock()
+		sum := md5.Sum([]byte(challenge + s.Secret))
+		return challenge != "" && action.Field("Key") == hex.EncodeToString(sum[:])
+	}
+	return action.Field("Secret") == s.Secret
+}
+
+// record adds action to the received actions and wakes up WaitAction
+func (s *Server) record(action *goami2.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, action)
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// active returns logged in sessions
+func (s *Server) active() []*session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*session
+	for sess := range s.sessions {
+		if sess.loggedIn {
+			list = append(list, sess)
+		}
+	}
+	return list
+}
+
+func (s *Server) write(sess *session, msg *goami2.Message) error {
+	s.sleep()
+	sess.wmu.Lock()
+	defer sess.wmu.Unlock()
+	_, err := sess.conn.Write(msg.Byte())
+	return err
+}
+
+func (s *Server) sleep() {
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// readAction reads lines until the empty line and parses AMI action
+func readAction(r *bufio.Reader) (*goami2.Message, error) {
+	var packet strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if line == "\r\n" && packet.Len() == 0 {
+			continue
+		}
+		packet.WriteString(line)
+		if line == "\r\n" {
+			return goami2.Parse(packet.String())
+		}
+	}
+}
+
+// clone copies messages returned by handler so ActionID can be added
+// without changing them
+func clone(msgs []*goami2.Message) []*goami2.Message {
+	list := make([]*goami2.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m := goami2.NewMessage()
+		for _, h := range msg.Headers() {
+			m.AddField(h.Name, h.Value)
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+// Success creates "Response: Success" message with fields given as
+// name and value pairs
+func Success(fields ...string) *goami2.Message {
+	return message("Response", "Success", fields)
+}
+
+// Error creates "Response: Error" message with the text message
+func Error(text string) *goami2.Message {
+	return message("Response", "Error", []string{"Message", text})
+}
+
+// Event creates event message with fields given as name and value pairs
+func Event(name string, fields ...string) *goami2.Message {
+	return message("Event", name, fields)
+}
+
+func message(name, value string, fields []string) *goami2.Message {
+	msg := goami2.NewMessage()
+	msg.AddField(name, value)
+	for i := 0; i+1 < len(fields); i += 2 {
+		msg.AddField(fields[i], fields[i+1])
+	}
+	return msg
+}
@@ -0,0 +1,164 @@
+package goami2test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/stretchr/testify/assert"
+)
+
+func connect(t *testing.T, srv *Server, opts ...goami2.Option) *goami2.Client {
+	cl, err := goami2.NewClientWithContext(context.Background(), srv.Conn(), "admin", "pa55w0rd", opts...)
+	assert.Nil(t, err)
+	return cl
+}
+
+func TestServerActions(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("CoreStatus", func(action *goami2.Message) []*goami2.Message {
+		return []*goami2.Message{Success("CoreCurrentCalls", "3")}
+	})
+	cl := connect(t, srv)
+	defer cl.Close()
+
+	resp, err := cl.SendAction(context.Background(), goami2.NewAction("corestatus"))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", resp.Field("CoreCurrentCalls"))
+	resp, err = cl.SendAction(context.Background(), goami2.NewAction("Ping"))
+	assert.Nil(t, err)
+	assert.True(t, resp.IsSuccess())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	login, err := srv.WaitAction(ctx, "Login")
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", login.Field("Username"))
+	ping, err := srv.WaitAction(ctx, "ping")
+	assert.Nil(t, err)
+	assert.Equal(t, resp.ActionID(), ping.ActionID())
+	assert.Len(t, srv.Actions(), 3)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = srv.WaitAction(ctx, "Ping")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerNoReply(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.Handle("Ping", func(*goami2.Message) []*goami2.Message { return nil })
+	cl := connect(t, srv)
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := cl.SendAction(ctx, goami2.NewAction("Ping"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerEmit(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	cl := connect(t, srv)
+	defer cl.Close()
+
+	srv.Emit(Event("Newchannel", "Channel", "PJSIP/100-01"), Event("Hangup"))
+	srv.EmitRaw("Event: FullyBooted\r\n\r\n")
+	assert.Equal(t, "PJSIP/100-01", (<-cl.AllMessages()).Field("Channel"))
+	assert.Equal(t, "Hangup", (<-cl.AllMessages()).Field("Event"))
+	assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+}
+
+func TestServerLogin(t *testing.T) {
+	t.Run("rejected", func(t *testing.T) {
+		srv := NewServer()
+		srv.Username = "admin"
+		srv.Secret = "secret"
+		defer srv.Close()
+
+		_, err := goami2.NewClient(srv.Conn(), "admin", "pa55w0rd")
+		assert.ErrorIs(t, err, goami2.ErrAMI)
+		_, err = goami2.NewClient(srv.Conn(), "admin", "pa55w0rd", goami2.WithAuthMD5())
+		assert.ErrorIs(t, err, goami2.ErrAMI)
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		srv := NewServer()
+		srv.Prompt = "Asterisk Call Manager/2.10.4"
+		srv.Username = "admin"
+		srv.Secret = "pa55w0rd"
+		defer srv.Close()
+
+		cl := connect(t, srv)
+		defer cl.Close()
+		assert.Equal(t, "Asterisk Call Manager/2.10.4", cl.Banner())
+		md5 := connect(t, srv, goami2.WithAuthMD5())
+		defer md5.Close()
+		assert.Equal(t, 2, srv.Sessions())
+	})
+
+	t.Run("action before login", func(t *testing.T) {
+		srv := NewServer()
+		defer srv.Close()
+		reply, _ := srv.reply(&session{}, goami2.NewAction("Ping"))
+		assert.Equal(t, "Permission denied", reply[0].Field("Message"))
+	})
+}
+
+func TestServerFaults(t *testing.T) {
+	t.Run("slow writes", func(t *testing.T) {
+		srv := NewServer()
+		defer srv.Close()
+		cl := connect(t, srv)
+		defer cl.Close()
+
+		srv.SetWriteDelay(30 * time.Millisecond)
+		start := time.Now()
+		_, err := cl.SendAction(context.Background(), goami2.NewAction("Ping"))
+		assert.Nil(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	})
+
+	t.Run("drop and reconnect", func(t *testing.T) {
+		srv := NewServer()
+		defer srv.Close()
+		addr, err := srv.Listen()
+		assert.Nil(t, err)
+		_, err = srv.Listen()
+		assert.ErrorIs(t, err, ErrClosed)
+
+		cl, err := goami2.Dial(context.Background(), addr, "admin", "pa55w0rd",
+			goami2.WithReconnect(3, 10*time.Millisecond))
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err = srv.WaitAction(ctx, "Login")
+		assert.Nil(t, err)
+		srv.Drop()
+		_, err = srv.WaitAction(ctx, "Login")
+		assert.Nil(t, err)
+	})
+
+	t.Run("logoff and close", func(t *testing.T) {
+		srv := NewServer()
+		cl := connect(t, srv)
+		defer cl.Close()
+
+		resp, err := cl.SendAction(context.Background(), goami2.NewAction("Logoff"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Goodbye", resp.Field("Response"))
+		assert.Nil(t, srv.Close())
+		assert.Equal(t, 0, srv.Sessions())
+
+		_, err = goami2.NewClient(srv.Conn(), "admin", "pa55w0rd")
+		assert.ErrorIs(t, err, goami2.ErrConn)
+		_, err = srv.Listen()
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+}
@@ -0,0 +1,127 @@
+package goami2
+
+// HandlerID identifies registered event handler
+type HandlerID uint64
+
+// eventHandler runs handler function for the subscription messages
+type eventHandler struct {
+	ch   <-chan *Message
+	stop chan struct{}
+}
+
+// OnEvent registers handler called for every event with the given name.
+// Name is case insensitive. Each handler runs in its own goroutine and
+// receives events in order, so a slow handler does not block other handlers.
+// Events are queued for the handler in the buffer set by WithHandlerBuffer.
+// When buffer is full the reading loop waits for the handler shortly and then
+// drops the event, dropped events are counted by DroppedMessages. Same as
+// Subscribe, handler claims its events, so they are not sent to the
+// AllMessages channel, use ObserveEvents to only observe them. Dispatching
+// stops on RemoveHandler or Close.
+func (c *Client) OnEvent(name string, handler func(*Message)) HandlerID {
+	return c.addHandler(c.handlerSubscription(false, name), handler)
+}
+
+// OnEvents registers handler called for every event with one of the given
+// names, for example []string{"DialBegin", "DialEnd"}. Handler receives the
+// events in order they are read. When no names given handler is called for
+// every event, same as OnAllEvents. See OnEvent for the concurrency model.
+func (c *Client) OnEvents(names []string, handler func(*Message)) HandlerID {
+	return c.addHandler(c.handlerSubscription(false, names...), handler)
+}
+
+// OnAllEvents registers handler called for every event.
+// See OnEvent for the concurrency model.
+func (c *Client) OnAllEvents(handler func(*Message)) HandlerID {
+	return c.addHandler(c.handlerSubscription(false), handler)
+}
+
+// ObserveEvents works as OnEvents, but handler does not claim the events:
+// they are still sent to the AllMessages channel and other subscribers, same
+// as with SubscribeAll.
+func (c *Client) ObserveEvents(names []string, handler func(*Message)) HandlerID {
+	return c.addHandler(c.handlerSubscription(true, names...), handler)
+}
+
+// handlerSubscription creates subscription of the event handler with the
+// handler buffer size and blocking overflow policy
+func (c *Client) handlerSubscription(passive bool, names ...string) <-chan *Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &subscription{
+		ch:       make(chan *Message, c.handlerBuf),
+		events:   names,
+		passive:  passive,
+		overflow: OverflowBlock,
+	}
+	if c.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	if c.subs == nil {
+		c.subs = make(map[<-chan *Message]*subscription)
+	}
+	c.subs[sub.ch] = sub
+	return sub.ch
+}
+
+// RemoveHandler stops dispatching events to the handler
+func (c *Client) RemoveHandler(id HandlerID) {
+	c.mu.Lock()
+	h, ok := c.handlers[id]
+	if ok {
+		delete(c.handlers, id)
+		close(h.stop)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.Unsubscribe(h.ch)
+	}
+}
+
+func (c *Client) addHandler(ch <-chan *Message, handler func(*Message)) HandlerID {
+	h := &eventHandler{ch: ch, stop: make(chan struct{})}
+
+	c.mu.Lock()
+	if c.handlers == nil {
+		c.handlers = make(map[HandlerID]*eventHandler)
+	}
+	c.lastHandler++
+	id := c.lastHandler
+	if c.closed {
+		close(h.stop)
+	} else {
+		c.handlers[id] = h
+	}
+	c.mu.Unlock()
+
+	go h.run(handler)
+	return id
+}
+
+func (h *eventHandler) run(handler func(*Message)) {
+	for {
+		select {
+		case <-h.stop:
+			return
+		case msg, ok := <-h.ch:
+			if !ok {
+				return
+			}
+			select {
+			case <-h.stop:
+				return
+			default:
+				handler(msg)
+			}
+		}
+	}
+}
+
+// stopHandlers stops all handlers. Must be called with locked mutex
+func (c *Client) stopHandlers() {
+	for id, h := range c.handlers {
+		close(h.stop)
+		delete(c.handlers, id)
+	}
+}
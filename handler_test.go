@@ -0,0 +1,163 @@
+package goami2
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientEventHandlers(t *testing.T) {
+	setup := func() (net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		return connSrv, cl
+	}
+
+	t.Run("dispatch events to handlers", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+
+		hangups := make(chan string, 2)
+		all := make(chan string, 4)
+		cl.OnEvent("hangup", func(msg *Message) { hangups <- msg.Field("Channel") })
+		cl.OnAllEvents(func(msg *Message) { all <- msg.Field("Event") })
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+
+		assert.Equal(t, "PJSIP/100-01", <-hangups)
+		assert.Equal(t, "Newchannel", <-all)
+		assert.Equal(t, "Hangup", <-all)
+	})
+
+	t.Run("dispatch several events to one handler in order", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+
+		dials := make(chan string, 3)
+		cl.OnEvents([]string{"DialBegin", "dialend"}, func(msg *Message) { dials <- msg.Field("Event") })
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: DialBegin\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: Newstate\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: DialEnd\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+
+		assert.Equal(t, "DialBegin", <-dials)
+		assert.Equal(t, "DialEnd", <-dials)
+		assert.Equal(t, "Newstate", (<-cl.AllMessages()).Field("Event"))
+	})
+
+	t.Run("slow handler does not block others", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+
+		block := make(chan struct{})
+		defer close(block)
+		cl.OnEvent("Hangup", func(*Message) { <-block })
+		fast := make(chan struct{}, 10)
+		cl.OnEvent("Hangup", func(*Message) { fast <- struct{}{} })
+
+		go func() {
+			for i := 0; i < 3; i++ {
+				_, _ = srv.Write([]byte("Event: Hangup\r\n\r\n"))
+			}
+		}()
+		for i := 0; i < 3; i++ {
+			select {
+			case <-fast:
+			case <-time.After(time.Second):
+				t.Fatal("handler is blocked")
+			}
+		}
+	})
+
+	t.Run("burst is queued for handler", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+
+		var mu sync.Mutex
+		calls := 0
+		cl.OnEvent("Newchannel", func(*Message) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		})
+		go func() {
+			for i := 0; i < 500; i++ {
+				_, _ = srv.Write([]byte("Event: Newchannel\r\n\r\n"))
+			}
+		}()
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return calls == 500
+		}, time.Second, time.Millisecond)
+		assert.Zero(t, cl.DroppedMessages())
+	})
+
+	t.Run("drops of slow handler are counted", func(t *testing.T) {
+		connClient, srv := net.Pipe()
+		cl := makeClient(connClient)
+		WithHandlerBuffer(1)(cl)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		block := make(chan struct{})
+		defer close(block)
+		cl.OnEvent("Hangup", func(*Message) { <-block })
+		go func() {
+			for i := 0; i < 4; i++ {
+				_, _ = srv.Write([]byte("Event: Hangup\r\n\r\n"))
+			}
+			_, _ = srv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+		}()
+		assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+		// one event is handled, one is buffered
+		assert.Equal(t, uint64(2), cl.DroppedMessages())
+	})
+
+	t.Run("observer does not claim events", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+
+		observed := make(chan string, 1)
+		cl.ObserveEvents([]string{"Hangup"}, func(msg *Message) { observed <- msg.Field("Channel") })
+		go func() {
+			_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+		assert.Equal(t, "PJSIP/100-01", <-observed)
+		assert.Equal(t, "Hangup", (<-cl.AllMessages()).Field("Event"))
+	})
+
+	t.Run("remove handler and close stop dispatching", func(t *testing.T) {
+		srv, cl := setup()
+		var mu sync.Mutex
+		calls := 0
+		count := func(*Message) {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+		}
+		id := cl.OnEvent("Hangup", count)
+		cl.RemoveHandler(id)
+		assert.NotPanics(t, func() { cl.RemoveHandler(id) })
+
+		cl.OnAllEvents(count)
+		cl.Close()
+		cl.OnAllEvents(count)
+		_, _ = srv.Write([]byte("Event: Hangup\r\n\r\n"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 0, calls)
+		assert.Empty(t, cl.handlers)
+	})
+}
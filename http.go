@@ -0,0 +1,247 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	httpTimeout   = 10 * time.Second // default timeout for HTTP action
+	httpWaitEvent = 30               // WaitEvent long-poll timeout in seconds
+)
+
+// Manager is AMI client API implemented by both TCP Client and HTTPClient,
+// so callers can swap transports
+type Manager interface {
+	Action(action *Message) bool
+	SendAction(ctx context.Context, action *Message) (*Message, error)
+	AllMessages() <-chan *Message
+	Err() <-chan error
+	Close()
+}
+
+var (
+	_ Manager = (*Client)(nil)
+	_ Manager = (*HTTPClient)(nil)
+)
+
+// HTTPClient is AMI client over Asterisk HTTP manager interface "/rawman".
+// Actions are sent with HTTP POST and events are received with the long-poll
+// "Action: WaitEvent" to the messages channel.
+type HTTPClient struct {
+	mu     sync.Mutex
+	url    string
+	http   *http.Client
+	recv   chan *Message
+	err    chan error
+	closed bool
+	cancel context.CancelFunc
+}
+
+// NewHTTPClient login into Asterisk HTTP manager with base URL, for example
+// "http://pbx:8088/asterisk", and starts polling events. Session is kept with
+// the "mansession_id" cookie. Login is aborted when context is done.
+func NewHTTPClient(ctx context.Context, baseURL, username, password string) (*HTTPClient, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create cookie jar: %w", ErrConn, err)
+	}
+	c := &HTTPClient{
+		url:  strings.TrimRight(baseURL, "/") + "/rawman",
+		http: &http.Client{Jar: jar},
+		recv: make(chan *Message, chanBuffer),
+		err:  make(chan error, 1),
+	}
+
+	login := NewAction("Login")
+	login.AddField("Username", username)
+	login.AddField("Secret", password)
+	resp, err := c.SendAction(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, "failed login")
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.poll(ctx)
+	return c, nil
+}
+
+// Action sends AMI action and sends the response to the messages channel.
+// Returns false if request fails.
+func (c *HTTPClient) Action(action *Message) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	resp, err := c.SendAction(ctx, action)
+	if err != nil {
+		return false
+	}
+	c.emitMsg(resp)
+	return true
+}
+
+// SendAction posts action and returns the response. Events returned
+// with the response are sent to the messages channel.
+func (c *HTTPClient) SendAction(ctx context.Context, action *Message) (*Message, error) {
+	msgs, err := c.post(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs[1:] {
+		if !strings.EqualFold(msg.Field("Event"), "WaitEventComplete") {
+			c.emitMsg(msg)
+		}
+	}
+	return msgs[0], nil
+}
+
+// AllMessages returns a channel that receives AMI events
+func (c *HTTPClient) AllMessages() <-chan *Message {
+	return c.recv
+}
+
+// Err returns channel of errors of the client
+func (c *HTTPClient) Err() <-chan error {
+	return c.err
+}
+
+// Close stops polling events, logs off the manager session and closes
+// client channels
+func (c *HTTPClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.cancel != nil {
+		c.cancel()
+	}
+	// session would stay on the server until it times out otherwise
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+	_, _ = c.post(ctx, NewAction("Logoff"))
+	close(c.recv)
+	close(c.err)
+}
+
+// poll events with "Action: WaitEvent" until context is done or request fails
+func (c *HTTPClient) poll(ctx context.Context) {
+	for {
+		action := NewAction("WaitEvent")
+		action.AddField("Timeout", fmt.Sprint(httpWaitEvent))
+		resp, err := c.SendAction(ctx, action)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.emitErr(fmt.Errorf("%w: %w", ErrEOF, err))
+			return
+		}
+		// expired session is rejected at once and must not be polled again
+		if !resp.IsSuccess() {
+			c.emitErr(fmt.Errorf("%w: %w", ErrEOF, rejected(resp, "wait event failed")))
+			return
+		}
+	}
+}
+
+// post action as form and parse response packets
+func (c *HTTPClient) post(ctx context.Context, action *Message) ([]*Message, error) {
+	form := url.Values{}
+	for _, h := range action.Headers() {
+		form.Add(h.Name, h.Value)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to create request: %w", ErrConn, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: failed http request: %w", ErrConn, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected http status %q", ErrConn, resp.Status)
+	}
+
+	packets, err := splitPackets(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed read response: %w", ErrConn, err)
+	}
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("%w: empty response", ErrAMI)
+	}
+	msgs := make([]*Message, 0, len(packets))
+	for _, pack := range packets {
+		msg, err := parsePacket(pack)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (c *HTTPClient) emitMsg(msg *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.recv <- msg:
+	case <-time.After(chanGiveup):
+		// failed to send and exit here to avoid blocking
+	}
+}
+
+func (c *HTTPClient) emitErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.err <- err:
+	case <-time.After(chanGiveup):
+	}
+}
+
+// splitPackets splits HTTP response body into AMI packets. Lines may be
+// terminated with "\n" and command output is kept until end marker.
+func splitPackets(r io.Reader) ([]string, error) {
+	var packets []string
+	reader := bufio.NewReader(r)
+	scanner := &packetScanner{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line != "" {
+			packets = append(packets, scanner.push(normalizeLine(line))...)
+		}
+		if err == io.EOF {
+			if pack, ok := scanner.flush(); ok { // last packet without empty line
+				packets = append(packets, pack)
+			}
+			return packets, nil
+		}
+	}
+}
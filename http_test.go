@@ -0,0 +1,151 @@
+package goami2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fake Asterisk HTTP manager
+func amiHTTPServer(t *testing.T, events chan string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/asterisk/rawman", r.URL.Path)
+		assert.Nil(t, r.ParseForm())
+		action := strings.ToLower(r.PostForm.Get("Action"))
+		if action != "login" {
+			if c, err := r.Cookie("mansession_id"); err != nil || c.Value != "s1" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+		switch action {
+		case "login":
+			if r.PostForm.Get("Secret") != "pa55w0rd" {
+				_, _ = w.Write([]byte("Response: Error\r\nMessage: Authentication failed\r\n\r\n"))
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "mansession_id", Value: "s1"})
+			_, _ = w.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		case "waitevent":
+			select {
+			case ev := <-events:
+				if ev == "logoff" {
+					_, _ = w.Write([]byte("Response: Error\r\nMessage: Permission denied\r\n\r\n"))
+					return
+				}
+				_, _ = w.Write([]byte("Response: Success\r\nMessage: Waiting for Event completed.\r\n\r\n" +
+					ev + "Event: WaitEventComplete\r\n\r\n"))
+			case <-r.Context().Done():
+			case <-time.After(100 * time.Millisecond):
+				_, _ = w.Write([]byte("Response: Success\r\n\r\nEvent: WaitEventComplete\r\n\r\n"))
+			}
+		case "logoff":
+			if events != nil {
+				events <- "logoff"
+			}
+			_, _ = w.Write([]byte("Response: Goodbye\r\nMessage: Thanks for all the fish.\r\n\r\n"))
+		case "getvar":
+			_, _ = w.Write([]byte("Response: Success\r\nActionID: " + r.PostForm.Get("ActionID") +
+				"\r\nVariable: " + r.PostForm.Get("Variable") + "\r\nValue: 42\r\n"))
+		default:
+			_, _ = w.Write([]byte("Response: Error\r\nMessage: Invalid/unknown command\r\n\r\n"))
+		}
+	}))
+}
+
+func TestHTTPClient(t *testing.T) {
+	events := make(chan string, 1)
+	srv := amiHTTPServer(t, events)
+	defer srv.Close()
+
+	t.Run("login failed", func(t *testing.T) {
+		_, err := NewHTTPClient(context.Background(), srv.URL+"/asterisk", "admin", "wrong")
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Authentication failed")
+	})
+
+	t.Run("actions and events", func(t *testing.T) {
+		var cl Manager
+		cl, err := NewHTTPClient(context.Background(), srv.URL+"/asterisk/", "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		action := NewAction("Getvar")
+		action.AddField("ActionID", "1")
+		action.AddField("Variable", "FOO")
+		resp, err := cl.SendAction(context.Background(), action)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", resp.ActionID())
+		assert.Equal(t, "42", resp.Field("Value"))
+
+		events <- "Event: FullyBooted\r\nStatus: Fully Booted\r\n\r\n"
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+
+		assert.True(t, cl.Action(NewAction("Foo")))
+		msg = <-cl.AllMessages()
+		assert.Equal(t, "Invalid/unknown command", msg.Field("Message"))
+	})
+
+	t.Run("logoff on close", func(t *testing.T) {
+		events := make(chan string, 1)
+		srv := amiHTTPServer(t, events)
+		defer srv.Close()
+		cl, err := NewHTTPClient(context.Background(), srv.URL+"/asterisk", "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		cl.Close()
+		select {
+		case ev := <-events:
+			assert.Equal(t, "logoff", ev)
+		default:
+			t.Fatal("session is not logged off")
+		}
+	})
+
+	t.Run("error when session expires", func(t *testing.T) {
+		events := make(chan string, 1)
+		srv := amiHTTPServer(t, events)
+		defer srv.Close()
+		cl, err := NewHTTPClient(context.Background(), srv.URL+"/asterisk", "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		events <- "logoff"
+		err = <-cl.Err()
+		assert.ErrorIs(t, err, ErrEOF)
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, "Permission denied")
+	})
+
+	t.Run("error when server is gone", func(t *testing.T) {
+		srv := amiHTTPServer(t, nil)
+		cl, err := NewHTTPClient(context.Background(), srv.URL+"/asterisk", "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		srv.CloseClientConnections()
+		srv.Close()
+		err = <-cl.Err()
+		assert.ErrorIs(t, err, ErrEOF)
+		assert.ErrorIs(t, err, ErrConn)
+		assert.False(t, cl.Action(NewAction("Ping")))
+	})
+}
+
+func TestSplitPackets(t *testing.T) {
+	body := "Response: Follows\nPrivilege: Command\n\n0 active channels\n--END COMMAND--\n\n" +
+		"Event: Hangup\r\nCause: 16\r\n\r\n\r\n" +
+		"Event: Last\r\n"
+	packets, err := splitPackets(strings.NewReader(body))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"Response: Follows\r\nPrivilege: Command\r\n\r\n0 active channels\r\n--END COMMAND--\r\n\r\n",
+		"Event: Hangup\r\nCause: 16\r\n\r\n",
+		"Event: Last\r\n\r\n",
+	}, packets)
+}
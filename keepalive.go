@@ -0,0 +1,62 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// keepalive sends Ping action every keepAlive interval until context is done.
+// Sends error to the fail channel and stops when no response within
+// keepAliveTimeout, or network timeout when it is not set.
+func (c *Client) keepalive(ctx context.Context, fail chan<- error) {
+	timeout := c.keepAliveTimeout
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	ticker := time.NewTicker(c.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// pings are not delayed behind rate limited actions
+		pingCtx, cancel := context.WithTimeout(withoutRateLimit(ctx), timeout)
+		_, err := c.request(pingCtx, NewAction("Ping"))
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fail <- fmt.Errorf("%w: %w", ErrKeepAliveTimeout, err)
+			return
+		}
+	}
+}
+
+// watchdog checks time of the last received message until context is done.
+// Sends error to the fail channel and stops when nothing is received within
+// staleTimeout since the last message or the watchdog start.
+func (c *Client) watchdog(ctx context.Context, fail chan<- error) {
+	start := time.Now()
+	ticker := time.NewTicker(max(c.staleTimeout/4, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			last := c.LastActivity()
+			if last.Before(start) {
+				last = start
+			}
+			if idle := now.Sub(last); idle >= c.staleTimeout {
+				fail <- fmt.Errorf("%w: nothing received for %s", ErrStaleConnection, idle.Round(time.Millisecond))
+				return
+			}
+		}
+	}
+}
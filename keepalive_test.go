@@ -0,0 +1,133 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientKeepAlive(t *testing.T) {
+	t.Run("ping responses are not delivered", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithKeepAlive(time.Millisecond)(cl)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			r := bufio.NewReader(connSrv)
+			for i := 0; i < 3; i++ {
+				msg, err := srvReadAction(r)
+				if err != nil {
+					return
+				}
+				_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " +
+					msg.ActionID() + "\r\nPing: Pong\r\n\r\n"))
+			}
+			_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+		}()
+
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "FullyBooted", msg.Field("Event"))
+	})
+
+	t.Run("fail on keepalive timeout", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithKeepAlive(time.Millisecond)(cl)
+		cl.timeout = 10 * time.Millisecond
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			// read and never reply
+			r := bufio.NewReader(connSrv)
+			for {
+				if _, err := srvReadAction(r); err != nil {
+					return
+				}
+			}
+		}()
+
+		err := <-cl.Err()
+		assert.ErrorIs(t, err, ErrKeepAliveTimeout)
+		assert.ErrorIs(t, err, ErrEOF)
+		assert.Nil(t, cl.getConn())
+	})
+
+	t.Run("fail on ping response deadline", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithKeepAlive(time.Millisecond)(cl)
+		WithKeepAliveTimeout(10 * time.Millisecond)(cl)
+		WithKeepAliveTimeout(0)(cl)
+		assert.Equal(t, 10*time.Millisecond, cl.keepAliveTimeout)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		go func() {
+			r := bufio.NewReader(connSrv)
+			for {
+				if _, err := srvReadAction(r); err != nil {
+					return
+				}
+			}
+		}()
+
+		start := time.Now()
+		assert.ErrorIs(t, <-cl.Err(), ErrKeepAliveTimeout)
+		assert.Less(t, time.Since(start), cl.timeout)
+	})
+
+	t.Run("disconnect does not emit parse errors", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			connClient, connSrv := net.Pipe()
+			cl := makeClient(connClient)
+			go cl.loop(context.Background())
+			_ = connSrv.Close()
+			err := <-cl.Err()
+			assert.ErrorIs(t, err, ErrEOF)
+			assert.NotErrorIs(t, err, ErrAMI)
+			cl.Close()
+		}
+	})
+}
+
+func TestClientWatchdog(t *testing.T) {
+	t.Run("fail when nothing is received", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		defer connSrv.Close()
+		cl := makeClient(connClient)
+		WithStaleTimeout(20 * time.Millisecond)(cl)
+		go cl.loop(context.Background())
+		defer cl.Close()
+
+		// events keep connection alive
+		for i := 0; i < 4; i++ {
+			_, _ = connSrv.Write([]byte("Event: Newexten\r\n\r\n"))
+			<-cl.AllMessages()
+			time.Sleep(10 * time.Millisecond)
+		}
+		select {
+		case err := <-cl.Err():
+			t.Fatalf("unexpected error: %s", err)
+		default:
+		}
+
+		err := <-cl.Err()
+		assert.ErrorIs(t, err, ErrStaleConnection)
+		assert.ErrorIs(t, err, ErrEOF)
+		assert.ErrorContains(t, err, "nothing received for")
+		assert.Nil(t, cl.getConn())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cl := makeClient(nil)
+		WithStaleTimeout(-time.Second)(cl)
+		assert.Zero(t, cl.staleTimeout)
+	})
+}
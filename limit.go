@@ -0,0 +1,49 @@
+package goami2
+
+import "fmt"
+
+// ErrLimit is wrapped by LimitError
+var ErrLimit = fmt.Errorf("%w: limit exceeded", ErrAMI)
+
+// Limits of the AMI packets received from the server. They protect client
+// from unbounded memory growth when peer misbehaves.
+type Limits struct {
+	LineLength  int // maximum length of the line, without line terminator
+	MessageSize int // maximum size of the packet in bytes
+	Headers     int // maximum number of headers, command output lines are not counted
+}
+
+// DefaultLimits are used for the limits that are not set with WithLimits
+var DefaultLimits = Limits{
+	LineLength:  64 << 10,
+	MessageSize: 4 << 20,
+	Headers:     1024,
+}
+
+// LimitError is sent to the Err channel when received packet exceeds the
+// limit. Packet is discarded and client keeps reading messages. It wraps
+// ErrLimit.
+type LimitError struct {
+	Limit string // "line length", "message size" or "headers"
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s exceeds %d", ErrLimit, e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error { return ErrLimit }
+
+// withDefaults returns limits with zero values replaced by defaults
+func (l Limits) withDefaults() Limits {
+	if l.LineLength <= 0 {
+		l.LineLength = DefaultLimits.LineLength
+	}
+	if l.MessageSize <= 0 {
+		l.MessageSize = DefaultLimits.MessageSize
+	}
+	if l.Headers <= 0 {
+		l.Headers = DefaultLimits.Headers
+	}
+	return l
+}
@@ -0,0 +1,105 @@
+package goami2
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitError(t *testing.T) {
+	err := error(&LimitError{Limit: "headers", Max: 10})
+	assert.ErrorIs(t, err, ErrLimit)
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.Equal(t, "goami2: AMI proto: limit exceeded: headers exceeds 10", err.Error())
+
+	assert.Equal(t, DefaultLimits, Limits{}.withDefaults())
+	assert.Equal(t, Limits{LineLength: 10, MessageSize: DefaultLimits.MessageSize,
+		Headers: DefaultLimits.Headers}, Limits{LineLength: 10}.withDefaults())
+}
+
+func TestPacketScannerLimits(t *testing.T) {
+	push := func(s *packetScanner, lines ...string) ([]string, []error) {
+		var packets []string
+		var errs []error
+		for _, line := range lines {
+			p, err := s.pushLine([]byte(line))
+			packets = append(packets, p...)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return packets, errs
+	}
+
+	t.Run("message size", func(t *testing.T) {
+		s := &packetScanner{maxSize: 30}
+		packets, errs := push(s, "Event: Hangup", "Channel: PJSIP/100-01", "Cause: 16", "",
+			"Event: FullyBooted", "")
+		assert.Equal(t, []string{"Event: FullyBooted\r\n\r\n"}, packets)
+		assert.Len(t, errs, 1)
+		var limit *LimitError
+		assert.True(t, errors.As(errs[0], &limit))
+		assert.Equal(t, "message size", limit.Limit)
+		assert.Equal(t, 30, limit.Max)
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		s := &packetScanner{maxHeaders: 2}
+		packets, errs := push(s, "Event: Hangup", "Channel: PJSIP/100-01", "Cause: 16", "",
+			"Response: Follows", "line 1", "line 2", "--END COMMAND--", "",
+			"Event: FullyBooted", "")
+		assert.Equal(t, []string{
+			"Response: Follows\r\nline 1\r\nline 2\r\n--END COMMAND--\r\n\r\n",
+			"Event: FullyBooted\r\n\r\n",
+		}, packets)
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "headers exceeds 2")
+	})
+
+	t.Run("discard command output", func(t *testing.T) {
+		s := &packetScanner{maxSize: 40}
+		packets, errs := push(s, "Response: Follows", "line 1", "", "line 3", "line 4",
+			"--END COMMAND--", "", "Event: FullyBooted", "")
+		assert.Equal(t, []string{"Event: FullyBooted\r\n\r\n"}, packets)
+		assert.Len(t, errs, 1)
+	})
+}
+
+func TestLineReaderLimit(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	r := &lineReader{r: bufio.NewReaderSize(strings.NewReader(
+		"Channel: "+long+"\r\nEvent: Hangup\r\n"), 16), max: 20}
+
+	_, err := r.readLine()
+	assert.ErrorIs(t, err, ErrLimit)
+	assert.ErrorContains(t, err, "line length exceeds 20")
+	line, err := r.readLine()
+	assert.Nil(t, err)
+	assert.Equal(t, "Event: Hangup", string(line))
+	assert.LessOrEqual(t, cap(r.scratch), 64)
+}
+
+func TestClientLimits(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	connSrvSess(connSrv, []string{
+		"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+		"Event: Newchannel\r\nChannel: " + strings.Repeat("x", 100) + "\r\nUniqueid: 1\r\n\r\n",
+		"Event: VarSet\r\nVariable: A\r\nValue: 1\r\nUniqueid: 1\r\n\r\n",
+		"Event: FullyBooted\r\n\r\n",
+	})
+
+	cl, err := NewClient(connClient, "admin", "pa55w0rd", WithLimits(Limits{LineLength: 50, Headers: 3}))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	var limit *LimitError
+	assert.ErrorAs(t, <-cl.Err(), &limit)
+	assert.Equal(t, "line length", limit.Limit)
+	assert.ErrorAs(t, <-cl.Err(), &limit)
+	assert.Equal(t, "headers", limit.Limit)
+	assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+}
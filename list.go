@@ -0,0 +1,116 @@
+package goami2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// eventList collects events of the list action
+type eventList struct {
+	events   []*Message
+	done     chan struct{}
+	complete bool
+	last     func(*Message) bool // returns true for the last event of the list
+	release  func()              // unregisters list action in flight, see Drain
+}
+
+// SendActionList sends action that responds with the list of events, like
+// "CoreShowChannels", "QueueStatus" or "PJSIPShowEndpoints", and collects
+// events with the action ActionID until the list is complete. The list is
+// complete on event with "EventList: Complete" header or event name ending with
+// "Complete". Returned slice ends with the completion event, that usually has
+// "ListItems" field. Events of the list are not sent to the AllMessages channel
+// or subscribers. Returns ProtocolError when action is rejected and response
+// when action does not respond with the list.
+// Action timeout set by WithActionTimeout is applied to the whole list when
+// context has no deadline.
+func (c *Client) SendActionList(ctx context.Context, action *Message) ([]*Message, error) {
+	timeout := false
+	if _, ok := ctx.Deadline(); !ok && c.actionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.actionTimeout)
+		defer cancel()
+		timeout = true
+	}
+	fail := func(err error) error {
+		if timeout && errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: ActionID %q: %w", ErrActionTimeout, action.ActionID(), err)
+		}
+		return err
+	}
+
+	list := &eventList{done: make(chan struct{}), last: isListComplete}
+	defer c.dropList(list)
+	resp, err := c.roundTrip(ctx, action, list)
+	if err != nil {
+		return nil, fail(err)
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, "action failed")
+	}
+	if !strings.EqualFold(resp.Field("EventList"), "start") &&
+		!strings.Contains(strings.ToLower(resp.Field("Message")), "follow") {
+		return []*Message{resp}, nil
+	}
+
+	if err := c.waitList(ctx, list); err != nil {
+		return nil, fail(err)
+	}
+	return list.events, nil
+}
+
+// waitList blocks until list is complete, connection is lost or context is done
+func (c *Client) waitList(ctx context.Context, list *eventList) error {
+	select {
+	case <-list.done:
+		if !list.complete {
+			return c.lostErr()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// collect event of the list action. Returns false if there is no
+// action collecting events with the message ActionID
+func (c *Client) collect(msg *Message) bool {
+	id := msg.ActionID()
+	if id == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list, ok := c.lists[id]
+	if !ok {
+		return false
+	}
+	list.events = append(list.events, msg)
+	if list.last(msg) {
+		list.complete = true
+		close(list.done)
+		delete(c.lists, id)
+	}
+	return true
+}
+
+// dropList removes list that is not collecting events anymore
+func (c *Client) dropList(list *eventList) {
+	c.mu.Lock()
+	for id, l := range c.lists {
+		if l == list {
+			delete(c.lists, id)
+		}
+	}
+	c.mu.Unlock()
+	if list.release != nil {
+		list.release()
+	}
+}
+
+func isListComplete(msg *Message) bool {
+	return strings.EqualFold(msg.Field("EventList"), "Complete") ||
+		strings.HasSuffix(msg.Field("Event"), "Complete")
+}
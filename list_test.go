@@ -0,0 +1,134 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSendActionList(t *testing.T) {
+	setup := func(repl func(id string) []string) *Client {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			for _, data := range repl(msg.ActionID()) {
+				_, _ = connSrv.Write([]byte(data))
+			}
+		}()
+		return cl
+	}
+
+	t.Run("collect events until complete", func(t *testing.T) {
+		cl := setup(func(id string) []string {
+			return []string{
+				"Response: Success\r\nActionID: " + id + "\r\nEventList: start\r\n" +
+					"Message: Channels will follow\r\n\r\n",
+				"Event: CoreShowChannel\r\nActionID: " + id + "\r\nChannel: PJSIP/100-01\r\n\r\n",
+				"Event: Newchannel\r\nChannel: PJSIP/300-03\r\n\r\n",
+				"Event: CoreShowChannel\r\nActionID: " + id + "\r\nChannel: PJSIP/200-02\r\n\r\n",
+				"Event: CoreShowChannelsComplete\r\nActionID: " + id + "\r\nEventList: Complete\r\n" +
+					"ListItems: 2\r\n\r\n",
+			}
+		})
+		defer cl.Close()
+
+		list, err := cl.SendActionList(context.Background(), NewAction("CoreShowChannels"))
+		assert.Nil(t, err)
+		assert.Len(t, list, 3)
+		assert.Equal(t, "PJSIP/100-01", list[0].Field("Channel"))
+		assert.Equal(t, "PJSIP/200-02", list[1].Field("Channel"))
+		assert.Equal(t, "2", list[2].Field("ListItems"))
+
+		// events of other actions are sent to messages channel
+		assert.Equal(t, "PJSIP/300-03", (<-cl.AllMessages()).Field("Channel"))
+		cl.mu.Lock()
+		assert.Empty(t, cl.lists)
+		cl.mu.Unlock()
+	})
+
+	t.Run("complete by event name", func(t *testing.T) {
+		cl := setup(func(id string) []string {
+			return []string{
+				"Response: Success\r\nActionID: " + id + "\r\nMessage: Channel status will follow\r\n\r\n",
+				"Event: Status\r\nActionID: " + id + "\r\nChannel: PJSIP/100-01\r\n\r\n",
+				"Event: StatusComplete\r\nActionID: " + id + "\r\nItems: 1\r\n\r\n",
+			}
+		})
+		defer cl.Close()
+
+		list, err := cl.SendActionList(context.Background(), NewAction("Status"))
+		assert.Nil(t, err)
+		assert.Len(t, list, 2)
+		assert.Equal(t, "StatusComplete", list[1].Field("Event"))
+	})
+
+	t.Run("response without list", func(t *testing.T) {
+		cl := setup(func(id string) []string {
+			return []string{"Response: Success\r\nActionID: " + id + "\r\nPing: Pong\r\n\r\n"}
+		})
+		defer cl.Close()
+
+		list, err := cl.SendActionList(context.Background(), NewAction("Ping"))
+		assert.Nil(t, err)
+		assert.Len(t, list, 1)
+		assert.Equal(t, "Pong", list[0].Field("Ping"))
+	})
+
+	t.Run("action rejected", func(t *testing.T) {
+		cl := setup(func(id string) []string {
+			return []string{"Response: Error\r\nActionID: " + id + "\r\nMessage: Permission denied\r\n\r\n"}
+		})
+		defer cl.Close()
+
+		_, err := cl.SendActionList(context.Background(), NewAction("QueueStatus"))
+		assert.ErrorIs(t, err, ErrAMI)
+		var perr *ProtocolError
+		assert.ErrorAs(t, err, &perr)
+		assert.Equal(t, "Permission denied", perr.Response.Field("Message"))
+	})
+
+	t.Run("action timeout on incomplete list", func(t *testing.T) {
+		cl := setup(func(id string) []string {
+			return []string{
+				"Response: Success\r\nActionID: " + id + "\r\nEventList: start\r\n\r\n",
+				"Event: QueueMember\r\nActionID: " + id + "\r\n\r\n",
+			}
+		})
+		WithActionTimeout(20 * time.Millisecond)(cl)
+		defer cl.Close()
+
+		_, err := cl.SendActionList(context.Background(), NewAction("QueueStatus"))
+		assert.ErrorIs(t, err, ErrActionTimeout)
+		cl.mu.Lock()
+		assert.Empty(t, cl.lists)
+		cl.mu.Unlock()
+	})
+
+	t.Run("connection lost", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		defer cl.Close()
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() +
+				"\r\nEventList: start\r\n\r\n"))
+			_ = connSrv.Close()
+		}()
+
+		_, err := cl.SendActionList(context.Background(), NewAction("QueueStatus"))
+		assert.ErrorIs(t, err, ErrEOF)
+	})
+}
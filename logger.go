@@ -0,0 +1,141 @@
+package goami2
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// headers which values are masked in logs, captures and Message.Redacted:
+// login secrets and MD5 challenge. "Key" header is masked only in MD5 login
+// action, other actions, like DBGet, use it for the database key.
+var secretHeaders = []string{"Secret", "Password", "Challenge"}
+
+const redactedValue = "********"
+
+// nopHandler is a slog.Handler that discards all records
+type nopHandler struct{}
+
+func (nopHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (nopHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h nopHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h nopHandler) WithGroup(string) slog.Handler           { return h }
+
+// logDebug is a shortcut to avoid building log attributes
+// when debug level is not enabled
+func (c *Client) logDebug() bool {
+	return c.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// logAction logs outbound action with masked secret headers
+func (c *Client) logAction(data []byte) {
+	if !c.logDebug() {
+		return
+	}
+	msg, err := Parse(string(data))
+	if err != nil {
+		c.logger.Debug("send raw data", "length", len(data))
+		return
+	}
+	c.logger.Debug("send action", "action", msg.Field("Action"),
+		"actionid", msg.ActionID(), "message", msg.Redacted().String())
+}
+
+// logMessage logs inbound message type
+func (c *Client) logMessage(msg *Message) {
+	if !c.logDebug() {
+		return
+	}
+	if msg.IsEvent() {
+		c.logger.Debug("received event", "event", msg.Field("Event"))
+		return
+	}
+	c.logger.Debug("received response", "response", msg.Field("Response"),
+		"actionid", msg.ActionID())
+}
+
+// Redacted returns copy of the message with masked values of secret headers,
+// like "Secret" and MD5 "Key" of the login action, safe to write to logs
+func (m *Message) Redacted() *Message {
+	cp := m.Clone()
+	cp.raw = nil
+	md5 := strings.EqualFold(m.Field("Action"), "Login") && strings.EqualFold(m.Field("AuthType"), "MD5")
+	for i, h := range cp.h {
+		if isSecretHeader(h.Name) || (md5 && isKeyHeader(h.Name)) {
+			cp.h[i].Value = redactedValue
+		}
+	}
+	return cp
+}
+
+// LogValue implements slog.LogValuer, so messages are logged redacted
+func (m *Message) LogValue() slog.Value {
+	return slog.StringValue(m.Redacted().String())
+}
+
+func isSecretHeader(name string) bool {
+	for _, secret := range secretHeaders {
+		if strings.EqualFold(strings.TrimSpace(name), secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func isKeyHeader(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), "Key")
+}
+
+// redactPacket masks values of secret header lines in the raw data, that can
+// hold several packets or a part of the packet
+func redactPacket(data []byte) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	out := make([]byte, 0, len(data))
+	for i, line := range lines {
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if !ok || !(isSecretHeader(string(name)) || (isKeyHeader(string(name)) && isMD5Login(lines, i))) {
+			out = append(out, line...)
+			continue
+		}
+		out = append(out, name...)
+		out = append(out, ": "+redactedValue...)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			out = append(out, "\r\n"...)
+		} else if bytes.HasSuffix(line, []byte("\n")) {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
+
+// isMD5Login returns true when the packet of the line i is MD5 login action.
+// Packet lines are the lines between empty lines around the line i.
+func isMD5Login(lines [][]byte, i int) bool {
+	start, end := i, i
+	for start > 0 && !isEmptyLine(lines[start-1]) {
+		start--
+	}
+	for end < len(lines) && !isEmptyLine(lines[end]) {
+		end++
+	}
+	login, md5 := false, false
+	for _, line := range lines[start:end] {
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(string(name))) {
+		case "action":
+			login = equalFold(value, "Login")
+		case "authtype":
+			md5 = equalFold(value, "MD5")
+		}
+	}
+	return login && md5
+}
+
+func isEmptyLine(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0
+}
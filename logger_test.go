@@ -0,0 +1,129 @@
+package goami2
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrent safe log output buffer
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestClientLogger(t *testing.T) {
+	out := &logBuffer{}
+	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	connClient, connSrv := net.Pipe()
+	connSrvSess(connSrv, []string{
+		"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+		"Event: FullyBooted\r\nStatus: Fully Booted\r\n\r\n",
+	})
+	cl, err := NewClient(connClient, "admin", "pa55w0rd", WithLogger(logger))
+	assert.Nil(t, err)
+	defer cl.Close()
+	<-cl.AllMessages()
+
+	logs := out.String()
+	assert.Contains(t, logs, `msg="send action" action=Login`)
+	assert.Contains(t, logs, `msg="logged in" username=admin`)
+	assert.Contains(t, logs, `msg="received event" event=FullyBooted`)
+	assert.Contains(t, logs, "Secret: "+redactedValue)
+	assert.NotContains(t, logs, "pa55w0rd")
+}
+
+func TestClientLoggerErrors(t *testing.T) {
+	out := &logBuffer{}
+	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithLogger(logger)(cl)
+	WithOverflowPolicy(OverflowDropNewest)(cl)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	_, _ = connSrv.Write([]byte("Event: Foo\r\nfoo bar\r\n\r\n"))
+	assert.ErrorIs(t, <-cl.Err(), ErrAMI)
+	for i := 0; i < cap(cl.recv)+1; i++ {
+		_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+	}
+	assert.Eventually(t, func() bool { return cl.DroppedMessages() == 1 },
+		time.Second, time.Millisecond)
+
+	logs := out.String()
+	assert.Contains(t, logs, `msg="failed to parse message"`)
+	assert.Contains(t, logs, `packet="Event: Foo\r\nfoo bar\r\n\r\n"`)
+	assert.Contains(t, logs, `msg="dropped message" reason="messages channel is full" `+
+		`event=FullyBooted actionid="" dropped=1`)
+}
+
+func TestRedact(t *testing.T) {
+	msg := NewAction("Login")
+	msg.AddField("Username", "admin")
+	msg.AddField("secret", "pa55w0rd")
+	msg.AddField("AuthType", "md5")
+	msg.AddField("Key", "0b5a3b8d1e7f")
+
+	want := "Action: Login\r\nUsername: admin\r\nsecret: ********\r\nAuthType: md5\r\nKey: ********\r\n\r\n"
+	assert.Equal(t, want, msg.Redacted().String())
+	assert.Equal(t, "pa55w0rd", msg.Field("Secret"))
+
+	get := NewAction("DBGet").Set("Family", "cidname").Set("Key", "100")
+	assert.Equal(t, get.String(), get.Redacted().String())
+
+	resp, err := Parse("Response: Success\r\nChallenge: 840415273\r\n\r\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "Response: Success\r\nChallenge: ********\r\n\r\n", string(resp.Redacted().Bytes()))
+	assert.Equal(t, "840415273", resp.Field("Challenge"))
+}
+
+func TestRedactPacket(t *testing.T) {
+	data := "Action: Login\r\nUsername: admin\r\nSecret: pa55w0rd\r\n\r\n" +
+		"Action: DBPut\r\nFamily: cidname\r\nKey: 100\r\n\r\n" +
+		"Action: Login\r\nKey:0b5a3b8d\nAuthType: MD5\nSecr"
+	want := "Action: Login\r\nUsername: admin\r\nSecret: ********\r\n\r\n" +
+		"Action: DBPut\r\nFamily: cidname\r\nKey: 100\r\n\r\n" +
+		"Action: Login\r\nKey: ********\nAuthType: MD5\nSecr"
+	assert.Equal(t, want, string(redactPacket([]byte(data))))
+}
+
+func TestMessageLogValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+	logger.Info("login", "action", NewAction("Login").Set("Username", "admin").Set("Secret", "pa55w0rd"))
+	assert.Contains(t, buf.String(), `Secret: ********`)
+	assert.NotContains(t, buf.String(), "pa55w0rd")
+}
+
+func TestNopLogger(t *testing.T) {
+	connClient, _ := net.Pipe()
+	cl := makeClient(connClient)
+	assert.False(t, cl.logDebug())
+	logger := cl.logger.With("foo", "bar").WithGroup("baz")
+	assert.NotPanics(t, func() { logger.Info("discarded") })
+
+	WithLogger(nil)(cl)
+	assert.NotNil(t, cl.logger)
+	assert.NotPanics(t, func() { cl.logAction([]byte("Action: Ping\r\n\r\n")) })
+}
@@ -0,0 +1,297 @@
+package goami2
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var typeDuration = reflect.TypeOf(time.Duration(0))
+
+// NewActionFromStruct creates action message from the struct or pointer to
+// struct. Exported fields are converted to headers named by "ami" tag or Go
+// field name when there is no tag. Tag option "omitempty" skips zero value
+// fields, option "required" fails on zero value fields and tag "-" skips the
+// field. Action name is the value of the field with name "Action" or struct
+// type name. Supported field kinds are strings, numbers and bools, that are
+// rendered as "true" and "false". time.Duration is rendered as number of
+// seconds or milliseconds when field has tag option "ms", for example
+// `ami:"Timeout,ms"`. Field of map[string]string type is expanded to repeated
+// "key=value" headers, for example for "Variable" header. Returns error for
+// unsupported field kinds and values with line breaks.
+func NewActionFromStruct(v any) (*Message, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: action must be a struct, got %T", ErrAMI, v)
+	}
+
+	rt := rv.Type()
+	msg := NewMessage()
+	msg.AddField("Action", rt.Name())
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, opts, ok := amiTag(sf)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if opts.required && fv.IsZero() {
+			return nil, fmt.Errorf("%w: %s: missing required field %q", ErrAMI, rt.Name(), name)
+		}
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if opts.omitempty && fv.IsZero() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Map {
+			if fv.Type().Key().Kind() != reflect.String || fv.Type().Elem().Kind() != reflect.String {
+				return nil, fmt.Errorf("%w: unsupported field %q type %s", ErrAMI, sf.Name, fv.Type())
+			}
+			keys := make([]string, 0, fv.Len())
+			for _, k := range fv.MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				value := k + "=" + fv.MapIndex(reflect.ValueOf(k)).String()
+				if err := validateField(name, value); err != nil {
+					return nil, err
+				}
+				msg.AddField(name, value)
+			}
+			continue
+		}
+
+		value, err := fieldString(fv, opts.unit)
+		if err != nil {
+			return nil, fmt.Errorf("%w: field %q: %s", ErrAMI, sf.Name, err)
+		}
+		if err := validateField(name, value); err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(name, "Action") {
+			_ = msg.SetField("Action", value)
+			continue
+		}
+		msg.AddField(name, value)
+	}
+	if msg.Field("Action") == "" {
+		return nil, fmt.Errorf("%w: missing action name for %T", ErrAMI, v)
+	}
+	return msg, nil
+}
+
+// tagOptions are "ami" tag options after the header name
+type tagOptions struct {
+	omitempty bool
+	required  bool
+	unit      time.Duration // time.Duration fields unit: "s" (default) or "ms"
+}
+
+// amiTag returns header name of the struct field and tag options.
+// Returns false if field must be skipped
+func amiTag(sf reflect.StructField) (string, tagOptions, bool) {
+	opts := tagOptions{unit: time.Second}
+	if !sf.IsExported() {
+		return "", opts, false
+	}
+	parts := strings.Split(sf.Tag.Get("ami"), ",")
+	name := parts[0]
+	if name == "-" {
+		return "", opts, false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			opts.omitempty = true
+		case "required":
+			opts.required = true
+		case "ms":
+			opts.unit = time.Millisecond
+		case "s":
+			opts.unit = time.Second
+		}
+	}
+	return name, opts, true
+}
+
+func fieldString(fv reflect.Value, unit time.Duration) (string, error) {
+	if fv.Type() == typeDuration {
+		d := fv.Interface().(time.Duration)
+		return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64), nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported type %s", fv.Type())
+	}
+}
+
+// Decode message headers into struct pointed by v. Struct fields are matched with
+// headers by "ami" tag or Go field name, case insensitive. Header values are
+// converted to the field type: strings, numbers, bools (yes/no, true/false, 1/0,
+// on/off) and time.Duration from "hh:mm:ss" form or number of seconds, or
+// milliseconds with tag option "ms", same as NewActionFromStruct. Field of
+// map[string]string type collects repeated "key=value" headers like "Variable".
+// Fields of embedded structs are decoded the same way as the outer struct fields.
+// Headers without matching field are ignored. Empty values are skipped.
+func (m *Message) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: decode requires pointer to struct, got %T", ErrAMI, v)
+	}
+
+	return m.decodeStruct(rv.Elem())
+}
+
+// decodeStruct decodes message headers into fields of struct value.
+// Fields of embedded structs are decoded as fields of the outer struct
+func (m *Message) decodeStruct(rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.IsExported() {
+			if err := m.decodeStruct(rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		name, opts, ok := amiTag(sf)
+		if !ok {
+			continue
+		}
+		values := m.FieldValues(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Map {
+			if fv.Type().Key().Kind() != reflect.String || fv.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%w: unsupported field %q type %s", ErrAMI, sf.Name, fv.Type())
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.MakeMap(fv.Type()))
+			}
+			for _, val := range values {
+				key, value := varsplit(val)
+				fv.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()),
+					reflect.ValueOf(value).Convert(fv.Type().Elem()))
+			}
+			continue
+		}
+
+		if values[0] == "" {
+			continue
+		}
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if err := setField(fv, values[0], opts.unit); err != nil {
+			return fmt.Errorf("%w: failed to decode header %q into field %q: %s",
+				ErrAMI, name, sf.Name, err)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, value string, unit time.Duration) error {
+	if fv.Type() == typeDuration {
+		d, err := parseDuration(value, unit)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// parseBool parses AMI boolean values
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true", "1", "on", "y", "t":
+		return true, nil
+	case "no", "false", "0", "off", "n", "f":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// parseDuration parses duration given as number of units or
+// in the form "hh:mm:ss"
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if !strings.Contains(value, ":") {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+
+	var d time.Duration
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, nil
+}
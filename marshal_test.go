@@ -0,0 +1,199 @@
+package goami2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Originate struct {
+	Channel  string
+	Context  string            `ami:"Context,omitempty"`
+	Exten    string            `ami:"Exten,omitempty"`
+	Priority int               `ami:"Priority,omitempty"`
+	Timeout  time.Duration     `ami:"Timeout,omitempty,ms"`
+	CallerID string            `ami:"CallerID,omitempty"`
+	Async    bool              `ami:"Async"`
+	Variable map[string]string `ami:"Variable,omitempty"`
+	Internal string            `ami:"-"`
+	private  string
+}
+
+func TestNewActionFromStruct(t *testing.T) {
+	t.Run("action from struct type name", func(t *testing.T) {
+		msg, err := NewActionFromStruct(&Originate{
+			Channel:  "PJSIP/100",
+			Context:  "default",
+			Exten:    "200",
+			Priority: 1,
+			Timeout:  30 * time.Second,
+			Async:    true,
+			Variable: map[string]string{"FOO": "bar", "ACCOUNT": "123"},
+			Internal: "skip",
+			private:  "skip",
+		})
+		assert.Nil(t, err)
+		want := "Action: Originate\r\n" +
+			"Channel: PJSIP/100\r\n" +
+			"Context: default\r\n" +
+			"Exten: 200\r\n" +
+			"Priority: 1\r\n" +
+			"Timeout: 30000\r\n" +
+			"Async: true\r\n" +
+			"Variable: ACCOUNT=123\r\n" +
+			"Variable: FOO=bar\r\n\r\n"
+		assert.Equal(t, want, msg.String())
+	})
+
+	t.Run("omit empty fields", func(t *testing.T) {
+		msg, err := NewActionFromStruct(Originate{Channel: "PJSIP/100"})
+		assert.Nil(t, err)
+		assert.Equal(t, "Action: Originate\r\nChannel: PJSIP/100\r\nAsync: false\r\n\r\n", msg.String())
+	})
+
+	t.Run("fail on missing required fields", func(t *testing.T) {
+		type DBGet struct {
+			Family string `ami:"Family,required"`
+			Key    string `ami:"Key,required"`
+		}
+		msg, err := NewActionFromStruct(DBGet{Family: "cidname", Key: "100"})
+		assert.Nil(t, err)
+		assert.Equal(t, "Action: DBGet\r\nFamily: cidname\r\nKey: 100\r\n\r\n", msg.String())
+
+		_, err = NewActionFromStruct(DBGet{Family: "cidname"})
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.ErrorContains(t, err, `DBGet: missing required field "Key"`)
+	})
+
+	t.Run("action name from field", func(t *testing.T) {
+		priority := 2
+		msg, err := NewActionFromStruct(struct {
+			Action   string
+			Channel  string `ami:"Channel"`
+			Priority *int
+			Count    *int
+			Ratio    float64
+			Volume   float32
+			Size     uint
+		}{"Setvar", "PJSIP/100", &priority, nil, 0.5, 0.1, 64})
+		assert.Nil(t, err)
+		assert.Equal(t, "Action: Setvar\r\nChannel: PJSIP/100\r\nPriority: 2\r\n"+
+			"Ratio: 0.5\r\nVolume: 0.1\r\nSize: 64\r\n\r\n", msg.String())
+	})
+
+	t.Run("fail on unsupported types", func(t *testing.T) {
+		tests := map[string]any{
+			`not struct`:     "Originate",
+			`nil pointer`:    (*Originate)(nil),
+			`nested struct`:  struct{ Nested struct{ Foo string } }{},
+			`int map`:        struct{ Vars map[string]int }{map[string]int{"a": 1}},
+			`slice`:          struct{ List []string }{[]string{"a"}},
+			`no action name`: struct{ Channel string }{"PJSIP/100"},
+		}
+		for name, v := range tests {
+			t.Run(name, func(t *testing.T) {
+				msg, err := NewActionFromStruct(v)
+				assert.ErrorIs(t, err, ErrAMI)
+				assert.Nil(t, msg)
+			})
+		}
+	})
+}
+
+type newchannelTest struct {
+	Event        string
+	Channel      string
+	ChannelState int    `ami:"ChannelState"`
+	Uniqueid     string `ami:"UniqueID"`
+	AccountCode  string
+	Priority     *int
+	Ratio        float32
+	Size         uint8
+	Answered     bool
+	Duration     time.Duration
+	Uptime       time.Duration
+	ChanVariable map[string]string
+	Missing      string
+}
+
+func TestMessageDecode(t *testing.T) {
+	input := "Event: Newchannel\r\n" +
+		"Channel: PJSIP/100-00000001\r\n" +
+		"ChannelState: 4\r\n" +
+		"Uniqueid: 1598887681.60\r\n" +
+		"AccountCode: \r\n" +
+		"Priority: 1\r\n" +
+		"Ratio: 0.25\r\n" +
+		"Size: 12\r\n" +
+		"Answered: yes\r\n" +
+		"Duration: 65\r\n" +
+		"Uptime: 01:02:03\r\n" +
+		"Unknown: field\r\n" +
+		"ChanVariable: realm=sip.com\r\n" +
+		"ChanVariable: account=123\r\n\r\n"
+
+	msg, err := Parse(input)
+	assert.Nil(t, err)
+
+	var ev newchannelTest
+	err = msg.Decode(&ev)
+	assert.Nil(t, err)
+	assert.Equal(t, "Newchannel", ev.Event)
+	assert.Equal(t, "PJSIP/100-00000001", ev.Channel)
+	assert.Equal(t, 4, ev.ChannelState)
+	assert.Equal(t, "1598887681.60", ev.Uniqueid)
+	assert.Equal(t, "", ev.AccountCode)
+	assert.Equal(t, 1, *ev.Priority)
+	assert.Equal(t, float32(0.25), ev.Ratio)
+	assert.Equal(t, uint8(12), ev.Size)
+	assert.True(t, ev.Answered)
+	assert.Equal(t, 65*time.Second, ev.Duration)
+	assert.Equal(t, time.Hour+2*time.Minute+3*time.Second, ev.Uptime)
+	assert.Equal(t, map[string]string{"realm": "sip.com", "account": "123"}, ev.ChanVariable)
+	assert.Equal(t, "", ev.Missing)
+}
+
+func TestMessageDurationRoundTrip(t *testing.T) {
+	type action struct {
+		Action   string
+		Timeout  time.Duration `ami:"Timeout,ms"`
+		Interval time.Duration `ami:"Interval,s"`
+		Wait     time.Duration
+	}
+	in := action{"Test", 30 * time.Second, 90 * time.Second, 1500 * time.Millisecond}
+
+	msg, err := NewActionFromStruct(in)
+	assert.Nil(t, err)
+	assert.Equal(t, "30000", msg.Field("Timeout"))
+	assert.Equal(t, "90", msg.Field("Interval"))
+	assert.Equal(t, "1.5", msg.Field("Wait"))
+
+	var out action
+	assert.Nil(t, msg.Decode(&out))
+	assert.Equal(t, in, out)
+}
+
+func TestMessageDecodeFail(t *testing.T) {
+	msg := NewMessage()
+	msg.AddField("ChannelState", "four")
+	msg.AddField("Answered", "maybe")
+	msg.AddField("Duration", "1:xx")
+
+	tests := map[string]any{
+		`not pointer`:     newchannelTest{},
+		`nil pointer`:     (*newchannelTest)(nil),
+		`not struct`:      new(string),
+		`invalid int`:     &struct{ ChannelState int }{},
+		`invalid bool`:    &struct{ Answered bool }{},
+		`invalid time`:    &struct{ Duration time.Duration }{},
+		`unsupported map`: &struct{ ChannelState map[string]int }{},
+		`unsupported`:     &struct{ ChannelState []string }{},
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := msg.Decode(v)
+			assert.ErrorIs(t, err, ErrAMI)
+		})
+	}
+}
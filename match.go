@@ -0,0 +1,321 @@
+package goami2
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Matcher reports if the message is received by the subscription, see
+// Client.SubscribeMatch. Matchers are called from the reading loop and must
+// be fast.
+type Matcher func(msg *Message) bool
+
+// MatchEvents matches events with one of the names, case insensitive
+func MatchEvents(names ...string) Matcher {
+	return func(msg *Message) bool {
+		name := msg.Field("Event")
+		for _, n := range names {
+			if strings.EqualFold(n, name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchHeader matches messages with the header value
+func MatchHeader(name, value string) Matcher {
+	return func(msg *Message) bool {
+		return msg.Field(name) == value
+	}
+}
+
+// MatchAll matches messages matched by all matchers
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(msg *Message) bool {
+		for _, m := range matchers {
+			if !m(msg) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAny matches messages matched by any of matchers
+func MatchAny(matchers ...Matcher) Matcher {
+	return func(msg *Message) bool {
+		for _, m := range matchers {
+			if m(msg) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// CompileMatcher compiles filter expression, for example
+//
+//	Event == "Hangup" && Channel =~ "^PJSIP/100"
+//
+// Expression compares header values with quoted strings: "==" and "!=" for
+// equality, "=~" and "!~" for regular expression match. Comparisons are
+// combined with "&&", "||", "!" and parentheses. Missing header has empty
+// value.
+func CompileMatcher(expr string) (Matcher, error) {
+	p := &exprParser{src: expr}
+	p.next()
+	m, err := p.or()
+	if err == nil && (p.err != nil || p.tok.kind != tokEOF) {
+		err = p.fail("unexpected " + p.tok.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid filter expression %q: %w", Error, expr, err)
+	}
+	return m, nil
+}
+
+// MustCompileMatcher is like CompileMatcher but panics on invalid expression
+func MustCompileMatcher(expr string) Matcher {
+	m, err := CompileMatcher(expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// SubscribeMatch creates subscription that receives events matched by the
+// matcher. Matcher is evaluated before events are copied to subscribers.
+// Matching events are not sent to the AllMessages channel, same as with
+// Subscribe, and channel buffer size and overflow policy are the same.
+func (c *Client) SubscribeMatch(match Matcher) *Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &subscription{
+		ch:       make(chan *Message, chanBuffer),
+		filter:   match,
+		overflow: OverflowDropNewest,
+	}
+	if c.closed {
+		close(sub.ch)
+	} else {
+		if c.subs == nil {
+			c.subs = make(map[<-chan *Message]*subscription)
+		}
+		c.subs[sub.ch] = sub
+	}
+	return &Subscription{client: c, ch: sub.ch}
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokOp // comparison operators
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at offset %d", t.text, t.pos)
+}
+
+// exprParser is recursive descent parser of filter expressions
+type exprParser struct {
+	src string
+	pos int
+	tok token
+	err error // lexer error
+}
+
+func (p *exprParser) fail(reason string) error {
+	if p.err != nil {
+		return p.err
+	}
+	return errors.New(reason)
+}
+
+// next reads the next token
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	two := p.src[p.pos:min(p.pos+2, len(p.src))]
+	switch {
+	case two == "==" || two == "!=" || two == "=~" || two == "!~":
+		p.pos += 2
+		p.tok = token{kind: tokOp, text: two, pos: start}
+	case two == "&&":
+		p.pos += 2
+		p.tok = token{kind: tokAnd, text: two, pos: start}
+	case two == "||":
+		p.pos += 2
+		p.tok = token{kind: tokOr, text: two, pos: start}
+	case p.src[p.pos] == '!':
+		p.pos++
+		p.tok = token{kind: tokNot, text: "!", pos: start}
+	case p.src[p.pos] == '(':
+		p.pos++
+		p.tok = token{kind: tokLParen, text: "(", pos: start}
+	case p.src[p.pos] == ')':
+		p.pos++
+		p.tok = token{kind: tokRParen, text: ")", pos: start}
+	case p.src[p.pos] == '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			p.err = fmt.Errorf("unterminated string at offset %d", start)
+			p.tok = token{kind: tokEOF, pos: start}
+			return
+		}
+		p.pos++
+		s, err := strconv.Unquote(p.src[start:p.pos])
+		if err != nil {
+			p.err = fmt.Errorf("invalid string at offset %d", start)
+			p.tok = token{kind: tokEOF, pos: start}
+			return
+		}
+		p.tok = token{kind: tokString, text: s, pos: start}
+	case isIdentByte(p.src[p.pos]):
+		for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		p.err = fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], start)
+		p.tok = token{kind: tokEOF, pos: start}
+	}
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '-' || b == '.' ||
+		('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9')
+}
+
+// or := and ("||" and)*
+func (p *exprParser) or() (Matcher, error) {
+	m, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	matchers := []Matcher{m}
+	for p.tok.kind == tokOr {
+		p.next()
+		m, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	if len(matchers) == 1 {
+		return matchers[0], nil
+	}
+	return MatchAny(matchers...), nil
+}
+
+// and := unary ("&&" unary)*
+func (p *exprParser) and() (Matcher, error) {
+	m, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	matchers := []Matcher{m}
+	for p.tok.kind == tokAnd {
+		p.next()
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	if len(matchers) == 1 {
+		return matchers[0], nil
+	}
+	return MatchAll(matchers...), nil
+}
+
+// unary := "!" unary | "(" or ")" | header op string
+func (p *exprParser) unary() (Matcher, error) {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		m, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(msg *Message) bool { return !m(msg) }, nil
+	case tokLParen:
+		p.next()
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.fail("expected \")\" instead of " + p.tok.String())
+		}
+		p.next()
+		return m, nil
+	case tokIdent:
+		return p.compare()
+	}
+	return nil, p.fail("expected header name instead of " + p.tok.String())
+}
+
+// compare := header op string
+func (p *exprParser) compare() (Matcher, error) {
+	header := p.tok.text
+	p.next()
+	if p.tok.kind != tokOp {
+		return nil, p.fail("expected comparison instead of " + p.tok.String())
+	}
+	op := p.tok.text
+	p.next()
+	if p.tok.kind != tokString {
+		return nil, p.fail("expected quoted string instead of " + p.tok.String())
+	}
+	value := p.tok.text
+	pos := p.tok.pos
+	p.next()
+
+	switch op {
+	case "==":
+		return MatchHeader(header, value), nil
+	case "!=":
+		return func(msg *Message) bool { return msg.Field(header) != value }, nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression at offset %d: %w", pos, err)
+	}
+	if op == "!~" {
+		return func(msg *Message) bool { return !re.MatchString(msg.Field(header)) }, nil
+	}
+	return func(msg *Message) bool { return re.MatchString(msg.Field(header)) }, nil
+}
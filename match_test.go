@@ -0,0 +1,96 @@
+package goami2
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileMatcher(t *testing.T) {
+	hangup, _ := Parse("Event: Hangup\r\nChannel: PJSIP/100-0001\r\nCause: 16\r\n\r\n")
+	newch, _ := Parse("Event: Newchannel\r\nChannel: SIP/200-0002\r\n\r\n")
+
+	tests := []struct {
+		expr   string
+		hangup bool
+		newch  bool
+	}{
+		{`Event == "Hangup"`, true, false},
+		{`Event != "Hangup"`, false, true},
+		{`Event == "Hangup" && Channel =~ "^PJSIP/100"`, true, false},
+		{`Channel =~ "^PJSIP/" || Channel =~ "^SIP/"`, true, true},
+		{`Channel !~ "^PJSIP/"`, false, true},
+		{`!(Event == "Hangup") && Missing == ""`, false, true},
+		{`Event=="Newchannel"||(Event=="Hangup"&&Cause=="17")`, false, true},
+		{`Uniqueid == "say \"hi\""`, false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			m, err := CompileMatcher(tc.expr)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.hangup, m(hangup))
+			assert.Equal(t, tc.newch, m(newch))
+		})
+	}
+}
+
+func TestCompileMatcherErrors(t *testing.T) {
+	tests := map[string]string{
+		``:                              "expected header name instead of end of expression",
+		`Event`:                         "expected comparison instead of end of expression",
+		`Event == Hangup`:               `expected quoted string instead of "Hangup" at offset 9`,
+		`Event == "Hangup`:              "unterminated string at offset 9",
+		`Event == "Hangup" &&`:          "expected header name instead of end of expression",
+		`(Event == "Hangup"`:            `expected ")" instead of end of expression`,
+		`Event == "Hangup")`:            `unexpected ")" at offset 17`,
+		`Event == "Hangup" & Cause`:     `unexpected '&' at offset 18`,
+		`Channel =~ "(PJSIP"`:           "invalid regular expression at offset 11",
+		`Event == "Hangup" Cause == ""`: `unexpected "Cause" at offset 18`,
+	}
+	for expr, want := range tests {
+		t.Run(expr, func(t *testing.T) {
+			_, err := CompileMatcher(expr)
+			assert.ErrorIs(t, err, Error)
+			assert.ErrorContains(t, err, want)
+		})
+	}
+	assert.Panics(t, func() { MustCompileMatcher("Event") })
+}
+
+func TestMatchers(t *testing.T) {
+	msg, _ := Parse("Event: QueueMemberStatus\r\nQueue: sales\r\nPaused: 1\r\n\r\n")
+	assert.True(t, MatchEvents("Hangup", "queuememberstatus")(msg))
+	assert.False(t, MatchEvents()(msg))
+	assert.True(t, MatchAll(MatchHeader("Queue", "sales"), MatchHeader("Paused", "1"))(msg))
+	assert.False(t, MatchAll(MatchHeader("Queue", "sales"), MatchHeader("Paused", "0"))(msg))
+	assert.True(t, MatchAny(MatchHeader("Queue", "support"), MatchHeader("Paused", "1"))(msg))
+	assert.False(t, MatchAny()(msg))
+}
+
+func TestClientSubscribeMatch(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	sub := cl.SubscribeMatch(MustCompileMatcher(`Event == "Hangup" && Channel =~ "^PJSIP/100"`))
+	defer sub.Cancel()
+	go func() {
+		_, _ = connSrv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/200-01\r\n\r\n" +
+			"Response: Success\r\nChannel: PJSIP/100-01\r\n\r\n" +
+			"Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+	}()
+
+	msg := <-sub.Messages()
+	assert.Equal(t, "PJSIP/100-01", msg.Field("Channel"))
+	assert.True(t, msg.IsEvent())
+	// not matched messages are sent to the messages channel
+	assert.Equal(t, "PJSIP/200-01", (<-cl.AllMessages()).Field("Channel"))
+	assert.True(t, (<-cl.AllMessages()).IsSuccess())
+
+	cl.Close()
+	_, ok := <-cl.SubscribeMatch(MatchEvents("Hangup")).Messages()
+	assert.False(t, ok)
+}
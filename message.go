@@ -1,16 +1,27 @@
 package goami2
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Message represents AMI message object
+// Message represents AMI message object. Header names are case insensitive
+// in all field accessors, since different Asterisk versions send names like
+// "ActionID", "Actionid" or "UniqueID" and "Uniqueid". Names are kept as
+// received, so message is written back unchanged.
 type Message struct {
-	h []Header
+	h   []Header
+	raw []byte // packet as received from AMI server
 }
 
 // Header of AMI Message
@@ -19,12 +30,36 @@ type Header struct {
 	Value string
 }
 
-// NewMessage creates new Message
-func NewMessage() *Message {
-	return &Message{
+// maxPooledHeaders is the headers capacity of the message that is too big
+// to keep in the pool
+const maxPooledHeaders = 256
+
+// messagePool keeps released messages for reuse
+var messagePool = sync.Pool{
+	New: func() any {
 		// big enough capacity to have best performance and alloc
-		h: make([]Header, 0, 32),
+		return &Message{h: make([]Header, 0, 32)}
+	},
+}
+
+// NewMessage creates new Message. Message is taken from the pool of released
+// messages when there is one.
+func NewMessage() *Message {
+	return messagePool.Get().(*Message)
+}
+
+// Release returns message to the pool, so Parse and NewMessage can reuse it
+// without allocation. It is optional and helps to reduce GC pressure on busy
+// servers. Message must not be used after Release, including by other
+// subscribers and handlers that received the same message.
+func (m *Message) Release() {
+	if cap(m.h) > maxPooledHeaders {
+		return
 	}
+	clear(m.h)
+	m.h = m.h[:0]
+	m.raw = nil
+	messagePool.Put(m)
 }
 
 // NewAction creats action message
@@ -34,7 +69,9 @@ func NewAction(name string) *Message {
 	return msg
 }
 
-// Field return field value.  Case insensitive field search by name
+// Field return field value.  Case insensitive field search by name.
+// When message has several headers with the name, returns the first one.
+// Use FieldValues to get all of them.
 func (m *Message) Field(key string) string {
 	for _, hdr := range m.Headers() {
 		if strings.EqualFold(hdr.Name, key) {
@@ -44,6 +81,37 @@ func (m *Message) Field(key string) string {
 	return ""
 }
 
+// FieldInt returns field value as integer. Returns false if field
+// is absent or is not a number
+func (m *Message) FieldInt(key string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(m.Field(key)))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// FieldBool returns field value as boolean. Accepts AMI values yes/no,
+// true/false, 1/0 and on/off. Returns false if field is absent or invalid
+func (m *Message) FieldBool(key string) (bool, bool) {
+	b, err := parseBool(strings.TrimSpace(m.Field(key)))
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// FieldDuration returns field value as duration. Accepts "hh:mm:ss" form,
+// like in "Uptime" or "BridgeDuration", or number of seconds.
+// Returns false if field is absent or invalid
+func (m *Message) FieldDuration(key string) (time.Duration, bool) {
+	d, err := parseDuration(strings.TrimSpace(m.Field(key)), time.Second)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
+
 // FieldValues list of values for multiple headers with the same name
 func (m *Message) FieldValues(key string) []string {
 	hdrs := make([]string, 0)
@@ -69,18 +137,22 @@ func (m *Message) ActionID() string {
 func (m *Message) AddActionID() {
 	buf := make([]byte, 12)
 	_, _ = rand.Read(buf)
-	m.SetField("ActionID", fmt.Sprintf("%x", buf))
+	_ = m.SetField("ActionID", fmt.Sprintf("%x", buf))
 }
 
-// AddField add field with key and name
+// AddField add field with key and name. Line breaks are removed from key and
+// value so they can not break AMI framing and inject headers or actions.
+// Use SetField to get error on invalid input instead.
 func (m *Message) AddField(key, value string) {
-	m.h = append(m.h, Header{Name: key, Value: value})
+	m.raw = nil
+	m.h = append(m.h, Header{Name: stripLineBreaks(key), Value: stripLineBreaks(value)})
 }
 
 // DelField removes field from message by name
 func (m *Message) DelField(key string) {
 	for i, hdr := range m.Headers() {
 		if strings.EqualFold(hdr.Name, key) {
+			m.raw = nil
 			m.h = slices.Delete(m.h, i, i+1)
 			return
 		}
@@ -88,8 +160,13 @@ func (m *Message) DelField(key string) {
 }
 
 // SetField get first available field by name and updates
-// its value. If field not in list then append new field
-func (m *Message) SetField(name, value string) {
+// its value. If field not in list then append new field.
+// Returns error and does not change the message if name or value
+// contains line breaks or name contains colon.
+func (m *Message) SetField(name, value string) error {
+	if err := validateField(name, value); err != nil {
+		return err
+	}
 	id := -1
 
 	for i, hdr := range m.Headers() {
@@ -102,8 +179,117 @@ func (m *Message) SetField(name, value string) {
 	if id == -1 {
 		m.AddField(name, value)
 	} else {
+		m.raw = nil
 		m.h[id].Value = value
 	}
+	return nil
+}
+
+// SetFieldValues replaces all headers with the name by headers with given
+// values, like several "Variable" headers. New headers are placed at position
+// of the first replaced header, or appended when message has no such header.
+// When no values given all headers with the name are removed.
+// Returns error and does not change the message if name or any value contains
+// line breaks or name contains colon.
+func (m *Message) SetFieldValues(name string, values ...string) error {
+	for _, v := range append([]string{""}, values...) {
+		if err := validateField(name, v); err != nil {
+			return err
+		}
+	}
+	pos := -1
+	headers := make([]Header, 0, len(m.h)+len(values))
+	for _, hdr := range m.Headers() {
+		if !strings.EqualFold(hdr.Name, name) {
+			headers = append(headers, hdr)
+		} else if pos == -1 {
+			pos = len(headers)
+		}
+	}
+	if pos == -1 {
+		pos = len(headers)
+	}
+	fields := make([]Header, len(values))
+	for i, v := range values {
+		fields[i] = Header{Name: name, Value: v}
+	}
+	m.raw = nil
+	m.h = slices.Insert(headers, pos, fields...)
+	return nil
+}
+
+// Set sets header value like SetField and returns the message, so setters
+// can be chained, for example NewAction("Originate").Set("Channel", ch).
+// Line breaks are removed from name and value, same as with AddField.
+// Header with colon in name is not set.
+func (m *Message) Set(name, value string) *Message {
+	_ = m.SetField(stripLineBreaks(name), stripLineBreaks(value))
+	return m
+}
+
+// SetVar sets channel variable with "Variable: name=value" header and
+// returns the message for chaining. Existing "Variable" header of the
+// variable is updated. Variable name is case insensitive.
+func (m *Message) SetVar(name, value string) *Message {
+	name, value = stripLineBreaks(name), stripLineBreaks(value)
+	for i, hdr := range m.Headers() {
+		if !strings.EqualFold(hdr.Name, "Variable") {
+			continue
+		}
+		if k, _ := varsplit(hdr.Value); strings.EqualFold(k, name) {
+			m.raw = nil
+			m.h[i].Value = name + "=" + value
+			return m
+		}
+	}
+	m.AddField("Variable", name+"="+value)
+	return m
+}
+
+// SetVars sets channel variables with SetVar in order of variable names
+// and returns the message for chaining
+func (m *Message) SetVars(vars map[string]string) *Message {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.SetVar(name, vars[name])
+	}
+	return m
+}
+
+// validateField returns error if header breaks AMI framing
+func validateField(name, value string) error {
+	if strings.ContainsAny(name, "\r\n:") {
+		return fmt.Errorf("%w: invalid header name %q", ErrAMI, name)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("%w: invalid header %q value: line break", ErrAMI, name)
+	}
+	return nil
+}
+
+// stripLineBreaks removes CR and LF characters
+func stripLineBreaks(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// Privileges returns permission classes of the message from "Privilege" field,
+// for example "call" and "all" for "Privilege: call,all".
+// Returns empty slice when field is absent.
+func (m *Message) Privileges() []string {
+	privileges := []string{}
+	for _, p := range strings.Split(m.Field("Privilege"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			privileges = append(privileges, p)
+		}
+	}
+	return privileges
 }
 
 // IsEvent returns true if message is event
@@ -121,6 +307,12 @@ func (m *Message) IsSuccess() bool {
 	return strings.EqualFold(m.Field("Response"), "success")
 }
 
+// Clone creates a copy of the message that can be modified without changing
+// the original, for example by middleware redacting or rewriting headers
+func (m *Message) Clone() *Message {
+	return &Message{h: slices.Clone(m.h), raw: m.raw}
+}
+
 // Len returns number of headers in the message
 func (m *Message) Len() int {
 	return len(m.h)
@@ -155,25 +347,155 @@ func (m *Message) JSON() string {
 	return "{}"
 }
 
-func FromJSON(input string) (*Message, error) {
-	var jsonObj any
-	if err := json.Unmarshal([]byte(input), &jsonObj); err != nil {
-		return nil, fmt.Errorf("%w: failed to unmarshal from json: %s", ErrAMI, err)
-	}
+// FromJSON creates message from JSON object. Object values are strings,
+// numbers or booleans. Array value adds header for every element, so repeated
+// headers like "Variable" are kept. Nested object value adds "key=value"
+// header for every object field, like in the object produced by JSON.
+func FromJSON(input []byte) (*Message, error) {
 	msg := NewMessage()
+	if err := msg.UnmarshalJSON(input); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
 
-	for k, v := range jsonObj.(map[string]any) {
-		switch t := v.(type) {
-		case map[string]any:
-			for nam, val := range t {
-				msg.AddField(k, fmt.Sprintf("%v=%v", nam, val))
+// MarshalJSON implements json.Marshaler. Message is encoded as JSON object
+// with header names as keys in order of headers. Values of repeated headers,
+// like "Variable", are encoded as array at the position of the first header.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	var names []string
+	values := make(map[string][]string)
+	for _, h := range m.Headers() {
+		key := strings.ToLower(h.Name)
+		if _, ok := values[key]; !ok {
+			names = append(names, h.Name)
+		}
+		values[key] = append(values[key], h.Value)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		var val []byte
+		if v := values[strings.ToLower(name)]; len(v) == 1 {
+			val, _ = json.Marshal(v[0])
+		} else {
+			val, _ = json.Marshal(v)
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It replaces message headers
+// with fields of JSON object in the order they appear. See FromJSON for the
+// accepted values.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	fail := func(err error) error {
+		return fmt.Errorf("%w: failed to unmarshal from json: %w", ErrAMI, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := jsonDelim(dec, '{'); err != nil {
+		return fail(err)
+	}
+
+	headers := make([]Header, 0, 32)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fail(err)
+		}
+		name := tok.(string)
+		if tok, err = dec.Token(); err != nil {
+			return fail(err)
+		}
+		switch tok {
+		case json.Delim('['):
+			for dec.More() {
+				val, err := jsonScalar(dec)
+				if err != nil {
+					return fail(err)
+				}
+				headers = append(headers, Header{Name: name, Value: val})
+			}
+			err = jsonDelim(dec, ']')
+		case json.Delim('{'):
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return fail(err)
+				}
+				val, err := jsonScalar(dec)
+				if err != nil {
+					return fail(err)
+				}
+				headers = append(headers, Header{Name: name, Value: fmt.Sprintf("%v=%s", key, val)})
 			}
+			err = jsonDelim(dec, '}')
+		case nil:
 		default:
-			msg.AddField(k, fmt.Sprintf("%v", t))
+			var val string
+			if val, err = scalarString(tok); err == nil {
+				headers = append(headers, Header{Name: name, Value: val})
+			}
+		}
+		if err != nil {
+			return fail(err)
 		}
 	}
+	if err := jsonDelim(dec, '}'); err != nil {
+		return fail(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fail(errors.New("unexpected data after object"))
+	}
 
-	return msg, nil
+	m.h = headers
+	m.raw = nil
+	return nil
+}
+
+// jsonDelim reads the next token and checks it is the delimiter
+func jsonDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expected %q, got %v", delim, tok)
+	}
+	return nil
+}
+
+// jsonScalar reads the next token as string, number or boolean value
+func jsonScalar(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	return scalarString(tok)
+}
+
+func scalarString(tok json.Token) (string, error) {
+	switch v := tok.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", tok)
 }
 
 // String AMI message as string
@@ -190,11 +512,33 @@ func (m *Message) String() string {
 	return buf.String()
 }
 
+// SortedString returns AMI message as string with headers sorted by name, case
+// insensitive. Repeated headers keep their order, so messages with the same
+// headers added in different order have the same string, for example in tests.
+func (m *Message) SortedString() string {
+	sorted := &Message{h: slices.Clone(m.h)}
+	slices.SortStableFunc(sorted.h, func(a, b Header) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return sorted.String()
+}
+
 // Byte AMI message as byte array
 func (m *Message) Byte() []byte {
 	return []byte(m.String())
 }
 
+// Bytes returns the message packet. For the message received from AMI server
+// it is exact packet bytes as read from the network, including lines that are
+// not headers, like command output. Otherwise, or when message was modified,
+// headers are serialized in the order they were added, same as Byte.
+func (m *Message) Bytes() []byte {
+	if m.raw != nil {
+		return slices.Clone(m.raw)
+	}
+	return m.Byte()
+}
+
 // Var search in AMI message variable fields like Variable or ChanVariable etc
 // for a value of the type key=value or just key. If found, returns value as string
 // and true. Variable name is case insensative.
@@ -1,7 +1,9 @@
 package goami2
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -74,6 +76,45 @@ func TestMessageSetField(t *testing.T) {
 	assert.Equal(t, "Action: Status\r\nFoo: 222\r\nBar: 333\r\n\r\n", msg.String())
 }
 
+func TestMessageFieldInjection(t *testing.T) {
+	injection := "x\r\nAction: Command\r\nCommand: core stop now\r\n\r\n"
+
+	t.Run("SetField rejects line breaks", func(t *testing.T) {
+		msg := NewAction("Setvar")
+		msg.AddField("Variable", "FOO")
+		assert.ErrorIs(t, msg.SetField("Variable", injection), ErrAMI)
+		assert.ErrorIs(t, msg.SetField("Value", injection), ErrAMI)
+		assert.ErrorIs(t, msg.SetField("Value\nAction", "Command"), ErrAMI)
+		assert.ErrorIs(t, msg.SetField("Action: Command", ""), ErrAMI)
+		assert.Equal(t, "Action: Setvar\r\nVariable: FOO\r\n\r\n", msg.String())
+		assert.Nil(t, msg.SetField("Value", "bar"))
+	})
+
+	t.Run("AddField strips line breaks", func(t *testing.T) {
+		msg := NewAction("Setvar")
+		msg.AddField("Variable", injection)
+		assert.Equal(t, "Action: Setvar\r\nVariable: xAction: CommandCommand: core stop now\r\n\r\n", msg.String())
+		parsed, err := Parse(msg.String())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, parsed.Len())
+		assert.Equal(t, "Setvar", parsed.Field("Action"))
+	})
+
+	t.Run("struct action rejects line breaks", func(t *testing.T) {
+		_, err := NewActionFromStruct(struct {
+			Action   string
+			Variable map[string]string
+		}{"Originate", map[string]string{"FOO": injection}})
+		assert.ErrorIs(t, err, ErrAMI)
+
+		_, err = NewActionFromStruct(struct {
+			Action  string
+			Channel string
+		}{"Originate", injection})
+		assert.ErrorIs(t, err, ErrAMI)
+	})
+}
+
 func TestMessageVar(t *testing.T) {
 	m := NewMessage()
 	m.AddField("Event", "Newchannel")
@@ -115,6 +156,89 @@ func TestMessageVar(t *testing.T) {
 	}
 }
 
+func TestMessageFieldCase(t *testing.T) {
+	msg, err := Parse("Event: Newchannel\r\nUniqueID: 1598887681.60\r\n" +
+		"Actionid: id-1\r\nChannelState: 6\r\nCHANVARIABLE: a=1\r\nChanVariable: b=2\r\n\r\n")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "1598887681.60", msg.Field("Uniqueid"))
+	assert.Equal(t, "id-1", msg.ActionID())
+	n, ok := msg.FieldInt("channelstate")
+	assert.True(t, ok)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, []string{"a=1", "b=2"}, msg.FieldValues("ChanVariable"))
+
+	var snap ChannelSnapshot
+	assert.Nil(t, msg.Decode(&snap))
+	assert.Equal(t, "1598887681.60", snap.Uniqueid)
+
+	assert.Nil(t, msg.SetField("ActionID", "id-2"))
+	msg.DelField("UNIQUEID")
+	assert.Equal(t, "Event: Newchannel\r\nActionid: id-2\r\nChannelState: 6\r\n"+
+		"CHANVARIABLE: a=1\r\nChanVariable: b=2\r\n\r\n", msg.String())
+}
+
+func TestMessageSetFieldValues(t *testing.T) {
+	msg, err := Parse("Action: Originate\r\nVariable: A=1\r\nChannel: PJSIP/100\r\n" +
+		"variable: B=2\r\n\r\n")
+	assert.Nil(t, err)
+
+	assert.Nil(t, msg.SetFieldValues("Variable", "X=1", "Y=2", "Z=3"))
+	assert.Equal(t, "Action: Originate\r\nVariable: X=1\r\nVariable: Y=2\r\n"+
+		"Variable: Z=3\r\nChannel: PJSIP/100\r\n\r\n", string(msg.Bytes()))
+	assert.Equal(t, "X=1", msg.Field("Variable"))
+
+	assert.Nil(t, msg.SetFieldValues("Codecs", "ulaw", "alaw"))
+	assert.Equal(t, []string{"ulaw", "alaw"}, msg.FieldValues("codecs"))
+	assert.Equal(t, 7, msg.Len())
+
+	assert.Nil(t, msg.SetFieldValues("VARIABLE"))
+	assert.Empty(t, msg.FieldValues("Variable"))
+	assert.Equal(t, 4, msg.Len())
+
+	assert.ErrorIs(t, msg.SetFieldValues("Codecs", "ulaw", "alaw\r\nAction: Command"), ErrAMI)
+	assert.ErrorIs(t, msg.SetFieldValues("Co:decs", "ulaw"), ErrAMI)
+	assert.Equal(t, []string{"ulaw", "alaw"}, msg.FieldValues("codecs"))
+}
+
+func TestMessageBuilder(t *testing.T) {
+	msg := NewAction("Originate").
+		Set("Channel", "PJSIP/100").
+		Set("Exten", "200").
+		Set("Exten", "300").
+		Set("Con:text", "default").
+		Set("Context\r\n", "default\r\nAction: Command").
+		SetVar("FOO", "bar").
+		SetVars(map[string]string{"B": "2", "A": "1=1", "foo": "baz"})
+
+	assert.Equal(t, "Action: Originate\r\nChannel: PJSIP/100\r\nExten: 300\r\n"+
+		"Context: defaultAction: Command\r\nVariable: foo=baz\r\nVariable: A=1=1\r\n"+
+		"Variable: B=2\r\n\r\n", msg.String())
+
+	v, ok := msg.Var("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1=1", v)
+}
+
+func TestMessageRelease(t *testing.T) {
+	msg, err := Parse("Event: Hangup\r\nChannel: PJSIP/100\r\n\r\n")
+	assert.Nil(t, err)
+	headers := msg.h[:2]
+	msg.Release()
+	assert.Equal(t, []Header{{}, {}}, headers, "released headers are cleared")
+
+	msg = NewMessage()
+	assert.Equal(t, 0, msg.Len())
+	assert.Nil(t, msg.raw)
+
+	big := NewMessage()
+	for i := 0; i <= maxPooledHeaders; i++ {
+		big.AddField("Variable", "x")
+	}
+	big.Release()
+	assert.Equal(t, maxPooledHeaders+1, big.Len(), "big message is not pooled")
+}
+
 func TestMessageJSON(t *testing.T) {
 	m := NewMessage()
 	m.AddField("Event", "Newchannel")
@@ -162,7 +286,7 @@ func TestMessageFromJSON(t *testing.T) {
 	t.Run("successfully created message", func(t *testing.T) {
 		jstr := `{"action":"ConfbridgeKick","conference":"Sales",` +
 			`"channel":"Local/Sales-65f4a00b-001"}`
-		m, err := FromJSON(jstr)
+		m, err := FromJSON([]byte(jstr))
 		assert.Nil(t, err)
 
 		assert.Equal(t, "ConfbridgeKick", m.Field("Action"))
@@ -181,7 +305,7 @@ func TestMessageFromJSON(t *testing.T) {
 		}
 
 		for _, tc := range tests {
-			_, err := FromJSON(tc.input)
+			_, err := FromJSON([]byte(tc.input))
 			assert.ErrorIs(t, err, tc.want, tc.input)
 		}
 	})
@@ -192,7 +316,7 @@ func TestMessageFromJSONMultilevel(t *testing.T) {
 		`"context":"inbound","event":"Newchannel","exten":"31337",` +
 		`"chanvariable":{"account":"123","realm":"sip.pbx.com"},` +
 		`"variable":{"DIR":"inbound","extern":"true","FOO":""}}`
-	m, err := FromJSON(jstr)
+	m, err := FromJSON([]byte(jstr))
 	assert.Nil(t, err)
 
 	assert.Equal(t, "Newchannel", m.Field("Event"))
@@ -204,3 +328,226 @@ func TestMessageFromJSONMultilevel(t *testing.T) {
 	assert.ElementsMatch(t, []string{"DIR=inbound", "extern=true", "FOO="},
 		m.FieldValues("variable"))
 }
+
+func TestMessageMarshalJSON(t *testing.T) {
+	m := NewMessage()
+	m.AddField("Event", "VarSet")
+	m.AddField("Channel", "PJSIP/100-01")
+	m.AddField("Variable", "DIR=inbound")
+	m.AddField("Value", "say \"hi\"")
+	m.AddField("Variable", "FOO")
+
+	data, err := json.Marshal(m)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Event":"VarSet","Channel":"PJSIP/100-01",`+
+		`"Variable":["DIR=inbound","FOO"],"Value":"say \"hi\""}`, string(data))
+
+	data, err = json.Marshal(map[string]*Message{"msg": NewMessage()})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"msg":{}}`, string(data))
+}
+
+func TestMessageUnmarshalJSON(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		m, err := Parse("Event: Hangup\r\nChannel: PJSIP/100-01\r\n" +
+			"ChanVariable: a=1\r\nChanVariable: b=2\r\n\r\n")
+		assert.Nil(t, err)
+		data, err := json.Marshal(m)
+		assert.Nil(t, err)
+
+		var got struct{ Msg *Message }
+		assert.Nil(t, json.Unmarshal([]byte(`{"Msg":`+string(data)+`}`), &got))
+		assert.Equal(t, m.Headers(), got.Msg.Headers())
+	})
+
+	t.Run("values", func(t *testing.T) {
+		m, err := FromJSON([]byte(`{"Action":"Originate","Timeout":30000,"Async":true,` +
+			`"Data":null,"Variable":["A=1",2],"ChanVariable":{"x":"1","y":2}}`))
+		assert.Nil(t, err)
+		assert.Equal(t, []Header{
+			{"Action", "Originate"}, {"Timeout", "30000"}, {"Async", "true"},
+			{"Variable", "A=1"}, {"Variable", "2"},
+			{"ChanVariable", "x=1"}, {"ChanVariable", "y=2"},
+		}, m.Headers())
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, input := range []string{
+			`["Action"]`,
+			`{"Action":"Foo"} {}`,
+			`{"Variable":["A",["B"]]}`,
+			`{"Variable":{"A":{"B":"C"}}}`,
+			`{"Action":`,
+		} {
+			_, err := FromJSON([]byte(input))
+			assert.ErrorIs(t, err, ErrAMI, input)
+		}
+	})
+}
+
+func TestMessageBytes(t *testing.T) {
+	t.Run("raw packet round trip", func(t *testing.T) {
+		packets := []string{
+			"Event: Newchannel\r\nChannel: PJSIP/100-00000001\r\nUniqueid: 1598887681.60\r\n" +
+				"ChanVariable: b=2\r\nChanVariable: a=1\r\n\r\n",
+			"Response: Follows\r\nPrivilege: Command\r\nActionID: 1\r\n" +
+				"Channel              Location\r\n\r\n0 active channels\r\n--END COMMAND--\r\n\r\n",
+		}
+		for _, pack := range packets {
+			msg, err := parsePacket(pack)
+			assert.Nil(t, err)
+			assert.Equal(t, pack, string(msg.Bytes()))
+			assert.Equal(t, pack, string(msg.Clone().Bytes()))
+		}
+	})
+
+	t.Run("modified message is serialized", func(t *testing.T) {
+		msg, err := parsePacket("Event: Hangup\r\nCause: 16\r\n\r\n")
+		assert.Nil(t, err)
+		msg.SetField("Cause", "17")
+		assert.Equal(t, "Event: Hangup\r\nCause: 17\r\n\r\n", string(msg.Bytes()))
+	})
+
+	t.Run("deterministic action output", func(t *testing.T) {
+		msg := NewAction("Originate")
+		msg.AddField("Channel", "PJSIP/100")
+		msg.AddField("Variable", "B=2")
+		msg.AddField("Variable", "A=1")
+		msg.AddField("ActionID", "1")
+		want := "Action: Originate\r\nChannel: PJSIP/100\r\nVariable: B=2\r\nVariable: A=1\r\nActionID: 1\r\n\r\n"
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, want, string(msg.Bytes()))
+		}
+	})
+}
+
+func TestMessagePrivileges(t *testing.T) {
+	msg := NewMessage()
+	assert.Equal(t, []string{}, msg.Privileges())
+	msg.AddField("Privilege", "call, all,")
+	assert.Equal(t, []string{"call", "all"}, msg.Privileges())
+}
+
+func TestMessageTypedFields(t *testing.T) {
+	msg := NewMessage()
+	msg.AddField("Priority", "12")
+	msg.AddField("Negative", " -3 ")
+	msg.AddField("Empty", "")
+	msg.AddField("Text", "foo")
+	msg.AddField("Yes", "yes")
+	msg.AddField("No", "No")
+	msg.AddField("True", "true")
+	msg.AddField("False", "false")
+	msg.AddField("One", "1")
+	msg.AddField("Zero", "0")
+	msg.AddField("Uptime", "01:02:03")
+	msg.AddField("BridgeDuration", "00:00:45")
+	msg.AddField("Seconds", "90")
+
+	t.Run("int", func(t *testing.T) {
+		tests := map[string]struct {
+			want int
+			ok   bool
+		}{
+			"Priority": {12, true},
+			"Negative": {-3, true},
+			"Zero":     {0, true},
+			"Empty":    {0, false},
+			"Text":     {0, false},
+			"Missing":  {0, false},
+		}
+		for key, tc := range tests {
+			n, ok := msg.FieldInt(key)
+			assert.Equal(t, tc.want, n, key)
+			assert.Equal(t, tc.ok, ok, key)
+		}
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		tests := map[string]struct {
+			want bool
+			ok   bool
+		}{
+			"Yes":     {true, true},
+			"No":      {false, true},
+			"True":    {true, true},
+			"False":   {false, true},
+			"One":     {true, true},
+			"Zero":    {false, true},
+			"Text":    {false, false},
+			"Missing": {false, false},
+		}
+		for key, tc := range tests {
+			b, ok := msg.FieldBool(key)
+			assert.Equal(t, tc.want, b, key)
+			assert.Equal(t, tc.ok, ok, key)
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		tests := map[string]struct {
+			want time.Duration
+			ok   bool
+		}{
+			"Uptime":         {time.Hour + 2*time.Minute + 3*time.Second, true},
+			"BridgeDuration": {45 * time.Second, true},
+			"Seconds":        {90 * time.Second, true},
+			"Zero":           {0, true},
+			"Text":           {0, false},
+			"Empty":          {0, false},
+			"Missing":        {0, false},
+		}
+		for key, tc := range tests {
+			d, ok := msg.FieldDuration(key)
+			assert.Equal(t, tc.want, d, key)
+			assert.Equal(t, tc.ok, ok, key)
+		}
+	})
+}
+
+func TestMessageClone(t *testing.T) {
+	msg, err := Parse("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n")
+	assert.Nil(t, err)
+
+	cp := msg.Clone()
+	assert.Nil(t, cp.SetField("Channel", "PJSIP/200-01"))
+	cp.DelField("Event")
+	assert.Equal(t, "Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n", string(msg.Bytes()))
+	assert.Equal(t, "Channel: PJSIP/200-01\r\n\r\n", string(cp.Bytes()))
+	assert.Equal(t, msg.Bytes(), msg.Clone().Bytes())
+}
+
+func TestMessageSortedString(t *testing.T) {
+	a := NewAction("Originate").Set("Variable", "B=2").Set("Channel", "PJSIP/100")
+	a.AddField("Variable", "A=1")
+	b := NewMessage()
+	b.AddField("Channel", "PJSIP/100")
+	b.AddField("Action", "Originate")
+	b.AddField("Variable", "B=2")
+	b.AddField("Variable", "A=1")
+
+	assert.NotEqual(t, a.String(), b.String())
+	assert.Equal(t, "Action: Originate\r\nChannel: PJSIP/100\r\nVariable: B=2\r\nVariable: A=1\r\n\r\n",
+		a.SortedString())
+	assert.Equal(t, a.SortedString(), b.SortedString())
+	assert.Equal(t, "Action: Originate\r\nVariable: B=2\r\nChannel: PJSIP/100\r\nVariable: A=1\r\n\r\n",
+		a.String())
+}
+
+func BenchmarkMessageField(b *testing.B) {
+	msg, _ := Parse(rawPack)
+	tests := map[string]string{
+		"first": "Event",
+		"last":  "Uniqueid",
+		"case":  "uniqueid",
+		"miss":  "DestChannel",
+	}
+	for name, key := range tests {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = msg.Field(key)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package goami2
+
+import (
+	"expvar"
+	"time"
+)
+
+// Metrics receives client counters, for example to export them to Prometheus.
+// Methods are called from the reading loop and senders and must be fast and
+// safe for concurrent use.
+type Metrics interface {
+	// IncActionsSent is called for every action written to the connection
+	IncActionsSent()
+	// IncEventsReceived is called for every received event with the event name
+	// or "unknown" when event has no name
+	IncEventsReceived(eventName string)
+	// IncReconnects is called on every successful reconnect
+	IncReconnects()
+	// ObserveActionLatency is called with time between sending action
+	// and receiving its response by SendAction and client helpers
+	ObserveActionLatency(d time.Duration)
+}
+
+// ExtendedMetrics is optionally implemented by Metrics to observe receiving
+// backpressure. Client checks for it when metrics are set with WithMetrics.
+type ExtendedMetrics interface {
+	// IncParseErrors is called for every packet that failed to parse
+	IncParseErrors()
+	// IncMessagesDropped is called for every message dropped because
+	// messages channel buffer is full or client is paused
+	IncMessagesDropped()
+	// SetMessagesQueued is called with number of messages waiting in the
+	// messages channel buffer every time message is forwarded to the channel
+	SetMessagesQueued(n int)
+}
+
+// nopMetrics is default metrics that does nothing
+type nopMetrics struct{}
+
+func (nopMetrics) IncActionsSent()                    {}
+func (nopMetrics) IncEventsReceived(string)           {}
+func (nopMetrics) IncReconnects()                     {}
+func (nopMetrics) ObserveActionLatency(time.Duration) {}
+func (nopMetrics) IncParseErrors()                    {}
+func (nopMetrics) IncMessagesDropped()                {}
+func (nopMetrics) SetMessagesQueued(int)              {}
+
+// ExpvarMetrics implements Metrics and ExtendedMetrics with expvar variables.
+// It is expvar.Var itself and can be published with expvar.Publish, for
+// example expvar.Publish("ami", metrics), to be served on "/debug/vars".
+// Variables are "actions_sent", "events_received" map by event name,
+// "reconnects", "action_latency_seconds" sum and "action_latency_count",
+// "parse_errors", "messages_dropped" and "messages_queued".
+type ExpvarMetrics struct {
+	expvar.Map
+	actionsSent    expvar.Int
+	eventsReceived expvar.Map
+	reconnects     expvar.Int
+	latencySum     expvar.Float
+	latencyCount   expvar.Int
+	parseErrors    expvar.Int
+	dropped        expvar.Int
+	queued         expvar.Int
+}
+
+// NewExpvarMetrics creates metrics with zero counters
+func NewExpvarMetrics() *ExpvarMetrics {
+	m := &ExpvarMetrics{}
+	m.Set("actions_sent", &m.actionsSent)
+	m.Set("events_received", &m.eventsReceived)
+	m.Set("reconnects", &m.reconnects)
+	m.Set("action_latency_seconds", &m.latencySum)
+	m.Set("action_latency_count", &m.latencyCount)
+	m.Set("parse_errors", &m.parseErrors)
+	m.Set("messages_dropped", &m.dropped)
+	m.Set("messages_queued", &m.queued)
+	return m
+}
+
+func (m *ExpvarMetrics) IncActionsSent()               { m.actionsSent.Add(1) }
+func (m *ExpvarMetrics) IncEventsReceived(name string) { m.eventsReceived.Add(name, 1) }
+func (m *ExpvarMetrics) IncReconnects()                { m.reconnects.Add(1) }
+func (m *ExpvarMetrics) IncParseErrors()               { m.parseErrors.Add(1) }
+func (m *ExpvarMetrics) IncMessagesDropped()           { m.dropped.Add(1) }
+func (m *ExpvarMetrics) SetMessagesQueued(n int)       { m.queued.Set(int64(n)) }
+func (m *ExpvarMetrics) ObserveActionLatency(d time.Duration) {
+	m.latencySum.Add(d.Seconds())
+	m.latencyCount.Add(1)
+}
+
+// extendedMetrics returns client metrics as ExtendedMetrics or nop metrics
+// when they do not implement it
+func (c *Client) extendedMetrics() ExtendedMetrics {
+	if m, ok := c.metrics.(ExtendedMetrics); ok {
+		return m
+	}
+	return nopMetrics{}
+}
+
+// eventName returns event name for metrics
+func eventName(msg *Message) string {
+	if name := msg.Field("Event"); name != "" {
+		return name
+	}
+	return "unknown"
+}
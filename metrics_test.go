@@ -0,0 +1,143 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMetrics struct {
+	mu         sync.Mutex
+	sent       int
+	events     map[string]int
+	reconnects int
+	latency    []time.Duration
+}
+
+func (m *testMetrics) IncActionsSent() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent++
+}
+
+func (m *testMetrics) IncEventsReceived(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events[name]++
+}
+
+func (m *testMetrics) IncReconnects() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnects++
+}
+
+func (m *testMetrics) ObserveActionLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = append(m.latency, d)
+}
+
+func TestClientMetrics(t *testing.T) {
+	metrics := &testMetrics{events: map[string]int{}}
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithMetrics(metrics)(cl)
+	WithMetrics(nil)(cl)
+	WithReconnect(1, time.Millisecond)(cl)
+	cl.dial = func(context.Context) (net.Conn, error) {
+		conn, srv := net.Pipe()
+		connSrvSess(srv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		return conn, nil
+	}
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		r := bufio.NewReader(connSrv)
+		_, _ = srvReadAction(r) // Action without response
+		msg, err := srvReadAction(r)
+		if err != nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: Newchannel\r\n\r\nEvent: Newchannel\r\n\r\n"))
+		_ = connSrv.Close()
+	}()
+
+	assert.True(t, cl.Action(NewAction("Events")))
+	_, err := cl.SendAction(context.Background(), NewAction("Ping"))
+	assert.Nil(t, err)
+	for _, name := range []string{"Newchannel", "Newchannel", "Disconnected", "Reconnected"} {
+		assert.Equal(t, name, (<-cl.AllMessages()).Field("Event"))
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	assert.Equal(t, 2, metrics.sent)
+	assert.Equal(t, map[string]int{"Newchannel": 2}, metrics.events)
+	assert.Equal(t, 1, metrics.reconnects)
+	assert.Len(t, metrics.latency, 1)
+	assert.GreaterOrEqual(t, metrics.latency[0], 5*time.Millisecond)
+}
+
+func TestEventName(t *testing.T) {
+	msg := NewMessage()
+	assert.Equal(t, "unknown", eventName(msg))
+	msg.AddField("Event", "Hangup")
+	assert.Equal(t, "Hangup", eventName(msg))
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	metrics := NewExpvarMetrics()
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithMetrics(metrics)(cl)
+	WithOverflowPolicy(OverflowDropNewest)(cl)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		r := bufio.NewReader(connSrv)
+		msg, err := srvReadAction(r)
+		if err != nil {
+			return
+		}
+		_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: Foo\r\nfoo bar\r\n\r\n"))
+		for i := 0; i < chanBuffer+2; i++ {
+			_, _ = connSrv.Write([]byte("Event: Newchannel\r\n\r\n"))
+		}
+	}()
+
+	_, err := cl.SendAction(context.Background(), NewAction("Ping"))
+	assert.Nil(t, err)
+	assert.ErrorIs(t, <-cl.Err(), ErrAMI)
+	assert.Eventually(t, func() bool { return cl.DroppedMessages() == 2 },
+		time.Second, time.Millisecond)
+
+	assert.Equal(t, "1", metrics.Get("actions_sent").String())
+	assert.Equal(t, `{"Newchannel": 14}`, metrics.Get("events_received").String())
+	assert.Equal(t, "1", metrics.Get("action_latency_count").String())
+	assert.NotEqual(t, "0", metrics.Get("action_latency_seconds").String())
+	assert.Equal(t, "1", metrics.Get("parse_errors").String())
+	assert.Equal(t, "2", metrics.Get("messages_dropped").String())
+	assert.Equal(t, "12", metrics.Get("messages_queued").String())
+	assert.Equal(t, "0", metrics.Get("reconnects").String())
+	assert.True(t, json.Valid([]byte(metrics.String())))
+}
+
+func TestExtendedMetricsOptional(t *testing.T) {
+	cl := makeClient(nil)
+	WithMetrics(&testMetrics{})(cl)
+	assert.Equal(t, nopMetrics{}, cl.extendedMetrics())
+	WithMetrics(NewExpvarMetrics())(cl)
+	assert.IsType(t, &ExpvarMetrics{}, cl.extendedMetrics())
+}
@@ -0,0 +1,64 @@
+package goami2
+
+// SendFunc sends action to AMI server
+type SendFunc func(action *Message) error
+
+// ReceiveFunc dispatches message received from AMI server
+type ReceiveFunc func(msg *Message)
+
+// Use registers middleware for outbound actions sent with Action, MustSend,
+// Send, SendAction and client helpers. Middleware is called in order of
+// registration, first registered middleware is the outermost one, and the last
+// one calls the socket write. Middleware can modify the action, for example set
+// ActionID prefix, stop sending by returning error without calling next or
+// measure the time of next call. Actions are written to the connection
+// atomically after all middleware run. Actions given to MustSend and Send as bytes
+// are parsed to Message when middleware is registered. Login on connect and
+// reconnect does not run middleware.
+func (c *Client) Use(mw func(next SendFunc) SendFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middleware = append(c.middleware, mw)
+}
+
+// UseInbound registers middleware for messages received from AMI server.
+// Middleware runs in order of registration before the message is delivered to
+// the waiting action, handlers, subscribers or the messages channel, and before
+// it is logged. Middleware can modify the message, for example redact header
+// values or add tags, or drop the message by not calling next. Dropping the
+// response leaves the action waiting until timeout. Middleware is called from
+// the reading loop and must be fast. Login responses do not run middleware.
+func (c *Client) UseInbound(mw func(next ReceiveFunc) ReceiveFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inbound = append(c.inbound, mw)
+}
+
+// chainInbound wraps dispatch function with registered inbound middleware
+func (c *Client) chainInbound(dispatch ReceiveFunc) ReceiveFunc {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.inbound) - 1; i >= 0; i-- {
+		dispatch = c.inbound[i](dispatch)
+	}
+	return dispatch
+}
+
+// chain wraps send function with registered middleware. Permissions are
+// checked after middleware, that can change the action.
+func (c *Client) chain(send SendFunc) SendFunc {
+	send = c.permitted(send)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		send = c.middleware[i](send)
+	}
+	return send
+}
+
+// hasMiddleware returns true if any middleware registered
+func (c *Client) hasMiddleware() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.middleware) > 0
+}
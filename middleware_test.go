@@ -0,0 +1,136 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientUse(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	actions := make(chan *Message, 3)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			if msg.ActionID() != "" {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+			}
+		}
+	}()
+
+	var order []string
+	cl.Use(func(next SendFunc) SendFunc {
+		return func(action *Message) error {
+			order = append(order, "first")
+			if action.ActionID() != "" {
+				action.SetField("ActionID", "tenant1-"+action.ActionID())
+			}
+			return next(action)
+		}
+	})
+	cl.Use(func(next SendFunc) SendFunc {
+		return func(action *Message) error {
+			order = append(order, "second")
+			if action.Field("Action") == "Reload" {
+				return ErrAMI
+			}
+			action.AddField("Tenant", "tenant1")
+			return next(action)
+		}
+	})
+
+	t.Run("modify action and correlate response", func(t *testing.T) {
+		order = nil
+		action := NewAction("Ping")
+		action.AddField("ActionID", "42")
+		resp, err := cl.SendAction(context.Background(), action)
+		assert.Nil(t, err)
+		assert.Equal(t, "tenant1-42", resp.ActionID())
+		assert.Equal(t, []string{"first", "second"}, order)
+		msg := <-actions
+		assert.Equal(t, "tenant1-42", msg.ActionID())
+		assert.Equal(t, "tenant1", msg.Field("Tenant"))
+	})
+
+	t.Run("bytes action", func(t *testing.T) {
+		assert.Nil(t, cl.MustSend([]byte("Action: Events\r\nEventMask: off\r\n\r\n")))
+		msg := <-actions
+		assert.Equal(t, "Events", msg.Field("Action"))
+		assert.Equal(t, "tenant1", msg.Field("Tenant"))
+
+		err := cl.MustSend([]byte("invalid\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrAMI)
+	})
+
+	t.Run("short circuit", func(t *testing.T) {
+		order = nil
+		assert.False(t, cl.Action(NewAction("Reload")))
+		_, err := cl.SendAction(context.Background(), NewAction("Reload"))
+		assert.ErrorIs(t, err, ErrAMI)
+		assert.Equal(t, []string{"first", "second", "first", "second"}, order)
+		assert.Empty(t, actions)
+		cl.mu.Lock()
+		assert.Empty(t, cl.pending)
+		cl.mu.Unlock()
+	})
+}
+
+func TestClientUseInbound(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	var order []string
+	cl.UseInbound(func(next ReceiveFunc) ReceiveFunc {
+		return func(msg *Message) {
+			order = append(order, "first")
+			if msg.Field("Event") == "RTCPSent" {
+				return
+			}
+			next(msg)
+		}
+	})
+	cl.UseInbound(func(next ReceiveFunc) ReceiveFunc {
+		return func(msg *Message) {
+			order = append(order, "second")
+			if msg.Field("Secret") != "" {
+				_ = msg.SetField("Secret", "***")
+			}
+			msg.AddField("Tenant", "tenant1")
+			next(msg)
+		}
+	})
+
+	go func() {
+		msg, err := srvReadAction(bufio.NewReader(connSrv))
+		if err != nil {
+			return
+		}
+		_, _ = connSrv.Write([]byte("Event: RTCPSent\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: UserEvent\r\nSecret: pa55w0rd\r\n\r\n"))
+	}()
+
+	resp, err := cl.SendAction(context.Background(), NewAction("Ping"))
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant1", resp.Field("Tenant"))
+
+	msg := <-cl.AllMessages()
+	assert.Equal(t, "UserEvent", msg.Field("Event"))
+	assert.Equal(t, "***", msg.Field("Secret"))
+	assert.Equal(t, "tenant1", msg.Field("Tenant"))
+	assert.Equal(t, []string{"first", "first", "second", "first", "second"}, order)
+}
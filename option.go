@@ -0,0 +1,387 @@
+package goami2
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Option configures Client on construction
+type Option func(*Client)
+
+// WithReconnect enables reconnect mode. When connection is lost the client
+// redials the same address, login with the same credentials and resumes
+// reading messages. Channels AllMessages and Err stay open during reconnect.
+// When connection is lost message with "Event: Disconnected" and "Reason"
+// field is sent to the messages channel and on successful reconnect message
+// with "Event: Reconnected" is sent, so consumers can detect the gap in
+// events. Client waits backoff before each attempt, see WithMaxBackoff.
+// Client gives up after maxRetries failed attempts and sends ErrEOF error to
+// the errors channel. When maxRetries is zero or negative the client tries to
+// reconnect until context is done or client is closed. Actions waiting for
+// response when connection is lost fail with ErrReconnecting.
+func WithReconnect(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.reconnect = true
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithMaxBackoff makes reconnect delay grow exponentially. Backoff set by
+// WithReconnect doubles after each failed attempt up to max.
+func WithMaxBackoff(max time.Duration) Option {
+	return func(c *Client) {
+		c.maxBackoff = max
+	}
+}
+
+// WithTimeout sets network timeout for login, writing actions and Dial.
+// Default is one second. Zero or negative timeout is ignored.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithDialer sets dialer used by Dial and on reconnect. Nil dialer is ignored.
+func WithDialer(d Dialer) Option {
+	return func(c *Client) {
+		if d != nil {
+			c.dialer = d
+		}
+	}
+}
+
+// WithTLSConfig enables TLS session with given configuration over connection
+// dialed by Dial. When ServerName is not set it is taken from the address.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithActionTimeout sets default timeout for SendAction when context has no
+// deadline. Late response to the timed out action is dropped.
+func WithActionTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.actionTimeout = d
+	}
+}
+
+// WithActionIDPrefix sets prefix of ActionIDs generated for actions,
+// for example to tell actions of different applications in Asterisk logs.
+func WithActionIDPrefix(prefix string) Option {
+	return func(c *Client) {
+		c.idPrefix = stripLineBreaks(prefix)
+	}
+}
+
+// WithActionIDFunc sets generator of ActionIDs added to actions sent without
+// one. Generator must return unique ids and is called from multiple goroutines.
+// Prefix set with WithActionIDPrefix is added to generated ids. Raw bytes sent
+// with Send and MustSend are not changed. By default, ActionID is a random
+// client session id with a counter that keeps growing over reconnects.
+// Nil generator is ignored.
+func WithActionIDFunc(fn func() string) Option {
+	return func(c *Client) {
+		if fn != nil {
+			c.idFunc = fn
+		}
+	}
+}
+
+// WithResyncActions sets actions that are sent after each successful reconnect,
+// for example to request "Status" again. Filters added with AddEventFilter are
+// applied again before the actions. Responses and events of the actions are
+// sent to the messages channel after "Reconnected" event.
+func WithResyncActions(actions ...*Message) Option {
+	return func(c *Client) {
+		c.resync = actions
+	}
+}
+
+// WithPrivilegeFilter sets permission classes of events the client forwards
+// to the messages channel and subscribers. Events without any of the allowed
+// privileges in "Privilege" field are dropped. Responses are not filtered.
+func WithPrivilegeFilter(allowed ...string) Option {
+	return func(c *Client) {
+		c.privileges = allowed
+	}
+}
+
+// WithLoginRetry makes constructors retry login up to attempts times with
+// interval delay, see Client.LoginWithRetry.
+func WithLoginRetry(attempts int, interval time.Duration) Option {
+	return func(c *Client) {
+		c.loginAttempts = attempts
+		c.loginInterval = interval
+	}
+}
+
+// WithCredentials sets fallback credentials. When login is rejected, for
+// example with "Authentication failed" during credentials rotation, client
+// redials and logs in with the next credentials in order. Accepted
+// credentials replace the constructor credentials for reconnect.
+func WithCredentials(creds ...Credentials) Option {
+	return func(c *Client) {
+		c.fallbackCreds = append(c.fallbackCreds, creds...)
+	}
+}
+
+// WithCredentialsProvider sets function called before every login and
+// reconnect to get fresh credentials, for example from a secret store.
+// Provided credentials are tried before the constructor credentials and
+// credentials of WithCredentials. Login fails when provider fails.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(c *Client) {
+		c.credProvider = provider
+	}
+}
+
+// WithEventsMask sets events mask of the login action, for example "system,call"
+// to receive only system and call events or "off" to receive no events.
+// By default all events permitted to the manager user are sent.
+func WithEventsMask(mask ...string) Option {
+	return func(c *Client) {
+		c.eventsMask = stripLineBreaks(strings.Join(mask, ","))
+	}
+}
+
+// WithAuthMD5 enables MD5 challenge-response login, so the password is not
+// sent over the network. Manager user must have "authtype=md5" in manager.conf.
+func WithAuthMD5() Option {
+	return func(c *Client) {
+		c.authMD5 = true
+	}
+}
+
+// WithKeepAlive enables sending "Action: Ping" every interval. When there is no
+// response within the network timeout the connection is considered dead and it
+// is closed with ErrKeepAliveTimeout error, same way as on read error. With
+// WithReconnect client connects again. Ping responses are not sent to the
+// messages channel.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(c *Client) {
+		c.keepAlive = interval
+	}
+}
+
+// WithKeepAliveTimeout sets how long to wait for the Ping response of
+// WithKeepAlive before the connection is considered dead. By default it is
+// the network timeout. Zero or negative timeout is ignored.
+func WithKeepAliveTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.keepAliveTimeout = timeout
+		}
+	}
+}
+
+// WithStaleTimeout enables watchdog of inbound traffic. When nothing is
+// received from the server within timeout the connection is considered dead
+// and it is closed with ErrStaleConnection error. With WithReconnect client
+// connects again. Watchdog sends nothing itself, so timeout must be longer
+// than pauses between events of a quiet server, or the server must be pinged
+// with WithKeepAlive. Zero or negative timeout disables the watchdog.
+func WithStaleTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.staleTimeout = max(timeout, 0)
+	}
+}
+
+// WithReplayBuffer keeps up to size most recent events, not older than age,
+// for subscribers created with SubscribeReplay or OnEventsReplay. Zero age
+// keeps events until they are pushed out by newer events. Zero or negative
+// size disables the buffer.
+func WithReplayBuffer(size int, age time.Duration) Option {
+	return func(c *Client) {
+		if size <= 0 {
+			c.replay = nil
+			return
+		}
+		c.replay = &replayBuffer{size: size, age: age}
+	}
+}
+
+// WithLogger sets logger for the client. Client logs outbound actions,
+// inbound messages and dropped messages on debug level, login and reconnect
+// attempts and packets that failed to parse.
+// Values of secret headers, like login password, are masked.
+// By default client does not log anything. Nil logger is ignored.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithCapture records all raw data read from and written to AMI connections,
+// including reconnects, as JSON CaptureRecord per line to w. Secret headers
+// of actions are masked. Capture can be fed back through the parser with
+// Replay to reproduce issues.
+func WithCapture(w io.Writer) Option {
+	return func(c *Client) {
+		if w != nil {
+			c.capture = newCapture(w)
+		}
+	}
+}
+
+// WithLimits sets limits of the line length, message size and number of
+// headers of the packets received from AMI server. Packet exceeding a limit
+// is discarded and LimitError is sent to the Err channel. Zero limits use
+// DefaultLimits.
+func WithLimits(limits Limits) Option {
+	return func(c *Client) {
+		c.limits = limits
+	}
+}
+
+// WithGluedPackets makes client split packets glued together without empty
+// line, which some proxies and Asterisk versions send under load. New packet
+// starts with "Event" or "Response" header, except "Response" header of the
+// OriginateResponse event. Packets that repeat these headers, like UserEvent
+// with custom "Event" header, are split too, so by default packets are split
+// only by empty line.
+func WithGluedPackets() Option {
+	return func(c *Client) {
+		c.splitGlued = true
+	}
+}
+
+// WithTracer sets tracer of the actions that wait for the response.
+// Nil tracer disables tracing.
+func WithTracer(t Tracer) Option {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// WithMetrics sets metrics hooks of the client. When metrics also implements
+// ExtendedMetrics it receives parse errors, dropped messages and messages
+// channel depth. Nil metrics is ignored.
+func WithMetrics(m Metrics) Option {
+	return func(c *Client) {
+		if m != nil {
+			c.metrics = m
+		}
+	}
+}
+
+// WithBufferSize sets messages channel buffer size
+func WithBufferSize(n int) Option {
+	return func(c *Client) {
+		if n < 0 {
+			n = 0
+		}
+		c.recv = make(chan *Message, n)
+	}
+}
+
+// WithHandlerBuffer sets buffer size of the events queued for every event
+// handler, see Client.OnEvent. Default is 1024 events.
+func WithHandlerBuffer(n int) Option {
+	return func(c *Client) {
+		if n < 0 {
+			n = 0
+		}
+		c.handlerBuf = n
+	}
+}
+
+// WithErrorBuffer sets errors channel buffer size. Errors are never delivered
+// with blocking: when buffer is full the oldest error is dropped, see
+// Client.DroppedErrors.
+func WithErrorBuffer(n int) Option {
+	return func(c *Client) {
+		if n < 0 {
+			n = 0
+		}
+		c.err = make(chan error, n)
+	}
+}
+
+// WithPauseBuffer sets number of messages buffered while client is paused.
+// Buffered messages are delivered on Resume. When buffer is full the oldest
+// message is dropped. By default messages received while paused are dropped.
+func WithPauseBuffer(size int) Option {
+	return func(c *Client) {
+		c.pauseBuffer = size
+	}
+}
+
+// WithOverflowPolicy sets the policy for the incoming messages when
+// messages channel buffer is full. Default is OverflowBlock.
+// Number of dropped messages is returned by Client.DroppedMessages.
+// OverflowDropOldest works as OverflowDropNewest with zero buffer size.
+// Policy of subscription channels is set with Client.SubscribeWithPolicy.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Client) {
+		c.overflow = policy
+	}
+}
+
+// WithRateLimit limits outbound actions rate with token bucket of actionsPerSecond
+// rate and burst size. When limit is reached MustSend, Send and Action wait for
+// the token up to the network timeout and SendAction waits until context is done.
+// Keepalive pings and logoff of Shutdown are not limited, so a long queue of
+// actions does not fail the keepalive. Zero rate means no limit.
+func WithRateLimit(actionsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		if actionsPerSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newLimiter(actionsPerSecond, burst)
+	}
+}
+
+// WithPermissionDiscovery loads actions permitted to the manager user with
+// "Action: ListCommands" after login and after reconnect, see Client.Can.
+// Client construction fails when the list can not be loaded.
+func WithPermissionDiscovery() Option {
+	return func(c *Client) {
+		c.discover = true
+	}
+}
+
+// WithOutboundQueue queues actions sent without waiting for the response,
+// with Action, Send, MustSend or SendContext, while client is reconnecting and
+// sends them in order after reconnect and login. Queue keeps up to size
+// actions. When queue is full OverflowDropOldest drops the oldest action and
+// other policies reject the action with ErrQueueFull. Actions saved to the
+// store, when it is not nil, are sent after the first login, so queued
+// actions survive restart of the process. SendAction and other actions waiting
+// for the response are not queued.
+func WithOutboundQueue(size int, policy OverflowPolicy, store QueueStore) Option {
+	return func(c *Client) {
+		if size <= 0 {
+			c.outq = nil
+			return
+		}
+		c.outq = &outboundQueue{size: size, policy: policy, store: store}
+	}
+}
+
+// WithSchemaValidation validates inbound events against the schema and
+// reports events with missing headers to the Client.Diagnostics channel, so
+// headers renamed by Asterisk upgrade are noticed. Bundled schema of the
+// server AMI version is used when schema is nil, see DefaultSchema. When
+// unknown is true events missing in the schema are reported too. Events are
+// delivered as usual.
+func WithSchemaValidation(schema Schema, unknown bool) Option {
+	return func(c *Client) {
+		c.diag = make(chan Diagnostic, chanBuffer)
+		c.schema = nil
+		if schema != nil {
+			c.schema = schema.normalize()
+		}
+		c.schemaUnknown = unknown
+	}
+}
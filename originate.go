@@ -0,0 +1,169 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OriginateParams are parameters of "Action: Originate". Call is originated
+// either to the dialplan with Context, Exten and Priority or to the application
+// with Application and Data.
+type OriginateParams struct {
+	Channel     string
+	Context     string
+	Exten       string
+	Priority    int // defaults to 1 for dialplan target
+	Application string
+	Data        string
+	CallerID    string
+	Account     string
+	Timeout     time.Duration // time to wait for the channel to answer
+	Variable    map[string]string
+}
+
+// Originate validates parameters and sends "Action: Originate" with "Async: true",
+// so the response returns as soon as the call is queued. Result of the call is
+// reported with "Event: OriginateResponse" that has the same ActionID as the
+// returned response. Returns error without sending the action when channel or
+// target is missing or both dialplan and application targets are set.
+func (c *Client) Originate(ctx context.Context, params OriginateParams) (*Message, error) {
+	action, err := params.action()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, "originate failed")
+	}
+	return resp, nil
+}
+
+// OriginateResult is the result of the originated call reported by
+// "Event: OriginateResponse"
+type OriginateResult struct {
+	Success    bool
+	Reason     int    // AST_CONTROL reason code, 4 when call is answered
+	ReasonText string // reason code description, for example "Busy"
+	Channel    string
+	Uniqueid   string
+	Event      *Message // "Event: OriginateResponse" message
+}
+
+// OriginateAndWait originates the call same as Originate and waits for
+// "Event: OriginateResponse" with the action ActionID until context is done.
+// Failed call is not an error, it is reported with OriginateResult.Success
+// and Reason. The event is not sent to the AllMessages channel or subscribers.
+func (c *Client) OriginateAndWait(ctx context.Context, params OriginateParams) (*OriginateResult, error) {
+	action, err := params.action()
+	if err != nil {
+		return nil, err
+	}
+	list := &eventList{done: make(chan struct{}), last: isOriginateResponse}
+	defer c.dropList(list)
+	resp, err := c.roundTrip(ctx, action, list)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, "originate failed")
+	}
+	if err := c.waitList(ctx, list); err != nil {
+		return nil, err
+	}
+
+	ev := list.events[len(list.events)-1]
+	reason, _ := strconv.Atoi(ev.Field("Reason"))
+	return &OriginateResult{
+		Success:    ev.IsSuccess(),
+		Reason:     reason,
+		ReasonText: originateReason(reason),
+		Channel:    ev.Field("Channel"),
+		Uniqueid:   ev.Field("Uniqueid"),
+		Event:      ev,
+	}, nil
+}
+
+func isOriginateResponse(msg *Message) bool {
+	return strings.EqualFold(msg.Field("Event"), "OriginateResponse")
+}
+
+// originateReason returns description of OriginateResponse reason code
+func originateReason(code int) string {
+	switch code {
+	case 0:
+		return "Failure"
+	case 1:
+		return "Hangup"
+	case 3:
+		return "No answer"
+	case 4:
+		return "Answered"
+	case 5:
+		return "Busy"
+	case 8:
+		return "Congestion"
+	}
+	return "Unknown"
+}
+
+// action validates parameters and creates originate action
+func (p OriginateParams) action() (*Message, error) {
+	if p.Channel == "" {
+		return nil, fmt.Errorf("%w: originate: missing channel", ErrAMI)
+	}
+	dialplan := p.Exten != "" || p.Context != ""
+	app := p.Application != ""
+	if dialplan && app {
+		return nil, fmt.Errorf("%w: originate: exten and application are mutually exclusive", ErrAMI)
+	}
+	if !dialplan && !app {
+		return nil, fmt.Errorf("%w: originate: missing exten or application", ErrAMI)
+	}
+
+	action := NewAction("Originate")
+	action.AddField("Channel", p.Channel)
+	if dialplan {
+		if p.Exten == "" || p.Context == "" {
+			return nil, fmt.Errorf("%w: originate: dialplan requires context and exten", ErrAMI)
+		}
+		priority := p.Priority
+		if priority == 0 {
+			priority = 1
+		}
+		action.AddField("Context", p.Context)
+		action.AddField("Exten", p.Exten)
+		action.AddField("Priority", strconv.Itoa(priority))
+	} else {
+		action.AddField("Application", p.Application)
+		if p.Data != "" {
+			action.AddField("Data", p.Data)
+		}
+	}
+	if p.CallerID != "" {
+		action.AddField("CallerID", p.CallerID)
+	}
+	if p.Account != "" {
+		action.AddField("Account", p.Account)
+	}
+	if p.Timeout > 0 {
+		action.AddField("Timeout", strconv.FormatInt(p.Timeout.Milliseconds(), 10))
+	}
+	action.AddField("Async", "true")
+
+	keys := make([]string, 0, len(p.Variable))
+	for k := range p.Variable {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		action.AddField("Variable", k+"="+p.Variable[k])
+	}
+	return action, nil
+}
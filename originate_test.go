@@ -0,0 +1,165 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginateParamsAction(t *testing.T) {
+	t.Run("dialplan target", func(t *testing.T) {
+		action, err := OriginateParams{
+			Channel:  "PJSIP/100",
+			Context:  "default",
+			Exten:    "200",
+			CallerID: "Alice <100>",
+			Timeout:  30 * time.Second,
+			Variable: map[string]string{"FOO": "bar", "ACCOUNT": "123"},
+		}.action()
+		assert.Nil(t, err)
+		want := "Action: Originate\r\n" +
+			"Channel: PJSIP/100\r\n" +
+			"Context: default\r\n" +
+			"Exten: 200\r\n" +
+			"Priority: 1\r\n" +
+			"CallerID: Alice <100>\r\n" +
+			"Timeout: 30000\r\n" +
+			"Async: true\r\n" +
+			"Variable: ACCOUNT=123\r\n" +
+			"Variable: FOO=bar\r\n\r\n"
+		assert.Equal(t, want, action.String())
+	})
+
+	t.Run("application target", func(t *testing.T) {
+		action, err := OriginateParams{Channel: "PJSIP/100", Application: "Playback", Data: "hello-world"}.action()
+		assert.Nil(t, err)
+		assert.Equal(t, "Action: Originate\r\nChannel: PJSIP/100\r\nApplication: Playback\r\n"+
+			"Data: hello-world\r\nAsync: true\r\n\r\n", action.String())
+	})
+
+	tests := map[string]OriginateParams{
+		`missing channel`: {Context: "default", Exten: "200"},
+		`missing target`:  {Channel: "PJSIP/100"},
+		`both targets`:    {Channel: "PJSIP/100", Context: "default", Exten: "200", Application: "Playback"},
+		`missing context`: {Channel: "PJSIP/100", Exten: "200"},
+	}
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			action, err := params.action()
+			assert.ErrorIs(t, err, ErrAMI)
+			assert.Nil(t, action)
+		})
+	}
+}
+
+func TestClientOriginate(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for _, resp := range []string{"Success\r\nMessage: Originate successfully queued", "Error\r\nMessage: Extension does not exist."} {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: " + resp + "\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	params := OriginateParams{Channel: "PJSIP/100", Context: "default", Exten: "200"}
+	resp, err := cl.Originate(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Equal(t, "Originate successfully queued", resp.Field("Message"))
+
+	_, err = cl.Originate(context.Background(), params)
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorContains(t, err, "Extension does not exist.")
+
+	_, err = cl.Originate(context.Background(), OriginateParams{Channel: "PJSIP/100"})
+	assert.ErrorContains(t, err, "missing exten or application")
+}
+
+func TestClientOriginateAndWait(t *testing.T) {
+	setup := func(event func(id string) string) *Client {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		go func() {
+			msg, err := srvReadAction(bufio.NewReader(connSrv))
+			if err != nil {
+				return
+			}
+			id := msg.ActionID()
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + id +
+				"\r\nMessage: Originate successfully queued\r\n\r\n"))
+			_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = connSrv.Write([]byte(event(id)))
+		}()
+		return cl
+	}
+	params := OriginateParams{Channel: "PJSIP/100", Context: "default", Exten: "200"}
+
+	t.Run("call answered", func(t *testing.T) {
+		cl := setup(func(id string) string {
+			return "Event: OriginateResponse\r\nActionID: " + id + "\r\nResponse: Success\r\n" +
+				"Channel: PJSIP/100-01\r\nReason: 4\r\nUniqueid: 1598887681.60\r\n\r\n"
+		})
+		defer cl.Close()
+
+		res, err := cl.OriginateAndWait(context.Background(), params)
+		assert.Nil(t, err)
+		assert.True(t, res.Success)
+		assert.Equal(t, 4, res.Reason)
+		assert.Equal(t, "Answered", res.ReasonText)
+		assert.Equal(t, "PJSIP/100-01", res.Channel)
+		assert.Equal(t, "1598887681.60", res.Uniqueid)
+		assert.Equal(t, "OriginateResponse", res.Event.Field("Event"))
+		assert.Equal(t, "Newchannel", (<-cl.AllMessages()).Field("Event"))
+	})
+
+	t.Run("call failed", func(t *testing.T) {
+		cl := setup(func(id string) string {
+			return "Event: OriginateResponse\r\nActionID: " + id + "\r\nResponse: Failure\r\n" +
+				"Channel: PJSIP/100\r\nReason: 5\r\nUniqueid: <null>\r\n\r\n"
+		})
+		defer cl.Close()
+
+		res, err := cl.OriginateAndWait(context.Background(), params)
+		assert.Nil(t, err)
+		assert.False(t, res.Success)
+		assert.Equal(t, "Busy", res.ReasonText)
+	})
+
+	t.Run("abort on context deadline", func(t *testing.T) {
+		cl := setup(func(string) string { return "Event: FullyBooted\r\n\r\n" })
+		defer cl.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := cl.OriginateAndWait(ctx, params)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		cl.mu.Lock()
+		assert.Empty(t, cl.lists)
+		cl.mu.Unlock()
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		cl := makeClient(nil)
+		_, err := cl.OriginateAndWait(context.Background(), OriginateParams{})
+		assert.ErrorIs(t, err, ErrAMI)
+	})
+}
+
+func TestOriginateReason(t *testing.T) {
+	assert.Equal(t, "Failure", originateReason(0))
+	assert.Equal(t, "No answer", originateReason(3))
+	assert.Equal(t, "Congestion", originateReason(8))
+	assert.Equal(t, "Unknown", originateReason(42))
+}
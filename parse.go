@@ -5,10 +5,9 @@
 
 package goami2
 
-import "fmt"
-
-// Parse AMI message as string into *Message structure
-func Parse(data string) (*Message, error) {
+// parseCanonical parses AMI message in canonical form, with "\r\n" line
+// terminators and without folded lines, into *Message structure
+func parseCanonical(data string) (*Message, error) {
 	msg := NewMessage()
 	var cur, mar int
 	var ns, ne, vs, ve int
@@ -19,8 +18,8 @@ func Parse(data string) (*Message, error) {
 	for { 
 {
 	var yych byte
-	if (len(data) <= cur) {
-		return nil, fmt.Errorf("%w: unexpected end of input", ErrAMI)
+	if (len(data) - cur < 2) {
+		return nil, syntaxError(data, len(data), "unexpected end of input")
 	}
 	yych = data[cur]
 	switch (yych) {
@@ -70,8 +69,8 @@ yy5:
 	{ return msg, nil }
 yy6:
 	cur += 1
-	if (len(data) <= cur) {
-		return nil, fmt.Errorf("%w: unexpected end of input", ErrAMI)
+	if (len(data) - cur < 1) {
+		return nil, syntaxError(data, len(data), "unexpected end of input")
 	}
 	yych = data[cur]
 	switch (yych) {
@@ -94,8 +93,8 @@ yy7:
 	goto yy2
 yy8:
 	cur += 1
-	if (len(data) <= cur) {
-		return nil, fmt.Errorf("%w: unexpected end of input", ErrAMI)
+	if (len(data) - cur < 2) {
+		return nil, syntaxError(data, len(data), "unexpected end of input")
 	}
 	yych = data[cur]
 	switch (yych) {
@@ -112,8 +111,8 @@ yy8:
 	}
 yy9:
 	cur += 1
-	if (len(data) <= cur) {
-		return nil, fmt.Errorf("%w: unexpected end of input", ErrAMI)
+	if (len(data) - cur < 2) {
+		return nil, syntaxError(data, len(data), "unexpected end of input")
 	}
 	yych = data[cur]
 	switch (yych) {
@@ -146,7 +145,7 @@ yy11:
 }
 
 	}
-	return nil, fmt.Errorf("%w: invalid input: %q", ErrAMI, data[cur:])
+	return nil, syntaxError(data, cur-1, "invalid input")
 }
 
 // vi: ft=go
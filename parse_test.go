@@ -103,6 +103,63 @@ func TestParseEventOK(t *testing.T) {
 	}
 }
 
+func TestParseErrorOffset(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		offset int
+		want   string
+	}{
+		`invalid header`: {
+			"Event: MoH\r\nPriv:all\r\nChan\r\n\r\n", 22, `invalid input at offset 22: "Chan\r\n\r\n"`,
+		},
+		`invalid first line`: {"foo bar", 0, `invalid input at offset 0: "foo bar"`},
+		`incomplete packet`:  {"Event: Hangup\r\n", 15, "unexpected end of input"},
+		`ends after CR`:      {"Event: Hangup\r\n\r", 16, "unexpected end of input"},
+		`header ends at CR`:  {"Event: Hangup\r", 14, "unexpected end of input"},
+		`not canonical`:      {"Event: Hangup\nbad\n\n", 15, `invalid input at offset 15`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			var perr *ParseError
+			assert.ErrorAs(t, err, &perr)
+			assert.ErrorIs(t, err, ErrAMI)
+			assert.ErrorContains(t, err, tc.want)
+			assert.Equal(t, tc.input, string(perr.Raw))
+			assert.Equal(t, tc.offset, perr.Offset)
+		})
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	canonical := "Event: Newexten\r\nChannel: PJSIP/100-01\r\nAppData: Hello world\r\n\r\n"
+	tests := map[string]string{
+		`line feed only`:       "Event: Newexten\nChannel: PJSIP/100-01\nAppData: Hello world\n\n",
+		`mixed line endings`:   "Event: Newexten\r\nChannel: PJSIP/100-01\nAppData: Hello world\r\n\n",
+		`trailing whitespace`:  "Event: Newexten \r\nChannel: PJSIP/100-01\t\r\nAppData: Hello world  \r\n\r\n",
+		`folded line`:          "Event: Newexten\r\nChannel: PJSIP/100-01\r\nAppData: Hello\r\n   world\r\n\r\n",
+		`folded with tab`:      "Event: Newexten\nChannel: PJSIP/100-01\nAppData: Hello\n\tworld \n\n",
+		`leading whitespace`:   "  Event: Newexten\r\nChannel: PJSIP/100-01\r\nAppData: Hello world\r\n\r\n",
+		`whitespace only last`: "Event: Newexten\r\nChannel: PJSIP/100-01\r\nAppData: Hello world\r\n  \r\n",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, err := Parse(input)
+			assert.Nil(t, err)
+			assert.Equal(t, canonical, msg.String())
+		})
+	}
+
+	msg, err := Parse("Event: VarSet\r\nValue: \r\n\r\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "", msg.Field("Value"))
+
+	_, err = Parse("Event: Newexten\nChannel: PJSIP/100-01\n")
+	assert.ErrorIs(t, err, ErrAMI)
+}
+
 func TestParseEventFail(t *testing.T) {
 	tests := map[string]struct {
 		input string
@@ -152,6 +209,14 @@ func BenchmarkParse(b *testing.B) {
 	}
 }
 
+func BenchmarkParseRelease(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		msg, _ := Parse(rawPack)
+		msg.Release()
+	}
+}
+
 func BenchmarkMessageToString(b *testing.B) {
 	msg, _ := Parse(rawPack)
 	b.ResetTimer()
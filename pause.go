@@ -0,0 +1,51 @@
+package goami2
+
+// Pause stops delivering messages to the messages channel and subscribers.
+// Client keeps reading the connection, so Asterisk is not blocked, and
+// responses to SendAction and client helpers are still delivered.
+// Messages received while paused are discarded, or buffered for replay on
+// Resume when buffer size is set with WithPauseBuffer option.
+func (c *Client) Pause() {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	c.paused = true
+}
+
+// Resume continues delivering messages. Messages buffered while paused are
+// delivered first, in order they were received.
+func (c *Client) Resume() {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	c.paused = false
+	held := c.held
+	c.held = nil
+	for _, msg := range held {
+		c.emitMsg(msg)
+	}
+}
+
+// Paused returns true if client is paused
+func (c *Client) Paused() bool {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	return c.paused
+}
+
+// forward delivers message from reading loop or holds it while paused
+func (c *Client) forward(msg *Message) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	if !c.paused {
+		c.emitMsg(msg)
+		return
+	}
+	if c.pauseBuffer <= 0 {
+		c.drop(msg, "client is paused")
+		return
+	}
+	if len(c.held) == c.pauseBuffer {
+		c.drop(c.held[0], "pause buffer is full")
+		c.held = c.held[1:]
+	}
+	c.held = append(c.held, msg)
+}
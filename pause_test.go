@@ -0,0 +1,69 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPause(t *testing.T) {
+	setup := func(opts ...Option) (*bufio.Reader, net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		for _, opt := range opts {
+			opt(cl)
+		}
+		go cl.loop(context.Background())
+		return bufio.NewReader(connSrv), connSrv, cl
+	}
+	event := func(n string) []byte {
+		return []byte("Event: Newchannel\r\nChannel: PJSIP/" + n + "\r\n\r\n")
+	}
+
+	t.Run("discard while paused and actions work", func(t *testing.T) {
+		r, srv, cl := setup()
+		defer cl.Close()
+
+		cl.Pause()
+		assert.True(t, cl.Paused())
+		go func() {
+			_, _ = srv.Write(event("1"))
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = srv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}()
+		resp, err := cl.SendAction(context.Background(), NewAction("Reload"))
+		assert.Nil(t, err)
+		assert.True(t, resp.IsSuccess())
+		assert.Equal(t, uint64(1), cl.DroppedMessages())
+
+		cl.Resume()
+		assert.False(t, cl.Paused())
+		go func() { _, _ = srv.Write(event("2")) }()
+		assert.Equal(t, "PJSIP/2", (<-cl.AllMessages()).Field("Channel"))
+	})
+
+	t.Run("buffer and replay in order", func(t *testing.T) {
+		_, srv, cl := setup(WithPauseBuffer(2))
+		defer cl.Close()
+
+		cl.Pause()
+		for _, n := range []string{"1", "2", "3"} {
+			_, _ = srv.Write(event(n))
+		}
+		assert.Eventually(t, func() bool { return cl.DroppedMessages() == 1 }, time.Second, time.Millisecond)
+		assert.Empty(t, cl.AllMessages())
+
+		cl.Resume()
+		go func() { _, _ = srv.Write(event("4")) }()
+		for _, want := range []string{"PJSIP/2", "PJSIP/3", "PJSIP/4"} {
+			assert.Equal(t, want, (<-cl.AllMessages()).Field("Channel"))
+		}
+	})
+}
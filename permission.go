@@ -0,0 +1,112 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// writePrivileges are manager.conf write permissions required by common
+// actions, used to explain rejected actions
+var writePrivileges = map[string]string{
+	"originate":      "originate",
+	"command":        "command",
+	"redirect":       "call",
+	"hangup":         "system",
+	"setvar":         "call",
+	"getvar":         "call",
+	"atxfer":         "call",
+	"blindtransfer":  "call",
+	"park":           "call",
+	"mixmonitor":     "system",
+	"stopmixmonitor": "system",
+	"playdtmf":       "call",
+	"queueadd":       "agent",
+	"queueremove":    "agent",
+	"queuepause":     "agent",
+	"dbget":          "system",
+	"dbput":          "system",
+	"dbdel":          "system",
+	"dbdeltree":      "system",
+	"moduleload":     "system",
+	"reload":         "system",
+	"filter":         "system",
+	"userevent":      "user",
+	"updateconfig":   "config",
+	"getconfig":      "config",
+}
+
+// LoadPermissions loads actions permitted to the manager user with
+// "Action: ListCommands". After permissions are loaded actions that are not
+// permitted fail with ErrPermission before they are sent.
+// WithPermissionDiscovery loads permissions on connect.
+func (c *Client) LoadPermissions(ctx context.Context) error {
+	resp, err := c.request(ctx, NewAction("ListCommands"))
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "failed to list commands")
+	}
+
+	commands := make(map[string]string)
+	for _, h := range resp.Headers() {
+		if strings.EqualFold(h.Name, "Response") || strings.EqualFold(h.Name, "ActionID") {
+			continue
+		}
+		priv := ""
+		if _, after, ok := strings.Cut(h.Value, "(Priv:"); ok {
+			priv = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(after), ")"))
+		}
+		commands[strings.ToLower(h.Name)] = priv
+	}
+
+	c.mu.Lock()
+	c.commands = commands
+	c.mu.Unlock()
+	return nil
+}
+
+// Can reports if the manager user is permitted to send the action. Returns
+// true when permissions are not loaded, see LoadPermissions.
+func (c *Client) Can(action string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.commands == nil {
+		return true
+	}
+	_, ok := c.commands[strings.ToLower(action)]
+	return ok
+}
+
+// discoverPermissions loads permissions on connect when enabled
+func (c *Client) discoverPermissions(ctx context.Context) error {
+	if !c.discover {
+		return nil
+	}
+	if err := c.LoadPermissions(ctx); err != nil {
+		return fmt.Errorf("failed to discover permissions: %w", err)
+	}
+	return nil
+}
+
+// reloadPermissions loads permissions of the new session after reconnect
+func (c *Client) reloadPermissions(ctx context.Context) {
+	if err := c.LoadPermissions(ctx); err != nil {
+		c.logger.Warn("failed to reload permissions", "error", err)
+	}
+}
+
+// permitted wraps send function to reject actions that are not permitted
+func (c *Client) permitted(send SendFunc) SendFunc {
+	return func(action *Message) error {
+		name := action.Field("Action")
+		if c.Can(name) {
+			return send(action)
+		}
+		if priv, ok := writePrivileges[strings.ToLower(name)]; ok {
+			return fmt.Errorf("%w: manager user lacks %q write permission for %q", ErrPermission, priv, name)
+		}
+		return fmt.Errorf("%w: manager user is not permitted to send %q", ErrPermission, name)
+	}
+}
@@ -0,0 +1,76 @@
+package goami2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func listCommands(*goami2.Message) []*goami2.Message {
+	return []*goami2.Message{goami2test.Success(
+		"Ping", "Keepalive command.  (Priv: <none>)",
+		"Getvar", "Gets a channel variable or function value.  (Priv: call,reporting,all)",
+		"Logoff", "Logoff Manager.  (Priv: <none>)",
+	)}
+}
+
+func TestClientPermissions(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("ListCommands", listCommands)
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+	ctx := context.Background()
+
+	// permitted before permissions are loaded
+	assert.True(t, cl.Can("Originate"))
+	_, err = cl.SendAction(ctx, goami2.NewAction("Originate"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, cl.LoadPermissions(ctx))
+	assert.True(t, cl.Can("Ping"))
+	assert.True(t, cl.Can("getvar"))
+	assert.False(t, cl.Can("Originate"))
+
+	_, err = cl.SendAction(ctx, goami2.NewAction("Originate"))
+	assert.ErrorIs(t, err, goami2.ErrPermission)
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+	assert.EqualError(t, err, `goami2: AMI proto: permission denied: manager user lacks "originate" write permission for "Originate"`)
+
+	_, err = cl.SendAction(ctx, goami2.NewAction("VoicemailRefresh"))
+	assert.ErrorContains(t, err, `manager user is not permitted to send "VoicemailRefresh"`)
+	assert.False(t, cl.Action(goami2.NewAction("Originate")))
+
+	_, err = cl.SendAction(ctx, goami2.NewAction("Ping"))
+	assert.Nil(t, err)
+}
+
+func TestWithPermissionDiscovery(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("ListCommands", listCommands)
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd", goami2.WithPermissionDiscovery())
+	assert.Nil(t, err)
+	defer cl.Close()
+	assert.False(t, cl.Can("Originate"))
+	assert.True(t, cl.Can("Getvar"))
+
+	t.Run("fails when commands are not listed", func(t *testing.T) {
+		ami := goami2test.NewServer()
+		defer ami.Close()
+		ami.Handle("ListCommands", func(*goami2.Message) []*goami2.Message {
+			return []*goami2.Message{goami2test.Error("Permission denied")}
+		})
+		_, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd", goami2.WithPermissionDiscovery())
+		var perr *goami2.ProtocolError
+		assert.True(t, errors.As(err, &perr))
+		assert.ErrorContains(t, err, "failed to discover permissions")
+	})
+}
@@ -0,0 +1,160 @@
+package pjsip
+
+import (
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// Handle updates inventory with EndpointList, EndpointDetail, AorDetail,
+// ContactStatusDetail or ContactStatus event. Other events are ignored.
+// Contact handlers are called when ContactStatus event changes the contact.
+func (inv *Inventory) Handle(msg *goami2.Message) {
+	inv.mu.Lock()
+	contact, changed := inv.update(msg)
+	handlers := inv.onContact
+	inv.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, fn := range handlers {
+		fn(contact)
+	}
+}
+
+// update applies event to the inventory. Returns contact and true when
+// ContactStatus event changes the contact.
+func (inv *Inventory) update(msg *goami2.Message) (Contact, bool) {
+	switch strings.ToLower(msg.Field("Event")) {
+	case "endpointlist":
+		ep := inv.endpointOf(msg.Field("ObjectName"))
+		if ep == nil {
+			break
+		}
+		ep.Transport = msg.Field("Transport")
+		ep.Aors = split(msg.Field("Aor"))
+		ep.Auths = split(msg.Field("Auths"))
+		ep.OutboundAuths = split(msg.Field("OutboundAuths"))
+		ep.DeviceState = msg.Field("DeviceState")
+		ep.ActiveChannels, _ = msg.FieldInt("ActiveChannels")
+		// contacts are listed as "aor/uri"
+		for _, c := range split(msg.Field("Contacts")) {
+			if aor, uri, ok := strings.Cut(c, "/"); ok {
+				inv.contactOf(aor, uri).Endpoint = ep.Name
+			}
+		}
+	case "endpointdetail":
+		ep := inv.endpointOf(msg.Field("ObjectName"))
+		if ep == nil {
+			break
+		}
+		ep.Context = msg.Field("Context")
+		if aors := split(msg.Field("Aors")); len(aors) > 0 {
+			ep.Aors = aors
+		}
+		if ds := msg.Field("DeviceState"); ds != "" {
+			ep.DeviceState = ds
+		}
+	case "aordetail":
+		name := msg.Field("ObjectName")
+		if name == "" {
+			break
+		}
+		aor := &Aor{Name: name, Endpoint: msg.Field("EndpointName")}
+		aor.MaxContacts, _ = msg.FieldInt("MaxContacts")
+		aor.QualifyFrequency, _ = msg.FieldInt("QualifyFrequency")
+		inv.aors[strings.ToLower(name)] = aor
+	case "contactstatusdetail":
+		aor, uri := msg.Field("AOR"), msg.Field("URI")
+		if aor == "" || uri == "" {
+			break
+		}
+		c := inv.contactOf(aor, uri)
+		setContact(c, msg, msg.Field("Status"))
+	case "contactstatus":
+		aor, uri := msg.Field("AOR"), msg.Field("URI")
+		if aor == "" || uri == "" {
+			break
+		}
+		status := msg.Field("ContactStatus")
+		key := aor + "/" + uri
+		if strings.EqualFold(status, "Removed") {
+			c, ok := inv.contacts[key]
+			if !ok {
+				return Contact{}, false
+			}
+			delete(inv.contacts, key)
+			removed := *c
+			removed.Status = status
+			removed.Updated = inv.now()
+			return removed, true
+		}
+		c := inv.contactOf(aor, uri)
+		prev := *c
+		setContact(c, msg, status)
+		if c.Status == prev.Status && c.RTT == prev.RTT && c.UserAgent == prev.UserAgent {
+			return Contact{}, false
+		}
+		c.Updated = inv.now()
+		return *c, true
+	}
+	return Contact{}, false
+}
+
+func (inv *Inventory) endpointOf(name string) *Endpoint {
+	if name == "" {
+		return nil
+	}
+	key := strings.ToLower(name)
+	ep, ok := inv.endpoints[key]
+	if !ok {
+		ep = &Endpoint{Name: name}
+		inv.endpoints[key] = ep
+	}
+	return ep
+}
+
+func (inv *Inventory) contactOf(aor, uri string) *Contact {
+	key := aor + "/" + uri
+	c, ok := inv.contacts[key]
+	if !ok {
+		c = &Contact{URI: uri, Aor: aor}
+		inv.contacts[key] = c
+	}
+	return c
+}
+
+// setContact updates contact with status and fields of the event.
+// "Created" and "Updated" statuses do not replace the qualify status.
+func setContact(c *Contact, msg *goami2.Message, status string) {
+	if ep := msg.Field("EndpointName"); ep != "" {
+		c.Endpoint = ep
+	}
+	if ua := msg.Field("UserAgent"); ua != "" {
+		c.UserAgent = ua
+	}
+	if usec, ok := msg.FieldInt("RoundtripUsec"); ok {
+		c.RTT = time.Duration(usec) * time.Microsecond
+	}
+	switch strings.ToLower(status) {
+	case "", "created", "updated":
+		if c.Status == "" {
+			c.Status = status
+		}
+	default:
+		c.Status = status
+	}
+}
+
+// split comma separated list
+func split(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,90 @@
+package pjsip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+func TestInventoryHandle(t *testing.T) {
+	inv := New()
+	now := time.Date(2020, 8, 31, 12, 0, 0, 0, time.UTC)
+	inv.now = func() time.Time { return now }
+	var changes []Contact
+	inv.OnContact(func(c Contact) { changes = append(changes, c) })
+
+	inv.Handle(event(t, "Event: EndpointList\r\nObjectType: endpoint\r\nObjectName: 100\r\n"+
+		"Transport: udp\r\nAor: 100\r\nAuths: 100-auth\r\nOutboundAuths: \r\n"+
+		"Contacts: 100/sip:100@10.0.0.2:5060,\r\nDeviceState: Not in use\r\nActiveChannels: 0\r\n"))
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.2:5060\r\nContactStatus: Reachable\r\n"+
+		"AOR: 100\r\nEndpointName: 100\r\nRoundtripUsec: 1500\r\nUserAgent: Yealink\r\n"))
+	// same status is not reported
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.2:5060\r\nContactStatus: Reachable\r\n"+
+		"AOR: 100\r\nEndpointName: 100\r\nRoundtripUsec: 1500\r\n"))
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.3:5060\r\nContactStatus: Created\r\n"+
+		"AOR: 100\r\nEndpointName: 100\r\n"))
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.3:5060\r\nContactStatus: Unreachable\r\n"+
+		"AOR: 100\r\nEndpointName: 100\r\n"))
+
+	ep, ok := inv.Endpoint("100")
+	assert.True(t, ok)
+	assert.Equal(t, "udp", ep.Transport)
+	assert.Equal(t, []string{"100"}, ep.Aors)
+	assert.Equal(t, []string{"100-auth"}, ep.Auths)
+	assert.Empty(t, ep.OutboundAuths)
+	assert.Len(t, ep.Contacts, 2)
+	assert.True(t, ep.Contacts[0].Reachable())
+	assert.Equal(t, 1500*time.Microsecond, ep.Contacts[0].RTT)
+	assert.Equal(t, "Yealink", ep.Contacts[0].UserAgent)
+	assert.Equal(t, now, ep.Contacts[0].Updated)
+	assert.False(t, ep.Contacts[1].Reachable())
+
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.3:5060\r\nContactStatus: Removed\r\n"+
+		"AOR: 100\r\nEndpointName: 100\r\n"))
+	inv.Handle(event(t, "Event: ContactStatus\r\nURI: sip:100@10.0.0.9:5060\r\nContactStatus: Removed\r\n"+
+		"AOR: 100\r\n"))
+	ep, _ = inv.Endpoint("100")
+	assert.Len(t, ep.Contacts, 1)
+
+	statuses := make([]string, 0, len(changes))
+	for _, c := range changes {
+		statuses = append(statuses, c.Status)
+	}
+	assert.Equal(t, []string{"Reachable", "Created", "Unreachable", "Removed"}, statuses)
+	assert.Equal(t, "sip:100@10.0.0.3:5060", changes[3].URI)
+}
+
+func TestInventoryHandleDetails(t *testing.T) {
+	inv := New()
+	inv.Handle(event(t, "Event: EndpointDetail\r\nObjectType: endpoint\r\nObjectName: 200\r\n"+
+		"Context: from-internal\r\nAors: 200\r\nDeviceState: In use\r\n"))
+	inv.Handle(event(t, "Event: AorDetail\r\nObjectType: aor\r\nObjectName: 200\r\n"+
+		"MaxContacts: 2\r\nQualifyFrequency: 60\r\nEndpointName: 200\r\n"))
+	inv.Handle(event(t, "Event: ContactStatusDetail\r\nAOR: 200\r\nURI: sip:200@10.0.0.4:5060\r\n"+
+		"UserAgent: Zoiper\r\nStatus: Avail\r\nRoundtripUsec: 900\r\nEndpointName: 200\r\n"))
+	inv.Handle(event(t, "Event: Newchannel\r\nChannel: PJSIP/200-01\r\n"))
+
+	ep, ok := inv.Endpoint("200")
+	assert.True(t, ok)
+	assert.Equal(t, "from-internal", ep.Context)
+	assert.Equal(t, "In use", ep.DeviceState)
+
+	aor, ok := inv.Aor("200")
+	assert.True(t, ok)
+	assert.Equal(t, 2, aor.MaxContacts)
+	assert.Equal(t, 60, aor.QualifyFrequency)
+	assert.Len(t, aor.Contacts, 1)
+	assert.True(t, aor.Contacts[0].Reachable())
+	assert.Equal(t, "Zoiper", aor.Contacts[0].UserAgent)
+
+	_, ok = inv.Aor("300")
+	assert.False(t, ok)
+}
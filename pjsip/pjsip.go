@@ -0,0 +1,205 @@
+// Package pjsip keeps inventory of PJSIP endpoints, AORs and contacts loaded
+// with PJSIPShowEndpoints and PJSIPShowEndpoint actions and keeps contact
+// status updated from ContactStatus events.
+package pjsip
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// events consumed by the inventory
+var inventoryEvents = []string{"ContactStatus", "Reconnected"}
+
+// Endpoint is the PJSIP endpoint
+type Endpoint struct {
+	Name           string
+	Transport      string
+	Context        string   // dialplan context, loaded with details
+	Aors           []string // names of endpoint AORs
+	Auths          []string
+	OutboundAuths  []string
+	DeviceState    string // like "Not in use", "In use" or "Unavailable"
+	ActiveChannels int
+	Contacts       []Contact // contacts of the endpoint AORs
+}
+
+// Aor is the PJSIP address of record, loaded with details
+type Aor struct {
+	Name             string
+	Endpoint         string
+	MaxContacts      int
+	QualifyFrequency int
+	Contacts         []Contact
+}
+
+// Contact is the contact registered or configured for the AOR
+type Contact struct {
+	URI       string
+	Aor       string
+	Endpoint  string
+	Status    string        // like "Reachable", "Unreachable", "Unknown" or "NonQualified"
+	RTT       time.Duration // qualify round trip time
+	UserAgent string
+	Updated   time.Time // time of the last status change
+}
+
+// Reachable reports if contact responded to the last qualify
+func (c Contact) Reachable() bool {
+	switch strings.ToLower(c.Status) {
+	case "reachable", "avail", "available":
+		return true
+	}
+	return false
+}
+
+// Inventory of PJSIP endpoints. Inventory is safe for concurrent use.
+type Inventory struct {
+	// Details enables loading of AORs and contacts status with
+	// PJSIPShowEndpoint action for every endpoint
+	Details bool
+
+	mu        sync.Mutex
+	endpoints map[string]*Endpoint // by lower case name
+	aors      map[string]*Aor      // by lower case name
+	contacts  map[string]*Contact  // by aor/uri
+	onContact []func(Contact)
+	now       func() time.Time
+}
+
+// New creates empty inventory
+func New() *Inventory {
+	return &Inventory{
+		endpoints: make(map[string]*Endpoint),
+		aors:      make(map[string]*Aor),
+		contacts:  make(map[string]*Contact),
+		now:       time.Now,
+	}
+}
+
+// Attach registers inventory as AMI client event handler. Inventory is
+// loaded again when client reconnects. Handler can be removed with
+// Client.RemoveHandler.
+func (inv *Inventory) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.OnEvents(inventoryEvents, func(msg *goami2.Message) {
+		if strings.EqualFold(msg.Field("Event"), "Reconnected") {
+			_ = inv.Load(context.Background(), cl)
+			return
+		}
+		inv.Handle(msg)
+	})
+}
+
+// OnContact registers handler called with the contact every time its status
+// changes. Contact removed from the AOR has status "Removed".
+func (inv *Inventory) OnContact(fn func(Contact)) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.onContact = append(inv.onContact, fn)
+}
+
+// Load replaces inventory with the result of PJSIPShowEndpoints action and,
+// when Details is set, PJSIPShowEndpoint action for every endpoint
+func (inv *Inventory) Load(ctx context.Context, cl *goami2.Client) error {
+	list, err := cl.SendActionList(ctx, goami2.NewAction("PJSIPShowEndpoints"))
+	if err != nil {
+		return err
+	}
+	if inv.Details {
+		var details []*goami2.Message
+		for _, msg := range list {
+			name := msg.Field("ObjectName")
+			if !isEvent(msg, "EndpointList") || name == "" {
+				continue
+			}
+			action := goami2.NewAction("PJSIPShowEndpoint")
+			action.AddField("Endpoint", name)
+			events, err := cl.SendActionList(ctx, action)
+			if err != nil {
+				return err
+			}
+			details = append(details, events...)
+		}
+		list = append(list, details...)
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.endpoints = make(map[string]*Endpoint)
+	inv.aors = make(map[string]*Aor)
+	inv.contacts = make(map[string]*Contact)
+	for _, msg := range list {
+		inv.update(msg)
+	}
+	return nil
+}
+
+// Endpoint returns endpoint by name, case insensitive
+func (inv *Inventory) Endpoint(name string) (Endpoint, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	ep, ok := inv.endpoints[strings.ToLower(name)]
+	if !ok {
+		return Endpoint{}, false
+	}
+	return inv.endpoint(ep), true
+}
+
+// Endpoints returns endpoints ordered by name
+func (inv *Inventory) Endpoints() []Endpoint {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	endpoints := make([]Endpoint, 0, len(inv.endpoints))
+	for _, ep := range inv.endpoints {
+		endpoints = append(endpoints, inv.endpoint(ep))
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Name < endpoints[j].Name })
+	return endpoints
+}
+
+// Aor returns AOR by name, case insensitive
+func (inv *Inventory) Aor(name string) (Aor, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	aor, ok := inv.aors[strings.ToLower(name)]
+	if !ok {
+		return Aor{}, false
+	}
+	cp := *aor
+	cp.Contacts = inv.aorContacts(aor.Name)
+	return cp, true
+}
+
+// endpoint copies endpoint with contacts of its AORs
+func (inv *Inventory) endpoint(ep *Endpoint) Endpoint {
+	cp := *ep
+	cp.Aors = append([]string(nil), ep.Aors...)
+	cp.Auths = append([]string(nil), ep.Auths...)
+	cp.OutboundAuths = append([]string(nil), ep.OutboundAuths...)
+	cp.Contacts = nil
+	for _, aor := range ep.Aors {
+		cp.Contacts = append(cp.Contacts, inv.aorContacts(aor)...)
+	}
+	return cp
+}
+
+// aorContacts returns contacts of the AOR ordered by URI
+func (inv *Inventory) aorContacts(aor string) []Contact {
+	var contacts []Contact
+	for _, c := range inv.contacts {
+		if strings.EqualFold(c.Aor, aor) {
+			contacts = append(contacts, *c)
+		}
+	}
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].URI < contacts[j].URI })
+	return contacts
+}
+
+func isEvent(msg *goami2.Message, name string) bool {
+	return strings.EqualFold(msg.Field("Event"), name)
+}
@@ -0,0 +1,113 @@
+package pjsip
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func endpointList(*goami2.Message) []*goami2.Message {
+	return []*goami2.Message{
+		goami2test.Success("EventList", "start", "Message", "A listing of Endpoints follows"),
+		goami2test.Event("EndpointList", "ObjectName", "200", "Transport", "tcp", "Aor", "200",
+			"Contacts", "", "DeviceState", "Unavailable", "ActiveChannels", "0"),
+		goami2test.Event("EndpointList", "ObjectName", "100", "Transport", "udp", "Aor", "100",
+			"Contacts", "100/sip:100@10.0.0.2:5060", "DeviceState", "In use", "ActiveChannels", "1"),
+		goami2test.Event("EndpointListComplete", "EventList", "Complete", "ListItems", "2"),
+	}
+}
+
+func TestInventoryLoad(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("PJSIPShowEndpoints", endpointList)
+	ami.Handle("PJSIPShowEndpoint", func(action *goami2.Message) []*goami2.Message {
+		name := action.Field("Endpoint")
+		return []*goami2.Message{
+			goami2test.Success("EventList", "start", "Message", "Following are Events for each object associated with the Endpoint"),
+			goami2test.Event("EndpointDetail", "ObjectName", name, "Context", "from-internal", "Aors", name),
+			goami2test.Event("AorDetail", "ObjectName", name, "MaxContacts", "1", "EndpointName", name),
+			goami2test.Event("ContactStatusDetail", "AOR", "100", "URI", "sip:100@10.0.0.2:5060",
+				"Status", "Avail", "RoundtripUsec", "1200", "EndpointName", "100"),
+			goami2test.Event("EndpointDetailComplete", "EventList", "Complete", "ListItems", "3"),
+		}
+	})
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+	ctx := context.Background()
+
+	inv := New()
+	assert.Nil(t, inv.Load(ctx, cl))
+	endpoints := inv.Endpoints()
+	assert.Len(t, endpoints, 2)
+	assert.Equal(t, "100", endpoints[0].Name)
+	assert.Equal(t, 1, endpoints[0].ActiveChannels)
+	assert.Len(t, endpoints[0].Contacts, 1)
+	assert.Equal(t, "", endpoints[0].Contacts[0].Status)
+	assert.Empty(t, endpoints[1].Contacts)
+	_, ok := inv.Aor("100")
+	assert.False(t, ok)
+
+	inv.Details = true
+	assert.Nil(t, inv.Load(ctx, cl))
+	ep, _ := inv.Endpoint("100")
+	assert.Equal(t, "from-internal", ep.Context)
+	assert.Len(t, ep.Contacts, 1)
+	assert.True(t, ep.Contacts[0].Reachable())
+	assert.Equal(t, 1200*time.Microsecond, ep.Contacts[0].RTT)
+	aor, ok := inv.Aor("200")
+	assert.True(t, ok)
+	assert.Equal(t, 1, aor.MaxContacts)
+}
+
+func TestInventoryAttach(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	ami.Handle("PJSIPShowEndpoints", endpointList)
+
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	inv := New()
+	inv.Attach(cl)
+	assert.Nil(t, inv.Load(context.Background(), cl))
+	ami.Emit(goami2test.Event("ContactStatus", "URI", "sip:100@10.0.0.2:5060",
+		"ContactStatus", "Unreachable", "AOR", "100", "EndpointName", "100"))
+	assert.Eventually(t, func() bool {
+		ep, _ := inv.Endpoint("100")
+		return len(ep.Contacts) == 1 && ep.Contacts[0].Status == "Unreachable"
+	}, time.Second, time.Millisecond)
+}
+
+func TestInventoryAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	inv := New()
+	var updates atomic.Int32
+	inv.OnContact(func(Contact) { updates.Add(1) })
+	inv.Attach(cl)
+	const contacts = 300
+	var burst strings.Builder
+	for i := 0; i < contacts; i++ {
+		fmt.Fprintf(&burst, "Event: ContactStatus\r\nURI: sip:%d@10.0.0.2:5060\r\nContactStatus: Reachable\r\n"+
+			"AOR: %d\r\nEndpointName: %d\r\nRoundtripUsec: 1500\r\n\r\n", i, i, i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return updates.Load() == contacts }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
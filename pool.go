@@ -0,0 +1,251 @@
+package goami2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultPoolRetry is the delay before redial of the failed pool node
+const defaultPoolRetry = 5 * time.Second
+
+// ErrNodeUnavailable is returned when pool node is unknown or not connected
+var ErrNodeUnavailable = fmt.Errorf("%w: pool node unavailable", ErrConn)
+
+// PoolNode is the AMI server of the pool
+type PoolNode struct {
+	Name     string // node label, Address when empty
+	Address  string // Dial address, like "pbx1.example.com:5038"
+	Username string
+	Password string
+	Retry    time.Duration // delay before redial of the failed node, 5 seconds when zero
+	Options  []Option      // node options, applied after the pool options
+}
+
+// PoolMessage is the message received from the pool node
+type PoolMessage struct {
+	Node string
+	*Message
+}
+
+// NodeError is the error of the pool node client
+type NodeError struct {
+	Node string
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("node %s: %s", e.Node, e.Err)
+}
+
+func (e *NodeError) Unwrap() error { return e.Err }
+
+// Pool manages clients of several AMI servers, like an Asterisk cluster.
+// Messages of all nodes are sent to one channel labeled with the node name.
+// Every node is dialed in background and redialed when it fails to connect
+// or its client terminates. Pool is safe for concurrent use.
+type Pool struct {
+	mu      sync.Mutex
+	names   []string
+	clients map[string]*Client
+	msgs    chan PoolMessage
+	errs    chan error
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPool creates pool of the nodes and starts dialing them. Options are
+// applied to the clients of all nodes. Returns error when there are no nodes
+// or node names are not unique. Nodes are dialed until context is done or
+// pool is closed.
+func NewPool(ctx context.Context, nodes []PoolNode, opts ...Option) (*Pool, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%w: pool: no nodes", ErrAMI)
+	}
+	p := &Pool{
+		clients: make(map[string]*Client),
+		msgs:    make(chan PoolMessage, chanBuffer),
+		errs:    make(chan error, chanBuffer),
+		stop:    make(chan struct{}),
+	}
+	seen := make(map[string]bool)
+	for i := range nodes {
+		if nodes[i].Name == "" {
+			nodes[i].Name = nodes[i].Address
+		}
+		if seen[nodes[i].Name] {
+			return nil, fmt.Errorf("%w: pool: duplicate node %q", ErrAMI, nodes[i].Name)
+		}
+		seen[nodes[i].Name] = true
+		p.names = append(p.names, nodes[i].Name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-p.stop:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	for _, node := range nodes {
+		node.Options = append(append([]Option{}, opts...), node.Options...)
+		p.wg.Add(1)
+		go p.run(ctx, node)
+	}
+	return p, nil
+}
+
+// Messages returns a channel that receives messages of all nodes
+func (p *Pool) Messages() <-chan PoolMessage {
+	return p.msgs
+}
+
+// Err returns channel of the node errors, they are wrapped with NodeError
+func (p *Pool) Err() <-chan error {
+	return p.errs
+}
+
+// Nodes returns names of the pool nodes
+func (p *Pool) Nodes() []string {
+	return append([]string{}, p.names...)
+}
+
+// Connected returns names of the connected nodes, in the pool nodes order
+func (p *Pool) Connected() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var names []string
+	for _, name := range p.names {
+		if cl, ok := p.clients[name]; ok && cl.State() == StateConnected {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// State returns connection state of the node. Node that is dialed or
+// waits for redial is in StateConnecting, unknown node is in StateClosed.
+func (p *Pool) State(node string) ConnState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cl, ok := p.clients[node]; ok {
+		return cl.State()
+	}
+	for _, name := range p.names {
+		if name == node && !isClosedChan(p.stop) {
+			return StateConnecting
+		}
+	}
+	return StateClosed
+}
+
+// Client returns current client of the node. Client must not be closed by
+// the caller, it is replaced when node is redialed.
+func (p *Pool) Client(node string) (*Client, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cl, ok := p.clients[node]
+	return cl, ok
+}
+
+// SendAction sends action to the node and waits for the response, see
+// Client.SendAction. Returns ErrNodeUnavailable when node is unknown or
+// not connected.
+func (p *Pool) SendAction(ctx context.Context, node string, action *Message) (*Message, error) {
+	cl, ok := p.Client(node)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNodeUnavailable, node)
+	}
+	return cl.SendAction(ctx, action)
+}
+
+// Close stops dialing and closes clients of all nodes. Messages and errors
+// channels are closed after all clients are closed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if isClosedChan(p.stop) {
+		p.mu.Unlock()
+		return
+	}
+	close(p.stop)
+	p.mu.Unlock()
+	p.wg.Wait()
+	close(p.msgs)
+	close(p.errs)
+}
+
+// run dials the node and forwards its messages until pool is closed
+func (p *Pool) run(ctx context.Context, node PoolNode) {
+	defer p.wg.Done()
+	retry := node.Retry
+	if retry <= 0 {
+		retry = defaultPoolRetry
+	}
+	for {
+		cl, err := Dial(ctx, node.Address, node.Username, node.Password, node.Options...)
+		if err == nil {
+			p.mu.Lock()
+			p.clients[node.Name] = cl
+			p.mu.Unlock()
+			err = p.forward(node.Name, cl)
+			p.mu.Lock()
+			delete(p.clients, node.Name)
+			p.mu.Unlock()
+			cl.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !errors.Is(err, ErrClosed) {
+			p.emitErr(node.Name, err)
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// forward sends node client messages and errors to the pool channels until
+// client terminates. Returns error which terminated the client.
+func (p *Pool) forward(name string, cl *Client) error {
+	states := cl.StateChanges()
+	var last error
+	for {
+		select {
+		case msg := <-cl.AllMessages():
+			select {
+			case p.msgs <- PoolMessage{Node: name, Message: msg}:
+			case <-p.stop:
+				return nil
+			}
+		case err := <-cl.Err():
+			last = err
+			p.emitErr(name, err)
+		case change, ok := <-states:
+			if !ok {
+				return nil
+			}
+			if change.To == StateClosed && change.Err != nil && change.Err != last {
+				return change.Err
+			}
+		case <-p.stop:
+			return nil
+		}
+	}
+}
+
+func (p *Pool) emitErr(name string, err error) {
+	select {
+	case p.errs <- &NodeError{Node: name, Err: err}:
+	case <-p.stop:
+	case <-time.After(chanGiveup):
+		// failed to send and exit here to avoid blocking
+	}
+}
@@ -0,0 +1,99 @@
+package goami2_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitConnected waits until pool has connected nodes
+func waitConnected(t *testing.T, pool *goami2.Pool, n int) {
+	t.Helper()
+	assert.Eventually(t, func() bool { return len(pool.Connected()) == n }, time.Second, time.Millisecond)
+}
+
+func TestPool(t *testing.T) {
+	srv1, srv2 := goami2test.NewServer(), goami2test.NewServer()
+	defer srv1.Close()
+	defer srv2.Close()
+	srv2.Handle("CoreStatus", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{goami2test.Success("CoreCurrentCalls", "3")}
+	})
+	addr1, err := srv1.Listen()
+	assert.Nil(t, err)
+	addr2, err := srv2.Listen()
+	assert.Nil(t, err)
+
+	pool, err := goami2.NewPool(context.Background(), []goami2.PoolNode{
+		{Name: "pbx1", Address: addr1, Username: "admin", Password: "pa55w0rd", Retry: 10 * time.Millisecond},
+		{Address: addr2, Username: "admin", Password: "pa55w0rd"},
+	})
+	assert.Nil(t, err)
+	defer pool.Close()
+	assert.Equal(t, []string{"pbx1", addr2}, pool.Nodes())
+	waitConnected(t, pool, 2)
+	assert.Equal(t, goami2.StateConnected, pool.State("pbx1"))
+	assert.Equal(t, goami2.StateClosed, pool.State("pbx3"))
+
+	srv1.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/100-01"))
+	msg := <-pool.Messages()
+	assert.Equal(t, "pbx1", msg.Node)
+	assert.Equal(t, "PJSIP/100-01", msg.Field("Channel"))
+
+	resp, err := pool.SendAction(context.Background(), addr2, goami2.NewAction("CoreStatus"))
+	assert.Nil(t, err)
+	assert.Equal(t, "3", resp.Field("CoreCurrentCalls"))
+	_, err = pool.SendAction(context.Background(), "pbx3", goami2.NewAction("CoreStatus"))
+	assert.ErrorIs(t, err, goami2.ErrNodeUnavailable)
+
+	// node is redialed when connection is lost
+	srv1.Drop()
+	var nodeErr *goami2.NodeError
+	assert.ErrorAs(t, <-pool.Err(), &nodeErr)
+	assert.Equal(t, "pbx1", nodeErr.Node)
+	assert.ErrorIs(t, nodeErr, goami2.ErrEOF)
+	assert.Eventually(t, func() bool { return srv1.Sessions() == 1 }, time.Second, time.Millisecond)
+	waitConnected(t, pool, 2)
+
+	pool.Close()
+	pool.Close()
+	_, ok := <-pool.Messages()
+	assert.False(t, ok)
+	assert.Equal(t, goami2.StateClosed, pool.State("pbx1"))
+}
+
+func TestPoolFailedNode(t *testing.T) {
+	srv := goami2test.NewServer()
+	srv.Secret = "pa55w0rd"
+	defer srv.Close()
+	addr, err := srv.Listen()
+	assert.Nil(t, err)
+
+	pool, err := goami2.NewPool(context.Background(), []goami2.PoolNode{
+		{Name: "pbx1", Address: addr, Username: "admin", Password: "wrong", Retry: time.Hour},
+	})
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	err = <-pool.Err()
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+	assert.ErrorContains(t, err, "node pbx1: ")
+	assert.Equal(t, goami2.StateConnecting, pool.State("pbx1"))
+	assert.Empty(t, pool.Connected())
+}
+
+func TestNewPoolInvalid(t *testing.T) {
+	_, err := goami2.NewPool(context.Background(), nil)
+	assert.ErrorContains(t, err, "pool: no nodes")
+
+	_, err = goami2.NewPool(context.Background(), []goami2.PoolNode{
+		{Address: "127.0.0.1:5038"}, {Name: "127.0.0.1:5038", Address: "127.0.0.2:5038"},
+	})
+	assert.True(t, errors.Is(err, goami2.ErrAMI))
+	assert.ErrorContains(t, err, `duplicate node "127.0.0.1:5038"`)
+}
@@ -0,0 +1,211 @@
+// Package proxy is AMI server that multiplexes many manager clients onto one
+// upstream Asterisk connection. Clients login with credentials of the proxy
+// users store, receive upstream events selected by the user filter and send
+// actions over the shared upstream session.
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/staskobzar/goami2"
+)
+
+// DefaultPrompt is sent to clients when upstream banner is unknown
+const DefaultPrompt = "Asterisk Call Manager/5.0.0"
+
+// ErrClosed is returned by Serve after Close
+var ErrClosed = fmt.Errorf("%w: proxy closed", goami2.Error)
+
+// User of the proxy
+type User struct {
+	Username string
+	Secret   string
+	Events   goami2.Matcher // events sent to the user, all events when nil
+	Actions  []string       // permitted actions, case insensitive, all actions when empty
+}
+
+// permitted returns true if user can send the action
+func (u User) permitted(action string) bool {
+	if len(u.Actions) == 0 {
+		return true
+	}
+	for _, a := range u.Actions {
+		if strings.EqualFold(a, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store returns user by name. Returns false when user does not exist.
+type Store interface {
+	User(ctx context.Context, username string) (User, bool, error)
+}
+
+// StoreFunc is an adapter to use ordinary function as Store
+type StoreFunc func(ctx context.Context, username string) (User, bool, error)
+
+// User calls f(ctx, username)
+func (f StoreFunc) User(ctx context.Context, username string) (User, bool, error) {
+	return f(ctx, username)
+}
+
+// Users returns static store of the users
+func Users(users ...User) Store {
+	byName := make(map[string]User, len(users))
+	for _, u := range users {
+		byName[u.Username] = u
+	}
+	return StoreFunc(func(_ context.Context, username string) (User, bool, error) {
+		u, ok := byName[username]
+		return u, ok, nil
+	})
+}
+
+// Server accepts AMI clients and proxies them to the upstream client
+type Server struct {
+	// Logger of the sessions, discards logs when nil
+	Logger *slog.Logger
+
+	upstream *goami2.Client
+	store    Store
+
+	mu        sync.Mutex
+	listeners map[net.Listener]bool
+	sessions  map[*session]bool
+	lastID    uint64
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New creates proxy server of the upstream client that authenticates
+// clients with the store
+func New(upstream *goami2.Client, store Store) *Server {
+	return &Server{
+		upstream:  upstream,
+		store:     store,
+		listeners: make(map[net.Listener]bool),
+		sessions:  make(map[*session]bool),
+	}
+}
+
+// Serve accepts client connections until listener fails or server is
+// closed. Returns ErrClosed after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrClosed
+			}
+			return err
+		}
+		s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves client connection in a new goroutine
+func (s *Server) ServeConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = conn.Close()
+		return
+	}
+	s.lastID++
+	sess := newSession(s, conn, s.lastID)
+	s.sessions[sess] = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		sess.run()
+		s.mu.Lock()
+		delete(s.sessions, sess)
+		s.mu.Unlock()
+	}()
+}
+
+// Sessions returns number of connected clients
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Close stops listeners, closes client connections and waits for sessions
+// to stop. Upstream client is not closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		err = errors.Join(err, l.Close())
+	}
+	for sess := range s.sessions {
+		_ = sess.conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return s.Logger
+}
+
+// authenticate login action of the session
+func (s *Server) authenticate(ctx context.Context, login *goami2.Message, challenge string) (User, error) {
+	u, ok, err := s.store.User(ctx, login.Field("Username"))
+	if err != nil {
+		return User{}, err
+	}
+	if !ok {
+		return User{}, errAuth
+	}
+	if strings.EqualFold(login.Field("AuthType"), "MD5") {
+		if challenge == "" || !equal(login.Field("Key"), md5Key(challenge, u.Secret)) {
+			return User{}, errAuth
+		}
+		return u, nil
+	}
+	if !equal(login.Field("Secret"), u.Secret) {
+		return User{}, errAuth
+	}
+	return u, nil
+}
+
+var errAuth = errors.New("authentication failed")
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
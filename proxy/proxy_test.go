@@ -0,0 +1,133 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/staskobzar/goami2/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func startProxy(t *testing.T, store proxy.Store) (*goami2test.Server, *proxy.Server, string) {
+	t.Helper()
+	ami := goami2test.NewServer()
+	upstream, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+
+	srv := proxy.New(upstream, store)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.Close()
+		upstream.Close()
+		ami.Close()
+	})
+	return ami, srv, "tcp://" + l.Addr().String()
+}
+
+func TestProxy(t *testing.T) {
+	store := proxy.Users(
+		proxy.User{Username: "ops", Secret: "s3cret"},
+		proxy.User{
+			Username: "reports",
+			Secret:   "r3ports",
+			Events:   goami2.MatchEvents("Hangup"),
+			Actions:  []string{"Ping"},
+		},
+	)
+	ami, srv, addr := startProxy(t, store)
+	ami.Handle("CoreStatus", func(*goami2.Message) []*goami2.Message {
+		return []*goami2.Message{goami2test.Success("CoreCurrentCalls", "3")}
+	})
+	ctx := context.Background()
+
+	ops, err := goami2.Dial(ctx, addr, "ops", "s3cret")
+	assert.Nil(t, err)
+	defer ops.Close()
+	reports, err := goami2.Dial(ctx, addr, "reports", "r3ports")
+	assert.Nil(t, err)
+	defer reports.Close()
+	assert.Equal(t, 2, srv.Sessions())
+	assert.Equal(t, 1, ami.Sessions())
+
+	t.Run("actions are sent upstream", func(t *testing.T) {
+		resp, err := ops.SendAction(ctx, goami2.NewAction("CoreStatus"))
+		assert.Nil(t, err)
+		assert.Equal(t, "3", resp.Field("CoreCurrentCalls"))
+		action, err := ami.WaitAction(ctx, "CoreStatus")
+		assert.Nil(t, err)
+		assert.Regexp(t, "^proxy-1-", action.ActionID())
+		assert.NotEqual(t, resp.ActionID(), action.ActionID())
+	})
+
+	t.Run("action not permitted", func(t *testing.T) {
+		resp, err := reports.SendAction(ctx, goami2.NewAction("CoreStatus"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Error", resp.Field("Response"))
+		assert.Equal(t, "Permission denied", resp.Field("Message"))
+
+		resp, err = reports.SendAction(ctx, goami2.NewAction("Ping"))
+		assert.Nil(t, err)
+		assert.True(t, resp.IsSuccess())
+	})
+
+	t.Run("events are filtered per user", func(t *testing.T) {
+		opsEvents := ops.Subscribe("Newchannel", "Hangup")
+		reportsEvents := reports.Subscribe("Newchannel", "Hangup")
+		ami.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/100-01"))
+		ami.Emit(goami2test.Event("Hangup", "Channel", "PJSIP/100-01"))
+
+		for _, name := range []string{"Newchannel", "Hangup"} {
+			select {
+			case ev := <-opsEvents:
+				assert.Equal(t, name, ev.Field("Event"))
+			case <-time.After(time.Second):
+				t.Fatal("missing event " + name)
+			}
+		}
+		select {
+		case ev := <-reportsEvents:
+			assert.Equal(t, "Hangup", ev.Field("Event"))
+		case <-time.After(time.Second):
+			t.Fatal("missing event Hangup")
+		}
+	})
+}
+
+func TestProxyAuthentication(t *testing.T) {
+	_, srv, addr := startProxy(t, proxy.Users(proxy.User{Username: "ops", Secret: "s3cret"}))
+	ctx := context.Background()
+
+	_, err := goami2.Dial(ctx, addr, "ops", "wr0ng")
+	var perr *goami2.ProtocolError
+	assert.True(t, errors.As(err, &perr))
+	assert.Equal(t, "Authentication failed", perr.Response.Field("Message"))
+
+	_, err = goami2.Dial(ctx, addr, "guest", "s3cret")
+	assert.True(t, errors.As(err, &perr))
+
+	t.Run("md5 challenge", func(t *testing.T) {
+		cl, err := goami2.Dial(ctx, addr, "ops", "s3cret", goami2.WithAuthMD5())
+		assert.Nil(t, err)
+		cl.Close()
+
+		_, err = goami2.Dial(ctx, addr, "ops", "wr0ng", goami2.WithAuthMD5())
+		assert.True(t, errors.As(err, &perr))
+	})
+
+	t.Run("store fails", func(t *testing.T) {
+		_, _, addr := startProxy(t, proxy.StoreFunc(func(context.Context, string) (proxy.User, bool, error) {
+			return proxy.User{}, false, errors.New("store is down")
+		}))
+		_, err := goami2.Dial(ctx, addr, "ops", "s3cret")
+		assert.True(t, errors.As(err, &perr))
+	})
+
+	assert.Eventually(t, func() bool { return srv.Sessions() == 0 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/staskobzar/goami2"
+)
+
+// session of the proxy client
+type session struct {
+	srv    *Server
+	conn   net.Conn
+	prefix string // ActionID prefix of the session actions upstream
+
+	wmu    sync.Mutex // serializes writes to the connection
+	mu     sync.Mutex
+	user   User
+	events bool // client receives events
+}
+
+func newSession(srv *Server, conn net.Conn, id uint64) *session {
+	return &session{srv: srv, conn: conn, prefix: fmt.Sprintf("proxy-%d-", id), events: true}
+}
+
+// run authenticates client and proxies actions until connection is closed
+func (s *session) run() {
+	defer s.conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := s.srv.logger().With("remote", s.conn.RemoteAddr().String())
+
+	prompt := s.srv.upstream.Banner()
+	if prompt == "" {
+		prompt = DefaultPrompt
+	}
+	if _, err := io.WriteString(s.conn, prompt+"\r\n"); err != nil {
+		return
+	}
+	r := bufio.NewReader(s.conn)
+	if !s.login(ctx, r) {
+		return
+	}
+	log = log.With("username", s.user.Username)
+	log.Info("client logged in")
+
+	sub := s.srv.upstream.SubscribeAll()
+	defer sub.Cancel()
+	go s.forward(sub.Messages())
+
+	for {
+		action, err := goami2.ParseMessage(r)
+		var limit *goami2.LimitError
+		if errors.As(err, &limit) {
+			log.Warn("discarded action", "error", err)
+			continue
+		}
+		if err != nil {
+			log.Info("client disconnected", "error", err)
+			return
+		}
+		if !s.handle(ctx, action) {
+			return
+		}
+	}
+}
+
+// login reads actions until client logs in. Returns false when client
+// fails to login or disconnects.
+func (s *session) login(ctx context.Context, r *bufio.Reader) bool {
+	var challenge string
+	for {
+		action, err := goami2.ParseMessage(r)
+		if err != nil {
+			return false
+		}
+		id := action.ActionID()
+		switch strings.ToLower(action.Field("Action")) {
+		case "challenge":
+			if !strings.EqualFold(action.Field("AuthType"), "MD5") {
+				s.reply(id, "Error", "Message", "Must specify AuthType")
+				continue
+			}
+			challenge = randomChallenge()
+			s.reply(id, "Success", "Challenge", challenge)
+		case "login":
+			user, err := s.srv.authenticate(ctx, action, challenge)
+			if err != nil {
+				if !errors.Is(err, errAuth) {
+					s.srv.logger().Warn("failed to authenticate", "error", err)
+				}
+				s.reply(id, "Error", "Message", "Authentication failed")
+				return false
+			}
+			s.mu.Lock()
+			s.user = user
+			s.events = !strings.EqualFold(action.Field("Events"), "off")
+			s.mu.Unlock()
+			s.reply(id, "Success", "Message", "Authentication accepted")
+			return true
+		default:
+			s.reply(id, "Error", "Message", "Missing action in request")
+		}
+	}
+}
+
+// handle action of the logged in client. Returns false when session ends.
+func (s *session) handle(ctx context.Context, action *goami2.Message) bool {
+	id := action.ActionID()
+	name := action.Field("Action")
+	switch strings.ToLower(name) {
+	case "logoff":
+		s.reply(id, "Goodbye", "Message", "Thanks for all the fish.")
+		return false
+	case "login", "challenge":
+		s.reply(id, "Success", "Message", "Already authenticated")
+		return true
+	case "events":
+		mask := action.Field("EventMask")
+		s.mu.Lock()
+		s.events = !strings.EqualFold(mask, "off")
+		s.mu.Unlock()
+		s.reply(id, "Success", "Events", mask)
+		return true
+	case "":
+		s.reply(id, "Error", "Message", "Missing action in request")
+		return true
+	}
+
+	s.mu.Lock()
+	permitted := s.user.permitted(name)
+	s.mu.Unlock()
+	if !permitted {
+		s.reply(id, "Error", "Message", "Permission denied")
+		return true
+	}
+
+	upstream := action.Clone()
+	_ = upstream.SetField("ActionID", s.prefix+id)
+	msgs, err := s.srv.upstream.SendActionList(ctx, upstream)
+	var perr *goami2.ProtocolError
+	switch {
+	case errors.As(err, &perr) && perr.Response != nil:
+		msgs = []*goami2.Message{perr.Response}
+	case err != nil:
+		s.reply(id, "Error", "Message", "Upstream failed: "+err.Error())
+		return true
+	}
+	for _, msg := range msgs {
+		msg = msg.Clone()
+		_ = msg.SetField("ActionID", id)
+		if id == "" {
+			msg.DelField("ActionID")
+		}
+		if err := s.write(msg); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// forward upstream events selected by the user filter to the client
+func (s *session) forward(msgs <-chan *goami2.Message) {
+	for msg := range msgs {
+		if !msg.IsEvent() {
+			continue
+		}
+		s.mu.Lock()
+		send := s.events && (s.user.Events == nil || s.user.Events(msg))
+		s.mu.Unlock()
+		if !send {
+			continue
+		}
+		if err := s.write(msg); err != nil {
+			return
+		}
+	}
+}
+
+// reply writes response with the fields given as name and value pairs
+func (s *session) reply(id, response string, fields ...string) {
+	msg := goami2.NewMessage()
+	msg.AddField("Response", response)
+	if id != "" {
+		msg.AddField("ActionID", id)
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		msg.AddField(fields[i], fields[i+1])
+	}
+	_ = s.write(msg)
+}
+
+func (s *session) write(msg *goami2.Message) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err := s.conn.Write(msg.Byte())
+	return err
+}
+
+func randomChallenge() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func md5Key(challenge, secret string) string {
+	sum := md5.Sum([]byte(challenge + secret))
+	return hex.EncodeToString(sum[:])
+}
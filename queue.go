@@ -0,0 +1,193 @@
+package goami2
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// QueueStore persists outbound queue, so actions queued while disconnected
+// survive restart of the process, see WithOutboundQueue
+type QueueStore interface {
+	// Load returns actions saved by the previous process
+	Load() ([]*Message, error)
+	// Save replaces saved actions with the queued actions
+	Save(actions []*Message) error
+}
+
+// outboundQueue keeps actions sent while client is reconnecting
+type outboundQueue struct {
+	mu       sync.Mutex
+	size     int
+	policy   OverflowPolicy
+	store    QueueStore
+	actions  []*Message
+	loaded   bool // actions of the store are loaded
+	flushing bool
+}
+
+// enqueue action. Must be called with locked mutex
+func (q *outboundQueue) enqueue(action *Message) error {
+	if len(q.actions) >= q.size {
+		if q.policy != OverflowDropOldest {
+			return fmt.Errorf("%w: action %q", ErrQueueFull, action.Field("Action"))
+		}
+		q.actions = q.actions[1:]
+	}
+	q.actions = append(q.actions, action.Clone())
+	return nil
+}
+
+// save queued actions to the store. Must be called with locked mutex
+func (q *outboundQueue) save() error {
+	if q.store == nil {
+		return nil
+	}
+	return q.store.Save(q.actions)
+}
+
+// QueuedActions returns number of actions in the outbound queue waiting
+// for reconnect
+func (c *Client) QueuedActions() int {
+	if c.outq == nil {
+		return 0
+	}
+	c.outq.mu.Lock()
+	defer c.outq.mu.Unlock()
+	return len(c.outq.actions)
+}
+
+// queueOrWrite queues action when client is reconnecting or queue is not
+// flushed yet, so actions are sent in order. Otherwise it writes the action.
+func (c *Client) queueOrWrite(action *Message, write func(*Message) error) error {
+	q := c.outq
+	if q == nil {
+		return write(action)
+	}
+	q.mu.Lock()
+	if c.State() != StateReconnecting && len(q.actions) == 0 && !q.flushing {
+		q.mu.Unlock()
+		return write(action)
+	}
+	defer q.mu.Unlock()
+	if err := q.enqueue(action); err != nil {
+		return err
+	}
+	if err := q.save(); err != nil {
+		c.logger.Warn("failed to save outbound queue", "error", err)
+	}
+	return nil
+}
+
+// flushQueue starts sending queued actions after login. Actions saved by
+// the previous process are sent first. Sending stops when write fails and
+// remaining actions are sent after the next reconnect.
+func (c *Client) flushQueue() {
+	q := c.outq
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.loaded && q.store != nil {
+		stored, err := q.store.Load()
+		if err != nil {
+			c.logger.Warn("failed to load outbound queue", "error", err)
+		}
+		q.actions = append(stored, q.actions...)
+	}
+	q.loaded = true
+	if q.flushing || len(q.actions) == 0 {
+		return
+	}
+	// actions sent while flushing are queued behind
+	q.flushing = true
+	go c.flush(q)
+}
+
+func (c *Client) flush(q *outboundQueue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer func() { q.flushing = false }()
+	for len(q.actions) > 0 {
+		action := q.actions[0]
+		q.mu.Unlock()
+		err := c.limitWrite(action.Byte())
+		q.mu.Lock()
+		if err != nil {
+			c.logger.Warn("failed to flush outbound queue", "action", action.Field("Action"), "error", err)
+			return
+		}
+		q.actions = q.actions[1:]
+		if err := q.save(); err != nil {
+			c.logger.Warn("failed to save outbound queue", "error", err)
+		}
+	}
+}
+
+// fileStore saves outbound queue to the file
+type fileStore struct {
+	path string
+}
+
+// FileQueueStore returns QueueStore that saves actions to the file as AMI
+// packets. File is replaced atomically and removed when queue is empty.
+func FileQueueStore(path string) QueueStore {
+	return &fileStore{path: path}
+}
+
+// Load actions from the file. Missing file is empty queue.
+func (s *fileStore) Load() ([]*Message, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read queue: %w", Error, err)
+	}
+	var actions []*Message
+	for _, packet := range strings.SplitAfter(string(data), "\r\n\r\n") {
+		if strings.TrimSpace(packet) == "" {
+			continue
+		}
+		action, err := Parse(packet)
+		if err != nil {
+			return actions, fmt.Errorf("%w: failed to read queue %s: %w", Error, s.path, err)
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// Save actions to the file
+func (s *fileStore) Save(actions []*Message) error {
+	if len(actions) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: failed to remove queue: %w", Error, err)
+		}
+		return nil
+	}
+	var buf []byte
+	for _, action := range actions {
+		buf = append(buf, action.Byte()...)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("%w: failed to save queue: %w", Error, err)
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("%w: failed to save queue: %w", Error, err)
+	}
+	return nil
+}
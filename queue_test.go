@@ -0,0 +1,91 @@
+package goami2_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func userEvent(name string) *goami2.Message {
+	action := goami2.NewAction("UserEvent")
+	action.AddField("UserEvent", name)
+	return action
+}
+
+// userEvents returns names of UserEvent actions received by the server
+func userEvents(ami *goami2test.Server) []string {
+	var names []string
+	for _, action := range ami.Actions() {
+		if action.Field("Action") == "UserEvent" {
+			names = append(names, action.Field("UserEvent"))
+		}
+	}
+	return names
+}
+
+func TestClientOutboundQueue(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	addr, err := ami.Listen()
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	cl, err := goami2.Dial(ctx, addr, "admin", "pa55w0rd",
+		goami2.WithReconnect(0, 100*time.Millisecond),
+		goami2.WithOutboundQueue(2, goami2.OverflowDropNewest, nil))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	ami.Drop()
+	assert.Eventually(t, func() bool { return cl.State() == goami2.StateReconnecting },
+		time.Second, time.Millisecond)
+	assert.True(t, cl.Action(userEvent("first")))
+	assert.Nil(t, cl.SendContext(ctx, userEvent("second")))
+	assert.ErrorIs(t, cl.SendContext(ctx, userEvent("third")), goami2.ErrQueueFull)
+	assert.Equal(t, 2, cl.QueuedActions())
+
+	assert.Eventually(t, func() bool { return len(userEvents(ami)) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, userEvents(ami))
+	assert.Equal(t, 0, cl.QueuedActions())
+
+	// sent without queue when connected
+	assert.True(t, cl.Action(userEvent("fourth")))
+	assert.Eventually(t, func() bool { return len(userEvents(ami)) == 3 }, time.Second, time.Millisecond)
+}
+
+func TestClientOutboundQueueStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue")
+	store := goami2.FileQueueStore(path)
+	actions, err := store.Load()
+	assert.Nil(t, err)
+	assert.Empty(t, actions)
+
+	assert.Nil(t, store.Save([]*goami2.Message{userEvent("first"), userEvent("second")}))
+	actions, err = store.Load()
+	assert.Nil(t, err)
+	assert.Len(t, actions, 2)
+	assert.Equal(t, "second", actions[1].Field("UserEvent"))
+
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	addr, err := ami.Listen()
+	assert.Nil(t, err)
+	cl, err := goami2.Dial(context.Background(), addr, "admin", "pa55w0rd",
+		goami2.WithOutboundQueue(8, goami2.OverflowDropOldest, store))
+	assert.Nil(t, err)
+	defer cl.Close()
+	assert.True(t, cl.Action(userEvent("third")))
+
+	assert.Eventually(t, func() bool { return len(userEvents(ami)) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"first", "second", "third"}, userEvents(ami))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,170 @@
+package queues
+
+import (
+	"strings"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// Handle updates monitor state with AMI event. Events that are not queue
+// events or without Queue header are ignored.
+func (m *Monitor) Handle(msg *goami2.Message) {
+	name := strings.ToLower(msg.Field("Event"))
+	queue := msg.Field("Queue")
+	if queue == "" || !isQueueEvent(name) {
+		return
+	}
+
+	m.mu.Lock()
+	var change Change
+	var ok bool
+	q := m.queue(queue)
+	switch name {
+	case "queuememberadded", "queuememberstatus", "queuememberpause":
+		change, ok = q.setMember(decodeMember(msg))
+	case "queuememberremoved":
+		change, ok = q.removeMember(decodeMember(msg))
+	case "queuecallerjoin":
+		change, ok = q.join(msg, m.now())
+	case "queuecallerleave":
+		change, ok = q.leave(msg)
+	case "queuecallerabandon":
+		q.Abandoned++
+		change, ok = q.agent(msg, CallerAbandoned)
+	case "agentconnect":
+		change, ok = q.agent(msg, AgentConnected)
+	case "agentcomplete":
+		q.Completed++
+		change, ok = q.agent(msg, AgentCompleted)
+		change.TalkTime, _ = msg.FieldDuration("TalkTime")
+	}
+	handlers := m.onChange
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	change.Queue = queue
+	for _, fn := range handlers {
+		fn(change)
+	}
+}
+
+// setMember adds or updates the queue member
+func (q *Queue) setMember(mem Member) (Change, bool) {
+	if mem.Interface == "" {
+		return Change{}, false
+	}
+	if i := q.member(mem.Interface); i >= 0 {
+		q.Members[i] = mem
+		return Change{Kind: MemberUpdated, Member: mem}, true
+	}
+	q.Members = append(q.Members, mem)
+	return Change{Kind: MemberAdded, Member: mem}, true
+}
+
+// removeMember removes member from the queue
+func (q *Queue) removeMember(mem Member) (Change, bool) {
+	i := q.member(mem.Interface)
+	if i < 0 {
+		return Change{}, false
+	}
+	q.Members = append(q.Members[:i], q.Members[i+1:]...)
+	return Change{Kind: MemberRemoved, Member: mem}, true
+}
+
+// join adds caller to the queue at the caller position
+func (q *Queue) join(msg *goami2.Message, now time.Time) (Change, bool) {
+	caller := decodeCaller(msg)
+	if caller.Uniqueid == "" {
+		return Change{}, false
+	}
+	caller.Joined = now
+	if i := q.caller(caller.Uniqueid); i >= 0 {
+		q.Callers = append(q.Callers[:i], q.Callers[i+1:]...)
+	}
+	i := caller.Position - 1
+	if i < 0 || i > len(q.Callers) {
+		i = len(q.Callers)
+	}
+	q.Callers = append(q.Callers[:i], append([]Caller{caller}, q.Callers[i:]...)...)
+	q.renumber(msg)
+	return Change{Kind: CallerJoined, Caller: q.Callers[i]}, true
+}
+
+// leave removes caller from the queue
+func (q *Queue) leave(msg *goami2.Message) (Change, bool) {
+	i := q.caller(msg.Field("Uniqueid"))
+	if i < 0 {
+		return Change{}, false
+	}
+	caller := q.Callers[i]
+	q.Callers = append(q.Callers[:i], q.Callers[i+1:]...)
+	q.renumber(msg)
+	return Change{Kind: CallerLeft, Caller: caller}, true
+}
+
+// agent reports caller abandon or agent event. AgentConnect marks the
+// member in call, AgentComplete marks the member not in call.
+func (q *Queue) agent(msg *goami2.Message, kind ChangeKind) (Change, bool) {
+	change := Change{Kind: kind}
+	change.HoldTime, _ = msg.FieldDuration("HoldTime")
+	if i := q.caller(msg.Field("Uniqueid")); i >= 0 {
+		change.Caller = q.Callers[i]
+	} else {
+		change.Caller = decodeCaller(msg)
+		change.Caller.Queue = q.Name
+	}
+	if kind == CallerAbandoned {
+		return change, true
+	}
+
+	iface := msg.Field("Interface")
+	i := q.member(iface)
+	if i < 0 {
+		return Change{}, false
+	}
+	q.Members[i].InCall = kind == AgentConnected
+	change.Member = q.Members[i]
+	return change, true
+}
+
+// renumber updates callers positions and number of waiting
+// callers with "Count" header of the event
+func (q *Queue) renumber(msg *goami2.Message) {
+	for i := range q.Callers {
+		q.Callers[i].Position = i + 1
+	}
+	q.Calls = len(q.Callers)
+	if count, ok := msg.FieldInt("Count"); ok {
+		q.Calls = count
+	}
+}
+
+func (q *Queue) member(iface string) int {
+	for i, mem := range q.Members {
+		if strings.EqualFold(mem.Interface, iface) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queue) caller(uniqueid string) int {
+	for i, caller := range q.Callers {
+		if caller.Uniqueid == uniqueid {
+			return i
+		}
+	}
+	return -1
+}
+
+func isQueueEvent(name string) bool {
+	for _, ev := range queueEvents {
+		if strings.EqualFold(ev, name) {
+			return true
+		}
+	}
+	return false
+}
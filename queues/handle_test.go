@@ -0,0 +1,179 @@
+package queues
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorMembers(t *testing.T) {
+	mon := New()
+	var changes []Change
+	mon.OnChange(func(c Change) { changes = append(changes, c) })
+
+	mon.Handle(event(t, "Event: QueueMemberAdded\r\nQueue: sales\r\nMemberName: Alice\r\n"+
+		"Interface: PJSIP/100\r\nMembership: dynamic\r\nStatus: 1\r\nPaused: 0\r\n"))
+	mon.Handle(event(t, "Event: QueueMemberAdded\r\nQueue: sales\r\nMemberName: Bob\r\n"+
+		"Interface: PJSIP/200\r\nStatus: 1\r\n"))
+	mon.Handle(event(t, "Event: QueueMemberPause\r\nQueue: sales\r\nMemberName: Alice\r\n"+
+		"Interface: PJSIP/100\r\nStatus: 1\r\nPaused: 1\r\nPausedReason: break\r\n"))
+	mon.Handle(event(t, "Event: QueueMemberStatus\r\nQueue: sales\r\nMemberName: Bob\r\n"+
+		"Interface: PJSIP/200\r\nStatus: 6\r\n"))
+	mon.Handle(event(t, "Event: QueueMemberStatus\r\nQueue: sales\r\nMemberName: Nobody\r\n"))
+	mon.Handle(event(t, "Event: Newchannel\r\nQueue: sales\r\nInterface: PJSIP/300\r\n"))
+
+	q, ok := mon.Queue("sales")
+	assert.True(t, ok)
+	assert.Len(t, q.Members, 2)
+	assert.True(t, q.Members[0].Paused)
+	assert.Equal(t, "break", q.Members[0].PausedReason)
+	assert.Equal(t, 6, q.Members[1].Status)
+
+	mon.Handle(event(t, "Event: QueueMemberRemoved\r\nQueue: sales\r\nMemberName: Alice\r\n"+
+		"Interface: PJSIP/100\r\n"))
+	mon.Handle(event(t, "Event: QueueMemberRemoved\r\nQueue: sales\r\nInterface: PJSIP/100\r\n"))
+	q, _ = mon.Queue("sales")
+	assert.Len(t, q.Members, 1)
+	assert.Equal(t, "Bob", q.Members[0].Name)
+
+	kinds := make([]ChangeKind, 0, len(changes))
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ChangeKind{MemberAdded, MemberAdded, MemberUpdated,
+		MemberUpdated, MemberRemoved}, kinds)
+	assert.Equal(t, "sales", changes[4].Queue)
+	assert.Equal(t, "Alice", changes[4].Member.Name)
+}
+
+func TestMonitorCallers(t *testing.T) {
+	mon := New()
+	now := time.Date(2020, 8, 31, 12, 0, 0, 0, time.UTC)
+	mon.now = func() time.Time { return now }
+	var changes []Change
+	mon.OnChange(func(c Change) { changes = append(changes, c) })
+
+	mon.Handle(event(t, "Event: QueueMemberAdded\r\nQueue: sales\r\nMemberName: Alice\r\n"+
+		"Interface: PJSIP/100\r\n"))
+	mon.Handle(event(t, "Event: QueueCallerJoin\r\nQueue: sales\r\nPosition: 1\r\nCount: 1\r\n"+
+		"Channel: PJSIP/300-01\r\nUniqueid: 1598887690.70\r\nCallerIDNum: 300\r\n"))
+	mon.Handle(event(t, "Event: QueueCallerJoin\r\nQueue: sales\r\nPosition: 2\r\nCount: 2\r\n"+
+		"Channel: PJSIP/400-01\r\nUniqueid: 1598887690.80\r\n"))
+	// priority caller joins ahead of others
+	mon.Handle(event(t, "Event: QueueCallerJoin\r\nQueue: sales\r\nPosition: 1\r\nCount: 3\r\n"+
+		"Channel: PJSIP/500-01\r\nUniqueid: 1598887690.90\r\n"))
+
+	q, _ := mon.Queue("sales")
+	assert.Equal(t, 3, q.Calls)
+	assert.Len(t, q.Callers, 3)
+	assert.Equal(t, "1598887690.90", q.Callers[0].Uniqueid)
+	assert.Equal(t, "1598887690.70", q.Callers[1].Uniqueid)
+	assert.Equal(t, 2, q.Callers[1].Position)
+	assert.Equal(t, now, q.Callers[1].Joined)
+
+	mon.Handle(event(t, "Event: QueueCallerLeave\r\nQueue: sales\r\nPosition: 1\r\nCount: 2\r\n"+
+		"Uniqueid: 1598887690.90\r\n"))
+	mon.Handle(event(t, "Event: AgentConnect\r\nQueue: sales\r\nChannel: PJSIP/500-01\r\n"+
+		"Uniqueid: 1598887690.90\r\nMemberName: Alice\r\nInterface: PJSIP/100\r\n"+
+		"HoldTime: 15\r\nRingTime: 3\r\n"))
+	q, _ = mon.Queue("sales")
+	assert.Equal(t, 2, q.Calls)
+	assert.Equal(t, 1, q.Callers[0].Position)
+	assert.Equal(t, "1598887690.70", q.Callers[0].Uniqueid)
+	assert.True(t, q.Members[0].InCall)
+
+	mon.Handle(event(t, "Event: QueueCallerAbandon\r\nQueue: sales\r\nPosition: 1\r\n"+
+		"Uniqueid: 1598887690.70\r\nHoldTime: 40\r\n"))
+	mon.Handle(event(t, "Event: QueueCallerLeave\r\nQueue: sales\r\nPosition: 1\r\nCount: 1\r\n"+
+		"Uniqueid: 1598887690.70\r\n"))
+	mon.Handle(event(t, "Event: QueueCallerLeave\r\nQueue: sales\r\nUniqueid: unknown\r\n"))
+	mon.Handle(event(t, "Event: AgentComplete\r\nQueue: sales\r\nUniqueid: 1598887690.90\r\n"+
+		"MemberName: Alice\r\nInterface: PJSIP/100\r\nHoldTime: 15\r\nTalkTime: 60\r\n"))
+	mon.Handle(event(t, "Event: AgentConnect\r\nQueue: sales\r\nInterface: PJSIP/900\r\n"))
+
+	q, _ = mon.Queue("sales")
+	assert.Equal(t, 1, q.Calls)
+	assert.Equal(t, 1, q.Abandoned)
+	assert.Equal(t, 1, q.Completed)
+	assert.False(t, q.Members[0].InCall)
+
+	kinds := make([]ChangeKind, 0, len(changes))
+	for _, c := range changes[1:] {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ChangeKind{CallerJoined, CallerJoined, CallerJoined, CallerLeft,
+		AgentConnected, CallerAbandoned, CallerLeft, AgentCompleted}, kinds)
+
+	connect := changes[5]
+	assert.Equal(t, "PJSIP/500-01", connect.Caller.Channel)
+	assert.Equal(t, "Alice", connect.Member.Name)
+	assert.Equal(t, 15*time.Second, connect.HoldTime)
+	abandon := changes[6]
+	assert.Equal(t, "300", abandon.Caller.CallerIDNum)
+	assert.Equal(t, 40*time.Second, abandon.HoldTime)
+	assert.Equal(t, time.Minute, changes[8].TalkTime)
+}
+
+func TestMonitorAttach(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	attached := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1024)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+		_, _ = connSrv.Read(buf)
+		_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		<-attached
+		_, _ = connSrv.Write([]byte("Event: QueueMemberAdded\r\nQueue: sales\r\n" +
+			"Interface: PJSIP/100\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+	}()
+
+	cl, err := goami2.NewClientWithContext(context.Background(), connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	mon := New()
+	added := make(chan Change, 1)
+	mon.OnChange(func(c Change) { added <- c })
+	mon.Attach(cl)
+	close(attached)
+
+	select {
+	case c := <-added:
+		assert.Equal(t, MemberAdded, c.Kind)
+		assert.Equal(t, "PJSIP/100", c.Member.Interface)
+	case <-time.After(time.Second):
+		t.Fatal("member is not tracked")
+	}
+	assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+}
+
+func TestMonitorAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	mon := New()
+	mon.Attach(cl)
+	const members = 3000
+	var burst strings.Builder
+	for i := 0; i < members; i++ {
+		fmt.Fprintf(&burst, "Event: QueueMemberAdded\r\nQueue: sales\r\nInterface: PJSIP/%d\r\n\r\n", i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool {
+		q, _ := mon.Queue("sales")
+		return len(q.Members) == members
+	}, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
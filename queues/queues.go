@@ -0,0 +1,265 @@
+// Package queues keeps in-memory state of Asterisk app_queue queues, members
+// and waiting callers. State is loaded with QueueStatus and QueueSummary
+// actions and kept updated from queue events.
+package queues
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/staskobzar/goami2"
+)
+
+// queue events consumed by the monitor
+var queueEvents = []string{
+	"QueueMemberStatus", "QueueMemberAdded", "QueueMemberRemoved", "QueueMemberPause",
+	"QueueCallerJoin", "QueueCallerLeave", "QueueCallerAbandon",
+	"AgentConnect", "AgentComplete",
+}
+
+// Queue is the state of the queue
+type Queue struct {
+	Name             string `ami:"Queue"`
+	Strategy         string
+	Max              int
+	Calls            int           // number of waiting callers
+	Holdtime         time.Duration // average hold time
+	TalkTime         time.Duration // average talk time
+	Completed        int
+	Abandoned        int
+	ServiceLevel     time.Duration
+	ServicelevelPerf float64
+	Weight           int
+	LoggedIn         int           // logged in members, from QueueSummary
+	Available        int           // available members, from QueueSummary
+	LongestHoldTime  time.Duration // from QueueSummary
+	Members          []Member      `ami:"-"`
+	Callers          []Caller      `ami:"-"` // ordered by position
+}
+
+// Member is the state of the queue member
+type Member struct {
+	Queue          string
+	Name           string `ami:"MemberName"`
+	Interface      string
+	StateInterface string
+	Membership     string // "static", "dynamic" or "realtime"
+	Penalty        int
+	CallsTaken     int
+	LastCall       int64 // unix time of the last call
+	LastPause      int64 // unix time of the last pause
+	InCall         bool
+	Status         int // device state: 1 not in use, 2 in use, 6 ringing etc.
+	Paused         bool
+	PausedReason   string
+}
+
+// Caller is the caller waiting in the queue
+type Caller struct {
+	Queue        string
+	Position     int
+	Channel      string
+	Uniqueid     string
+	CallerIDNum  string
+	CallerIDName string
+	Joined       time.Time `ami:"-"`
+}
+
+// ChangeKind is the kind of queue state change
+type ChangeKind int
+
+// State changes
+const (
+	MemberAdded ChangeKind = iota
+	MemberUpdated
+	MemberRemoved
+	CallerJoined
+	CallerLeft
+	CallerAbandoned
+	AgentConnected
+	AgentCompleted
+)
+
+// String returns change kind name
+func (k ChangeKind) String() string {
+	switch k {
+	case MemberAdded:
+		return "member added"
+	case MemberUpdated:
+		return "member updated"
+	case MemberRemoved:
+		return "member removed"
+	case CallerJoined:
+		return "caller joined"
+	case CallerLeft:
+		return "caller left"
+	case CallerAbandoned:
+		return "caller abandoned"
+	case AgentConnected:
+		return "agent connected"
+	case AgentCompleted:
+		return "agent completed"
+	}
+	return "unknown"
+}
+
+// Change is the notification of queue state change
+type Change struct {
+	Kind     ChangeKind
+	Queue    string
+	Member   Member        // member of member and agent changes
+	Caller   Caller        // caller of caller and agent changes
+	HoldTime time.Duration // caller hold time of abandon and agent changes
+	TalkTime time.Duration // talk time of AgentCompleted change
+}
+
+// Monitor keeps queues state loaded with Load and updated from
+// QueueMemberStatus, QueueMemberAdded, QueueMemberRemoved, QueueMemberPause,
+// QueueCallerJoin, QueueCallerLeave, QueueCallerAbandon, AgentConnect and
+// AgentComplete events. Monitor is safe for concurrent use.
+type Monitor struct {
+	mu       sync.Mutex
+	queues   map[string]*Queue
+	onChange []func(Change)
+	now      func() time.Time
+}
+
+// New creates empty monitor
+func New() *Monitor {
+	return &Monitor{
+		queues: make(map[string]*Queue),
+		now:    time.Now,
+	}
+}
+
+// Attach registers monitor as AMI client event handler. Handler can be
+// removed with Client.RemoveHandler. Attach before Load to not miss events
+// sent while the state is loading.
+func (m *Monitor) Attach(c *goami2.Client) goami2.HandlerID {
+	return c.OnEvents(queueEvents, m.Handle)
+}
+
+// Load replaces monitor state with the result of QueueStatus and
+// QueueSummary actions
+func (m *Monitor) Load(ctx context.Context, c *goami2.Client) error {
+	status, err := c.SendActionList(ctx, goami2.NewAction("QueueStatus"))
+	if err != nil {
+		return err
+	}
+	summary, err := c.SendActionList(ctx, goami2.NewAction("QueueSummary"))
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues = make(map[string]*Queue)
+	for _, msg := range status {
+		m.load(msg)
+	}
+	for _, msg := range summary {
+		if strings.EqualFold(msg.Field("Event"), "QueueSummary") {
+			_ = msg.Decode(m.queue(msg.Field("Queue")))
+		}
+	}
+	return nil
+}
+
+// load applies QueueStatus list event. Must be called with locked mutex
+func (m *Monitor) load(msg *goami2.Message) {
+	name := msg.Field("Queue")
+	if name == "" {
+		return
+	}
+	switch strings.ToLower(msg.Field("Event")) {
+	case "queueparams":
+		_ = msg.Decode(m.queue(name))
+	case "queuemember":
+		q := m.queue(name)
+		q.Members = append(q.Members, decodeMember(msg))
+	case "queueentry":
+		q := m.queue(name)
+		caller := decodeCaller(msg)
+		if wait, ok := msg.FieldDuration("Wait"); ok {
+			caller.Joined = m.now().Add(-wait)
+		}
+		q.Callers = append(q.Callers, caller)
+		sort.SliceStable(q.Callers, func(i, j int) bool {
+			return q.Callers[i].Position < q.Callers[j].Position
+		})
+	}
+}
+
+// OnChange registers function called on every queue state change.
+// Function is called from Handle and must not call OnChange.
+func (m *Monitor) OnChange(fn func(Change)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = append(m.onChange, fn)
+}
+
+// Queues returns queues ordered by name
+func (m *Monitor) Queues() []Queue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queues := make([]Queue, 0, len(m.queues))
+	for _, q := range m.queues {
+		queues = append(queues, q.copy())
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+	return queues
+}
+
+// Queue returns queue by name
+func (m *Monitor) Queue(name string) (Queue, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queues[name]
+	if !ok {
+		return Queue{}, false
+	}
+	return q.copy(), true
+}
+
+// queue returns queue by name and creates it when queue is not
+// known yet. Must be called with locked mutex
+func (m *Monitor) queue(name string) *Queue {
+	q, ok := m.queues[name]
+	if !ok {
+		q = &Queue{Name: name}
+		m.queues[name] = q
+	}
+	return q
+}
+
+// copy returns queue with own members and callers slices
+func (q *Queue) copy() Queue {
+	cp := *q
+	cp.Members = append([]Member(nil), q.Members...)
+	cp.Callers = append([]Caller(nil), q.Callers...)
+	return cp
+}
+
+// decodeMember decodes member from QueueMember list event or from
+// member event. QueueMember event has "Name" and "Location" headers
+// in place of "MemberName" and "Interface".
+func decodeMember(msg *goami2.Message) Member {
+	var mem Member
+	_ = msg.Decode(&mem)
+	if mem.Name == "" {
+		mem.Name = msg.Field("Name")
+	}
+	if mem.Interface == "" {
+		mem.Interface = msg.Field("Location")
+	}
+	return mem
+}
+
+func decodeCaller(msg *goami2.Message) Caller {
+	var caller Caller
+	_ = msg.Decode(&caller)
+	return caller
+}
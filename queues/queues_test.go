@@ -0,0 +1,149 @@
+package queues
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+// serve accepts client login and replies to actions with packets
+// returned by reply for the action name and ActionID
+func serve(t *testing.T, reply func(action, id string) string) *goami2.Client {
+	connClient, connSrv := net.Pipe()
+	go func() {
+		r := bufio.NewReader(connSrv)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/2.10.4\r\n"))
+		for {
+			var packet strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				packet.WriteString(line)
+				if line == "\r\n" {
+					break
+				}
+			}
+			msg, err := goami2.Parse(packet.String())
+			if err != nil {
+				return
+			}
+			if msg.Field("Action") == "Login" {
+				_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+				continue
+			}
+			_, _ = connSrv.Write([]byte(reply(msg.Field("Action"), msg.ActionID())))
+		}
+	}()
+
+	cl, err := goami2.NewClientWithContext(context.Background(), connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	return cl
+}
+
+func TestMonitorLoad(t *testing.T) {
+	cl := serve(t, func(action, id string) string {
+		start := "Response: Success\r\nActionID: " + id + "\r\nEventList: start\r\n" +
+			"Message: Queue status will follow\r\n\r\n"
+		if action == "QueueSummary" {
+			return start +
+				"Event: QueueSummary\r\nActionID: " + id + "\r\nQueue: sales\r\nLoggedIn: 2\r\n" +
+				"Available: 1\r\nCallers: 2\r\nHoldTime: 12\r\nTalkTime: 40\r\nLongestHoldTime: 30\r\n\r\n" +
+				"Event: QueueSummaryComplete\r\nActionID: " + id + "\r\nEventList: Complete\r\n\r\n"
+		}
+		return start +
+			"Event: QueueParams\r\nActionID: " + id + "\r\nQueue: sales\r\nMax: 0\r\n" +
+			"Strategy: ringall\r\nCalls: 2\r\nHoldtime: 10\r\nTalkTime: 40\r\nCompleted: 5\r\n" +
+			"Abandoned: 1\r\nServiceLevel: 60\r\nServicelevelPerf: 80.0\r\nWeight: 0\r\n\r\n" +
+			"Event: QueueMember\r\nActionID: " + id + "\r\nQueue: sales\r\nName: Alice\r\n" +
+			"Location: PJSIP/100\r\nStateInterface: PJSIP/100\r\nMembership: static\r\n" +
+			"Penalty: 0\r\nCallsTaken: 3\r\nLastCall: 1598887681\r\nInCall: 1\r\nStatus: 2\r\n" +
+			"Paused: 0\r\n\r\n" +
+			"Event: QueueMember\r\nActionID: " + id + "\r\nQueue: sales\r\nName: Bob\r\n" +
+			"Location: PJSIP/200\r\nMembership: dynamic\r\nStatus: 1\r\nPaused: 1\r\n" +
+			"PausedReason: lunch\r\n\r\n" +
+			"Event: QueueEntry\r\nActionID: " + id + "\r\nQueue: sales\r\nPosition: 2\r\n" +
+			"Channel: PJSIP/300-02\r\nUniqueid: 1598887690.71\r\nCallerIDNum: 300\r\nWait: 5\r\n\r\n" +
+			"Event: QueueEntry\r\nActionID: " + id + "\r\nQueue: sales\r\nPosition: 1\r\n" +
+			"Channel: PJSIP/300-01\r\nUniqueid: 1598887690.70\r\nCallerIDNum: 300\r\nWait: 30\r\n\r\n" +
+			"Event: QueueParams\r\nActionID: " + id + "\r\nQueue: support\r\nStrategy: rrmemory\r\n\r\n" +
+			"Event: QueueStatusComplete\r\nActionID: " + id + "\r\nEventList: Complete\r\n\r\n"
+	})
+	defer cl.Close()
+
+	mon := New()
+	now := time.Date(2020, 8, 31, 12, 0, 0, 0, time.UTC)
+	mon.now = func() time.Time { return now }
+	assert.Nil(t, mon.Load(context.Background(), cl))
+
+	queues := mon.Queues()
+	assert.Len(t, queues, 2)
+	assert.Equal(t, "sales", queues[0].Name)
+	assert.Equal(t, "support", queues[1].Name)
+
+	q, ok := mon.Queue("sales")
+	assert.True(t, ok)
+	assert.Equal(t, "ringall", q.Strategy)
+	assert.Equal(t, 2, q.Calls)
+	assert.Equal(t, 12*time.Second, q.Holdtime)
+	assert.Equal(t, 5, q.Completed)
+	assert.Equal(t, time.Minute, q.ServiceLevel)
+	assert.Equal(t, 80.0, q.ServicelevelPerf)
+	assert.Equal(t, 2, q.LoggedIn)
+	assert.Equal(t, 1, q.Available)
+	assert.Equal(t, 30*time.Second, q.LongestHoldTime)
+
+	assert.Len(t, q.Members, 2)
+	assert.Equal(t, "Alice", q.Members[0].Name)
+	assert.Equal(t, "PJSIP/100", q.Members[0].Interface)
+	assert.Equal(t, 3, q.Members[0].CallsTaken)
+	assert.Equal(t, int64(1598887681), q.Members[0].LastCall)
+	assert.True(t, q.Members[0].InCall)
+	assert.True(t, q.Members[1].Paused)
+	assert.Equal(t, "lunch", q.Members[1].PausedReason)
+
+	assert.Len(t, q.Callers, 2)
+	assert.Equal(t, "1598887690.70", q.Callers[0].Uniqueid)
+	assert.Equal(t, 1, q.Callers[0].Position)
+	assert.Equal(t, now.Add(-30*time.Second), q.Callers[0].Joined)
+	assert.Equal(t, "PJSIP/300-02", q.Callers[1].Channel)
+
+	_, ok = mon.Queue("unknown")
+	assert.False(t, ok)
+
+	// snapshot is a copy
+	q.Members[0].Name = "Eve"
+	q, _ = mon.Queue("sales")
+	assert.Equal(t, "Alice", q.Members[0].Name)
+}
+
+func TestMonitorLoadFails(t *testing.T) {
+	cl := serve(t, func(action, id string) string {
+		return "Response: Error\r\nActionID: " + id + "\r\nMessage: Permission denied\r\n\r\n"
+	})
+	defer cl.Close()
+
+	err := New().Load(context.Background(), cl)
+	assert.ErrorIs(t, err, goami2.ErrAMI)
+}
+
+func TestChangeKindString(t *testing.T) {
+	assert.Equal(t, "member added", MemberAdded.String())
+	assert.Equal(t, "caller left", CallerLeft.String())
+	assert.Equal(t, "agent connected", AgentConnected.String())
+	assert.Equal(t, "agent completed", AgentCompleted.String())
+	assert.Equal(t, "unknown", ChangeKind(42).String())
+}
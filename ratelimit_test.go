@@ -0,0 +1,80 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("burst then rate", func(t *testing.T) {
+		l := newLimiter(100, 3)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, time.Duration(0), l.reserve())
+		}
+		d := l.reserve()
+		assert.InDelta(t, 10*time.Millisecond, d, float64(time.Millisecond))
+	})
+
+	t.Run("wait respects context", func(t *testing.T) {
+		l := newLimiter(1, 1)
+		assert.Nil(t, l.wait(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, l.wait(ctx), context.DeadlineExceeded)
+		// canceled reservation returns the token
+		assert.InDelta(t, 0, l.tokens, 0.1)
+	})
+
+	t.Run("wait for token", func(t *testing.T) {
+		l := newLimiter(200, 1)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, l.wait(context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 9*time.Millisecond)
+	})
+}
+
+func TestClientRateLimit(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithRateLimit(1, 1)(cl)
+	cl.timeout = 10 * time.Millisecond
+	go cl.loop(context.Background())
+	defer cl.Close()
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			_, _ = connSrv.Write([]byte("Response: Success\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	assert.Nil(t, cl.MustSend(NewAction("Ping").Byte()))
+	err := cl.MustSend(NewAction("Ping").Byte())
+	assert.ErrorIs(t, err, ErrConn)
+	assert.ErrorContains(t, err, "rate limit")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err = cl.SendAction(ctx, NewAction("Ping"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// keepalive ping is written without waiting for the token
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	resp, err := cl.request(withoutRateLimit(ctx), NewAction("Ping"))
+	assert.Nil(t, err)
+	assert.True(t, resp.IsSuccess())
+
+	WithRateLimit(0, 0)(cl)
+	assert.Nil(t, cl.limiter)
+}
@@ -0,0 +1,78 @@
+package goami2
+
+import "time"
+
+// replayBuffer keeps recent events for late subscribers
+type replayBuffer struct {
+	size   int           // max number of events
+	age    time.Duration // max age of events, no limit when zero
+	events []replayEvent
+}
+
+type replayEvent struct {
+	at  time.Time
+	msg *Message
+}
+
+// add event to the buffer dropping the oldest event when buffer is full
+func (b *replayBuffer) add(msg *Message, now time.Time) {
+	if len(b.events) == b.size {
+		b.events = b.events[1:]
+	}
+	b.events = append(b.events, replayEvent{at: now, msg: msg})
+}
+
+// recent returns events that are not older than buffer age
+func (b *replayBuffer) recent(now time.Time) []*Message {
+	if b.age > 0 {
+		i := 0
+		for i < len(b.events) && now.Sub(b.events[i].at) > b.age {
+			i++
+		}
+		b.events = b.events[i:]
+	}
+	msgs := make([]*Message, 0, len(b.events))
+	for _, ev := range b.events {
+		msgs = append(msgs, ev.msg)
+	}
+	return msgs
+}
+
+// SubscribeReplay works as Subscribe and first delivers matching events of
+// the replay buffer, so subscriber created after connect catches up on recent
+// events, for example the burst of events following login. Replayed events
+// are followed by new events without gaps or duplicates. Replay buffer is
+// enabled with WithReplayBuffer, otherwise SubscribeReplay is the same as
+// Subscribe.
+func (c *Client) SubscribeReplay(eventNames ...string) <-chan *Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &subscription{events: eventNames, overflow: OverflowDropNewest}
+	var replayed []*Message
+	if c.replay != nil {
+		for _, msg := range c.replay.recent(time.Now()) {
+			if sub.match(msg, c.version) {
+				replayed = append(replayed, msg)
+			}
+		}
+	}
+	sub.ch = make(chan *Message, chanBuffer+len(replayed))
+	if c.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	for _, msg := range replayed {
+		sub.ch <- msg.Clone()
+	}
+	if c.subs == nil {
+		c.subs = make(map[<-chan *Message]*subscription)
+	}
+	c.subs[sub.ch] = sub
+	return sub.ch
+}
+
+// OnEventsReplay works as OnEvents and first calls handler with matching
+// events of the replay buffer, see SubscribeReplay
+func (c *Client) OnEventsReplay(names []string, handler func(*Message)) HandlerID {
+	return c.addHandler(c.SubscribeReplay(names...), handler)
+}
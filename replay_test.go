@@ -0,0 +1,65 @@
+package goami2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	b := &replayBuffer{size: 2, age: time.Minute}
+	now := time.Now()
+	for _, name := range []string{"Newchannel", "Newstate", "Hangup"} {
+		msg := NewMessage()
+		msg.AddField("Event", name)
+		b.add(msg, now)
+		now = now.Add(40 * time.Second)
+	}
+	msgs := b.recent(now.Add(-40 * time.Second))
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, "Newstate", msgs[0].Field("Event"))
+
+	msgs = b.recent(now)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, "Hangup", msgs[0].Field("Event"))
+}
+
+func TestClientSubscribeReplay(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	defer connSrv.Close()
+	cl := makeClient(connClient)
+	WithReplayBuffer(3, 0)(cl)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\nResponse: Success\r\n\r\n" +
+		"Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\nEvent: Newchannel\r\nChannel: PJSIP/200-01\r\n\r\n" +
+		"Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+	for i := 0; i < 5; i++ {
+		<-cl.AllMessages()
+	}
+
+	// oldest event is pushed out
+	ch := cl.SubscribeReplay("Newchannel", "FullyBooted")
+	assert.Equal(t, "PJSIP/100-01", (<-ch).Field("Channel"))
+	assert.Equal(t, "PJSIP/200-01", (<-ch).Field("Channel"))
+
+	_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/300-01\r\n\r\n"))
+	assert.Equal(t, "PJSIP/300-01", (<-ch).Field("Channel"))
+
+	calls := make(chan string, 4)
+	cl.OnEventsReplay([]string{"Hangup"}, func(msg *Message) { calls <- msg.Field("Channel") })
+	assert.Equal(t, "PJSIP/100-01", <-calls)
+
+	t.Run("disabled", func(t *testing.T) {
+		cl := makeClient(nil)
+		WithReplayBuffer(10, time.Second)(cl)
+		WithReplayBuffer(0, time.Second)(cl)
+		assert.Nil(t, cl.replay)
+		ch := cl.SubscribeReplay()
+		assert.Equal(t, chanBuffer, cap(ch))
+	})
+}
@@ -0,0 +1,156 @@
+package goami2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema lists headers required in the events by event name, see
+// WithSchemaValidation. Event and header names are case insensitive.
+type Schema map[string][]string
+
+// Diagnostic reports event that does not match the schema
+type Diagnostic struct {
+	Event   *Message
+	Unknown bool     // event is not in the schema
+	Missing []string // required headers missing in the event
+}
+
+func (d Diagnostic) String() string {
+	if d.Unknown {
+		return fmt.Sprintf("unknown event %q", d.Event.Field("Event"))
+	}
+	return fmt.Sprintf("event %q is missing headers %s", d.Event.Field("Event"), strings.Join(d.Missing, ", "))
+}
+
+// channel snapshot headers of AMI 2.0 events
+var snapshotHeaders = []string{"Channel", "ChannelState", "ChannelStateDesc", "CallerIDNum",
+	"CallerIDName", "Context", "Exten", "Priority", "Uniqueid", "Linkedid"}
+
+// Cdr event headers of all AMI versions
+var cdrHeaders = []string{"Source", "Destination", "DestinationContext", "Channel",
+	"Disposition", "Duration", "BillableSeconds", "UniqueID"}
+
+// bundled schema of AMI 2.0 and newer
+var defaultSchema = Schema{
+	"Newchannel":         snapshotHeaders,
+	"Newstate":           snapshotHeaders,
+	"Hangup":             {"Channel", "Uniqueid", "Linkedid", "Cause", "Cause-txt"},
+	"DialBegin":          {"DestChannel", "DestUniqueid", "DialString"},
+	"DialEnd":            {"DestChannel", "DestUniqueid", "DialStatus"},
+	"BridgeCreate":       {"BridgeUniqueid", "BridgeType"},
+	"BridgeDestroy":      {"BridgeUniqueid"},
+	"BridgeEnter":        {"BridgeUniqueid", "BridgeType", "Channel", "Uniqueid"},
+	"BridgeLeave":        {"BridgeUniqueid", "Channel", "Uniqueid"},
+	"VarSet":             {"Channel", "Uniqueid", "Variable", "Value"},
+	"Cdr":                cdrHeaders,
+	"QueueCallerJoin":    {"Queue", "Position", "Count", "Channel", "Uniqueid"},
+	"QueueCallerLeave":   {"Queue", "Position", "Count", "Channel", "Uniqueid"},
+	"QueueCallerAbandon": {"Queue", "Position", "OriginalPosition", "HoldTime", "Channel", "Uniqueid"},
+	"QueueMemberStatus":  {"Queue", "MemberName", "Interface", "Status", "Paused"},
+	"AgentConnect":       {"Queue", "Interface", "HoldTime", "Channel", "Uniqueid"},
+	"AgentComplete":      {"Queue", "Interface", "HoldTime", "TalkTime", "Reason", "Channel", "Uniqueid"},
+	"DeviceStateChange":  {"Device", "State"},
+	"ExtensionStatus":    {"Exten", "Context", "Hint", "Status"},
+	"PeerStatus":         {"ChannelType", "Peer", "PeerStatus"},
+	"ContactStatus":      {"URI", "ContactStatus", "AOR"},
+	"FullyBooted":        {"Status"},
+	"Shutdown":           {"Shutdown"},
+}
+
+// bundled schema of AMI 1.x
+var legacySchema = Schema{
+	"Newchannel":      {"Channel", "ChannelState", "ChannelStateDesc", "CallerIDNum", "Uniqueid"},
+	"Newstate":        {"Channel", "ChannelState", "ChannelStateDesc", "Uniqueid"},
+	"Hangup":          {"Channel", "Uniqueid", "Cause", "Cause-txt"},
+	"Dial":            {"SubEvent", "Channel"},
+	"Join":            {"Queue", "Position", "Count", "Channel", "Uniqueid"},
+	"Leave":           {"Queue", "Count", "Channel", "Uniqueid"},
+	"Bridge":          {"Bridgestate", "Channel1", "Channel2"},
+	"VarSet":          {"Channel", "Variable", "Value", "Uniqueid"},
+	"Cdr":             cdrHeaders,
+	"PeerStatus":      {"ChannelType", "Peer", "PeerStatus"},
+	"ExtensionStatus": {"Exten", "Context", "Status"},
+	"FullyBooted":     {"Status"},
+	"Shutdown":        {"Shutdown"},
+}
+
+// DefaultSchema returns bundled schema of common events for the AMI
+// version. Schema of AMI 2.0 is returned for unknown version.
+func DefaultSchema(v AMIVersion) Schema {
+	src := defaultSchema
+	if !v.IsZero() && v.Major < 2 {
+		src = legacySchema
+	}
+	schema := make(Schema, len(src))
+	for name, headers := range src {
+		schema[name] = append([]string(nil), headers...)
+	}
+	return schema
+}
+
+// normalize returns schema with lower case event names
+func (s Schema) normalize() Schema {
+	norm := make(Schema, len(s))
+	for name, headers := range s {
+		norm[strings.ToLower(name)] = headers
+	}
+	return norm
+}
+
+// validate returns diagnostic of the event that does not match the schema.
+// Events missing in the schema are reported when unknown is true.
+func (s Schema) validate(msg *Message, unknown bool) (Diagnostic, bool) {
+	headers, ok := s[strings.ToLower(msg.Field("Event"))]
+	if !ok {
+		return Diagnostic{Event: msg, Unknown: true}, unknown
+	}
+	var missing []string
+	for _, h := range headers {
+		if len(msg.FieldValues(h)) == 0 {
+			missing = append(missing, h)
+		}
+	}
+	return Diagnostic{Event: msg, Missing: missing}, len(missing) > 0
+}
+
+// Diagnostics returns channel of events that do not match the schema, see
+// WithSchemaValidation. Channel is buffered and diagnostics are dropped when
+// it is full. Channel is nil when validation is not enabled and is closed
+// with Close.
+func (c *Client) Diagnostics() <-chan Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.diag
+}
+
+// checkSchema validates inbound event and sends diagnostic when it does not
+// match the schema. Bundled schema is selected by AMI version of the current
+// connection.
+func (c *Client) checkSchema(msg *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.diag == nil || !msg.IsEvent() {
+		return
+	}
+	schema := c.schema
+	if schema == nil {
+		if c.versionSchema == nil || c.schemaVersion != c.version {
+			c.versionSchema = DefaultSchema(c.version).normalize()
+			c.schemaVersion = c.version
+		}
+		schema = c.versionSchema
+	}
+	diag, ok := schema.validate(msg, c.schemaUnknown)
+	if !ok {
+		return
+	}
+	if !diag.Unknown {
+		c.logger.Warn("event does not match schema", "event", msg.Field("Event"), "missing", diag.Missing)
+	}
+	diag.Event = msg.Clone()
+	select {
+	case c.diag <- diag:
+	default:
+	}
+}
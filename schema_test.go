@@ -0,0 +1,70 @@
+package goami2_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSchemaValidation(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd", goami2.WithSchemaValidation(nil, false))
+	assert.Nil(t, err)
+	defer cl.Close()
+	diags := cl.Diagnostics()
+
+	ami.Emit(
+		goami2test.Event("Hangup", "Channel", "PJSIP/100-01", "Uniqueid", "1598887690.70",
+			"Linkedid", "1598887690.70", "Cause", "16", "Cause-txt", "Normal Clearing"),
+		goami2test.Event("UserEvent", "UserEvent", "custom"),
+		// renamed "Cause-txt" header
+		goami2test.Event("HANGUP", "Channel", "PJSIP/100-02", "Uniqueid", "1598887690.71",
+			"Linkedid", "1598887690.71", "Cause", "16", "CauseText", "Normal Clearing"),
+	)
+	select {
+	case diag := <-diags:
+		assert.False(t, diag.Unknown)
+		assert.Equal(t, []string{"Cause-txt"}, diag.Missing)
+		assert.Equal(t, "PJSIP/100-02", diag.Event.Field("Channel"))
+		assert.Equal(t, `event "HANGUP" is missing headers Cause-txt`, diag.String())
+	case <-time.After(time.Second):
+		t.Fatal("no diagnostic")
+	}
+	// events are delivered as usual
+	assert.Eventually(t, func() bool { return len(cl.AllMessages()) == 3 }, time.Second, time.Millisecond)
+
+	cl.Close()
+	_, ok := <-diags
+	assert.False(t, ok)
+}
+
+func TestClientSchemaValidationUnknown(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	schema := goami2.Schema{"MyEvent": {"Key"}}
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd", goami2.WithSchemaValidation(schema, true))
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	ami.Emit(goami2test.Event("MyEvent", "Key", ""), goami2test.Event("Hangup"))
+	diag := <-cl.Diagnostics()
+	assert.True(t, diag.Unknown)
+	assert.Equal(t, `unknown event "Hangup"`, diag.String())
+}
+
+func TestDefaultSchema(t *testing.T) {
+	schema := goami2.DefaultSchema(goami2.AMIVersion{Major: 9})
+	assert.Contains(t, schema["Newchannel"], "Linkedid")
+	assert.Contains(t, schema, "DialBegin")
+	schema["Newchannel"][0] = "changed"
+	assert.Equal(t, "Channel", goami2.DefaultSchema(goami2.AMIVersion{})["Newchannel"][0])
+
+	legacy := goami2.DefaultSchema(goami2.AMIVersion{Major: 1, Minor: 1})
+	assert.NotContains(t, legacy["Newchannel"], "Linkedid")
+	assert.NotContains(t, legacy, "DialBegin")
+	assert.Contains(t, legacy, "Dial")
+}
@@ -0,0 +1,102 @@
+package goami2
+
+import "time"
+
+// ConnState is the client connection state
+type ConnState int32
+
+const (
+	// StateConnecting client is connecting and login into AMI server
+	StateConnecting ConnState = iota
+	// StateConnected client is logged in and reads messages
+	StateConnected
+	// StateReconnecting connection is lost and client is reconnecting
+	StateReconnecting
+	// StateClosed client is closed or connection is terminated
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// State returns current connection state of the client
+func (c *Client) State() ConnState {
+	return ConnState(c.state.Load())
+}
+
+// LastActivity returns time of the last message received from AMI server.
+// Returns zero time if nothing is received yet.
+func (c *Client) LastActivity() time.Time {
+	n := c.lastActivity.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// StateChange is the notification of the client connection state change
+type StateChange struct {
+	From ConnState
+	To   ConnState
+	Err  error // connection error which caused the change, if any
+	Time time.Time
+}
+
+// StateChanges returns a channel that receives connection state changes, so
+// application knows when events stop flowing and when client is logged in
+// again after reconnect. Channel is buffered and changes are dropped when
+// receiver is too slow to read them. Channel is closed after the change to
+// StateClosed, or right away when client is already closed.
+func (c *Client) StateChanges() <-chan StateChange {
+	ch := make(chan StateChange, chanBuffer)
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if c.State() == StateClosed {
+		close(ch)
+		return ch
+	}
+	c.stateSubs = append(c.stateSubs, ch)
+	return ch
+}
+
+// setState changes client state and notifies state changes subscribers.
+// Closed state is final
+func (c *Client) setState(s ConnState, err error) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	old := c.State()
+	if old == StateClosed || old == s {
+		return
+	}
+	c.state.Store(int32(s))
+
+	change := StateChange{From: old, To: s, Err: err, Time: time.Now()}
+	for _, ch := range c.stateSubs {
+		select {
+		case ch <- change:
+		default:
+			// subscriber is too slow, drop the change
+		}
+		if s == StateClosed {
+			close(ch)
+		}
+	}
+	if s == StateClosed {
+		c.stateSubs = nil
+	}
+}
+
+func (c *Client) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
@@ -0,0 +1,142 @@
+package goami2
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnStateString(t *testing.T) {
+	assert.Equal(t, "connecting", StateConnecting.String())
+	assert.Equal(t, "connected", StateConnected.String())
+	assert.Equal(t, "reconnecting", StateReconnecting.String())
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "unknown", ConnState(42).String())
+}
+
+func TestClientState(t *testing.T) {
+	t.Run("connected and closed", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		assert.Equal(t, StateConnecting, cl.State())
+		assert.True(t, cl.LastActivity().IsZero())
+
+		connSrvSess(connSrv, []string{
+			"Response: Success\r\nMessage: Authentication accepted\r\n\r\n",
+		})
+		assert.Nil(t, cl.login(context.Background(), "admin", "pa55w0rd"))
+		assert.Equal(t, StateConnected, cl.State())
+		loggedIn := cl.LastActivity()
+		assert.False(t, loggedIn.IsZero())
+
+		go cl.loop(context.Background())
+		time.Sleep(time.Millisecond)
+		go func() { _, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n")) }()
+		<-cl.AllMessages()
+		assert.True(t, cl.LastActivity().After(loggedIn))
+
+		cl.Close()
+		assert.Equal(t, StateClosed, cl.State())
+		cl.setState(StateConnected, nil)
+		assert.Equal(t, StateClosed, cl.State())
+	})
+
+	t.Run("closed when connection terminated", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		cl, err := NewClient(connClient, "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+		assert.Equal(t, StateConnected, cl.State())
+
+		_ = connSrv.Close()
+		<-cl.Err()
+		assert.Eventually(t, func() bool { return cl.State() == StateClosed },
+			time.Second, time.Millisecond)
+	})
+
+	t.Run("reconnecting", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		withDial := func(c *Client) {
+			c.dial = func(context.Context) (net.Conn, error) { return nil, ErrConn }
+		}
+		cl, err := NewClient(connClient, "admin", "pa55w0rd",
+			WithReconnect(0, 5*time.Millisecond), withDial)
+		assert.Nil(t, err)
+		defer cl.Close()
+
+		_ = connSrv.Close()
+		assert.Eventually(t, func() bool { return cl.State() == StateReconnecting },
+			time.Second, time.Millisecond)
+	})
+}
+
+func TestClientStateChanges(t *testing.T) {
+	next := func(t *testing.T, ch <-chan StateChange) StateChange {
+		select {
+		case change := <-ch:
+			return change
+		case <-time.After(time.Second):
+			t.Fatal("no state change")
+		}
+		return StateChange{}
+	}
+
+	t.Run("reconnect and close", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		WithReconnect(3, time.Millisecond)(cl)
+		cl.dial = func(context.Context) (net.Conn, error) {
+			conn, srv := net.Pipe()
+			connSrvSess(srv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+			return conn, nil
+		}
+		changes := cl.StateChanges()
+
+		connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		assert.Nil(t, cl.login(context.Background(), "admin", "pa55w0rd"))
+		go cl.loop(context.Background())
+
+		change := next(t, changes)
+		assert.Equal(t, StateConnecting, change.From)
+		assert.Equal(t, StateConnected, change.To)
+		assert.Nil(t, change.Err)
+		assert.False(t, change.Time.IsZero())
+
+		_ = connSrv.Close()
+		change = next(t, changes)
+		assert.Equal(t, StateReconnecting, change.To)
+		assert.ErrorIs(t, change.Err, ErrEOF)
+		change = next(t, changes)
+		assert.Equal(t, StateReconnecting, change.From)
+		assert.Equal(t, StateConnected, change.To)
+
+		cl.Close()
+		change = next(t, changes)
+		assert.Equal(t, StateClosed, change.To)
+		_, ok := <-changes
+		assert.False(t, ok)
+
+		_, ok = <-cl.StateChanges()
+		assert.False(t, ok)
+	})
+
+	t.Run("closed with connection error", func(t *testing.T) {
+		connClient, connSrv := net.Pipe()
+		connSrvSess(connSrv, []string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+		cl, err := NewClient(connClient, "admin", "pa55w0rd")
+		assert.Nil(t, err)
+		defer cl.Close()
+		changes := cl.StateChanges()
+
+		_ = connSrv.Close()
+		change := next(t, changes)
+		assert.Equal(t, StateConnected, change.From)
+		assert.Equal(t, StateClosed, change.To)
+		assert.ErrorIs(t, change.Err, ErrEOF)
+	})
+}
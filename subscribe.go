@@ -0,0 +1,225 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// subscription to the AMI events by names or predicate
+type subscription struct {
+	ch       chan *Message
+	events   []string
+	filter   func(*Message) bool
+	passive  bool // does not claim events from the AllMessages channel
+	first    bool // waits for the first event only, later events are not counted as dropped
+	all      bool // receives responses too
+	overflow OverflowPolicy
+}
+
+// Subscription is an independent consumer of the client messages, see
+// Client.SubscribeAll
+type Subscription struct {
+	client *Client
+	ch     <-chan *Message
+}
+
+// Messages returns the subscription channel. Channel is closed with Cancel
+// or when client is closed.
+func (s *Subscription) Messages() <-chan *Message {
+	return s.ch
+}
+
+// Cancel removes subscription and closes its channel
+func (s *Subscription) Cancel() {
+	s.client.Unsubscribe(s.ch)
+}
+
+// SubscribeAll creates subscription that receives every event and every
+// response that is not a reply to SendAction and other waiting actions.
+// Several packages in the same process can have their own subscriptions
+// without taking messages from each other: subscription does not claim
+// messages from the AllMessages channel or other subscribers and every
+// subscriber receives its own copy of the message. Channel buffer size and
+// overflow policy are the same as with Subscribe.
+func (c *Client) SubscribeAll() *Subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &subscription{
+		ch:       make(chan *Message, chanBuffer),
+		filter:   func(*Message) bool { return true },
+		passive:  true,
+		all:      true,
+		overflow: OverflowDropNewest,
+	}
+	if c.closed {
+		close(sub.ch)
+	} else {
+		if c.subs == nil {
+			c.subs = make(map[<-chan *Message]*subscription)
+		}
+		c.subs[sub.ch] = sub
+	}
+	return &Subscription{client: c, ch: sub.ch}
+}
+
+// Subscribe returns a channel that receives only events which names match one
+// of the given names. Names are case insensitive. When no names given the
+// channel receives all events. Each subscriber receives its own copy of the
+// message. Subscription channel is buffered and messages are dropped when
+// subscriber is too slow to read them, so it never blocks reading from the
+// connection. Events that match any subscription are not sent to the
+// AllMessages channel. With AMI 1.x servers events renamed in AMI 2.0 are
+// matched by their old names too, see AMIVersion.EventName. Channel is closed
+// with Unsubscribe or when client is closed.
+func (c *Client) Subscribe(eventNames ...string) <-chan *Message {
+	return c.SubscribeWithPolicy(OverflowDropNewest, chanBuffer, eventNames...)
+}
+
+// SubscribeWithPolicy works as Subscribe with the channel buffer size and the
+// policy for the events when buffer is full. OverflowDropOldest keeps size of
+// the most recent events, OverflowBlock waits for the subscriber shortly, same
+// as for the messages channel. Blocking subscriber delays the reading loop.
+func (c *Client) SubscribeWithPolicy(policy OverflowPolicy, size int, eventNames ...string) <-chan *Message {
+	if size < 0 {
+		size = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sub := &subscription{
+		ch:       make(chan *Message, size),
+		events:   eventNames,
+		overflow: policy,
+	}
+	if c.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	if c.subs == nil {
+		c.subs = make(map[<-chan *Message]*subscription)
+	}
+	c.subs[sub.ch] = sub
+	return sub.ch
+}
+
+// WaitEvent blocks until event for which match returns true is received and
+// returns it. Returns context error when context is done and ErrEOF when client
+// is closed or connection is terminated. WaitEvent does not take events from
+// the AllMessages channel or subscribers and several calls can wait at the same
+// time. Function match is called from the reading loop and must be fast.
+func (c *Client) WaitEvent(ctx context.Context, match func(*Message) bool) (*Message, error) {
+	ch, err := c.watchEvent(match)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Unsubscribe(ch)
+	return c.awaitEvent(ctx, ch)
+}
+
+// SendAndWaitEvent sends action and waits for the event, for which match
+// returns true, that results from the action. For example, wait for
+// "Event: QueueMemberPause" after "Action: QueuePause". Events are watched
+// before the action is sent, so the event received before the response is
+// not missed. Returns ProtocolError when action is rejected. Waiting for the
+// event is bound by the context, same as WaitEvent.
+func (c *Client) SendAndWaitEvent(ctx context.Context, action *Message, match func(*Message) bool) (*Message, error) {
+	ch, err := c.watchEvent(match)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Unsubscribe(ch)
+
+	resp, err := c.SendAction(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, rejected(resp, "action failed")
+	}
+	return c.awaitEvent(ctx, ch)
+}
+
+// watchEvent creates passive subscription that receives the first event
+// for which match returns true
+func (c *Client) watchEvent(match func(*Message) bool) (<-chan *Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	sub := &subscription{
+		ch:       make(chan *Message, 1),
+		filter:   match,
+		passive:  true,
+		first:    true,
+		overflow: OverflowDropNewest,
+	}
+	if c.subs == nil {
+		c.subs = make(map[<-chan *Message]*subscription)
+	}
+	c.subs[sub.ch] = sub
+	return sub.ch, nil
+}
+
+// awaitEvent waits for the event of the subscription created by watchEvent
+func (c *Client) awaitEvent(ctx context.Context, ch <-chan *Message) (*Message, error) {
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return msg, nil
+	case <-c.done:
+		return nil, fmt.Errorf("%w: connection terminated", ErrEOF)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Unsubscribe removes subscription and closes its channel
+func (c *Client) Unsubscribe(ch <-chan *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sub, ok := c.subs[ch]; ok {
+		close(sub.ch)
+		delete(c.subs, ch)
+	}
+}
+
+// publish event to all matching subscribers. Returns true when the event
+// matches at least one subscription. Must be called with locked mutex
+func (c *Client) publish(msg *Message) bool {
+	event := msg.IsEvent()
+	claimed := false
+	for _, sub := range c.subs {
+		if (!event && !sub.all) || !sub.match(msg, c.version) {
+			continue
+		}
+		claimed = claimed || !sub.passive
+		// messages are dropped when subscriber is too slow
+		for _, dropped := range offer(sub.ch, msg.Clone(), sub.overflow) {
+			if !sub.first {
+				c.drop(dropped, "subscriber is too slow")
+			}
+		}
+	}
+	return claimed
+}
+
+// match returns true if subscription receives the message. Event names
+// renamed in AMI 2.0 also match their AMI 1.x names on old servers.
+func (s *subscription) match(msg *Message, v AMIVersion) bool {
+	if s.filter != nil {
+		return s.filter(msg)
+	}
+	if len(s.events) == 0 {
+		return true
+	}
+	name := msg.Field("Event")
+	for _, ev := range s.events {
+		if strings.EqualFold(ev, name) || strings.EqualFold(v.EventName(ev), name) {
+			return true
+		}
+	}
+	return false
+}
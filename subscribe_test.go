@@ -0,0 +1,334 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientSubscribe(t *testing.T) {
+	setup := func() (net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		return connSrv, cl
+	}
+
+	t.Run("receive only subscribed events", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		chCall := cl.Subscribe("newchannel", "Hangup")
+		chAll := cl.Subscribe()
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Response: Success\r\nPing: Pong\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: PeerStatus\r\nPeer: PJSIP/100\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+
+		msg := <-chCall
+		assert.Equal(t, "Newchannel", msg.Field("Event"))
+		msg = <-chCall
+		assert.Equal(t, "Hangup", msg.Field("Event"))
+
+		for _, want := range []string{"Newchannel", "PeerStatus", "Hangup"} {
+			msg = <-chAll
+			assert.Equal(t, want, msg.Field("Event"))
+		}
+	})
+
+	t.Run("subscribers get own copy", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		ch1 := cl.Subscribe("Hangup")
+		ch2 := cl.Subscribe("Hangup")
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+
+		msg1 := <-ch1
+		msg2 := <-ch2
+		msg1.SetField("Channel", "foo")
+		assert.Equal(t, "PJSIP/100-01", msg2.Field("Channel"))
+	})
+
+	t.Run("subscribed events are not sent to all messages", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		ch := cl.Subscribe("Hangup")
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: PeerStatus\r\nPeer: PJSIP/100\r\n\r\n"))
+		}()
+
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "PeerStatus", msg.Field("Event"))
+		msg = <-ch
+		assert.Equal(t, "Hangup", msg.Field("Event"))
+	})
+
+	t.Run("slow subscriber does not block loop", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		_ = cl.Subscribe("Hangup") // never read
+
+		count := chanBuffer * 10
+		start := time.Now()
+		go func() {
+			for i := 0; i < count; i++ {
+				_, _ = srv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			}
+			_, _ = srv.Write([]byte("Event: PeerStatus\r\nPeer: PJSIP/100\r\n\r\n"))
+		}()
+		msg := <-cl.AllMessages()
+		assert.Equal(t, "PeerStatus", msg.Field("Event"))
+		// far less than giving up on a full channel for every message
+		assert.Less(t, time.Since(start), time.Duration(count)*chanGiveup/2)
+	})
+
+	t.Run("unsubscribe closes channel", func(t *testing.T) {
+		_, cl := setup()
+		defer cl.Close()
+		ch := cl.Subscribe("Hangup")
+		cl.Unsubscribe(ch)
+		_, ok := <-ch
+		assert.False(t, ok)
+		assert.NotPanics(t, func() { cl.Unsubscribe(ch) })
+	})
+
+	t.Run("close client closes subscriptions", func(t *testing.T) {
+		_, cl := setup()
+		ch1 := cl.Subscribe("Hangup")
+		ch2 := cl.Subscribe()
+		cl.Close()
+		_, ok := <-ch1
+		assert.False(t, ok)
+		_, ok = <-ch2
+		assert.False(t, ok)
+
+		ch3 := cl.Subscribe()
+		_, ok = <-ch3
+		assert.False(t, ok)
+	})
+}
+
+func TestClientWaitEvent(t *testing.T) {
+	setup := func() (net.Conn, *Client) {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		return connSrv, cl
+	}
+	bridgeEnter := func(channel string) func(*Message) bool {
+		return func(msg *Message) bool {
+			return msg.Field("Event") == "BridgeEnter" && msg.Field("Channel") == channel
+		}
+	}
+
+	t.Run("concurrent waits do not steal events", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		chSub := cl.Subscribe("BridgeEnter")
+
+		results := make(chan *Message, 2)
+		for _, channel := range []string{"PJSIP/100-01", "PJSIP/200-02"} {
+			go func(channel string) {
+				msg, err := cl.WaitEvent(context.Background(), bridgeEnter(channel))
+				assert.Nil(t, err)
+				results <- msg
+			}(channel)
+		}
+		assert.Eventually(t, func() bool {
+			cl.mu.Lock()
+			defer cl.mu.Unlock()
+			return len(cl.subs) == 3
+		}, time.Second, time.Millisecond)
+
+		go func() {
+			_, _ = srv.Write([]byte("Event: BridgeEnter\r\nChannel: PJSIP/200-02\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n"))
+			_, _ = srv.Write([]byte("Event: BridgeEnter\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		}()
+
+		got := map[string]bool{}
+		for i := 0; i < 2; i++ {
+			got[(<-results).Field("Channel")] = true
+		}
+		assert.Equal(t, map[string]bool{"PJSIP/100-01": true, "PJSIP/200-02": true}, got)
+		assert.Equal(t, "PJSIP/200-02", (<-chSub).Field("Channel"))
+		assert.Equal(t, "PJSIP/100-01", (<-chSub).Field("Channel"))
+		// not claimed by WaitEvent
+		assert.Equal(t, "Newchannel", (<-cl.AllMessages()).Field("Event"))
+
+		cl.mu.Lock()
+		assert.Len(t, cl.subs, 1)
+		cl.mu.Unlock()
+	})
+
+	t.Run("context timeout", func(t *testing.T) {
+		_, cl := setup()
+		defer cl.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := cl.WaitEvent(ctx, bridgeEnter("PJSIP/100-01"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("connection terminated", func(t *testing.T) {
+		srv, cl := setup()
+		defer cl.Close()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = srv.Close()
+		}()
+		_, err := cl.WaitEvent(context.Background(), bridgeEnter("PJSIP/100-01"))
+		assert.ErrorIs(t, err, ErrEOF)
+	})
+
+	t.Run("client closed", func(t *testing.T) {
+		_, cl := setup()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cl.Close()
+		}()
+		_, err := cl.WaitEvent(context.Background(), bridgeEnter("PJSIP/100-01"))
+		assert.ErrorIs(t, err, ErrEOF)
+		_, err = cl.WaitEvent(context.Background(), bridgeEnter("PJSIP/100-01"))
+		assert.ErrorIs(t, err, ErrEOF)
+	})
+}
+
+func TestClientSubscribeWithPolicy(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	oldest := cl.SubscribeWithPolicy(OverflowDropOldest, 2, "UserEvent")
+	newest := cl.SubscribeWithPolicy(OverflowDropNewest, 1, "UserEvent")
+	unbuffered := cl.SubscribeWithPolicy(OverflowDropOldest, -1, "UserEvent")
+	assert.Equal(t, 0, cap(unbuffered))
+
+	go func() {
+		for _, name := range []string{"one", "two", "three", "four"} {
+			_, _ = connSrv.Write([]byte("Event: UserEvent\r\nUserEvent: " + name + "\r\n\r\n"))
+		}
+		_, _ = connSrv.Write([]byte("Event: FullyBooted\r\n\r\n"))
+	}()
+	// events are dispatched in order
+	assert.Equal(t, "FullyBooted", (<-cl.AllMessages()).Field("Event"))
+
+	assert.Len(t, oldest, 2)
+	assert.Equal(t, "three", (<-oldest).Field("UserEvent"))
+	assert.Equal(t, "four", (<-oldest).Field("UserEvent"))
+	assert.Len(t, newest, 1)
+	assert.Equal(t, "one", (<-newest).Field("UserEvent"))
+	assert.Len(t, unbuffered, 0)
+	assert.Equal(t, uint64(2+3+4), cl.DroppedMessages())
+}
+
+func TestClientSubscribeAll(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+
+	sub1 := cl.SubscribeAll()
+	sub2 := cl.SubscribeAll()
+	chHangup := cl.Subscribe("Hangup")
+	go func() {
+		_, _ = connSrv.Write([]byte("Event: Newchannel\r\nChannel: PJSIP/100-01\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Response: Success\r\nPing: Pong\r\n\r\n"))
+		_, _ = connSrv.Write([]byte("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n"))
+	}()
+
+	for _, sub := range []*Subscription{sub1, sub2} {
+		assert.Equal(t, "Newchannel", (<-sub.Messages()).Field("Event"))
+		assert.Equal(t, "Pong", (<-sub.Messages()).Field("Ping"))
+		assert.Equal(t, "Hangup", (<-sub.Messages()).Field("Event"))
+	}
+	assert.Equal(t, "Hangup", (<-chHangup).Field("Event"))
+	assert.Equal(t, "Newchannel", (<-cl.AllMessages()).Field("Event"))
+	assert.Equal(t, "Pong", (<-cl.AllMessages()).Field("Ping"))
+
+	sub1.Cancel()
+	_, ok := <-sub1.Messages()
+	assert.False(t, ok)
+	sub1.Cancel()
+
+	cl.Close()
+	_, ok = <-sub2.Messages()
+	assert.False(t, ok)
+	_, ok = <-cl.SubscribeAll().Messages()
+	assert.False(t, ok)
+}
+
+func TestClientSendAndWaitEvent(t *testing.T) {
+	memberPause := func(msg *Message) bool {
+		return msg.Field("Event") == "QueueMemberPause" && msg.Field("Interface") == "PJSIP/100"
+	}
+	serve := func(reply func(id string) string) *Client {
+		connClient, connSrv := net.Pipe()
+		cl := makeClient(connClient)
+		go cl.loop(context.Background())
+		go func() {
+			r := bufio.NewReader(connSrv)
+			for {
+				action, err := srvReadAction(r)
+				if err != nil {
+					return
+				}
+				_, _ = connSrv.Write([]byte(reply(action.ActionID())))
+			}
+		}()
+		return cl
+	}
+
+	t.Run("event before response", func(t *testing.T) {
+		cl := serve(func(id string) string {
+			return "Event: QueueMemberPause\r\nQueue: sales\r\nInterface: PJSIP/200\r\n\r\n" +
+				"Event: QueueMemberPause\r\nQueue: sales\r\nInterface: PJSIP/100\r\nPaused: 1\r\n\r\n" +
+				"Response: Success\r\nActionID: " + id + "\r\nMessage: Interface paused successfully\r\n\r\n"
+		})
+		defer cl.Close()
+		action := NewAction("QueuePause")
+		action.AddField("Interface", "PJSIP/100")
+		action.AddField("Paused", "true")
+		ev, err := cl.SendAndWaitEvent(context.Background(), action, memberPause)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", ev.Field("Paused"))
+		// not claimed from the messages channel
+		assert.Equal(t, "PJSIP/200", (<-cl.AllMessages()).Field("Interface"))
+		cl.mu.Lock()
+		assert.Empty(t, cl.subs)
+		cl.mu.Unlock()
+	})
+
+	t.Run("action rejected", func(t *testing.T) {
+		cl := serve(func(id string) string {
+			return "Response: Error\r\nActionID: " + id + "\r\nMessage: Interface not found\r\n\r\n"
+		})
+		defer cl.Close()
+		_, err := cl.SendAndWaitEvent(context.Background(), NewAction("QueuePause"), memberPause)
+		var perr *ProtocolError
+		assert.ErrorAs(t, err, &perr)
+		assert.ErrorContains(t, err, "Interface not found")
+	})
+
+	t.Run("event timeout", func(t *testing.T) {
+		cl := serve(func(id string) string {
+			return "Response: Success\r\nActionID: " + id + "\r\n\r\n"
+		})
+		defer cl.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := cl.SendAndWaitEvent(ctx, NewAction("QueuePause"), memberPause)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
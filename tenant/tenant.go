@@ -0,0 +1,207 @@
+// Package tenant routes AMI events of multi-tenant PBX to per-tenant
+// subscriptions and trackers by channel name prefix, dialplan context and
+// account code rules.
+package tenant
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/staskobzar/goami2"
+)
+
+// subBuffer is the buffer size of the tenant subscriptions
+const subBuffer = 64
+
+// Unrouted is the tenant of events not matched by any rule
+const Unrouted = ""
+
+// event headers compared by the rules, including headers of the peer
+// channel of Dial and Cdr events
+var (
+	channelHeaders = []string{"Channel", "DestChannel", "DestinationChannel"}
+	contextHeaders = []string{"Context", "DestContext", "DestinationContext"}
+	accountHeaders = []string{"AccountCode", "DestAccountCode"}
+)
+
+// Rule assigns events to the tenant. Event matches the rule when it matches
+// all non-empty rule fields. Every field is compared with the headers of the
+// event channel and of the peer channel, so Dial, Bridge and Cdr events of
+// the calls between tenants are routed to both tenants.
+type Rule struct {
+	Tenant        string
+	ChannelPrefix string // prefix of the channel name, for example "PJSIP/acme-"
+	Context       string // dialplan context
+	AccountCode   string // channel account code
+}
+
+// empty returns true when rule has no conditions
+func (r Rule) empty() bool {
+	return r.ChannelPrefix == "" && r.Context == "" && r.AccountCode == ""
+}
+
+// Match reports if event matches the rule. Rule without conditions does not
+// match any event.
+func (r Rule) Match(msg *goami2.Message) bool {
+	if r.empty() {
+		return false
+	}
+	if r.ChannelPrefix != "" && !anyField(msg, channelHeaders, func(v string) bool {
+		return strings.HasPrefix(v, r.ChannelPrefix)
+	}) {
+		return false
+	}
+	if r.Context != "" && !anyField(msg, contextHeaders, func(v string) bool { return v == r.Context }) {
+		return false
+	}
+	if r.AccountCode != "" && !anyField(msg, accountHeaders, func(v string) bool { return v == r.AccountCode }) {
+		return false
+	}
+	return true
+}
+
+func anyField(msg *goami2.Message, headers []string, match func(string) bool) bool {
+	for _, h := range headers {
+		if v := msg.Field(h); v != "" && match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler is tenant scoped event consumer, for example calltracker.Tracker
+// or queues.Monitor
+type Handler interface {
+	Handle(msg *goami2.Message)
+}
+
+// Router delivers events to the subscriptions and handlers of the tenants
+// matched by the rules. Events not matched by any rule are delivered to the
+// Unrouted tenant. Router is safe for concurrent use.
+type Router struct {
+	mu       sync.Mutex
+	rules    []Rule
+	subs     map[string][]chan *goami2.Message
+	handlers map[string][]Handler
+	dropped  uint64
+}
+
+// New creates router with the rules
+func New(rules ...Rule) *Router {
+	return &Router{
+		rules:    append([]Rule(nil), rules...),
+		subs:     make(map[string][]chan *goami2.Message),
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// AddRule adds rule to the router
+func (r *Router) AddRule(rule Rule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(r.rules, rule)
+}
+
+// Attach registers router as AMI client observer of all events, so events
+// are still sent to Client.AllMessages and other subscribers. Handler can be
+// removed with Client.RemoveHandler.
+func (r *Router) Attach(cl *goami2.Client) goami2.HandlerID {
+	return cl.ObserveEvents(nil, r.Handle)
+}
+
+// Tenants returns sorted tenants of the event. Returns Unrouted tenant when
+// event is not matched by any rule.
+func (r *Router) Tenants(msg *goami2.Message) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tenants(msg)
+}
+
+func (r *Router) tenants(msg *goami2.Message) []string {
+	var tenants []string
+	for _, rule := range r.rules {
+		if !rule.Match(msg) || contains(tenants, rule.Tenant) {
+			continue
+		}
+		tenants = append(tenants, rule.Tenant)
+	}
+	if len(tenants) == 0 {
+		return []string{Unrouted}
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns channel of the tenant events. Channel is buffered and
+// events are dropped when reader is too slow, see Dropped. Channel is closed
+// with Unsubscribe.
+func (r *Router) Subscribe(tenant string) <-chan *goami2.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch := make(chan *goami2.Message, subBuffer)
+	r.subs[tenant] = append(r.subs[tenant], ch)
+	return ch
+}
+
+// Unsubscribe removes and closes the subscription channel
+func (r *Router) Unsubscribe(ch <-chan *goami2.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for tenant, subs := range r.subs {
+		for i, sub := range subs {
+			if sub == ch {
+				r.subs[tenant] = append(subs[:i:i], subs[i+1:]...)
+				close(sub)
+				return
+			}
+		}
+	}
+}
+
+// Track registers handler of the tenant events. Handlers are called from
+// Handle in order of registration and must not call router methods.
+func (r *Router) Track(tenant string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[tenant] = append(r.handlers[tenant], h)
+}
+
+// Dropped returns number of events dropped for slow subscribers
+func (r *Router) Dropped() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Handle delivers event to the subscriptions and handlers of the event
+// tenants. Every subscriber receives own copy of the event.
+func (r *Router) Handle(msg *goami2.Message) {
+	r.mu.Lock()
+	tenants := r.tenants(msg)
+	var handlers []Handler
+	for _, tenant := range tenants {
+		for _, ch := range r.subs[tenant] {
+			select {
+			case ch <- msg.Clone():
+			default:
+				r.dropped++
+			}
+		}
+		handlers = append(handlers, r.handlers[tenant]...)
+	}
+	r.mu.Unlock()
+
+	for _, h := range handlers {
+		h.Handle(msg)
+	}
+}
@@ -0,0 +1,169 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/staskobzar/goami2"
+	"github.com/staskobzar/goami2/calltracker"
+	"github.com/staskobzar/goami2/goami2test"
+	"github.com/stretchr/testify/assert"
+)
+
+func event(t *testing.T, packet string) *goami2.Message {
+	msg, err := goami2.Parse(packet + "\r\n")
+	assert.Nil(t, err)
+	return msg
+}
+
+func testRouter() *Router {
+	r := New(
+		Rule{Tenant: "acme", ChannelPrefix: "PJSIP/acme-"},
+		Rule{Tenant: "acme", Context: "acme-inbound"},
+		Rule{Tenant: "globex", AccountCode: "globex"},
+		Rule{Tenant: "ignored"},
+	)
+	r.AddRule(Rule{Tenant: "initech", ChannelPrefix: "PJSIP/initech-", Context: "initech"})
+	return r
+}
+
+func TestRuleMatch(t *testing.T) {
+	rule := Rule{Tenant: "acme", ChannelPrefix: "PJSIP/acme-", Context: "acme"}
+	assert.True(t, rule.Match(event(t, "Event: Newchannel\r\nChannel: PJSIP/acme-100-01\r\nContext: acme\r\n")))
+	assert.False(t, rule.Match(event(t, "Event: Newchannel\r\nChannel: PJSIP/acme-100-01\r\nContext: other\r\n")))
+	assert.False(t, rule.Match(event(t, "Event: Newchannel\r\nContext: acme\r\n")))
+	assert.True(t, rule.Match(event(t, "Event: DialBegin\r\nChannel: PJSIP/trunk-01\r\n"+
+		"DestChannel: PJSIP/acme-100-02\r\nDestContext: acme\r\n")))
+	assert.False(t, Rule{Tenant: "acme"}.Match(event(t, "Event: Newchannel\r\nChannel: PJSIP/acme-100-01\r\n")))
+}
+
+func TestRouterTenants(t *testing.T) {
+	r := testRouter()
+	tests := map[string]struct {
+		packet string
+		want   []string
+	}{
+		`channel prefix`: {"Event: Newchannel\r\nChannel: PJSIP/acme-100-01\r\nContext: acme-inbound\r\n", []string{"acme"}},
+		`account code`:   {"Event: Hangup\r\nChannel: PJSIP/trunk-01\r\nAccountCode: globex\r\n", []string{"globex"}},
+		`all fields`:     {"Event: Newstate\r\nChannel: PJSIP/initech-7-01\r\nContext: initech\r\n", []string{"initech"}},
+		`partial rule`:   {"Event: Newstate\r\nChannel: PJSIP/initech-7-01\r\nContext: from-trunk\r\n", []string{Unrouted}},
+		`both peers`: {"Event: DialBegin\r\nChannel: PJSIP/acme-100-01\r\nAccountCode: acme\r\n" +
+			"DestChannel: PJSIP/trunk-02\r\nDestAccountCode: globex\r\n", []string{"acme", "globex"}},
+		`unrouted`: {"Event: FullyBooted\r\nStatus: Fully Booted\r\n", []string{Unrouted}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.Tenants(event(t, tc.packet)))
+		})
+	}
+}
+
+func TestRouterSubscribe(t *testing.T) {
+	r := testRouter()
+	acme := r.Subscribe("acme")
+	unrouted := r.Subscribe(Unrouted)
+
+	msg := event(t, "Event: Newchannel\r\nChannel: PJSIP/acme-100-01\r\nUniqueid: 1.1\r\n")
+	r.Handle(msg)
+	r.Handle(event(t, "Event: FullyBooted\r\n"))
+	r.Handle(event(t, "Event: Newchannel\r\nChannel: PJSIP/trunk-01\r\nAccountCode: globex\r\n"))
+
+	got := <-acme
+	assert.Equal(t, "1.1", got.Field("Uniqueid"))
+	assert.NotSame(t, msg, got)
+	assert.Len(t, acme, 0)
+	assert.Equal(t, "FullyBooted", (<-unrouted).Field("Event"))
+	assert.Len(t, unrouted, 0)
+
+	t.Run("slow subscriber", func(t *testing.T) {
+		for i := 0; i < subBuffer+2; i++ {
+			r.Handle(msg)
+		}
+		assert.Len(t, acme, subBuffer)
+		assert.Equal(t, uint64(2), r.Dropped())
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		r.Unsubscribe(acme)
+		for range acme {
+		}
+		r.Handle(msg)
+		r.Unsubscribe(acme)
+	})
+}
+
+func TestRouterTrack(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	r := testRouter()
+	acme, globex := calltracker.New(), calltracker.New()
+	r.Track("acme", acme)
+	r.Track("globex", globex)
+	r.Attach(cl)
+
+	ami.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/acme-100-01", "Uniqueid", "1.1", "Linkedid", "1.1"))
+	ami.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/trunk-02", "Uniqueid", "1.2",
+		"Linkedid", "1.2", "AccountCode", "globex"))
+
+	assert.Eventually(t, func() bool {
+		return len(acme.Channels()) == 1 && len(globex.Channels()) == 1
+	}, time.Second, time.Millisecond)
+	_, ok := acme.Channel("1.1")
+	assert.True(t, ok)
+	_, ok = globex.Channel("1.1")
+	assert.False(t, ok)
+}
+
+func TestRouterAttachPassive(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	r := testRouter()
+	acme := calltracker.New()
+	r.Track("acme", acme)
+	r.Attach(cl)
+
+	ami.Emit(goami2test.Event("Newchannel", "Channel", "PJSIP/acme-100-01", "Uniqueid", "1.1", "Linkedid", "1.1"))
+	select {
+	case msg := <-cl.AllMessages():
+		assert.Equal(t, "Newchannel", msg.Field("Event"))
+	case <-time.After(time.Second):
+		t.Fatal("event is claimed by router")
+	}
+	assert.Eventually(t, func() bool { return len(acme.Channels()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestRouterAttachBurst(t *testing.T) {
+	ami := goami2test.NewServer()
+	defer ami.Close()
+	cl, err := goami2.NewClient(ami.Conn(), "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	r := testRouter()
+	acme := calltracker.New()
+	r.Track("acme", acme)
+	r.Attach(cl)
+	go func() {
+		for range cl.AllMessages() {
+		}
+	}()
+	const channels = 300
+	var burst strings.Builder
+	for i := 0; i < channels; i++ {
+		fmt.Fprintf(&burst, "Event: Newchannel\r\nChannel: PJSIP/acme-100-%08x\r\nUniqueid: 1.%d\r\nLinkedid: 1.%d\r\n\r\n", i, i, i)
+	}
+	ami.EmitRaw(burst.String())
+
+	assert.Eventually(t, func() bool { return len(acme.Channels()) == channels }, 5*time.Second, time.Millisecond)
+	assert.Zero(t, cl.DroppedMessages())
+}
@@ -0,0 +1,30 @@
+package goami2
+
+import "context"
+
+// Tracer traces actions that wait for the response, like SendAction,
+// SendActionList, client helpers and keepalive Ping. It allows to show AMI
+// actions in distributed traces, for example with OpenTelemetry:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) StartAction(ctx context.Context, action *goami2.Message) func(*goami2.Message, error) {
+//		_, span := t.tracer.Start(ctx, "AMI "+action.Field("Action"))
+//		return func(resp *goami2.Message, err error) {
+//			span.SetAttributes(attribute.String("ami.action_id", action.ActionID()))
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			} else {
+//				span.SetAttributes(attribute.String("ami.response", resp.Field("Response")))
+//			}
+//			span.End()
+//		}
+//	}
+type Tracer interface {
+	// StartAction is called with the caller context before action is sent.
+	// Returned function is called when the response is received or action
+	// fails. Action ActionID is final when returned function is called,
+	// since middleware can change it.
+	StartAction(ctx context.Context, action *Message) func(resp *Message, err error)
+}
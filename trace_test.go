@@ -0,0 +1,100 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxKey struct{}
+
+type span struct {
+	parent   any
+	action   string
+	actionID string
+	response string
+	err      error
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []span
+}
+
+func (t *testTracer) StartAction(ctx context.Context, action *Message) func(*Message, error) {
+	s := span{parent: ctx.Value(ctxKey{}), action: action.Field("Action")}
+	return func(resp *Message, err error) {
+		s.actionID = action.ActionID()
+		s.err = err
+		if resp != nil {
+			s.response = resp.Field("Response")
+		}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.spans = append(t.spans, s)
+	}
+}
+
+func TestClientTracer(t *testing.T) {
+	tracer := &testTracer{}
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	WithTracer(tracer)(cl)
+	cl.Use(func(next SendFunc) SendFunc {
+		return func(action *Message) error {
+			_ = action.SetField("ActionID", "traced-"+action.ActionID())
+			return next(action)
+		}
+	})
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	go func() {
+		r := bufio.NewReader(connSrv)
+		msg, err := srvReadAction(r)
+		if err != nil {
+			return
+		}
+		_, _ = connSrv.Write([]byte("Response: Error\r\nActionID: " + msg.ActionID() +
+			"\r\nMessage: No such channel\r\n\r\n"))
+		_, _ = srvReadAction(r)
+	}()
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "parent span")
+	resp, err := cl.SendAction(ctx, NewAction("Hangup"))
+	assert.Nil(t, err)
+	assert.False(t, resp.IsSuccess())
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		assert.Eventually(t, func() bool {
+			cl.mu.Lock()
+			defer cl.mu.Unlock()
+			return len(cl.pending) == 1
+		}, time.Second, time.Millisecond)
+		cancel()
+	}()
+	_, err = cl.SendAction(ctx, NewAction("Ping"))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	assert.Len(t, tracer.spans, 2)
+	hangup := tracer.spans[0]
+	assert.Equal(t, "parent span", hangup.parent)
+	assert.Equal(t, "Hangup", hangup.action)
+	assert.Equal(t, "Error", hangup.response)
+	assert.Equal(t, resp.ActionID(), hangup.actionID)
+	assert.Contains(t, hangup.actionID, "traced-")
+	assert.Nil(t, hangup.err)
+
+	ping := tracer.spans[1]
+	assert.Equal(t, "Ping", ping.action)
+	assert.ErrorIs(t, ping.err, context.Canceled)
+	assert.Empty(t, ping.response)
+}
@@ -0,0 +1,101 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UserEvent is the custom event sent with "Action: UserEvent" or raised by
+// dialplan UserEvent application, received as "Event: UserEvent".
+type UserEvent struct {
+	ChannelSnapshot                   // set when event is raised by dialplan
+	Name            string            // value of "UserEvent" header
+	Headers         map[string]string // custom headers, without AMI envelope
+	Event           *Message          // "Event: UserEvent" message
+}
+
+// userEventEnvelope are lower case names of the headers set by Asterisk,
+// not by the user event sender
+var userEventEnvelope = map[string]bool{
+	"event": true, "privilege": true, "timestamp": true, "systemname": true,
+	"userevent": true, "action": true, "actionid": true,
+}
+
+func init() {
+	for _, name := range []string{"Channel", "ChannelState", "ChannelStateDesc", "CallerIDNum",
+		"CallerIDName", "ConnectedLineNum", "ConnectedLineName", "Language", "AccountCode",
+		"Context", "Exten", "Priority", "Uniqueid", "Linkedid"} {
+		userEventEnvelope[strings.ToLower(name)] = true
+	}
+}
+
+// SendUserEvent sends "Action: UserEvent" that Asterisk broadcasts as
+// "Event: UserEvent" with "UserEvent: name" header and the custom headers
+// to the manager sessions with "user" read permissions, headers are sent in
+// order of names. Returns error without sending the action when name is empty
+// or a header breaks AMI framing or uses a name reserved for the envelope.
+func (c *Client) SendUserEvent(ctx context.Context, name string, headers map[string]string) error {
+	action, err := userEventAction(name, headers)
+	if err != nil {
+		return err
+	}
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "user event rejected")
+	}
+	return nil
+}
+
+// DecodeUserEvent decodes "Event: UserEvent" message and separates custom
+// headers from the headers added by Asterisk. Repeated custom headers keep
+// the last value. Returns error when message is not a user event.
+func DecodeUserEvent(msg *Message) (*UserEvent, error) {
+	if !strings.EqualFold(msg.Field("Event"), "UserEvent") {
+		return nil, fmt.Errorf("%w: not a user event: %q", ErrAMI, msg.Field("Event"))
+	}
+	ev := &UserEvent{
+		Name:    msg.Field("UserEvent"),
+		Headers: make(map[string]string),
+		Event:   msg,
+	}
+	if err := msg.Decode(&ev.ChannelSnapshot); err != nil {
+		return nil, err
+	}
+	for _, hdr := range msg.Headers() {
+		if !userEventEnvelope[strings.ToLower(hdr.Name)] {
+			ev.Headers[hdr.Name] = hdr.Value
+		}
+	}
+	return ev, nil
+}
+
+// userEventAction validates user event and creates its action
+func userEventAction(name string, headers map[string]string) (*Message, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: user event: missing name", ErrAMI)
+	}
+	action := NewAction("UserEvent")
+	if err := action.SetField("UserEvent", name); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(headers))
+	for key := range headers {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		if userEventEnvelope[strings.ToLower(key)] {
+			return nil, fmt.Errorf("%w: user event: reserved header %q", ErrAMI, key)
+		}
+		if err := validateField(key, headers[key]); err != nil {
+			return nil, err
+		}
+		action.AddField(key, headers[key])
+	}
+	return action, nil
+}
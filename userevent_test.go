@@ -0,0 +1,90 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserEventAction(t *testing.T) {
+	action, err := userEventAction("Ringing", map[string]string{"Queue": "sales", "Agent": "100"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Action: UserEvent\r\nUserEvent: Ringing\r\nAgent: 100\r\nQueue: sales\r\n\r\n",
+		action.String())
+
+	tests := map[string]struct {
+		name    string
+		headers map[string]string
+		err     string
+	}{
+		"missing name":    {"", nil, "missing name"},
+		"name line break": {"Ring\r\ning", nil, "line break"},
+		"reserved header": {"Ringing", map[string]string{"actionid": "1"}, `reserved header "actionid"`},
+		"invalid header":  {"Ringing", map[string]string{"Queue: x": "1"}, "invalid header name"},
+		"value break":     {"Ringing", map[string]string{"Queue": "a\nb"}, "line break"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			action, err := userEventAction(tc.name, tc.headers)
+			assert.ErrorIs(t, err, ErrAMI)
+			assert.ErrorContains(t, err, tc.err)
+			assert.Nil(t, action)
+		})
+	}
+}
+
+func TestDecodeUserEvent(t *testing.T) {
+	msg, err := Parse("Event: UserEvent\r\nPrivilege: user,all\r\nTimestamp: 1598887690.123\r\n" +
+		"Channel: PJSIP/100-01\r\nUniqueid: 1598887690.70\r\nPriority: 2\r\nUserEvent: Ringing\r\n" +
+		"ActionID: 7\r\nQueue: sales\r\nAgent: 100\r\n\r\n")
+	assert.Nil(t, err)
+
+	ev, err := DecodeUserEvent(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ringing", ev.Name)
+	assert.Equal(t, map[string]string{"Queue": "sales", "Agent": "100"}, ev.Headers)
+	assert.Equal(t, "PJSIP/100-01", ev.Channel)
+	assert.Equal(t, 2, ev.Priority)
+	assert.Same(t, msg, ev.Event)
+
+	msg, _ = Parse("Event: Hangup\r\nChannel: PJSIP/100-01\r\n\r\n")
+	_, err = DecodeUserEvent(msg)
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorContains(t, err, `not a user event: "Hangup"`)
+}
+
+func TestClientSendUserEvent(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	actions := make(chan *Message, 2)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		for _, resp := range []string{"Success", "Error\r\nMessage: Permission denied"} {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			_, _ = connSrv.Write([]byte("Response: " + resp + "\r\nActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	err := cl.SendUserEvent(context.Background(), "Ringing", map[string]string{"Queue": "sales"})
+	assert.Nil(t, err)
+	action := <-actions
+	assert.Equal(t, "Ringing", action.Field("UserEvent"))
+	assert.Equal(t, "sales", action.Field("Queue"))
+
+	err = cl.SendUserEvent(context.Background(), "Ringing", nil)
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorContains(t, err, "Permission denied")
+
+	err = cl.SendUserEvent(context.Background(), "", nil)
+	assert.ErrorContains(t, err, "missing name")
+}
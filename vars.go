@@ -0,0 +1,50 @@
+package goami2
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetVar returns value of the channel variable or dialplan function, like
+// "CALLERID(num)", with "Action: Getvar". Global variable is returned when
+// channel is empty. Value of not set variable is empty.
+func (c *Client) GetVar(ctx context.Context, channel, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("%w: getvar: missing variable", ErrAMI)
+	}
+	action := NewAction("Getvar")
+	if channel != "" {
+		action.AddField("Channel", channel)
+	}
+	action.AddField("Variable", name)
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return "", err
+	}
+	if !resp.IsSuccess() {
+		return "", rejected(resp, "getvar failed")
+	}
+	return resp.Field("Value"), nil
+}
+
+// SetVar sets channel variable or dialplan function with "Action: Setvar".
+// Global variable is set when channel is empty.
+func (c *Client) SetVar(ctx context.Context, channel, name, value string) error {
+	if name == "" {
+		return fmt.Errorf("%w: setvar: missing variable", ErrAMI)
+	}
+	action := NewAction("Setvar")
+	if channel != "" {
+		action.AddField("Channel", channel)
+	}
+	action.AddField("Variable", name)
+	action.AddField("Value", value)
+	resp, err := c.request(ctx, action)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return rejected(resp, "setvar failed")
+	}
+	return nil
+}
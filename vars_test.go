@@ -0,0 +1,71 @@
+package goami2
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientVars(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	cl := makeClient(connClient)
+	go cl.loop(context.Background())
+	defer cl.Close()
+
+	actions := make(chan *Message, 1)
+	go func() {
+		r := bufio.NewReader(connSrv)
+		vars := map[string]string{}
+		for {
+			msg, err := srvReadAction(r)
+			if err != nil {
+				return
+			}
+			actions <- msg
+			key := msg.Field("Channel") + "/" + msg.Field("Variable")
+			resp := "Response: Success\r\n"
+			switch {
+			case msg.Field("Channel") == "PJSIP/999-01":
+				resp = "Response: Error\r\nMessage: No such channel\r\n"
+			case msg.Field("Action") == "Setvar":
+				vars[key] = msg.Field("Value")
+				resp += "Message: Variable Set\r\n"
+			default:
+				resp += "Variable: " + msg.Field("Variable") + "\r\nValue: " + vars[key] + "\r\n"
+			}
+			_, _ = connSrv.Write([]byte(resp + "ActionID: " + msg.ActionID() + "\r\n\r\n"))
+		}
+	}()
+
+	ctx := context.Background()
+	assert.Nil(t, cl.SetVar(ctx, "PJSIP/100-01", "QUEUE", "sales"))
+	action := <-actions
+	assert.Equal(t, "Action: Setvar\r\nChannel: PJSIP/100-01\r\nVariable: QUEUE\r\nValue: sales\r\n"+
+		"ActionID: "+action.ActionID()+"\r\n\r\n", action.String())
+
+	value, err := cl.GetVar(ctx, "PJSIP/100-01", "QUEUE")
+	assert.Nil(t, err)
+	assert.Equal(t, "sales", value)
+	<-actions
+
+	value, err = cl.GetVar(ctx, "", "QUEUE")
+	assert.Nil(t, err)
+	assert.Equal(t, "", value)
+	assert.Equal(t, "", (<-actions).Field("Channel"))
+
+	err = cl.SetVar(ctx, "PJSIP/999-01", "QUEUE", "sales")
+	assert.ErrorContains(t, err, "setvar failed")
+	<-actions
+	_, err = cl.GetVar(ctx, "PJSIP/999-01", "QUEUE")
+	assert.ErrorIs(t, err, ErrAMI)
+	assert.ErrorIs(t, err, ErrNoSuchChannel)
+	assert.ErrorContains(t, err, "No such channel")
+	<-actions
+
+	_, err = cl.GetVar(ctx, "PJSIP/100-01", "")
+	assert.ErrorContains(t, err, "getvar: missing variable")
+	assert.ErrorContains(t, cl.SetVar(ctx, "PJSIP/100-01", "", ""), "setvar: missing variable")
+}
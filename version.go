@@ -0,0 +1,138 @@
+package goami2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Banner returns raw AMI prompt received on connect,
+// for example "Asterisk Call Manager/2.10.4"
+func (c *Client) Banner() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.banner
+}
+
+// AMIVersion is the AMI protocol version of the connect banner
+type AMIVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// events renamed in AMI 2.0 (Asterisk 12) by their AMI 1.x names
+var legacyEvents = map[string]string{
+	"queuecallerjoin":  "Join",
+	"queuecallerleave": "Leave",
+	"dialbegin":        "Dial",
+	"dialend":          "Dial",
+	"musiconholdstart": "MusicOnHold",
+	"musiconholdstop":  "MusicOnHold",
+}
+
+// String returns version like "2.10.4"
+func (v AMIVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// IsZero returns true for unknown version
+func (v AMIVersion) IsZero() bool {
+	return v == AMIVersion{}
+}
+
+// AtLeast returns true if version is equal or newer than major.minor.patch
+func (v AMIVersion) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+// Asterisk returns major version of Asterisk that uses this AMI version,
+// for example 13 for AMI 2.x, 16 for AMI 5.x and 20 for AMI 9.x.
+// Asterisk 1.8 and older are returned as 1. Returns zero for unknown
+// version.
+func (v AMIVersion) Asterisk() int {
+	switch {
+	case v.Major >= 5:
+		return v.Major + 11
+	case v.Major == 4:
+		return 15
+	case v.Major == 3:
+		return 14
+	case v.Major == 2 && v.Minor == 0:
+		return 12
+	case v.Major == 2:
+		return 13
+	case v.Major == 1 && v.Minor >= 3:
+		return 11
+	case v.Major == 1 && v.Minor == 2:
+		return 10
+	case v.Major == 1:
+		return 1
+	}
+	return 0
+}
+
+// EventName returns name of the event in this AMI version. Events renamed
+// in AMI 2.0, like "QueueCallerJoin" or "DialBegin", are returned with AMI
+// 1.x names, like "Join" or "Dial", so subscriptions work with old servers.
+// Other names are returned as is.
+func (v AMIVersion) EventName(name string) string {
+	if v.IsZero() || v.Major >= 2 {
+		return name
+	}
+	if legacy, ok := legacyEvents[strings.ToLower(name)]; ok {
+		return legacy
+	}
+	return name
+}
+
+// AMIVersion returns AMI protocol version from the connect banner. Both AMI
+// 1.x and newer banners are supported. Returns zero version if banner
+// version has unexpected format.
+func (c *Client) AMIVersion() AMIVersion {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// ManagerVersion returns AMI protocol version from the connect banner.
+// Returns zero version if banner version has unexpected format.
+func (c *Client) ManagerVersion() (major, minor, patch int) {
+	v := c.AMIVersion()
+	return v.Major, v.Minor, v.Patch
+}
+
+// ParseManagerVersion parses AMI banner like "Asterisk Call Manager/2.10.4"
+// into version numbers. Missing minor or patch numbers are zero.
+// Returns zero version and ErrUnknownVersion if banner format is unexpected.
+func ParseManagerVersion(banner string) (major, minor, patch int, err error) {
+	_, version, found := strings.Cut(strings.TrimSpace(banner), "/")
+	if !found || version == "" {
+		return 0, 0, 0, fmt.Errorf("%w: %q", ErrUnknownVersion, banner)
+	}
+
+	parts := strings.SplitN(version, ".", 3)
+	nums := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, 0, 0, fmt.Errorf("%w: %q", ErrUnknownVersion, banner)
+		}
+		nums[i] = n
+	}
+	return nums[0], nums[1], nums[2], nil
+}
+
+func (c *Client) setBanner(banner string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.banner = banner
+	major, minor, patch, _ := ParseManagerVersion(banner)
+	c.version = AMIVersion{Major: major, Minor: minor, Patch: patch}
+}
@@ -0,0 +1,102 @@
+package goami2
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseManagerVersion(t *testing.T) {
+	tests := map[string]struct {
+		banner string
+		want   [3]int
+		err    error
+	}{
+		`full version`:    {"Asterisk Call Manager/2.10.4", [3]int{2, 10, 4}, nil},
+		`with line end`:   {"Asterisk Call Manager/5.0.1\r\n", [3]int{5, 0, 1}, nil},
+		`major and minor`: {"Asterisk Call Manager/1.1", [3]int{1, 1, 0}, nil},
+		`no version`:      {"Asterisk Call Manager", [3]int{}, ErrUnknownVersion},
+		`empty version`:   {"Asterisk Call Manager/", [3]int{}, ErrUnknownVersion},
+		`invalid number`:  {"Asterisk Call Manager/2.x.4", [3]int{}, ErrUnknownVersion},
+		`extra suffix`:    {"Asterisk Call Manager/2.10.4-rc1", [3]int{}, ErrUnknownVersion},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			major, minor, patch, err := ParseManagerVersion(tc.banner)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, [3]int{major, minor, patch})
+		})
+	}
+}
+
+func TestClientManagerVersion(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	connSrvSess(connSrv,
+		[]string{"Response: Success\r\nMessage: Authentication accepted\r\n\r\n"})
+	cl, err := NewClient(connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	assert.Equal(t, "Asterisk Call Manager/2.10.4", cl.Banner())
+	major, minor, patch := cl.ManagerVersion()
+	assert.Equal(t, [3]int{2, 10, 4}, [3]int{major, minor, patch})
+}
+
+func TestAMIVersion(t *testing.T) {
+	v := AMIVersion{Major: 2, Minor: 10, Patch: 4}
+	assert.Equal(t, "2.10.4", v.String())
+	assert.False(t, v.IsZero())
+	assert.True(t, AMIVersion{}.IsZero())
+	assert.True(t, v.AtLeast(2, 10, 4))
+	assert.True(t, v.AtLeast(2, 9, 9))
+	assert.True(t, v.AtLeast(1, 20, 0))
+	assert.False(t, v.AtLeast(2, 10, 5))
+	assert.False(t, v.AtLeast(5, 0, 0))
+
+	tests := map[AMIVersion]int{
+		{}:         0,
+		{1, 1, 0}:  1,
+		{1, 2, 0}:  10,
+		{1, 3, 0}:  11,
+		{2, 0, 0}:  12,
+		{2, 10, 4}: 13,
+		{3, 2, 0}:  14,
+		{4, 0, 0}:  15,
+		{5, 0, 1}:  16,
+		{7, 0, 3}:  18,
+		{9, 0, 0}:  20,
+	}
+	for v, want := range tests {
+		assert.Equal(t, want, v.Asterisk(), v.String())
+	}
+
+	legacy := AMIVersion{Major: 1, Minor: 1}
+	assert.Equal(t, "Join", legacy.EventName("QueueCallerJoin"))
+	assert.Equal(t, "Dial", legacy.EventName("dialend"))
+	assert.Equal(t, "Hangup", legacy.EventName("Hangup"))
+	assert.Equal(t, "QueueCallerJoin", v.EventName("QueueCallerJoin"))
+	assert.Equal(t, "QueueCallerJoin", AMIVersion{}.EventName("QueueCallerJoin"))
+}
+
+func TestClientAMIVersionLegacy(t *testing.T) {
+	connClient, connSrv := net.Pipe()
+	go func() {
+		buf := make([]byte, 1024)
+		_, _ = connSrv.Write([]byte("Asterisk Call Manager/1.1\r\n"))
+		_, _ = connSrv.Read(buf)
+		_, _ = connSrv.Write([]byte("Response: Success\r\nMessage: Authentication accepted\r\n\r\n"))
+		_, _ = connSrv.Read(buf)
+		_, _ = connSrv.Write([]byte("Event: Join\r\nQueue: sales\r\nPosition: 1\r\n\r\n"))
+	}()
+	cl, err := NewClient(connClient, "admin", "pa55w0rd")
+	assert.Nil(t, err)
+	defer cl.Close()
+
+	assert.Equal(t, AMIVersion{Major: 1, Minor: 1}, cl.AMIVersion())
+	assert.Equal(t, 1, cl.AMIVersion().Asterisk())
+	ch := cl.Subscribe("QueueCallerJoin")
+	assert.True(t, cl.Action(NewAction("Ping")))
+	assert.Equal(t, "sales", (<-ch).Field("Queue"))
+}